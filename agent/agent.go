@@ -31,9 +31,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/hibernation"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
 	"github.com/aws/amazon-ssm-agent/agent/session/utility"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/status"
 )
 
 const (
@@ -51,6 +53,10 @@ var (
 	register, clear, force, fpFlag       bool
 	similarityThreshold                  int
 	registrationFile                     = filepath.Join(appconfig.DefaultDataStorePath, "registration")
+	// statusServer serves the local status API when it is enabled in appconfig
+	statusServer *status.Server
+	// metricsServer serves the metrics of the agent when they are enabled in appconfig
+	metricsServer *metrics.Server
 )
 
 func start(log logger.T, instanceIDPtr *string, regionPtr *string, shouldCheckHibernation bool) (ssmAgent agent.ISSMAgent, err error) {
@@ -71,6 +77,25 @@ func start(log logger.T, instanceIDPtr *string, regionPtr *string, shouldCheckHi
 	healthModule := health.NewHealthCheck(context, ssm.NewService())
 	hibernateState := hibernation.NewHibernateMode(healthModule, context)
 
+	// The status API is started before the health check so that it reports the agent while it hibernates
+	if config.Agent.StatusApiEnabled {
+		statusServer = status.NewServer(context, healthModule, hibernateState)
+		if err := statusServer.Start(); err != nil {
+			log.Errorf("Failed to start the status API: %v", err)
+			statusServer = nil
+		}
+	}
+
+	if config.Agent.MetricsEnabled {
+		metricsServer = metrics.NewServer(context)
+		if err := metricsServer.Start(); err != nil {
+			log.Errorf("Failed to start the metrics endpoint: %v", err)
+			metricsServer = nil
+		}
+	} else if err := metrics.DisableSpool(); err != nil {
+		log.Warnf("Failed to remove the metrics spool directory: %v", err)
+	}
+
 	ssmAgent = agent.NewSSMAgent(context, healthModule, hibernateState)
 	// Do a health check before starting the agent.
 	// Health check would include creating a health module and sending empty health pings to the service.
@@ -99,11 +124,24 @@ func startAgent(ssmAgent agent.ISSMAgent, context context.T, log logger.T, insta
 		return
 	}
 	ssmAgent.SetCoreManager(cpm)
+	if statusServer != nil {
+		statusServer.SetCoreManager(cpm)
+	}
 
 	ssmAgent.Start()
 	return
 }
 
+// stopLocalServers stops the status API and the metrics endpoint if they were started
+func stopLocalServers() {
+	if statusServer != nil {
+		statusServer.Stop()
+	}
+	if metricsServer != nil {
+		metricsServer.Stop()
+	}
+}
+
 func blockUntilSignaled(log logger.T) {
 	// Below channel will handle all machine initiated shutdown/reboot requests.
 
@@ -140,4 +178,5 @@ func run(log logger.T) {
 	}
 	blockUntilSignaled(log)
 	agent.Stop()
+	stopLocalServers()
 }
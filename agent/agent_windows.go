@@ -213,5 +213,6 @@ loop:
 	}
 	s <- svc.Status{State: svc.StopPending}
 	agent.Stop()
+	stopLocalServers()
 	return false, appconfig.SuccessExitCode
 }
@@ -97,8 +97,10 @@ func DefaultConfig() SsmagentConfig {
 		CommandRetryLimit:   DefaultCommandRetryLimit,
 	}
 	var mgs = MgsConfig{
-		SessionWorkersLimit: DefaultSessionWorkersLimit,
-		StopTimeoutMillis:   DefaultStopTimeoutMillis,
+		SessionWorkersLimit:       DefaultSessionWorkersLimit,
+		StopTimeoutMillis:         DefaultStopTimeoutMillis,
+		IdleSessionTimeoutMinutes: DefaultIdleSessionTimeoutMinutes,
+		MaxSessionDurationMinutes: DefaultMaxSessionDurationMinutes,
 	}
 	var ssm = SsmCfg{
 		HealthFrequencyMinutes:                DefaultSsmHealthFrequencyMinutes,
@@ -108,10 +110,14 @@ func DefaultConfig() SsmagentConfig {
 		AssociationLogsRetentionDurationHours: DefaultAssociationLogsRetentionDurationHours,
 		RunCommandLogsRetentionDurationHours:  DefaultRunCommandLogsRetentionDurationHours,
 		SessionLogsRetentionDurationHours:     DefaultSessionLogsRetentionDurationHours,
+		InventoryGathererTimeoutMinutes:       DefaultSsmInventoryGathererTimeoutMinutes,
 	}
 	var agent = AgentInfo{
 		Name:                 "amazon-ssm-agent",
 		OrchestrationRootDir: defaultOrchestrationRootDirName,
+		IPCChannelType:       DefaultIPCChannelType,
+		StatusSocketPath:     DefaultStatusSocketPath,
+		MetricsListenAddress: DefaultMetricsListenAddress,
 	}
 	var os = OsInfo{
 		Lang:    "en-US",
@@ -119,6 +125,9 @@ func DefaultConfig() SsmagentConfig {
 	}
 	var birdwatcher BirdwatcherCfg
 	var kms KmsConfig
+	var runScript = RunScriptCfg{
+		TerminationGracePeriodSeconds: DefaultTerminationGracePeriodSeconds,
+	}
 
 	var ssmagentCfg = SsmagentConfig{
 		Profile:     credsProfile,
@@ -130,6 +139,7 @@ func DefaultConfig() SsmagentConfig {
 		S3:          s3,
 		Birdwatcher: birdwatcher,
 		Kms:         kms,
+		RunScript:   runScript,
 	}
 
 	return ssmagentCfg
@@ -17,7 +17,9 @@ package appconfig
 
 import (
 	"log"
+	"net"
 	"strings"
+	"time"
 )
 
 //func parser(config *T) {
@@ -28,6 +30,11 @@ func parser(config *SsmagentConfig) {
 	config.Agent.Name = getStringValue(config.Agent.Name, DefaultAgentName)
 	config.Agent.OrchestrationRootDir = getStringValue(config.Agent.OrchestrationRootDir, defaultOrchestrationRootDirName)
 	config.Agent.Region = getStringValue(config.Agent.Region, "")
+	if config.Agent.IPCChannelType != IPCChannelTypeFile && config.Agent.IPCChannelType != IPCChannelTypeSocket {
+		config.Agent.IPCChannelType = DefaultIPCChannelType
+	}
+	config.Agent.StatusSocketPath = getStringValue(config.Agent.StatusSocketPath, DefaultStatusSocketPath)
+	config.Agent.MetricsListenAddress = getLoopbackAddressValue(config.Agent.MetricsListenAddress, DefaultMetricsListenAddress)
 
 	// MDS config
 	config.Mds.CommandWorkersLimit = getNumericValue(
@@ -67,7 +74,44 @@ func parser(config *SsmagentConfig) {
 		config.Ssm.RunCommandLogsRetentionDurationHours,
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
 		DefaultRunCommandLogsRetentionDurationHours)
+	config.Ssm.AssociationScheduleTimezone = getTimezoneValue(config.Ssm.AssociationScheduleTimezone, "")
+	config.Ssm.AssociationSplaySeconds = getNumericValue(
+		config.Ssm.AssociationSplaySeconds,
+		DefaultSsmAssociationSplaySecondsMin,
+		DefaultSsmAssociationSplaySecondsMax,
+		DefaultSsmAssociationSplaySeconds)
+	if config.Ssm.AssociationCatchUpPolicy != AssociationCatchUpRunOnce && config.Ssm.AssociationCatchUpPolicy != AssociationCatchUpSkip {
+		config.Ssm.AssociationCatchUpPolicy = DefaultSsmAssociationCatchUpPolicy
+	}
+	config.Ssm.InventoryGathererTimeoutMinutes = getNumericValue(
+		config.Ssm.InventoryGathererTimeoutMinutes,
+		DefaultSsmInventoryGathererTimeoutMinutesMin,
+		DefaultSsmInventoryGathererTimeoutMinutesMax,
+		DefaultSsmInventoryGathererTimeoutMinutes)
+
+	// MGS config
+	config.Mgs.IdleSessionTimeoutMinutes = getNumericValue(
+		config.Mgs.IdleSessionTimeoutMinutes,
+		DefaultIdleSessionTimeoutMinutesMin,
+		DefaultIdleSessionTimeoutMinutesMax,
+		DefaultIdleSessionTimeoutMinutes)
+	config.Mgs.MaxSessionDurationMinutes = getNumericValue(
+		config.Mgs.MaxSessionDurationMinutes,
+		DefaultMaxSessionDurationMinutesMin,
+		DefaultMaxSessionDurationMinutesMax,
+		DefaultMaxSessionDurationMinutes)
 
+	// RunScript config
+	config.RunScript.TerminationGracePeriodSeconds = getNumericValue(
+		config.RunScript.TerminationGracePeriodSeconds,
+		DefaultTerminationGracePeriodSecondsMin,
+		DefaultTerminationGracePeriodSecondsMax,
+		DefaultTerminationGracePeriodSeconds)
+	limits := &config.RunScript.ResourceLimits
+	limits.CPUQuotaPercent = getNumericValue(limits.CPUQuotaPercent, 0, ResourceLimitCPUQuotaPercentMax, 0)
+	limits.MemoryLimitMB = getNumericValue(limits.MemoryLimitMB, 0, ResourceLimitMemoryLimitMBMax, 0)
+	limits.MaxProcesses = getNumericValue(limits.MaxProcesses, 0, ResourceLimitMaxProcessesMax, 0)
+	limits.IOWeight = getNumericValue(limits.IOWeight, 0, ResourceLimitIOWeightMax, 0)
 }
 
 // getDefaultEndPoint returns the default endpoint for a service, it should be empty unless it's a china region
@@ -113,3 +157,31 @@ func getNumeric64Value(configValue int64, minValue int64, maxValue int64, defaul
 	}
 	return configValue
 }
+
+// getLoopbackAddressValue returns the default if config value isn't a host:port address on the loopback interface
+func getLoopbackAddressValue(configValue string, defaultValue string) string {
+	host, port, err := net.SplitHostPort(configValue)
+	if err != nil || port == "" {
+		return defaultValue
+	}
+	if strings.EqualFold(host, "localhost") {
+		return configValue
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return configValue
+	}
+	log.Printf("%v is not a loopback address, using %v", configValue, defaultValue)
+	return defaultValue
+}
+
+// getTimezoneValue returns the default if config value isn't the name of a time zone known to the system
+func getTimezoneValue(configValue string, defaultValue string) string {
+	if configValue == "" {
+		return defaultValue
+	}
+	if _, err := time.LoadLocation(configValue); err != nil {
+		log.Printf("%v is not a valid time zone, using %v: %v", configValue, defaultValue, err)
+		return defaultValue
+	}
+	return configValue
+}
@@ -41,6 +41,38 @@ func TestGetStringValue(t *testing.T) {
 	}
 }
 
+// getLoopbackAddressValue Tests
+
+func TestGetLoopbackAddressValue(t *testing.T) {
+	tests := []GetStringValueTest{
+		{"", "127.0.0.1:9779", "127.0.0.1:9779"},
+		{"127.0.0.1:9100", "127.0.0.1:9779", "127.0.0.1:9100"},
+		{"[::1]:9100", "127.0.0.1:9779", "[::1]:9100"},
+		{"localhost:9100", "127.0.0.1:9779", "localhost:9100"},
+		{"0.0.0.0:9100", "127.0.0.1:9779", "127.0.0.1:9779"},
+		{":9100", "127.0.0.1:9779", "127.0.0.1:9779"},
+		{"10.0.0.5:9100", "127.0.0.1:9779", "127.0.0.1:9779"},
+		{"127.0.0.1", "127.0.0.1:9779", "127.0.0.1:9779"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Output, getLoopbackAddressValue(test.Input, test.DefaultValue), test.Input)
+	}
+}
+
+// getTimezoneValue Tests
+
+func TestGetTimezoneValue(t *testing.T) {
+	tests := []GetStringValueTest{
+		{"", "", ""},
+		{"UTC", "", "UTC"},
+		{"America/New_York", "", "America/New_York"},
+		{"Mars/Olympus_Mons", "", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Output, getTimezoneValue(test.Input, test.DefaultValue), test.Input)
+	}
+}
+
 //GetDefaultEndpointTests
 
 type GetDefaultEndPointTest struct {
@@ -20,6 +20,14 @@ const (
 	// Agent defaults
 	DefaultAgentName = "amazon-ssm-agent"
 
+	// IPC channel types used between the agent and the out-of-process document workers
+	IPCChannelTypeFile    = "file"
+	IPCChannelTypeSocket  = "socket"
+	DefaultIPCChannelType = IPCChannelTypeFile
+
+	// DefaultMetricsListenAddress is the loopback address on which the agent serves its metrics when enabled
+	DefaultMetricsListenAddress = "127.0.0.1:9779"
+
 	DefaultCommandWorkersLimit    = 5
 	DefaultCommandWorkersLimitMin = 1
 
@@ -40,6 +48,22 @@ const (
 	DefaultSsmAssociationFrequencyMinutesMin = 5
 	DefaultSsmAssociationFrequencyMinutesMax = 60
 
+	// Splay of the scheduled associations, 0 runs every instance at the scheduled time
+	DefaultSsmAssociationSplaySeconds    = 0
+	DefaultSsmAssociationSplaySecondsMin = 0
+	DefaultSsmAssociationSplaySecondsMax = 86400
+
+	// Catch-up policies of the association runs missed while the agent was down,
+	// RunOnce runs each association which missed runs once, Skip waits for the next scheduled run
+	AssociationCatchUpRunOnce          = "RunOnce"
+	AssociationCatchUpSkip             = "Skip"
+	DefaultSsmAssociationCatchUpPolicy = AssociationCatchUpRunOnce
+
+	// Time an inventory gatherer can run before it is requested to stop
+	DefaultSsmInventoryGathererTimeoutMinutes    = 30
+	DefaultSsmInventoryGathererTimeoutMinutesMin = 1
+	DefaultSsmInventoryGathererTimeoutMinutesMax = 1440
+
 	//aws-ssm-agent bookkeeping constants
 	DefaultLocationOfPending     = "pending"
 	DefaultLocationOfCurrent     = "current"
@@ -78,6 +102,9 @@ const (
 	// DefaultDocumentRootDirName is the root directory for storing command states
 	DefaultDocumentRootDirName = "document"
 
+	// MetricsRootDirName is the directory where the document and session workers spool their metrics for the agent
+	MetricsRootDirName = "metrics"
+
 	// DefaultSessionRootDirName is the root directory for storing session manager data
 	DefaultSessionRootDirName = "session"
 
@@ -167,11 +194,39 @@ const (
 	DefaultSessionWorkersLimit    = 1000
 	DefaultSessionWorkersLimitMin = 1
 
+	// Session timeout defaults, 0 disables a timeout, sessions can set their own timeouts within the bounds
+	DefaultIdleSessionTimeoutMinutes    = 0
+	DefaultIdleSessionTimeoutMinutesMin = 0
+	DefaultIdleSessionTimeoutMinutesMax = 60
+	DefaultMaxSessionDurationMinutes    = 0
+	DefaultMaxSessionDurationMinutesMin = 0
+	DefaultMaxSessionDurationMinutesMax = 1440
+
+	// Termination grace period defaults, cancelled or timed out commands are killed when they don't exit within the grace period
+	DefaultTerminationGracePeriodSeconds    = 10
+	DefaultTerminationGracePeriodSecondsMin = 0
+	DefaultTerminationGracePeriodSecondsMax = 600
+
+	// Resource limits bounds, limits outside of the bounds are ignored
+	ResourceLimitCPUQuotaPercentMax = 100000
+	ResourceLimitMemoryLimitMBMax   = 1048576
+	ResourceLimitMaxProcessesMax    = 4194304
+	ResourceLimitIOWeightMax        = 10000
+
 	// PluginNameStandardStream is the name for session manager standard stream plugin aka shell.
 	PluginNameStandardStream = "Standard_Stream"
 
+	// PluginNamePort is the name for session manager port plugin.
+	PluginNamePort = "Port"
+
+	// PluginNameNonInteractiveCommands is the name for session manager plugin that runs a single command without a pty.
+	PluginNameNonInteractiveCommands = "NonInteractiveCommands"
+
 	// Session default RunAs user name
 	DefaultRunAsUserName = "ssm-user"
+
+	// SessionRunAsPolicyFileName is the name of the policy file, in the program folder, which restricts the users sessions and script steps can run as
+	SessionRunAsPolicyFileName = "session-runas-policy.json"
 )
 
 // Document versions that are supported by this Agent version.
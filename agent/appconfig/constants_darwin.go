@@ -47,12 +47,24 @@ const (
 	// are moved if the service cannot validate the document (generally impossible via cli)
 	LocalCommandRootInvalid = DefaultProgramFolder + "localcommands/invalid"
 
+	// LocalAssociationRoot specifies the directory where users can define associations for disconnected hosts
+	LocalAssociationRoot = DefaultProgramFolder + "localassociations"
+
+	// LocalAssociationRootHistory is the directory where the execution history of local associations is written
+	LocalAssociationRootHistory = DefaultProgramFolder + "localassociations/history"
+
+	// LocalAssociationRootCompliance is the directory where the compliance of local associations is written
+	LocalAssociationRootCompliance = DefaultProgramFolder + "localassociations/compliance"
+
 	// DownloadRoot specifies the directory under which files will be downloaded
 	DownloadRoot = DefaultProgramFolder + "download/"
 
 	// DefaultDataStorePath represents the directory for storing system data
 	DefaultDataStorePath = DefaultProgramFolder + "data/"
 
+	// DefaultStatusSocketPath is the socket on which the agent serves its local status API
+	DefaultStatusSocketPath = DefaultDataStorePath + "status.sock"
+
 	// EC2ConfigDataStorePath represents the directory for storing ec2 config data
 	EC2ConfigDataStorePath = "/var/lib/amazon/ec2config/"
 
@@ -49,12 +49,24 @@ const (
 	// are moved if the service cannot validate the document (generally impossible via cli)
 	LocalCommandRootInvalid = "/var/lib/amazon/ssm/localcommands/invalid"
 
+	// LocalAssociationRoot specifies the directory where users can define associations for disconnected hosts
+	LocalAssociationRoot = "/var/lib/amazon/ssm/localassociations"
+
+	// LocalAssociationRootHistory is the directory where the execution history of local associations is written
+	LocalAssociationRootHistory = "/var/lib/amazon/ssm/localassociations/history"
+
+	// LocalAssociationRootCompliance is the directory where the compliance of local associations is written
+	LocalAssociationRootCompliance = "/var/lib/amazon/ssm/localassociations/compliance"
+
 	// DownloadRoot specifies the directory under which files will be downloaded
 	DownloadRoot = "/var/log/amazon/ssm/download/"
 
 	// DefaultDataStorePath represents the directory for storing system data
 	DefaultDataStorePath = "/var/lib/amazon/ssm/"
 
+	// DefaultStatusSocketPath is the socket on which the agent serves its local status API
+	DefaultStatusSocketPath = DefaultDataStorePath + "status.sock"
+
 	// EC2ConfigDataStorePath represents the directory for storing ec2 config data
 	EC2ConfigDataStorePath = "/var/lib/amazon/ec2config/"
 
@@ -104,6 +104,15 @@ var LocalCommandRootCompleted string
 // are moved if the service cannot validate the document (generally impossible via cli)
 var LocalCommandRootInvalid string
 
+// LocalAssociationRoot specifies the directory where users can define associations for disconnected hosts
+var LocalAssociationRoot string
+
+// LocalAssociationRootHistory is the directory where the execution history of local associations is written
+var LocalAssociationRootHistory string
+
+// LocalAssociationRootCompliance is the directory where the compliance of local associations is written
+var LocalAssociationRootCompliance string
+
 // DefaultPluginPath represents the directory for storing plugins in SSM
 var DefaultPluginPath string
 
@@ -134,6 +143,9 @@ var UpdateContextFilePath string
 // SSMData specifies the directory we used to store SSM data.
 var SSMDataPath string
 
+// DefaultStatusSocketPath is the socket on which the agent serves its local status API
+var DefaultStatusSocketPath string
+
 // Windows environment variable %ProgramFiles%
 var EnvProgramFiles string
 
@@ -172,6 +184,7 @@ func init() {
 	ManifestCacheDirectory = filepath.Join(EnvProgramFiles, ManifestCacheFolder)
 	AppConfigPath = filepath.Join(DefaultProgramFolder, AppConfigFileName)
 	DefaultDataStorePath = filepath.Join(SSMDataPath, "InstanceData")
+	DefaultStatusSocketPath = filepath.Join(SSMDataPath, "status.sock")
 	PackageRoot = filepath.Join(SSMDataPath, "Packages")
 	PackageLockRoot = filepath.Join(SSMDataPath, "Locks\\Packages")
 	DaemonRoot = filepath.Join(SSMDataPath, "Daemons")
@@ -179,6 +192,9 @@ func init() {
 	LocalCommandRootSubmitted = filepath.Join(LocalCommandRoot, "Submitted")
 	LocalCommandRootCompleted = filepath.Join(LocalCommandRoot, "Completed")
 	LocalCommandRootInvalid = filepath.Join(LocalCommandRoot, "Invalid")
+	LocalAssociationRoot = filepath.Join(SSMDataPath, "LocalAssociations")
+	LocalAssociationRootHistory = filepath.Join(LocalAssociationRoot, "History")
+	LocalAssociationRootCompliance = filepath.Join(LocalAssociationRoot, "Compliance")
 	DownloadRoot = filepath.Join(temp, SSMFolder, "Download")
 	UpdaterArtifactsRoot = filepath.Join(temp, SSMFolder, "Update")
 	EC2UpdateArtifactsRoot = filepath.Join(EnvWinDir, EC2ConfigServiceFolder, "Update")
@@ -41,6 +41,16 @@ type SsmCfg struct {
 	AssociationLogsRetentionDurationHours int
 	RunCommandLogsRetentionDurationHours  int
 	SessionLogsRetentionDurationHours     int
+	// AssociationScheduleTimezone is the IANA time zone in which the cron and at expressions of associations are evaluated, UTC when empty
+	AssociationScheduleTimezone string
+	// AssociationSplaySeconds is the window within which the instance delays the scheduled runs of each association
+	AssociationSplaySeconds int
+	// AssociationCatchUpPolicy decides whether the associations which missed runs while the agent was down run once when it starts
+	AssociationCatchUpPolicy string
+	// LocalAssociationsEnabled reads the associations from the local association directory instead of the service
+	LocalAssociationsEnabled bool
+	// InventoryGathererTimeoutMinutes is the time an inventory gatherer can run before it's requested to stop
+	InventoryGathererTimeoutMinutes int
 }
 
 // AgentInfo represents metadata for amazon-ssm-agent
@@ -50,6 +60,13 @@ type AgentInfo struct {
 	Region               string
 	OrchestrationRootDir string
 	DownloadRootDir      string
+	IPCChannelType       string
+	// StatusApiEnabled serves the read-only status of the agent on a local socket
+	StatusApiEnabled bool
+	StatusSocketPath string
+	// MetricsEnabled serves the metrics of the agent in Prometheus text format on a loopback address
+	MetricsEnabled       bool
+	MetricsListenAddress string
 }
 
 // MgsConfig represents configuration for Message Gateway service
@@ -58,6 +75,10 @@ type MgsConfig struct {
 	Endpoint            string
 	StopTimeoutMillis   int64
 	SessionWorkersLimit int
+	// IdleSessionTimeoutMinutes ends sessions which exchange no data with the client for this duration, 0 doesn't end idle sessions
+	IdleSessionTimeoutMinutes int
+	// MaxSessionDurationMinutes ends sessions running for this duration, 0 doesn't limit the duration of sessions
+	MaxSessionDurationMinutes int
 }
 
 // KmsConfig represents configuration for Key Management Service
@@ -85,6 +106,82 @@ type BirdwatcherCfg struct {
 	ForceEnable bool
 }
 
+// BlackoutWindow represents a recurring period during which the agent defers scheduled work.
+// A window either starts on a cron expression and lasts DurationMinutes,
+// or lasts from StartTime to EndTime (hh:mm) on the given Weekdays, every day when no weekday is given.
+type BlackoutWindow struct {
+	Name            string
+	Cron            string
+	DurationMinutes int
+	Weekdays        []string
+	StartTime       string
+	EndTime         string
+	// Timezone is the IANA time zone in which the window is evaluated, UTC when empty
+	Timezone string
+}
+
+// BlackoutCfg represents the blackout windows of the agent
+type BlackoutCfg struct {
+	Windows []BlackoutWindow
+	// ExemptAssociations lists the ids of the associations which run during blackout windows
+	ExemptAssociations []string
+	// ApplyToRunCommand fails the commands which are received during a blackout window
+	ApplyToRunCommand bool
+}
+
+// RunScriptCfg represents the configuration of the script plugins
+type RunScriptCfg struct {
+	// TerminationGracePeriodSeconds is how long cancelled or timed out scripts have to exit before they are killed,
+	// steps can override it
+	TerminationGracePeriodSeconds int
+	// ResourceLimits are the default limits of the processes run by script steps, steps can override them
+	ResourceLimits ResourceLimitsCfg
+}
+
+// ResourceLimitsCfg represents the limits of the resources a process can use, zero values are unlimited
+type ResourceLimitsCfg struct {
+	// CPUQuotaPercent is the share of one cpu the processes can use, for e.g. 150 for one and a half cpus
+	CPUQuotaPercent int
+	// MemoryLimitMB is the memory the processes can use before they are killed
+	MemoryLimitMB int
+	// MaxProcesses is the number of processes and threads which can run at the same time
+	MaxProcesses int
+	// IOWeight is the relative share of block I/O between 1 and 10000, the default weight is 100
+	IOWeight int
+}
+
+// IsUnlimited returns true when none of the resources is limited
+func (limits ResourceLimitsCfg) IsUnlimited() bool {
+	return limits == ResourceLimitsCfg{}
+}
+
+// OutputSinkCfg enables an additional destination of the output of the commands
+type OutputSinkCfg struct {
+	// Type is the name the sink is registered with, syslog, journald, archive and webhook are built in
+	Type string
+	// Properties are the settings of the sink, they depend on its type
+	Properties map[string]string
+}
+
+// outputSinkSecretProperties are the properties of the sinks which hold credentials
+var outputSinkSecretProperties = []string{"Authorization"}
+
+// WithoutSecrets returns a copy of the sink without the properties which hold credentials,
+// the copy is persisted with the commands instead of the sink
+func (sink OutputSinkCfg) WithoutSecrets() OutputSinkCfg {
+	if sink.Properties == nil {
+		return sink
+	}
+	properties := make(map[string]string, len(sink.Properties))
+	for name, value := range sink.Properties {
+		properties[name] = value
+	}
+	for _, name := range outputSinkSecretProperties {
+		delete(properties, name)
+	}
+	return OutputSinkCfg{Type: sink.Type, Properties: properties}
+}
+
 // SsmagentConfig stores agent configuration values.
 type SsmagentConfig struct {
 	Profile     CredentialProfile
@@ -96,6 +193,9 @@ type SsmagentConfig struct {
 	S3          S3Cfg
 	Birdwatcher BirdwatcherCfg
 	Kms         KmsConfig
+	Blackout    BlackoutCfg
+	RunScript   RunScriptCfg
+	OutputSinks []OutputSinkCfg
 }
 
 // AppConstants represents some run time constant variable for various module.
@@ -0,0 +1,54 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package atexpr implements one-shot schedule expressions, e.g. at(2019-06-01T12:00:00)
+package atexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// atTimeLayout is the layout of the time of an at expression, the time zone is given separately
+const atTimeLayout = "2006-01-02T15:04:05"
+
+var atRegularExpression = regexp.MustCompile("(?i)^at\\((\\d{4}-\\d{2}-\\d{2}t\\d{2}:\\d{2}:\\d{2})\\)$")
+
+// AtExpression is a schedule expression which fires once
+type AtExpression struct {
+	at time.Time
+}
+
+// Parse parses an at expression whose time is in the given location
+func Parse(atLine string, location *time.Location) (*AtExpression, error) {
+	match := atRegularExpression.FindStringSubmatch(atLine)
+	if match == nil {
+		return nil, fmt.Errorf("Schedule expression is not a valid at expression. Expected at(yyyy-mm-ddThh:mm:ss).")
+	}
+
+	at, err := time.ParseInLocation(atTimeLayout, strings.ToUpper(match[1]), location)
+	if err != nil {
+		return nil, fmt.Errorf("Schedule expression is not a valid at expression. %v", err)
+	}
+	return &AtExpression{at: at}, nil
+}
+
+// Next returns the time of the expression if it is after fromTime, and the zero time once the expression has fired
+func (expr *AtExpression) Next(fromTime time.Time) time.Time {
+	if fromTime.Before(expr.at) {
+		return expr.at
+	}
+	return time.Time{}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package atexpr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		expected   time.Time
+		valid      bool
+	}{
+		{"utc", "at(2019-06-01T12:00:00)", time.UTC, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"upper case", "AT(2019-06-01T12:00:00)", time.UTC, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"lower case separator", "at(2019-06-01t12:00:00)", time.UTC, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"time zone", "at(2019-06-01T12:00:00)", newYork, time.Date(2019, 6, 1, 16, 0, 0, 0, time.UTC), true},
+		{"time only", "at(12:00)", time.UTC, time.Time{}, false},
+		{"no seconds", "at(2019-06-01T12:00)", time.UTC, time.Time{}, false},
+		{"extra characters", "at(2019-06-01T12:00:00)abc", time.UTC, time.Time{}, false},
+		{"invalid date", "at(2019-02-30T12:00:00)", time.UTC, time.Time{}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			atExpression, err := Parse(testCase.expression, testCase.location)
+			if !testCase.valid {
+				assert.Error(t, err)
+				assert.Nil(t, atExpression)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, testCase.expected.Equal(atExpression.at), "expected %v, got %v", testCase.expected, atExpression.at)
+		})
+	}
+}
+
+func TestNextFiresOnce(t *testing.T) {
+	atExpression, err := Parse("at(2019-06-01T12:00:00)", time.UTC)
+	assert.NoError(t, err)
+	at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		fromTime time.Time
+		expected time.Time
+	}{
+		{"never run", time.Time{}, at},
+		{"before", at.Add(-time.Hour), at},
+		{"at", at, time.Time{}},
+		{"after", at.Add(time.Second), time.Time{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, atExpression.Next(testCase.fromTime))
+		})
+	}
+}
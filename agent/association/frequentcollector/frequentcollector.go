@@ -152,6 +152,10 @@ func (collector *FrequentCollector) GetIntervalInSeconds(changeDetectionFrequenc
 		//get the rate in seconds
 		currentTime := time.Now()
 		nextTime := parsedExpression.Next(currentTime)
+		if nextTime.IsZero() {
+			// one-shot expressions which have fired don't have an interval
+			return false, 0
+		}
 		assocInterval := nextTime.Sub(currentTime).Seconds()
 
 		//calculate the interval in seconds
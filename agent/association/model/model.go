@@ -16,8 +16,11 @@ package model
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -26,6 +29,17 @@ import (
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
+// IgnoreBlackoutWindowsParameter is the association parameter which runs the association during the blackout windows
+// of the agent when it is true, the document of the association declares it as a String parameter
+const IgnoreBlackoutWindowsParameter = "ignoreBlackoutWindows"
+
+// ScheduleTimezoneParameter and ScheduleSplaySecondsParameter are the association parameters which set the time zone
+// and the splay of the schedule instead of the options of the agent, the document of the association declares them as String parameters
+const (
+	ScheduleTimezoneParameter     = "scheduleTimezone"
+	ScheduleSplaySecondsParameter = "scheduleSplaySeconds"
+)
+
 // InstanceAssociation represents detail information of an association
 type InstanceAssociation struct {
 	DocumentID        string
@@ -35,12 +49,84 @@ type InstanceAssociation struct {
 	ParsedExpression  scheduleexpression.ScheduleExpression
 	Document          *string
 	Errors            []error
+	// ScheduleTimezone is the IANA time zone in which the cron and at expressions are evaluated, UTC when empty
+	ScheduleTimezone string
+	// ScheduleSplay is the window within which the instance delays the scheduled runs of the association
+	ScheduleSplay time.Duration
+	// IgnoreBlackoutWindows runs the association during the blackout windows of the agent
+	IgnoreBlackoutWindows bool
+	// SkippedMissedRunsUntil is the time before which the runs missed by the association were skipped by the catch-up policy
+	SkippedMissedRunsUntil *time.Time
+}
+
+// ParseBlackoutOptions lets the association run during the blackout windows of the agent when its parameters request it,
+// the associations defined on the instance set IgnoreBlackoutWindows themselves
+func (assoc *InstanceAssociation) ParseBlackoutOptions(log log.T) {
+	value, found := assoc.parameter(IgnoreBlackoutWindowsParameter)
+	if !found {
+		return
+	}
+	ignore, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warnf("Invalid %v parameter %q of association %v, the blackout windows apply to it",
+			IgnoreBlackoutWindowsParameter, value, aws.StringValue(assoc.Association.AssociationId))
+		return
+	}
+	assoc.IgnoreBlackoutWindows = assoc.IgnoreBlackoutWindows || ignore
+}
+
+// ParseScheduleOptions sets the time zone and the splay of the schedule from the parameters of the association, the
+// associations defined on the instance set them themselves. The given options of the agent apply when neither does.
+func (assoc *InstanceAssociation) ParseScheduleOptions(log log.T, defaultTimezone string, defaultSplay time.Duration) {
+	timezoneSet, splaySet := assoc.ScheduleTimezone != "", assoc.ScheduleSplay != 0
+	associationID := aws.StringValue(assoc.Association.AssociationId)
+
+	if value, found := assoc.parameter(ScheduleTimezoneParameter); found {
+		if _, err := time.LoadLocation(value); err != nil {
+			log.Warnf("Invalid %v parameter %q of association %v, %v", ScheduleTimezoneParameter, value, associationID, err)
+		} else {
+			assoc.ScheduleTimezone, timezoneSet = value, true
+		}
+	}
+	if value, found := assoc.parameter(ScheduleSplaySecondsParameter); found {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < appconfig.DefaultSsmAssociationSplaySecondsMin || seconds > appconfig.DefaultSsmAssociationSplaySecondsMax {
+			log.Warnf("Invalid %v parameter %q of association %v, it must be between %v and %v seconds",
+				ScheduleSplaySecondsParameter, value, associationID,
+				appconfig.DefaultSsmAssociationSplaySecondsMin, appconfig.DefaultSsmAssociationSplaySecondsMax)
+		} else {
+			assoc.ScheduleSplay, splaySet = time.Duration(seconds)*time.Second, true
+		}
+	}
+
+	if !timezoneSet {
+		assoc.ScheduleTimezone = defaultTimezone
+	}
+	if !splaySet {
+		assoc.ScheduleSplay = defaultSplay
+	}
+}
+
+// parameter returns the trimmed value of the association parameter with the given name, an empty value counts as not set
+func (assoc *InstanceAssociation) parameter(name string) (string, bool) {
+	values := assoc.Association.Parameters[name]
+	if len(values) == 0 || values[0] == nil {
+		return "", false
+	}
+	value := strings.TrimSpace(*values[0])
+	return value, value != ""
 }
 
 // ParseExpression parses the expression with the given association
 func (newAssoc *InstanceAssociation) ParseExpression(log log.T) error {
 
-	parsedScheduleExpression, err := scheduleexpression.CreateScheduleExpression(log, *newAssoc.Association.ScheduleExpression)
+	options := scheduleexpression.Options{
+		Timezone: newAssoc.ScheduleTimezone,
+		Splay:    newAssoc.ScheduleSplay,
+		// every instance delays the association by a different offset, which is the same after a restart
+		SplayKey: aws.StringValue(newAssoc.Association.InstanceId) + "/" + aws.StringValue(newAssoc.Association.AssociationId),
+	}
+	parsedScheduleExpression, err := scheduleexpression.CreateScheduleExpressionWithOptions(log, *newAssoc.Association.ScheduleExpression, options)
 
 	if err != nil {
 		return fmt.Errorf("Failed to parse schedule expression %v, %v", *newAssoc.Association.ScheduleExpression, err)
@@ -81,8 +167,9 @@ func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
 		return
 	}
 
-	// Run association immediately if association has not been run before
-	if newAssoc.Association.LastExecutionDate == nil {
+	// Run association immediately if association has not been run before, unless it runs once at a given time
+	isOneShot := scheduleexpression.IsOneShotExpression(*newAssoc.Association.ScheduleExpression)
+	if newAssoc.Association.LastExecutionDate == nil && !isOneShot {
 		newAssoc.RunNow()
 		return
 	}
@@ -99,9 +186,45 @@ func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
 	}
 
 	// Set next schedule date of association according to it's schedule
-	newAssoc.NextScheduledDate = aws.Time(
-		newAssoc.ParsedExpression.Next(newAssoc.Association.LastExecutionDate.UTC()).UTC())
+	var lastExecutionDate time.Time
+	if newAssoc.Association.LastExecutionDate != nil {
+		lastExecutionDate = newAssoc.Association.LastExecutionDate.UTC()
+	}
+	// the runs skipped by the catch-up policy are not scheduled again
+	if newAssoc.SkippedMissedRunsUntil != nil && newAssoc.SkippedMissedRunsUntil.After(lastExecutionDate) {
+		lastExecutionDate = newAssoc.SkippedMissedRunsUntil.UTC()
+	}
+	nextScheduledDate := newAssoc.ParsedExpression.Next(lastExecutionDate)
+	if nextScheduledDate.IsZero() {
+		// one-shot associations which have run, or cron expressions without any time left
+		log.Infof("Skipping association %v as expression %v has no scheduled date after last execution date %v",
+			*newAssoc.Association.AssociationId, *newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(lastExecutionDate))
+		newAssoc.NextScheduledDate = nil
+		return
+	}
+	newAssoc.NextScheduledDate = aws.Time(nextScheduledDate.UTC())
 	log.Infof("Based upon expression %v and last execution date %v, next scheduled date for association %v is %v",
-		*newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(*newAssoc.Association.LastExecutionDate),
+		*newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(lastExecutionDate),
 		*newAssoc.Association.AssociationId, times.ToIsoDashUTC(*newAssoc.NextScheduledDate))
 }
+
+// SkipMissedRuns moves the next scheduled date of the association past the runs it missed before the given time,
+// associations which never ran or are pending still run now. The time is recorded so that the next scheduled date
+// computed again from the last execution date skips the same runs.
+func (assoc *InstanceAssociation) SkipMissedRuns(log log.T, now time.Time) {
+	if assoc.NextScheduledDate == nil || assoc.ParsedExpression == nil || assoc.Association.LastExecutionDate == nil ||
+		aws.StringValue(assoc.Association.DetailedStatus) == contracts.AssociationStatusPending ||
+		!assoc.NextScheduledDate.Before(now) {
+		return
+	}
+
+	assoc.SkippedMissedRunsUntil = aws.Time(now.UTC())
+	nextScheduledDate := assoc.ParsedExpression.Next(now)
+	if nextScheduledDate.IsZero() {
+		assoc.NextScheduledDate = nil
+	} else {
+		assoc.NextScheduledDate = aws.Time(nextScheduledDate.UTC())
+	}
+	log.Infof("Skipping runs of association %v missed since %v, next ScheduledDate is %v",
+		*assoc.Association.AssociationId, times.ToIsoDashUTC(*assoc.Association.LastExecutionDate), times.ToIsoDashUTC(nextScheduledDate))
+}
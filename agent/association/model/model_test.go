@@ -19,6 +19,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 )
@@ -339,7 +340,7 @@ func TestNextScheduleDateIsNilWhenExpressionTypeIsUnknownAndAssociationHasPrevio
 	assocRawData.Association.Name = &testAssociationName
 	assocId := "b2f71a28-cbe1-4429-b848-26c7e1f5ad0d"
 	assocRawData.Association.AssociationId = &assocId
-	testRateExpression := "at(5 PM)"
+	testRateExpression := "every(5 PM)"
 	assocRawData.Association.ScheduleExpression = &testRateExpression
 
 	// Setting last execution date implies that association has been executed already.
@@ -384,3 +385,175 @@ func TestNextScheduledDateIsNilWhenAssociationExpressionTypeIsUnknown(t *testing
 	// Assert
 	assert.Nil(t, assocRawData.NextScheduledDate)
 }
+
+func TestNextScheduledDateWithScheduleOptions(t *testing.T) {
+	logger := log.DefaultLogger()
+	lastExecutionDate := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name              string
+		expression        string
+		timezone          string
+		lastExecutionDate *time.Time
+		expected          *time.Time
+	}{
+		{"cron in time zone", "cron(0 2 * * ? *)", "America/New_York",
+			&lastExecutionDate, aws.Time(time.Date(2019, 6, 2, 6, 0, 0, 0, time.UTC))},
+		{"at waits for its time", "at(2019-06-01T12:00:00)", "America/New_York",
+			nil, aws.Time(time.Date(2019, 6, 1, 16, 0, 0, 0, time.UTC))},
+		{"at which has run is not scheduled again", "at(2019-06-01T12:00:00)", "",
+			&lastExecutionDate, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assoc := InstanceAssociation{
+				ScheduleTimezone: testCase.timezone,
+				Association: &ssm.InstanceAssociationSummary{
+					Name:               aws.String("Test"),
+					AssociationId:      aws.String("b2f71a28-cbe1-4429-b848-26c7e1f5ad0d"),
+					ScheduleExpression: aws.String(testCase.expression),
+					LastExecutionDate:  testCase.lastExecutionDate,
+				},
+			}
+
+			assoc.SetNextScheduledDate(logger)
+
+			assert.Equal(t, testCase.expected, assoc.NextScheduledDate)
+		})
+	}
+}
+
+func TestNextScheduledDateWithSplayIsStablePerInstance(t *testing.T) {
+	logger := log.DefaultLogger()
+	lastExecutionDate := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	scheduledDate := time.Date(2019, 6, 2, 2, 0, 0, 0, time.UTC)
+
+	nextScheduledDate := func(instanceID string) time.Time {
+		assoc := InstanceAssociation{
+			ScheduleSplay: time.Hour,
+			Association: &ssm.InstanceAssociationSummary{
+				Name:               aws.String("Test"),
+				AssociationId:      aws.String("b2f71a28-cbe1-4429-b848-26c7e1f5ad0d"),
+				InstanceId:         aws.String(instanceID),
+				ScheduleExpression: aws.String("cron(0 2 * * ? *)"),
+				LastExecutionDate:  &lastExecutionDate,
+			},
+		}
+		assoc.SetNextScheduledDate(logger)
+		return *assoc.NextScheduledDate
+	}
+
+	first := nextScheduledDate("i-1234567890abcdef0")
+	assert.Equal(t, first, nextScheduledDate("i-1234567890abcdef0"))
+	assert.NotEqual(t, first, nextScheduledDate("i-0fedcba0987654321"))
+	assert.False(t, first.Before(scheduledDate))
+	assert.True(t, first.Before(scheduledDate.Add(time.Hour)))
+}
+
+func TestSkipMissedRuns(t *testing.T) {
+	logger := log.NewMockLog()
+	now := time.Date(2019, 6, 5, 10, 30, 0, 0, time.UTC)
+	lastExecutionDate := time.Date(2019, 6, 5, 7, 0, 0, 0, time.UTC)
+
+	newAssociation := func(lastExecutionDate *time.Time, detailedStatus string) *InstanceAssociation {
+		assoc := &InstanceAssociation{
+			Association: &ssm.InstanceAssociationSummary{
+				AssociationId:      aws.String("association-id"),
+				ScheduleExpression: aws.String("cron(0 0/1 * * ? *)"),
+				LastExecutionDate:  lastExecutionDate,
+				DetailedStatus:     aws.String(detailedStatus),
+			},
+		}
+		assert.NoError(t, assoc.ParseExpression(logger))
+		assoc.NextScheduledDate = aws.Time(time.Date(2019, 6, 5, 8, 0, 0, 0, time.UTC))
+		return assoc
+	}
+
+	// runs missed since 08:00 are skipped, the association runs at 11:00
+	assoc := newAssociation(&lastExecutionDate, "Success")
+	assoc.SkipMissedRuns(logger, now)
+	assert.Equal(t, time.Date(2019, 6, 5, 11, 0, 0, 0, time.UTC), *assoc.NextScheduledDate)
+
+	// associations which never ran or are pending still run
+	assoc = newAssociation(nil, "Associated")
+	assoc.SkipMissedRuns(logger, now)
+	assert.Equal(t, time.Date(2019, 6, 5, 8, 0, 0, 0, time.UTC), *assoc.NextScheduledDate)
+
+	assoc = newAssociation(&lastExecutionDate, "Pending")
+	assoc.SkipMissedRuns(logger, now)
+	assert.Equal(t, time.Date(2019, 6, 5, 8, 0, 0, 0, time.UTC), *assoc.NextScheduledDate)
+}
+
+func TestParseBlackoutOptions(t *testing.T) {
+	logger := log.NewMockLog()
+	newAssociation := func(value *string) *InstanceAssociation {
+		return &InstanceAssociation{
+			Association: &ssm.InstanceAssociationSummary{
+				AssociationId: aws.String("assoc-id"),
+				Parameters:    map[string][]*string{IgnoreBlackoutWindowsParameter: {value}},
+			},
+		}
+	}
+
+	assoc := newAssociation(aws.String("true"))
+	assoc.ParseBlackoutOptions(logger)
+	assert.True(t, assoc.IgnoreBlackoutWindows)
+
+	assoc = newAssociation(aws.String("false"))
+	assoc.ParseBlackoutOptions(logger)
+	assert.False(t, assoc.IgnoreBlackoutWindows)
+
+	assoc = newAssociation(aws.String("sometimes"))
+	assoc.ParseBlackoutOptions(logger)
+	assert.False(t, assoc.IgnoreBlackoutWindows)
+
+	// the associations defined on the instance keep their own option
+	assoc = &InstanceAssociation{Association: &ssm.InstanceAssociationSummary{}, IgnoreBlackoutWindows: true}
+	assoc.ParseBlackoutOptions(logger)
+	assert.True(t, assoc.IgnoreBlackoutWindows)
+}
+
+func TestParseScheduleOptions(t *testing.T) {
+	logger := log.NewMockLog()
+	newAssociation := func(timezone, splaySeconds string) *InstanceAssociation {
+		return &InstanceAssociation{
+			Association: &ssm.InstanceAssociationSummary{
+				AssociationId: aws.String("assoc-id"),
+				Parameters: map[string][]*string{
+					ScheduleTimezoneParameter:     {aws.String(timezone)},
+					ScheduleSplaySecondsParameter: {aws.String(splaySeconds)},
+				},
+			},
+		}
+	}
+
+	assoc := newAssociation("Europe/Paris", "120")
+	assoc.ParseScheduleOptions(logger, "America/New_York", time.Hour)
+	assert.Equal(t, "Europe/Paris", assoc.ScheduleTimezone)
+	assert.Equal(t, 2*time.Minute, assoc.ScheduleSplay)
+
+	// a splay of 0 turns off the splay of the agent for the association
+	assoc = newAssociation("UTC", "0")
+	assoc.ParseScheduleOptions(logger, "America/New_York", time.Hour)
+	assert.Equal(t, "UTC", assoc.ScheduleTimezone)
+	assert.Equal(t, time.Duration(0), assoc.ScheduleSplay)
+
+	// empty and invalid parameters fall back to the options of the agent
+	for _, parameters := range [][]string{{"", ""}, {"Mars/Olympus", "soon"}, {"Invalid/Zone", "-1"}, {" ", "86401"}} {
+		assoc = newAssociation(parameters[0], parameters[1])
+		assoc.ParseScheduleOptions(logger, "America/New_York", time.Hour)
+		assert.Equal(t, "America/New_York", assoc.ScheduleTimezone)
+		assert.Equal(t, time.Hour, assoc.ScheduleSplay)
+	}
+
+	// the associations defined on the instance keep their own options
+	assoc = &InstanceAssociation{
+		Association:      &ssm.InstanceAssociationSummary{},
+		ScheduleTimezone: "Asia/Tokyo",
+		ScheduleSplay:    time.Minute,
+	}
+	assoc.ParseScheduleOptions(logger, "America/New_York", time.Hour)
+	assert.Equal(t, "Asia/Tokyo", assoc.ScheduleTimezone)
+	assert.Equal(t, time.Minute, assoc.ScheduleSplay)
+}
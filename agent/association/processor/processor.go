@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/association/cache"
 	"github.com/aws/amazon-ssm-agent/agent/association/frequentcollector"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
@@ -29,6 +30,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager/signal"
 	assocScheduler "github.com/aws/amazon-ssm-agent/agent/association/scheduler"
 	"github.com/aws/amazon-ssm-agent/agent/association/service"
+	"github.com/aws/amazon-ssm-agent/agent/blackout"
 	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -37,6 +39,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/carlescere/scheduler"
 )
 
@@ -47,6 +50,7 @@ const (
 	documentLevelTimeOutDurationHour        = 2
 	outputMessageTemplate            string = "%v out of %v plugin%v processed, %v success, %v failed, %v timedout, %v skipped. %v"
 	defaultRetryWaitOnBootInSeconds         = 30
+	associationDeferredMessage       string = "Association deferred until %v by blackout window %v"
 )
 
 // Processor contains the logic for processing association
@@ -59,6 +63,7 @@ type Processor struct {
 	proc               processor.Processor
 	resChan            chan contracts.DocumentResult
 	onBoot             bool
+	blackoutSchedule   *blackout.Schedule
 }
 
 var lock sync.RWMutex
@@ -78,8 +83,17 @@ func NewAssociationProcessor(context context.T) *Processor {
 		OsVersion: config.Os.Version,
 	}
 
-	assocSvc := service.NewAssociationService(name)
-	uploader := complianceUploader.NewComplianceUploader(context)
+	var assocSvc service.T
+	var uploader complianceUploader.T
+	if config.Ssm.LocalAssociationsEnabled {
+		// disconnected hosts read the associations from the local association directory and record the results locally
+		assocContext.Log().Infof("Reading associations from %v", appconfig.LocalAssociationRoot)
+		assocSvc = service.NewLocalAssociationService()
+		uploader = complianceUploader.NewLocalComplianceUploader()
+	} else {
+		assocSvc = service.NewAssociationService(name)
+		uploader = complianceUploader.NewComplianceUploader(context)
+	}
 
 	//TODO Rename everything to service and move package to framework
 	//association has no cancel worker
@@ -91,6 +105,7 @@ func NewAssociationProcessor(context context.T) *Processor {
 		agentInfo:          &agentInfo,
 		proc:               proc,
 		onBoot:             true,
+		blackoutSchedule:   blackout.NewSchedule(assocContext.Log(), config.Blackout),
 	}
 }
 
@@ -138,6 +153,32 @@ func (p *Processor) InitializeAssociationProcessor() {
 	log.Info("Initializing association scheduling service")
 	signal.InitializeAssociationSignalService(log, p.runScheduledAssociation)
 	log.Info("Association scheduling service initialized")
+
+	p.restoreSchedules(log)
+}
+
+// restoreSchedules runs the associations persisted before the agent restarted until the service is reached,
+// and persists the schedules from now on
+func (p *Processor) restoreSchedules(log log.T) {
+	instanceID, err := sys.InstanceID()
+	if err != nil {
+		log.Errorf("Unable to retrieve instance id, association schedules are not persisted, %v", err)
+		return
+	}
+
+	schedulemanager.EnablePersistence(instanceID, p.context.AppConfig().Ssm.AssociationCatchUpPolicy)
+	schedulemanager.RestorePersistedSchedules(log)
+	signal.ExecuteAssociation(log)
+}
+
+// setScheduleOptions sets the time zone and splay of the association from its parameters or definition, the ones
+// configured for the agent apply when the association sets none
+func (p *Processor) setScheduleOptions(assoc *model.InstanceAssociation) {
+	config := p.context.AppConfig()
+	assoc.ParseScheduleOptions(
+		p.context.Log(),
+		config.Ssm.AssociationScheduleTimezone,
+		time.Duration(config.Ssm.AssociationSplaySeconds)*time.Second)
 }
 
 // SetPollJob represents setter for PollJob
@@ -221,7 +262,9 @@ func (p *Processor) ProcessAssociation() {
 			continue
 		}
 
+		assoc.ParseBlackoutOptions(log)
 		if !assoc.IsRunOnceAssociation() {
+			p.setScheduleOptions(assoc)
 			if err = assoc.ParseExpression(log); err != nil {
 				message := fmt.Sprintf("Encountered error while parsing expression for association %v", *assoc.Association.AssociationId)
 				log.Errorf("%v, %v", message, err)
@@ -324,6 +367,12 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 		return
 	}
 
+	if p.deferForBlackout(log, scheduledAssociation) {
+		// look for other associations which are scheduled at this time
+		signal.ExecuteAssociation(log)
+		return
+	}
+
 	log.Debugf("Update association %v to pending ", *scheduledAssociation.Association.AssociationId)
 	// Update association status to pending
 	p.assocSvc.UpdateInstanceAssociationStatus(
@@ -394,6 +443,40 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 	}
 }
 
+// deferForBlackout defers the association to the end of the blackout window the agent is in,
+// it returns false if the association can run now
+func (p *Processor) deferForBlackout(log log.T, assoc *model.InstanceAssociation) bool {
+	associationID := *assoc.Association.AssociationId
+	if p.blackoutSchedule == nil || assoc.IgnoreBlackoutWindows || p.blackoutSchedule.IsExempt(associationID) {
+		return false
+	}
+
+	windowName, end, active := p.blackoutSchedule.ActiveWindow(time.Now().UTC())
+	if !active {
+		return false
+	}
+
+	message := fmt.Sprintf(associationDeferredMessage, times.ToIso8601UTC(end), windowName)
+	log.Infof("%v, association %v", message, associationID)
+	schedulemanager.DeferNextScheduledDate(log, associationID, end)
+
+	// the deferral is reported once, the association stays pending until the window ends
+	if aws.StringValue(assoc.Association.DetailedStatus) != contracts.AssociationStatusPending {
+		p.assocSvc.UpdateInstanceAssociationStatus(
+			log,
+			associationID,
+			*assoc.Association.Name,
+			*assoc.Association.InstanceId,
+			contracts.AssociationStatusPending,
+			contracts.AssociationErrorCodeNoError,
+			times.ToIso8601UTC(time.Now()),
+			message,
+			service.NoOutputUrl)
+		schedulemanager.UpdateAssociationStatus(associationID, contracts.AssociationStatusPending)
+	}
+	return true
+}
+
 func isAssociationTimedOut(assoc *model.InstanceAssociation) bool {
 	if assoc.Association.LastExecutionDate == nil {
 		return false
@@ -19,9 +19,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
 	"github.com/aws/amazon-ssm-agent/agent/association/service"
+	"github.com/aws/amazon-ssm-agent/agent/blackout"
 	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -292,6 +294,104 @@ func mockParser(parserMock *parserMock, payload *messageContracts.SendCommandPay
 		mock.AnythingOfType("*model.InstanceAssociation")).Return(docState)
 }
 
+func TestSetScheduleOptions(t *testing.T) {
+	config := appconfig.SsmagentConfig{}
+	config.Ssm.AssociationScheduleTimezone = "America/New_York"
+	config.Ssm.AssociationSplaySeconds = 600
+	ctx := &context.Mock{}
+	ctx.On("AppConfig").Return(config)
+	ctx.On("Log").Return(log.NewMockLog())
+	processor := Processor{context: ctx}
+
+	assoc := &model.InstanceAssociation{Association: &ssm.InstanceAssociationSummary{}}
+	processor.setScheduleOptions(assoc)
+	assert.Equal(t, "America/New_York", assoc.ScheduleTimezone)
+	assert.Equal(t, 10*time.Minute, assoc.ScheduleSplay)
+
+	// options set on the association take precedence over the agent configuration
+	assoc = &model.InstanceAssociation{
+		Association:      &ssm.InstanceAssociationSummary{},
+		ScheduleTimezone: "Europe/Paris",
+		ScheduleSplay:    time.Minute,
+	}
+	processor.setScheduleOptions(assoc)
+	assert.Equal(t, "Europe/Paris", assoc.ScheduleTimezone)
+	assert.Equal(t, time.Minute, assoc.ScheduleSplay)
+
+	// so do the options in the parameters of the association
+	assoc = &model.InstanceAssociation{Association: &ssm.InstanceAssociationSummary{
+		Parameters: map[string][]*string{
+			model.ScheduleTimezoneParameter:     {aws.String("Asia/Tokyo")},
+			model.ScheduleSplaySecondsParameter: {aws.String("0")},
+		},
+	}}
+	processor.setScheduleOptions(assoc)
+	assert.Equal(t, "Asia/Tokyo", assoc.ScheduleTimezone)
+	assert.Equal(t, time.Duration(0), assoc.ScheduleSplay)
+}
+
+func TestDeferForBlackout(t *testing.T) {
+	processor := createProcessor()
+	svcMock := service.NewMockDefault()
+	processor.assocSvc = svcMock
+	svcMock.On(
+		"UpdateInstanceAssociationStatus",
+		mock.AnythingOfType("*log.Mock"),
+		"Id-Test",
+		"Test-Association",
+		"test-association-id",
+		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult"))
+	allDay := appconfig.BlackoutWindow{Name: "freeze", StartTime: "00:00", EndTime: "00:00"}
+	processor.blackoutSchedule = blackout.NewSchedule(log.NewMockLog(), appconfig.BlackoutCfg{Windows: []appconfig.BlackoutWindow{allDay}})
+
+	testAssociations := createAssociationRawData()
+	schedulemanager.Refresh(log.NewMockLog(), testAssociations)
+	assoc := testAssociations[0]
+
+	assert.True(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+	assert.True(t, assoc.NextScheduledDate.After(time.Now()))
+	assert.Equal(t, contracts.AssociationStatusPending, *assoc.Association.DetailedStatus)
+
+	// the deferral is reported once
+	assert.True(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+	svcMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 1)
+
+	assoc.IgnoreBlackoutWindows = true
+	assert.False(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+}
+
+func TestDeferForBlackoutRunsExemptAssociations(t *testing.T) {
+	processor := createProcessor()
+	svcMock := service.NewMockDefault()
+	processor.assocSvc = svcMock
+	svcMock.On(
+		"UpdateInstanceAssociationStatus",
+		mock.AnythingOfType("*log.Mock"),
+		"Id-Test",
+		"Test-Association",
+		"test-association-id",
+		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult"))
+	allDay := appconfig.BlackoutWindow{StartTime: "00:00", EndTime: "00:00"}
+	processor.blackoutSchedule = blackout.NewSchedule(log.NewMockLog(), appconfig.BlackoutCfg{
+		Windows:            []appconfig.BlackoutWindow{allDay},
+		ExemptAssociations: []string{"Id-Test"},
+	})
+
+	assoc := createAssociationRawData()[0]
+	assert.False(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+
+	// the name of the association is the name of its document, which doesn't exempt it
+	processor.blackoutSchedule = blackout.NewSchedule(log.NewMockLog(), appconfig.BlackoutCfg{
+		Windows:            []appconfig.BlackoutWindow{allDay},
+		ExemptAssociations: []string{"Test-Association"},
+	})
+	assert.True(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+
+	// no blackout windows are configured
+	processor.blackoutSchedule = blackout.NewSchedule(log.NewMockLog(), appconfig.BlackoutCfg{})
+	assert.False(t, processor.deferForBlackout(log.NewMockLog(), assoc))
+}
+
 func createProcessor() *Processor {
 	processor := Processor{}
 	processor.context = context.NewMockDefault()
@@ -0,0 +1,128 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package scheduleexpression provides interface for schedule expression and factory for constructing generic parsed
+// schedule expression
+package scheduleexpression
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/gorhill/cronexpr"
+)
+
+// transitionSearchWindow is how far from a wall clock time the offsets of a time zone are looked up,
+// it is larger than any UTC offset and time zones don't change their offset twice within it
+const transitionSearchWindow = 24 * time.Hour
+
+// loadLocation returns the time zone with the given IANA name, UTC when the name is empty
+func loadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// zonedCronExpression evaluates a cron expression on the wall clock of a time zone.
+// cronexpr computes the times in the location of fromTime and breaks when the clocks change for daylight saving time,
+// so the expression is evaluated on a wall clock without transitions and the times are converted to the time zone.
+// Times skipped when the clocks go forward fire once the clocks have gone forward,
+// times repeated when the clocks go back fire only the first time.
+type zonedCronExpression struct {
+	expression *cronexpr.Expression
+	location   *time.Location
+}
+
+// Next returns the next time of the expression after fromTime
+func (expr *zonedCronExpression) Next(fromTime time.Time) time.Time {
+	if fromTime.IsZero() {
+		return fromTime
+	}
+
+	wallTime := toWallClock(fromTime.In(expr.location))
+	for {
+		wallTime = expr.expression.Next(wallTime)
+		if wallTime.IsZero() {
+			return wallTime
+		}
+		// a time skipped when the clocks went forward can be before fromTime
+		if next := fromWallClock(wallTime, expr.location); next.After(fromTime) {
+			return next
+		}
+	}
+}
+
+// toWallClock returns the wall clock time of t as a UTC time
+func toWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWallClock returns the time at which the clocks of the location show the given wall clock time.
+// time.Date doesn't specify which time it returns for wall clock times which are skipped or repeated,
+// so the offsets before and after a possible transition are tried.
+func fromWallClock(wallTime time.Time, location *time.Location) time.Time {
+	_, offsetBefore := wallTime.Add(-transitionSearchWindow).In(location).Zone()
+	_, offsetAfter := wallTime.Add(transitionSearchWindow).In(location).Zone()
+
+	before := wallTime.Add(-time.Duration(offsetBefore) * time.Second).In(location)
+	after := wallTime.Add(-time.Duration(offsetAfter) * time.Second).In(location)
+	beforeIsValid := toWallClock(before).Equal(wallTime)
+	afterIsValid := toWallClock(after).Equal(wallTime)
+
+	switch {
+	case beforeIsValid && afterIsValid:
+		// the wall clock time is repeated when the clocks go back, pick the first one
+		if after.Before(before) {
+			return after
+		}
+		return before
+	case afterIsValid:
+		return after
+	default:
+		// the wall clock time is skipped when the clocks go forward,
+		// with the offset before the transition it falls right after the transition
+		return before
+	}
+}
+
+// splayedExpression delays the times of a schedule expression by an offset
+type splayedExpression struct {
+	expression ScheduleExpression
+	offset     time.Duration
+}
+
+// Next returns the next delayed time of the expression after fromTime
+func (expr *splayedExpression) Next(fromTime time.Time) time.Time {
+	// fromTime is usually a delayed time, the time of the expression it was delayed from is the reference
+	if !fromTime.IsZero() {
+		fromTime = fromTime.Add(-expr.offset)
+	}
+	next := expr.expression.Next(fromTime)
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(expr.offset)
+}
+
+// splayOffset derives an offset within the splay window from the key,
+// every instance gets a different offset for an association but always the same one
+func splayOffset(splay time.Duration, key string) time.Duration {
+	splaySeconds := int64(splay / time.Second)
+	if splaySeconds <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return time.Duration(hash.Sum64()%uint64(splaySeconds)) * time.Second
+}
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/association/atexpr"
 	"github.com/aws/amazon-ssm-agent/agent/association/rateexpr"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/gorhill/cronexpr"
@@ -29,6 +30,7 @@ import (
 const (
 	expressionTypeCron = "cron"
 	expressionTypeRate = "rate"
+	expressionTypeAt   = "at("
 )
 
 //ScheduleExpression defines operations of a valid schedule expression which association/model makes use of
@@ -36,7 +38,47 @@ type ScheduleExpression interface {
 	Next(fromTime time.Time) time.Time
 }
 
+// Options customize how the times of a schedule expression are computed
+type Options struct {
+	// Timezone is the IANA name of the time zone in which cron and at expressions are evaluated, UTC when empty
+	Timezone string
+	// Splay is the window within which each instance delays the scheduled times by its own offset
+	Splay time.Duration
+	// SplayKey identifies the instance and the association the offset is derived from, so that it is stable across restarts
+	SplayKey string
+}
+
+// CreateScheduleExpression parses a cron, rate or at expression evaluated in UTC
 func CreateScheduleExpression(log log.T, scheduleExpression string) (ScheduleExpression, error) {
+	return CreateScheduleExpressionWithOptions(log, scheduleExpression, Options{})
+}
+
+// CreateScheduleExpressionWithOptions parses a cron, rate or at expression evaluated with the given time zone and splay
+func CreateScheduleExpressionWithOptions(log log.T, scheduleExpression string, options Options) (ScheduleExpression, error) {
+	location, err := loadLocation(options.Timezone)
+	if err != nil {
+		message := fmt.Sprintf("Time zone %v of schedule expression %v is invalid, %v", options.Timezone, scheduleExpression, err)
+		log.Error(message)
+		return nil, fmt.Errorf("%v", message)
+	}
+
+	parsedExpression, err := parseScheduleExpression(log, scheduleExpression, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset := splayOffset(options.Splay, options.SplayKey); offset > 0 {
+		parsedExpression = &splayedExpression{expression: parsedExpression, offset: offset}
+	}
+	return parsedExpression, nil
+}
+
+// IsOneShotExpression returns true for the at expressions which fire only once
+func IsOneShotExpression(scheduleExpression string) bool {
+	return strings.HasPrefix(strings.ToLower(scheduleExpression), expressionTypeAt)
+}
+
+func parseScheduleExpression(log log.T, scheduleExpression string, location *time.Location) (ScheduleExpression, error) {
 
 	lowerCasedScheduledExpression := strings.ToLower(scheduleExpression)
 
@@ -50,6 +92,9 @@ func CreateScheduleExpression(log log.T, scheduleExpression string) (ScheduleExp
 		parsedCronExpression, err := cronexpr.Parse(cronExpression)
 
 		if err == nil {
+			if location != time.UTC {
+				return &zonedCronExpression{expression: parsedCronExpression, location: location}, nil
+			}
 			return parsedCronExpression, nil
 		} else {
 			message := fmt.Sprintf("Error %v received while parsing cron expression %v", err, scheduleExpression)
@@ -70,6 +115,17 @@ func CreateScheduleExpression(log log.T, scheduleExpression string) (ScheduleExp
 		}
 	}
 
+	if strings.HasPrefix(lowerCasedScheduledExpression, expressionTypeAt) {
+		parsedAtExpression, err := atexpr.Parse(scheduleExpression, location)
+
+		if err != nil {
+			message := fmt.Sprintf("An error %v received while parsing at expression %v", err, scheduleExpression)
+			log.Error(message)
+			return nil, fmt.Errorf("%v", message)
+		}
+		return parsedAtExpression, nil
+	}
+
 	return nil, fmt.Errorf("Unknown expression type detected in expression %v", scheduleExpression)
 }
 
@@ -15,6 +15,7 @@ package scheduleexpression
 
 import (
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
@@ -123,11 +124,139 @@ func TestParseReturnsErrorWhenScheduleExpressionIsOfUnknownType(t *testing.T) {
 	// Assemble
 	logger := log.DefaultLogger()
 
+	// Act
+	parsedExpression, err := CreateScheduleExpression(logger, "every(12:00)")
+
+	// Assert
+	assert.Nil(t, parsedExpression)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Unknown expression type detected in expression every(12:00)", err.Error())
+}
+
+func TestParseReturnsErrorForInvalidAtExpression(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+
 	// Act
 	parsedExpression, err := CreateScheduleExpression(logger, "at(12:00)")
 
 	// Assert
 	assert.Nil(t, parsedExpression)
 	assert.NotNil(t, err)
-	assert.Equal(t, "Unknown expression type detected in expression at(12:00)", err.Error())
+	assert.Contains(t, err.Error(), "while parsing at expression at(12:00)")
+}
+
+func TestParseReturnsErrorForUnknownTimezone(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+
+	// Act
+	parsedExpression, err := CreateScheduleExpressionWithOptions(logger, "cron(0 2 * * ? *)", Options{Timezone: "Mars/Olympus_Mons"})
+
+	// Assert
+	assert.Nil(t, parsedExpression)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Time zone Mars/Olympus_Mons of schedule expression cron(0 2 * * ? *) is invalid")
+}
+
+func TestNext(t *testing.T) {
+	logger := log.DefaultLogger()
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		expression string
+		timezone   string
+		fromTime   time.Time
+		expected   time.Time
+	}{
+		{"cron in utc", "cron(0 2 * * ? *)", "",
+			time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), time.Date(2019, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"cron in time zone", "cron(0 2 * * ? *)", "America/New_York",
+			time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), time.Date(2019, 6, 2, 2, 0, 0, 0, newYork)},
+		{"cron in time zone with utc offset in from time", "cron(0 22 * * ? *)", "Australia/Sydney",
+			time.Date(2019, 6, 1, 11, 0, 0, 0, time.UTC), time.Date(2019, 6, 1, 22, 0, 0, 0, sydney)},
+		{"skipped time fires after the clocks go forward", "cron(30 2 * * ? *)", "America/New_York",
+			time.Date(2019, 3, 9, 12, 0, 0, 0, newYork), time.Date(2019, 3, 10, 3, 30, 0, 0, newYork)},
+		{"skipped time fires on the next day", "cron(30 2 * * ? *)", "America/New_York",
+			time.Date(2019, 3, 10, 3, 30, 0, 0, newYork), time.Date(2019, 3, 11, 2, 30, 0, 0, newYork)},
+		{"skipped time fires after the clocks go forward in the southern hemisphere", "cron(30 2 * * ? *)", "Australia/Sydney",
+			time.Date(2019, 10, 5, 12, 0, 0, 0, sydney), time.Date(2019, 10, 6, 3, 30, 0, 0, sydney)},
+		{"repeated time fires the first time", "cron(30 1 * * ? *)", "America/New_York",
+			time.Date(2019, 11, 2, 12, 0, 0, 0, newYork), time.Date(2019, 11, 3, 5, 30, 0, 0, time.UTC)},
+		{"repeated time fires only once", "cron(30 1 * * ? *)", "America/New_York",
+			time.Date(2019, 11, 3, 5, 30, 0, 0, time.UTC), time.Date(2019, 11, 4, 6, 30, 0, 0, time.UTC)},
+		{"repeated time fires the first time in the southern hemisphere", "cron(30 2 * * ? *)", "Australia/Sydney",
+			time.Date(2019, 4, 6, 12, 0, 0, 0, sydney), time.Date(2019, 4, 6, 15, 30, 0, 0, time.UTC)},
+		{"hourly cron across the clocks going back", "cron(0 * * * ? *)", "America/New_York",
+			time.Date(2019, 11, 3, 5, 0, 0, 0, time.UTC), time.Date(2019, 11, 3, 7, 0, 0, 0, time.UTC)},
+		{"rate ignores the time zone", "rate(1 hour)", "America/New_York",
+			time.Date(2019, 3, 10, 6, 30, 0, 0, time.UTC), time.Date(2019, 3, 10, 7, 30, 0, 0, time.UTC)},
+		{"at in utc", "at(2019-06-01T12:00:00)", "",
+			time.Time{}, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"at in time zone", "at(2019-06-01T12:00:00)", "America/New_York",
+			time.Time{}, time.Date(2019, 6, 1, 16, 0, 0, 0, time.UTC)},
+		{"at has fired", "at(2019-06-01T12:00:00)", "",
+			time.Date(2019, 6, 1, 12, 0, 5, 0, time.UTC), time.Time{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsedExpression, err := CreateScheduleExpressionWithOptions(logger, testCase.expression, Options{Timezone: testCase.timezone})
+			assert.NoError(t, err)
+			next := parsedExpression.Next(testCase.fromTime)
+			assert.True(t, testCase.expected.Equal(next), "expected %v, got %v", testCase.expected, next)
+		})
+	}
+}
+
+func TestNextWithSplay(t *testing.T) {
+	logger := log.DefaultLogger()
+	splay := 30 * time.Minute
+
+	testCases := []struct {
+		name       string
+		expression string
+		fromTime   time.Time
+		scheduled  time.Time
+	}{
+		{"cron", "cron(0 2 * * ? *)",
+			time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), time.Date(2019, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"cron from a splayed run", "cron(0 2 * * ? *)",
+			time.Date(2019, 6, 2, 2, 29, 59, 0, time.UTC), time.Date(2019, 6, 3, 2, 0, 0, 0, time.UTC)},
+		{"at", "at(2019-06-01T12:00:00)",
+			time.Time{}, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			offsets := map[time.Duration]bool{}
+			for _, instanceID := range []string{"i-1", "i-2", "i-3", "i-4", "i-5"} {
+				options := Options{Splay: splay, SplayKey: instanceID + "/assoc-1"}
+				parsedExpression, err := CreateScheduleExpressionWithOptions(logger, testCase.expression, options)
+				assert.NoError(t, err)
+
+				offset := parsedExpression.Next(testCase.fromTime).Sub(testCase.scheduled)
+				assert.True(t, offset >= 0 && offset < splay, "offset %v is not within the splay", offset)
+				assert.Equal(t, offset%time.Second, time.Duration(0))
+				offsets[offset] = true
+
+				// the offset of an instance is stable
+				parsedExpression, _ = CreateScheduleExpressionWithOptions(logger, testCase.expression, options)
+				assert.Equal(t, testCase.scheduled.Add(offset), parsedExpression.Next(testCase.fromTime))
+			}
+			assert.True(t, len(offsets) > 1, "instances should not share the same offset")
+		})
+	}
+}
+
+func TestIsOneShotExpression(t *testing.T) {
+	assert.True(t, IsOneShotExpression("at(2019-06-01T12:00:00)"))
+	assert.True(t, IsOneShotExpression("AT(2019-06-01T12:00:00)"))
+	assert.False(t, IsOneShotExpression("cron(0 2 * * ? *)"))
+	assert.False(t, IsOneShotExpression("rate(30 minutes)"))
+	assert.False(t, IsOneShotExpression(""))
 }
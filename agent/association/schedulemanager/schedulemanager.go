@@ -37,6 +37,15 @@ func Refresh(log log.T, assocs []*model.InstanceAssociation) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	lastExecutionDates := make(map[string]time.Time)
+	skippedMissedRuns := make(map[string]*time.Time)
+	for _, assoc := range associations {
+		if assoc.Association.LastExecutionDate != nil {
+			lastExecutionDates[*assoc.Association.AssociationId] = *assoc.Association.LastExecutionDate
+		}
+		skippedMissedRuns[*assoc.Association.AssociationId] = assoc.SkippedMissedRunsUntil
+	}
+
 	associations = []*model.InstanceAssociation{}
 	log.Debugf("Refreshing schedule manager with %v associations", len(assocs))
 
@@ -46,6 +55,16 @@ func Refresh(log log.T, assocs []*model.InstanceAssociation) {
 		if len(newAssoc.Errors) == 0 {
 			associations = append(associations, newAssoc)
 		}
+
+		// keep the runs the service doesn't know about yet, e.g. while it was unreachable
+		if lastExecutionDate, found := lastExecutionDates[*newAssoc.Association.AssociationId]; found &&
+			(newAssoc.Association.LastExecutionDate == nil || newAssoc.Association.LastExecutionDate.Before(lastExecutionDate)) {
+			newAssoc.Association.LastExecutionDate = aws.Time(lastExecutionDate)
+		}
+		// keep the runs skipped by the catch-up policy skipped
+		if newAssoc.SkippedMissedRunsUntil == nil {
+			newAssoc.SkippedMissedRunsUntil = skippedMissedRuns[*newAssoc.Association.AssociationId]
+		}
 	}
 
 	numberOfNewAssoc := 0
@@ -66,6 +85,10 @@ func Refresh(log log.T, assocs []*model.InstanceAssociation) {
 		}
 	}
 
+	catchUp(log)
+	refreshed = true
+	persist(log)
+
 	complianceModel.RefreshAssociationComplianceItems(associations)
 
 	log.Infof("Schedule manager refreshed with %v associations, %v new associations associated", len(associations), numberOfNewAssoc)
@@ -123,6 +146,34 @@ func LoadNextScheduledDate(log log.T) *time.Time {
 	return nextScheduleDate
 }
 
+// LoadNextScheduledAssociationSummary returns a copy of the association which is scheduled to run next
+func LoadNextScheduledAssociationSummary() *model.InstanceAssociation {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var next *model.InstanceAssociation
+	for _, assoc := range associations {
+		if assoc.NextScheduledDate == nil {
+			continue
+		}
+		if next == nil || next.NextScheduledDate.After(*assoc.NextScheduledDate) {
+			next = assoc
+		}
+	}
+	if next == nil {
+		return nil
+	}
+
+	summary := *next.Association
+	nextScheduledDate := *next.NextScheduledDate
+	return &model.InstanceAssociation{
+		DocumentID:        next.DocumentID,
+		CreateDate:        next.CreateDate,
+		NextScheduledDate: &nextScheduledDate,
+		Association:       &summary,
+	}
+}
+
 // UpdateNextScheduledDate sets next scheduled date for the given association
 func UpdateNextScheduledDate(log log.T, associationID string) {
 	lock.Lock()
@@ -135,6 +186,22 @@ func UpdateNextScheduledDate(log log.T, associationID string) {
 			if assoc.NextScheduledDate != nil {
 				log.Infof("Scheduling association %v, setting next ScheduledDate to %v", *assoc.Association.AssociationId, times.ToIsoDashUTC(*assoc.NextScheduledDate))
 			}
+			persist(log)
+			break
+		}
+	}
+}
+
+// DeferNextScheduledDate moves the next scheduled date of the given association to the given date
+func DeferNextScheduledDate(log log.T, associationID string, scheduledDate time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, assoc := range associations {
+		if *assoc.Association.AssociationId == associationID {
+			assoc.NextScheduledDate = aws.Time(scheduledDate.UTC())
+			log.Infof("Deferring association %v, setting next ScheduledDate to %v", associationID, times.ToIsoDashUTC(*assoc.NextScheduledDate))
+			persist(log)
 			break
 		}
 	}
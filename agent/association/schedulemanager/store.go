@@ -0,0 +1,174 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package schedulemanager
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	complianceModel "github.com/aws/amazon-ssm-agent/agent/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// schedulesFileName is the file in which the schedules of the associations are persisted
+const schedulesFileName = "schedules.json"
+
+var (
+	// schedulesFilePath is empty until persistence is enabled
+	schedulesFilePath string
+	catchUpPolicy     = appconfig.DefaultSsmAssociationCatchUpPolicy
+	// caughtUp is set once the runs missed while the agent was down are handled
+	caughtUp bool
+	// refreshed is set once the associations are refreshed from the service
+	refreshed bool
+)
+
+// persistedAssociation is the state of an association which is kept across agent restarts
+type persistedAssociation struct {
+	DocumentID            string
+	CreateDate            time.Time
+	NextScheduledDate     *time.Time
+	Association           *ssm.InstanceAssociationSummary
+	Document              *string
+	ScheduleTimezone      string
+	ScheduleSplay         time.Duration
+	IgnoreBlackoutWindows bool
+	// SkippedMissedRunsUntil is kept so that the runs skipped before a restart stay skipped
+	SkippedMissedRunsUntil *time.Time
+}
+
+// EnablePersistence persists the schedules of the associations of the instance from now on,
+// and sets how the runs missed while the agent was down are handled
+func EnablePersistence(instanceID string, policy string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	schedulesFilePath = path.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.DefaultDocumentRootDirName,
+		appconfig.DefaultLocationOfAssociation,
+		schedulesFileName)
+	catchUpPolicy = policy
+}
+
+// RestorePersistedSchedules schedules the associations persisted before the agent restarted,
+// so they keep running until the associations are refreshed from the service
+func RestorePersistedSchedules(log log.T) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if schedulesFilePath == "" || !fileutil.Exists(schedulesFilePath) {
+		return
+	}
+	if refreshed {
+		log.Debug("Associations are already refreshed from the service, ignoring persisted schedules")
+		return
+	}
+
+	var persistedAssociations []persistedAssociation
+	if err := jsonutil.UnmarshalFile(schedulesFilePath, &persistedAssociations); err != nil {
+		log.Errorf("Failed to load persisted association schedules, %v", err)
+		return
+	}
+
+	associations = []*model.InstanceAssociation{}
+	for _, persisted := range persistedAssociations {
+		if persisted.Association == nil || persisted.Association.AssociationId == nil {
+			continue
+		}
+		assoc := &model.InstanceAssociation{
+			DocumentID:             persisted.DocumentID,
+			CreateDate:             persisted.CreateDate,
+			Association:            persisted.Association,
+			Document:               persisted.Document,
+			ScheduleTimezone:       persisted.ScheduleTimezone,
+			ScheduleSplay:          persisted.ScheduleSplay,
+			IgnoreBlackoutWindows:  persisted.IgnoreBlackoutWindows,
+			SkippedMissedRunsUntil: persisted.SkippedMissedRunsUntil,
+		}
+		// the next scheduled date is computed again from the last execution date, then the catch-up policy applies
+		assoc.SetNextScheduledDate(log)
+		associations = append(associations, assoc)
+	}
+	catchUp(log)
+	complianceModel.RefreshAssociationComplianceItems(associations)
+
+	log.Infof("Schedule manager restored %v persisted associations", len(associations))
+}
+
+// catchUp applies the catch-up policy the first time the associations are scheduled after the agent started,
+// the caller holds the lock
+func catchUp(log log.T) {
+	if caughtUp {
+		return
+	}
+	caughtUp = true
+
+	if catchUpPolicy != appconfig.AssociationCatchUpSkip {
+		return
+	}
+	now := time.Now().UTC()
+	for _, assoc := range associations {
+		assoc.SkipMissedRuns(log, now)
+	}
+}
+
+// persist writes the schedules of the associations when persistence is enabled, the caller holds the lock
+func persist(log log.T) {
+	if schedulesFilePath == "" {
+		return
+	}
+
+	persistedAssociations := make([]persistedAssociation, 0, len(associations))
+	for _, assoc := range associations {
+		persistedAssociations = append(persistedAssociations, persistedAssociation{
+			DocumentID:             assoc.DocumentID,
+			CreateDate:             assoc.CreateDate,
+			NextScheduledDate:      assoc.NextScheduledDate,
+			Association:            assoc.Association,
+			Document:               assoc.Document,
+			ScheduleTimezone:       assoc.ScheduleTimezone,
+			ScheduleSplay:          assoc.ScheduleSplay,
+			IgnoreBlackoutWindows:  assoc.IgnoreBlackoutWindows,
+			SkippedMissedRunsUntil: assoc.SkippedMissedRunsUntil,
+		})
+	}
+
+	content, err := jsonutil.Marshal(persistedAssociations)
+	if err != nil {
+		log.Errorf("Failed to persist association schedules, %v", err)
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(schedulesFilePath)); err != nil {
+		log.Errorf("Failed to persist association schedules, %v", err)
+		return
+	}
+
+	// write a temporary file first so a crash doesn't leave truncated schedules behind
+	tempFilePath := schedulesFilePath + ".tmp"
+	if _, err = fileutil.WriteIntoFileWithPermissions(tempFilePath, content, os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
+		log.Errorf("Failed to persist association schedules, %v", err)
+		return
+	}
+	if err = os.Rename(tempFilePath, schedulesFilePath); err != nil {
+		log.Errorf("Failed to persist association schedules, %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package schedulemanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// setUpStore persists the schedules in a temporary directory and resets the state of the schedule manager
+func setUpStore(t *testing.T, policy string) (cleanUp func()) {
+	dir, err := ioutil.TempDir("", "schedules")
+	assert.NoError(t, err)
+
+	schedulesFilePath = filepath.Join(dir, schedulesFileName)
+	catchUpPolicy = policy
+	resetState()
+	return func() {
+		schedulesFilePath = ""
+		catchUpPolicy = appconfig.DefaultSsmAssociationCatchUpPolicy
+		resetState()
+		os.RemoveAll(dir)
+	}
+}
+
+// resetState forgets the associations as if the agent restarted
+func resetState() {
+	associations = []*model.InstanceAssociation{}
+	caughtUp = false
+	refreshed = false
+}
+
+func newScheduledAssociation(associationID string, lastExecutionDate *time.Time) *model.InstanceAssociation {
+	return &model.InstanceAssociation{
+		DocumentID: "document-" + associationID,
+		Document:   aws.String("{}"),
+		Association: &ssm.InstanceAssociationSummary{
+			AssociationId:      aws.String(associationID),
+			Name:               aws.String("Test-Association"),
+			InstanceId:         aws.String("i-1234567890"),
+			DocumentVersion:    aws.String("1"),
+			ScheduleExpression: aws.String("cron(0 0/1 * * ? *)"),
+			LastExecutionDate:  lastExecutionDate,
+			DetailedStatus:     aws.String("Success"),
+		},
+		ScheduleTimezone: "America/New_York",
+	}
+}
+
+func TestRestorePersistedSchedules(t *testing.T) {
+	defer setUpStore(t, appconfig.AssociationCatchUpRunOnce)()
+
+	lastExecutionDate := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second)
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", &lastExecutionDate)})
+
+	resetState()
+	RestorePersistedSchedules(logger)
+
+	restored := Schedules()
+	assert.Len(t, restored, 1)
+	assert.Equal(t, "association-id", *restored[0].Association.AssociationId)
+	assert.Equal(t, "{}", *restored[0].Document)
+	assert.Equal(t, "America/New_York", restored[0].ScheduleTimezone)
+	assert.True(t, lastExecutionDate.Equal(*restored[0].Association.LastExecutionDate))
+
+	// the runs missed while the agent was down run once
+	assert.True(t, restored[0].NextScheduledDate.Before(time.Now()))
+	next, err := LoadNextScheduledAssociation(logger)
+	assert.NoError(t, err)
+	assert.NotNil(t, next)
+}
+
+func TestRestorePersistedSchedulesSkipsMissedRuns(t *testing.T) {
+	defer setUpStore(t, appconfig.AssociationCatchUpSkip)()
+
+	lastExecutionDate := time.Now().UTC().Add(-3 * time.Hour)
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", &lastExecutionDate)})
+
+	resetState()
+	RestorePersistedSchedules(logger)
+
+	restored := Schedules()
+	assert.Len(t, restored, 1)
+	assert.True(t, restored[0].NextScheduledDate.After(time.Now()))
+	next, err := LoadNextScheduledAssociation(logger)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestRefreshAfterRestoreKeepsSkippedRuns(t *testing.T) {
+	defer setUpStore(t, appconfig.AssociationCatchUpSkip)()
+
+	lastExecutionDate := time.Now().UTC().Add(-3 * time.Hour)
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", &lastExecutionDate)})
+
+	resetState()
+	RestorePersistedSchedules(logger)
+
+	// the service still reports the last execution date from before the missed runs
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", &lastExecutionDate)})
+
+	refreshed := Schedules()
+	assert.Len(t, refreshed, 1)
+	assert.True(t, refreshed[0].NextScheduledDate.After(time.Now()))
+	next, err := LoadNextScheduledAssociation(logger)
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestRestorePersistedSchedulesAfterRefresh(t *testing.T) {
+	defer setUpStore(t, appconfig.AssociationCatchUpRunOnce)()
+
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("persisted-id", nil)})
+	resetState()
+
+	// the associations of the service replace the persisted ones
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("service-id", nil)})
+	RestorePersistedSchedules(logger)
+
+	assert.Len(t, Schedules(), 1)
+	assert.Equal(t, "service-id", *Schedules()[0].Association.AssociationId)
+}
+
+func TestRefreshKeepsRunsUnknownToService(t *testing.T) {
+	defer setUpStore(t, appconfig.AssociationCatchUpRunOnce)()
+
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", nil)})
+	UpdateNextScheduledDate(logger, "association-id")
+	localExecutionDate := *Schedules()[0].Association.LastExecutionDate
+
+	// the service doesn't know about the run yet
+	serviceExecutionDate := localExecutionDate.Add(-time.Hour)
+	Refresh(logger, []*model.InstanceAssociation{newScheduledAssociation("association-id", &serviceExecutionDate)})
+
+	assert.Equal(t, localExecutionDate, *Schedules()[0].Association.LastExecutionDate)
+	assert.True(t, Schedules()[0].NextScheduledDate.After(localExecutionDate))
+}
@@ -0,0 +1,360 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// localAssociationFileExtension is the extension of the association definitions in the local association directory
+	localAssociationFileExtension = ".json"
+	// localAssociationStateFileName is the file in which the status of the local associations is kept
+	localAssociationStateFileName = "localassociations.json"
+	localDocumentVersion          = "1"
+)
+
+// LocalAssociationDefinition is an association defined in a file of the local association directory,
+// the document is given inline or by a path relative to the directory
+type LocalAssociationDefinition struct {
+	AssociationID         string
+	Name                  string
+	DocumentPath          string
+	Document              json.RawMessage
+	Parameters            map[string][]string
+	ScheduleExpression    string
+	ScheduleTimezone      string
+	ScheduleSplaySeconds  int
+	IgnoreBlackoutWindows bool
+}
+
+// localAssociationState is the status of a local association which is kept across agent restarts
+type localAssociationState struct {
+	Checksum          string
+	DetailedStatus    string
+	LastExecutionDate *time.Time
+}
+
+// localExecutionRecord is an entry of the execution history of a local association
+type localExecutionRecord struct {
+	AssociationID    string
+	AssociationName  string
+	Status           string
+	ErrorCode        string
+	ExecutionDate    string
+	ExecutionSummary string
+}
+
+// LocalAssociationService reads the associations from the local association directory
+// and writes their execution history to local files instead of calling the service
+type LocalAssociationService struct {
+	associationDir string
+	historyDir     string
+	dataStorePath  string
+	lock           sync.Mutex
+	// documents are the documents of the listed associations, or the errors reading them, by association id
+	documents map[string]localDocument
+}
+
+// localDocument is the document of a local association or the error reading it
+type localDocument struct {
+	content  string
+	err      error
+	checksum string
+}
+
+// NewLocalAssociationService returns a service which reads the associations from the local association directory
+func NewLocalAssociationService() *LocalAssociationService {
+	return &LocalAssociationService{
+		associationDir: appconfig.LocalAssociationRoot,
+		historyDir:     appconfig.LocalAssociationRootHistory,
+		dataStorePath:  appconfig.DefaultDataStorePath,
+		documents:      make(map[string]localDocument),
+	}
+}
+
+// CreateNewServiceIfUnHealthy does nothing as there is no service to reach
+func (s *LocalAssociationService) CreateNewServiceIfUnHealthy(log log.T) {}
+
+// ListInstanceAssociations reads the association definitions of the local association directory,
+// definitions which are not valid are logged and ignored
+func (s *LocalAssociationService) ListInstanceAssociations(log log.T, instanceID string) ([]*model.InstanceAssociation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := fileutil.MakeDirs(s.associationDir); err != nil {
+		return nil, fmt.Errorf("unable to create local association directory %v, %v", s.associationDir, err)
+	}
+	fileNames, err := fileutil.GetFileNames(s.associationDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read local association directory %v, %v", s.associationDir, err)
+	}
+
+	states := s.loadStates(log, instanceID)
+	results := []*model.InstanceAssociation{}
+	s.documents = make(map[string]localDocument)
+	for _, fileName := range fileNames {
+		if !strings.HasSuffix(fileName, localAssociationFileExtension) {
+			continue
+		}
+
+		assoc, document, err := s.readDefinition(fileName, instanceID)
+		if err != nil {
+			log.Errorf("Ignoring local association %v, %v", fileName, err)
+			continue
+		}
+		associationID := *assoc.Association.AssociationId
+		if _, found := s.documents[associationID]; found {
+			log.Errorf("Ignoring local association %v, association id %v is already defined", fileName, associationID)
+			continue
+		}
+		document.checksum = *assoc.Association.Checksum
+		s.documents[associationID] = document
+
+		// a changed definition is a new association which runs again
+		if state, found := states[associationID]; found && state.Checksum == *assoc.Association.Checksum {
+			assoc.Association.DetailedStatus = aws.String(state.DetailedStatus)
+			assoc.Association.LastExecutionDate = state.LastExecutionDate
+		} else {
+			assoc.Association.DetailedStatus = aws.String(contracts.AssociationStatusAssociated)
+		}
+		results = append(results, assoc)
+	}
+
+	log.Debug("Number of local associations is ", len(results))
+	return results, nil
+}
+
+// readDefinition parses the association definition in the given file and reads its document
+func (s *LocalAssociationService) readDefinition(fileName string, instanceID string) (*model.InstanceAssociation, localDocument, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.associationDir, fileName))
+	if err != nil {
+		return nil, localDocument{}, err
+	}
+	var definition LocalAssociationDefinition
+	if err = json.Unmarshal(content, &definition); err != nil {
+		return nil, localDocument{}, fmt.Errorf("definition is not valid, %v", err)
+	}
+	if definition.AssociationID == "" {
+		definition.AssociationID = strings.TrimSuffix(fileName, localAssociationFileExtension)
+	}
+	if definition.Name == "" {
+		definition.Name = definition.AssociationID
+	}
+
+	var document localDocument
+	switch {
+	case len(definition.Document) > 0 && definition.DocumentPath != "":
+		return nil, localDocument{}, fmt.Errorf("definition has both Document and DocumentPath")
+	case len(definition.Document) > 0:
+		document.content = string(definition.Document)
+	case definition.DocumentPath != "":
+		documentPath := definition.DocumentPath
+		if !filepath.IsAbs(documentPath) {
+			documentPath = filepath.Join(s.associationDir, documentPath)
+		}
+		var documentContent []byte
+		if documentContent, document.err = ioutil.ReadFile(documentPath); document.err == nil {
+			document.content = string(documentContent)
+		}
+	default:
+		return nil, localDocument{}, fmt.Errorf("definition has neither Document nor DocumentPath")
+	}
+
+	// the checksum covers the document so changing either runs the association again
+	checksum := sha256.New()
+	checksum.Write(content)
+	checksum.Write([]byte(document.content))
+
+	parameters := make(map[string][]*string)
+	for name, values := range definition.Parameters {
+		parameters[name] = aws.StringSlice(values)
+	}
+
+	assoc := &model.InstanceAssociation{
+		CreateDate: time.Now().UTC(),
+		Association: &ssm.InstanceAssociationSummary{
+			AssociationId:   aws.String(definition.AssociationID),
+			Name:            aws.String(definition.Name),
+			InstanceId:      aws.String(instanceID),
+			DocumentVersion: aws.String(localDocumentVersion),
+			Checksum:        aws.String(hex.EncodeToString(checksum.Sum(nil))),
+			Parameters:      parameters,
+		},
+		ScheduleTimezone:      definition.ScheduleTimezone,
+		ScheduleSplay:         time.Duration(definition.ScheduleSplaySeconds) * time.Second,
+		IgnoreBlackoutWindows: definition.IgnoreBlackoutWindows,
+	}
+	if definition.ScheduleExpression != "" {
+		assoc.Association.ScheduleExpression = aws.String(definition.ScheduleExpression)
+	}
+	return assoc, document, nil
+}
+
+// LoadAssociationDetail sets the document read for the association
+func (s *LocalAssociationService) LoadAssociationDetail(log log.T, assoc *model.InstanceAssociation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	document, found := s.documents[*assoc.Association.AssociationId]
+	if !found {
+		return fmt.Errorf("local association %v is not defined", *assoc.Association.AssociationId)
+	}
+	if document.err != nil {
+		return fmt.Errorf("unable to read document of local association, %v", document.err)
+	}
+	assoc.Document = aws.String(document.content)
+	return nil
+}
+
+// UpdateAssociationStatus is only used with the legacy association api, which local associations don't use
+func (s *LocalAssociationService) UpdateAssociationStatus(
+	log log.T,
+	associationName string,
+	instanceID string,
+	status string,
+	executionSummary string) {
+	log.Debugf("Ignoring legacy status %v of local association %v", status, associationName)
+}
+
+// UpdateInstanceAssociationStatus appends the status to the execution history of the association
+// and keeps the status for the next time the associations are listed
+func (s *LocalAssociationService) UpdateInstanceAssociationStatus(
+	log log.T,
+	associationID string,
+	associationName string,
+	instanceID string,
+	status string,
+	errorCode string,
+	executionDate string,
+	executionSummary string,
+	outputUrl string) {
+
+	// Update status in schedulemanager to ensure state matches with the one of the local association
+	schedulemanager.UpdateAssociationStatus(associationID, status)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record := localExecutionRecord{
+		AssociationID:    associationID,
+		AssociationName:  associationName,
+		Status:           status,
+		ErrorCode:        errorCode,
+		ExecutionDate:    executionDate,
+		ExecutionSummary: executionSummary,
+	}
+	log.Infof("Updating local association %v status to %v", associationID, status)
+	if content, err := json.Marshal(record); err != nil {
+		log.Errorf("could not marshal local association status, %v", err)
+	} else if err = fileutil.MakeDirs(s.historyDir); err != nil {
+		log.Errorf("unable to create local association history directory %v, %v", s.historyDir, err)
+	} else if err = appendLine(filepath.Join(s.historyDir, associationID+".log"), content); err != nil {
+		log.Errorf("unable to write execution history of local association %v, %v", associationID, err)
+	}
+
+	states := s.loadStates(log, instanceID)
+	state := states[associationID]
+	state.DetailedStatus = status
+	if status != contracts.AssociationStatusPending {
+		state.LastExecutionDate = aws.Time(times.ParseIso8601UTC(executionDate))
+	}
+	if document, found := s.documents[associationID]; found {
+		state.Checksum = document.checksum
+	}
+	states[associationID] = state
+	s.saveStates(log, instanceID, states)
+}
+
+// appendLine appends a line to the file, which is created if needed
+func appendLine(fileName string, line []byte) error {
+	file, err := os.OpenFile(fileName, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// IsInstanceAssociationApiMode returns true as local associations are instance associations
+func (s *LocalAssociationService) IsInstanceAssociationApiMode() bool {
+	return true
+}
+
+// DescribeAssociation is only used with the legacy association api, which local associations don't use
+func (s *LocalAssociationService) DescribeAssociation(log log.T, instanceID string, docName string) (response *ssm.DescribeAssociationOutput, err error) {
+	return nil, fmt.Errorf("local associations can't be described")
+}
+
+// getStateFileName returns the full file name of the status of the local associations
+func (s *LocalAssociationService) getStateFileName(instanceID string) string {
+	return path.Join(s.dataStorePath,
+		instanceID,
+		appconfig.DefaultDocumentRootDirName,
+		appconfig.DefaultLocationOfAssociation,
+		localAssociationStateFileName)
+}
+
+// loadStates reads the status of the local associations, the caller holds the lock
+func (s *LocalAssociationService) loadStates(log log.T, instanceID string) map[string]localAssociationState {
+	states := make(map[string]localAssociationState)
+	fileName := s.getStateFileName(instanceID)
+	if !fileutil.Exists(fileName) {
+		return states
+	}
+	if err := jsonutil.UnmarshalFile(fileName, &states); err != nil {
+		log.Errorf("unable to read status of local associations, %v", err)
+		return make(map[string]localAssociationState)
+	}
+	return states
+}
+
+// saveStates writes the status of the local associations, the caller holds the lock
+func (s *LocalAssociationService) saveStates(log log.T, instanceID string, states map[string]localAssociationState) {
+	fileName := s.getStateFileName(instanceID)
+	content, err := jsonutil.Marshal(states)
+	if err != nil {
+		log.Errorf("could not marshal status of local associations, %v", err)
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(fileName)); err != nil {
+		log.Errorf("unable to write status of local associations, %v", err)
+		return
+	}
+	if _, err = fileutil.WriteIntoFileWithPermissions(fileName, content, os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
+		log.Errorf("unable to write status of local associations, %v", err)
+	}
+}
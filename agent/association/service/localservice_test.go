@@ -0,0 +1,153 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	inlineDefinition = `{
+		"Name": "Inline-Association",
+		"Document": {"schemaVersion": "2.2", "mainSteps": []},
+		"Parameters": {"commands": ["echo hello"]},
+		"ScheduleExpression": "cron(0 2 ? * SUN *)",
+		"ScheduleTimezone": "Europe/Paris",
+		"ScheduleSplaySeconds": 600
+	}`
+	pathDefinition        = `{"AssociationID": "path-id", "DocumentPath": "documents/document.json"}`
+	missingDocDefinition  = `{"DocumentPath": "documents/missing.json"}`
+	pathDocumentContent   = `{"schemaVersion": "2.2", "mainSteps": []}`
+	invalidDefinition     = `{"Document": `
+	noDocumentDefinition  = `{"Name": "No-Document"}`
+	localTestInstanceID   = "i-local"
+	localTestInlineID     = "inline"
+	localTestMissingDocID = "missing-document"
+)
+
+// newTestLocalService writes the definitions to a temporary local association directory
+func newTestLocalService(t *testing.T) (service *LocalAssociationService, dir string) {
+	dir, err := ioutil.TempDir("", "localassociations")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "documents"), 0700))
+
+	files := map[string]string{
+		localTestInlineID + ".json":     inlineDefinition,
+		"path.json":                     pathDefinition,
+		localTestMissingDocID + ".json": missingDocDefinition,
+		"invalid.json":                  invalidDefinition,
+		"nodocument.json":               noDocumentDefinition,
+		"README.txt":                    "not a definition",
+		"documents/document.json":       pathDocumentContent,
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	return &LocalAssociationService{
+		associationDir: dir,
+		historyDir:     filepath.Join(dir, "history"),
+		dataStorePath:  filepath.Join(dir, "datastore"),
+		documents:      make(map[string]localDocument),
+	}, dir
+}
+
+func findLocalAssociation(associations []*model.InstanceAssociation, associationID string) *model.InstanceAssociation {
+	for _, assoc := range associations {
+		if *assoc.Association.AssociationId == associationID {
+			return assoc
+		}
+	}
+	return nil
+}
+
+func TestLocalListInstanceAssociations(t *testing.T) {
+	service, dir := newTestLocalService(t)
+	defer os.RemoveAll(dir)
+
+	associations, err := service.ListInstanceAssociations(logMock, localTestInstanceID)
+	assert.NoError(t, err)
+	assert.Len(t, associations, 3)
+
+	inline := findLocalAssociation(associations, localTestInlineID)
+	assert.NotNil(t, inline)
+	assert.Equal(t, "Inline-Association", *inline.Association.Name)
+	assert.Equal(t, localTestInstanceID, *inline.Association.InstanceId)
+	assert.Equal(t, "cron(0 2 ? * SUN *)", *inline.Association.ScheduleExpression)
+	assert.Equal(t, "echo hello", *inline.Association.Parameters["commands"][0])
+	assert.Equal(t, contracts.AssociationStatusAssociated, *inline.Association.DetailedStatus)
+	assert.Nil(t, inline.Association.LastExecutionDate)
+	assert.Equal(t, "Europe/Paris", inline.ScheduleTimezone)
+	assert.Equal(t, 10*time.Minute, inline.ScheduleSplay)
+
+	path := findLocalAssociation(associations, "path-id")
+	assert.NotNil(t, path)
+	assert.Equal(t, "path-id", *path.Association.Name)
+	assert.True(t, path.IsRunOnceAssociation())
+	assert.NoError(t, service.LoadAssociationDetail(logMock, path))
+	assert.Equal(t, pathDocumentContent, *path.Document)
+
+	assert.NoError(t, service.LoadAssociationDetail(logMock, inline))
+	assert.Contains(t, *inline.Document, "mainSteps")
+
+	missing := findLocalAssociation(associations, localTestMissingDocID)
+	assert.NotNil(t, missing)
+	assert.Error(t, service.LoadAssociationDetail(logMock, missing))
+}
+
+func TestLocalUpdateInstanceAssociationStatus(t *testing.T) {
+	service, dir := newTestLocalService(t)
+	defer os.RemoveAll(dir)
+
+	_, err := service.ListInstanceAssociations(logMock, localTestInstanceID)
+	assert.NoError(t, err)
+
+	executionDate := time.Date(2019, 6, 5, 10, 0, 0, 0, time.UTC)
+	service.UpdateInstanceAssociationStatus(logMock, localTestInlineID, "Inline-Association", localTestInstanceID,
+		contracts.AssociationStatusInProgress, contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(executionDate), "Executing association", NoOutputUrl)
+	service.UpdateInstanceAssociationStatus(logMock, localTestInlineID, "Inline-Association", localTestInstanceID,
+		contracts.AssociationStatusSuccess, contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(executionDate), "1 out of 1 plugin processed", NoOutputUrl)
+
+	history, err := ioutil.ReadFile(filepath.Join(dir, "history", localTestInlineID+".log"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(history)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"Status":"Success"`)
+
+	// the status is kept for the next time the associations are listed
+	associations, err := service.ListInstanceAssociations(logMock, localTestInstanceID)
+	assert.NoError(t, err)
+	inline := findLocalAssociation(associations, localTestInlineID)
+	assert.Equal(t, contracts.AssociationStatusSuccess, *inline.Association.DetailedStatus)
+	assert.True(t, executionDate.Equal(*inline.Association.LastExecutionDate))
+
+	// a changed definition runs again
+	changedDefinition := strings.Replace(inlineDefinition, "echo hello", "echo bye", 1)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, localTestInlineID+".json"), []byte(changedDefinition), 0600))
+	associations, err = service.ListInstanceAssociations(logMock, localTestInstanceID)
+	assert.NoError(t, err)
+	inline = findLocalAssociation(associations, localTestInlineID)
+	assert.Equal(t, contracts.AssociationStatusAssociated, *inline.Association.DetailedStatus)
+	assert.Nil(t, inline.Association.LastExecutionDate)
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package blackout implements the blackout windows during which the agent defers scheduled work
+package blackout
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// timeOfDayLayout is the layout of the start and end times of weekday windows
+	timeOfDayLayout = "15:04"
+
+	// maxBlackoutDuration bounds the search for the end of windows which follow each other,
+	// so that windows which never end are checked again later
+	maxBlackoutDuration = 7 * 24 * time.Hour
+)
+
+// weekdays maps the accepted weekday names to the names used in cron expressions
+var weekdays = map[string]string{
+	"sun": "SUN", "sunday": "SUN",
+	"mon": "MON", "monday": "MON",
+	"tue": "TUE", "tuesday": "TUE",
+	"wed": "WED", "wednesday": "WED",
+	"thu": "THU", "thursday": "THU",
+	"fri": "FRI", "friday": "FRI",
+	"sat": "SAT", "saturday": "SAT",
+}
+
+// window is a parsed blackout window
+type window struct {
+	name     string
+	start    scheduleexpression.ScheduleExpression
+	duration time.Duration
+}
+
+// Schedule holds the blackout windows configured for the agent
+type Schedule struct {
+	windows            []window
+	exemptAssociations map[string]struct{}
+	applyToRunCommand  bool
+}
+
+// NewSchedule parses the blackout windows of the configuration, windows which are not valid are logged and ignored
+func NewSchedule(log log.T, config appconfig.BlackoutCfg) *Schedule {
+	schedule := &Schedule{
+		exemptAssociations: make(map[string]struct{}),
+		applyToRunCommand:  config.ApplyToRunCommand,
+	}
+	for i, windowConfig := range config.Windows {
+		if windowConfig.Name == "" {
+			windowConfig.Name = fmt.Sprintf("window %v", i+1)
+		}
+		parsedWindow, err := parseWindow(log, windowConfig)
+		if err != nil {
+			log.Errorf("Ignoring blackout window %v, %v", windowConfig.Name, err)
+			continue
+		}
+		schedule.windows = append(schedule.windows, parsedWindow)
+	}
+	for _, association := range config.ExemptAssociations {
+		schedule.exemptAssociations[association] = struct{}{}
+	}
+	return schedule
+}
+
+// parseWindow converts the configuration of a window to a start expression and a duration
+func parseWindow(log log.T, config appconfig.BlackoutWindow) (window, error) {
+	var (
+		cronExpression string
+		duration       time.Duration
+	)
+
+	switch {
+	case config.Cron != "" && (config.StartTime != "" || config.EndTime != "" || len(config.Weekdays) > 0):
+		return window{}, fmt.Errorf("window has both a cron expression and a time range")
+	case config.Cron != "":
+		if !strings.HasPrefix(strings.ToLower(config.Cron), "cron(") {
+			return window{}, fmt.Errorf("%v is not a cron expression", config.Cron)
+		}
+		if config.DurationMinutes <= 0 {
+			return window{}, fmt.Errorf("window with a cron expression needs a positive DurationMinutes")
+		}
+		cronExpression = config.Cron
+		duration = time.Duration(config.DurationMinutes) * time.Minute
+	default:
+		startTime, err := time.Parse(timeOfDayLayout, config.StartTime)
+		if err != nil {
+			return window{}, fmt.Errorf("StartTime %v is not a valid hh:mm time", config.StartTime)
+		}
+		endTime, err := time.Parse(timeOfDayLayout, config.EndTime)
+		if err != nil {
+			return window{}, fmt.Errorf("EndTime %v is not a valid hh:mm time", config.EndTime)
+		}
+		// windows which end before they start end on the next day
+		duration = endTime.Sub(startTime)
+		if duration <= 0 {
+			duration += 24 * time.Hour
+		}
+
+		days := "*"
+		if len(config.Weekdays) > 0 {
+			names := make([]string, 0, len(config.Weekdays))
+			for _, weekday := range config.Weekdays {
+				name, ok := weekdays[strings.ToLower(weekday)]
+				if !ok {
+					return window{}, fmt.Errorf("%v is not a weekday", weekday)
+				}
+				names = append(names, name)
+			}
+			days = strings.Join(names, ",")
+		}
+		cronExpression = fmt.Sprintf("cron(%d %d ? * %v *)", startTime.Minute(), startTime.Hour(), days)
+	}
+
+	start, err := scheduleexpression.CreateScheduleExpressionWithOptions(log, cronExpression, scheduleexpression.Options{Timezone: config.Timezone})
+	if err != nil {
+		return window{}, err
+	}
+	return window{name: config.Name, start: start, duration: duration}, nil
+}
+
+// coveringEnd returns the end of the occurrences of the window which cover t
+func (w window) coveringEnd(t time.Time) (end time.Time, covered bool) {
+	// the occurrences which cover t start after t minus the duration of the window
+	for start := w.start.Next(t.Add(-w.duration)); !start.IsZero() && !start.After(t); start = w.start.Next(start) {
+		end = start.Add(w.duration)
+		covered = true
+	}
+	return end, covered
+}
+
+// ActiveWindow returns the name of the window which covers t and the time at which the blackout ends,
+// windows which overlap or follow each other extend the blackout
+func (s *Schedule) ActiveWindow(t time.Time) (name string, end time.Time, active bool) {
+	end = t
+	for extended := true; extended && end.Sub(t) < maxBlackoutDuration; {
+		extended = false
+		for _, w := range s.windows {
+			if windowEnd, covered := w.coveringEnd(end); covered && windowEnd.After(end) {
+				if !active {
+					name = w.name
+					active = true
+				}
+				end = windowEnd
+				extended = true
+			}
+		}
+	}
+	return name, end, active
+}
+
+// IsExempt returns true if the association with the given id runs during blackout windows
+func (s *Schedule) IsExempt(associationID string) bool {
+	_, exempt := s.exemptAssociations[associationID]
+	return exempt
+}
+
+// AppliesToRunCommand returns true if the commands received during blackout windows are failed
+func (s *Schedule) AppliesToRunCommand() bool {
+	return s.applyToRunCommand
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package blackout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+func TestActiveWindow(t *testing.T) {
+	businessHours := appconfig.BlackoutWindow{
+		Name:      "business hours",
+		Weekdays:  []string{"Mon", "tuesday", "WED", "Thu", "Fri"},
+		StartTime: "09:00",
+		EndTime:   "17:00",
+		Timezone:  "America/New_York",
+	}
+	overnight := appconfig.BlackoutWindow{Name: "overnight", StartTime: "22:00", EndTime: "06:00"}
+	sundayMaintenance := appconfig.BlackoutWindow{Name: "sunday", Cron: "cron(0 2 ? * SUN *)", DurationMinutes: 120}
+	morning := appconfig.BlackoutWindow{Name: "morning", StartTime: "09:00", EndTime: "12:00"}
+	afternoon := appconfig.BlackoutWindow{Name: "afternoon", StartTime: "12:00", EndTime: "17:00"}
+
+	testCases := []struct {
+		name         string
+		windows      []appconfig.BlackoutWindow
+		time         time.Time
+		expectedName string
+		expectedEnd  time.Time
+		active       bool
+	}{
+		{"weekday in window", []appconfig.BlackoutWindow{businessHours}, time.Date(2019, 6, 5, 14, 0, 0, 0, time.UTC), "business hours", time.Date(2019, 6, 5, 21, 0, 0, 0, time.UTC), true},
+		{"weekday before window", []appconfig.BlackoutWindow{businessHours}, time.Date(2019, 6, 5, 12, 0, 0, 0, time.UTC), "", time.Time{}, false},
+		{"weekday at window end", []appconfig.BlackoutWindow{businessHours}, time.Date(2019, 6, 5, 21, 0, 0, 0, time.UTC), "", time.Time{}, false},
+		{"weekend", []appconfig.BlackoutWindow{businessHours}, time.Date(2019, 6, 8, 14, 0, 0, 0, time.UTC), "", time.Time{}, false},
+		{"overnight after midnight", []appconfig.BlackoutWindow{overnight}, time.Date(2019, 6, 5, 2, 0, 0, 0, time.UTC), "overnight", time.Date(2019, 6, 5, 6, 0, 0, 0, time.UTC), true},
+		{"overnight before midnight", []appconfig.BlackoutWindow{overnight}, time.Date(2019, 6, 5, 23, 0, 0, 0, time.UTC), "overnight", time.Date(2019, 6, 6, 6, 0, 0, 0, time.UTC), true},
+		{"cron in window", []appconfig.BlackoutWindow{sundayMaintenance}, time.Date(2019, 6, 9, 3, 0, 0, 0, time.UTC), "sunday", time.Date(2019, 6, 9, 4, 0, 0, 0, time.UTC), true},
+		{"cron after window", []appconfig.BlackoutWindow{sundayMaintenance}, time.Date(2019, 6, 9, 4, 30, 0, 0, time.UTC), "", time.Time{}, false},
+		{"adjacent windows", []appconfig.BlackoutWindow{afternoon, morning}, time.Date(2019, 6, 5, 10, 0, 0, 0, time.UTC), "morning", time.Date(2019, 6, 5, 17, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			schedule := NewSchedule(logger, appconfig.BlackoutCfg{Windows: testCase.windows})
+			assert.Len(t, schedule.windows, len(testCase.windows))
+
+			name, end, active := schedule.ActiveWindow(testCase.time)
+			assert.Equal(t, testCase.active, active)
+			if testCase.active {
+				assert.Equal(t, testCase.expectedName, name)
+				assert.True(t, testCase.expectedEnd.Equal(end), "expected %v, got %v", testCase.expectedEnd, end)
+			}
+		})
+	}
+}
+
+func TestActiveWindowNeverEnding(t *testing.T) {
+	always := appconfig.BlackoutWindow{Cron: "cron(0/5 * * * ? *)", DurationMinutes: 60}
+	schedule := NewSchedule(logger, appconfig.BlackoutCfg{Windows: []appconfig.BlackoutWindow{always}})
+	now := time.Date(2019, 6, 5, 10, 0, 0, 0, time.UTC)
+
+	name, end, active := schedule.ActiveWindow(now)
+	assert.True(t, active)
+	assert.Equal(t, "window 1", name)
+	assert.False(t, end.Before(now.Add(maxBlackoutDuration)))
+}
+
+func TestNewScheduleIgnoresInvalidWindows(t *testing.T) {
+	invalidWindows := []appconfig.BlackoutWindow{
+		{Name: "cron and range", Cron: "cron(0 2 ? * SUN *)", DurationMinutes: 60, StartTime: "09:00", EndTime: "10:00"},
+		{Name: "cron without duration", Cron: "cron(0 2 ? * SUN *)"},
+		{Name: "rate", Cron: "rate(30 minutes)", DurationMinutes: 60},
+		{Name: "invalid weekday", Weekdays: []string{"Funday"}, StartTime: "09:00", EndTime: "10:00"},
+		{Name: "invalid start", StartTime: "9am", EndTime: "10:00"},
+		{Name: "missing end", StartTime: "09:00"},
+		{Name: "invalid time zone", StartTime: "09:00", EndTime: "10:00", Timezone: "Mars/Olympus_Mons"},
+	}
+	valid := appconfig.BlackoutWindow{Name: "valid", StartTime: "09:00", EndTime: "10:00"}
+
+	schedule := NewSchedule(logger, appconfig.BlackoutCfg{Windows: append(invalidWindows, valid)})
+	assert.Len(t, schedule.windows, 1)
+	assert.Equal(t, "valid", schedule.windows[0].name)
+}
+
+func TestIsExempt(t *testing.T) {
+	schedule := NewSchedule(logger, appconfig.BlackoutCfg{ExemptAssociations: []string{"assoc-id"}})
+
+	assert.True(t, schedule.IsExempt("assoc-id"))
+	assert.False(t, schedule.IsExempt("other-id"))
+	assert.False(t, schedule.AppliesToRunCommand())
+}
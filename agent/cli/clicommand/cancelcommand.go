@@ -0,0 +1,196 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+)
+
+const (
+	cancelCommand          = "cancel-offline-command"
+	cancelCommandCommandID = "command-id"
+)
+
+const cancelCommandHelp = `NAME:
+    {{.CancelCommandName}}
+
+DESCRIPTION
+SYNOPSIS
+    {{.CancelCommandName}}
+    {{.CommandIdFlag}}
+
+PARAMETERS
+    {{.CommandIdFlag}} (string) Command ID from {{.SendCommandName}}.
+
+EXAMPLES
+    This example cancels a command run by the local amazon-ssm-agent service.
+
+    Command:
+
+      {{.SsmCliName}} {{.CancelCommandName}} {{.CommandIdFlag}} 01234567-890a-bcde-f012-34567890abcd
+
+    Output:
+
+      successfully cancelled command 01234567-890a-bcde-f012-34567890abcd
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin or the command is not running
+`
+
+type cancelCommandHelpParams struct {
+	SsmCliName        string
+	CancelCommandName string
+	SendCommandName   string
+	CommandIdFlag     string
+}
+
+func init() {
+	cliutil.Register(&CancelOfflineCommand{})
+}
+
+type CancelOfflineCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the cancel-offline-command cli command
+func (c *CancelOfflineCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, commandID := c.validateCancelCommandInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	if err := c.validateCommandState(commandID); err != nil {
+		return err, ""
+	}
+
+	content, err := jsonutil.Marshal(messageContracts.OfflineCancelPayload{CancelCommandID: commandID})
+	if err != nil {
+		return err, ""
+	}
+	if err, documentName := submitCommandDocument(content); err != nil {
+		return err, ""
+	} else if _, err := waitForSubmitStatus(documentName); err != nil {
+		return nil, fmt.Sprintf("failed to submit cancel request: %v", err)
+	}
+	return nil, c.waitForCancelStatus(commandID)
+}
+
+// Help prints help for the cancel-offline-command cli command
+func (c *CancelOfflineCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("CancelOfflineCommandHelp").Parse(cancelCommandHelp)
+		params := cancelCommandHelpParams{cliutil.SsmCliName, cancelCommand, sendCommand, cliutil.FormatFlag(cancelCommandCommandID)}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (CancelOfflineCommand) Name() string {
+	return cancelCommand
+}
+
+// validateCancelCommandInput checks the subcommands and parameters for required values, format, and unsupported values
+func (CancelOfflineCommand) validateCancelCommandInput(subcommands []string, parameters map[string][]string) (validation []string, commandID string) {
+	validation = make([]string, 0)
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", cancelCommand, subcommands), "")
+		return validation, "" // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	// look for required parameters
+	if _, exists := parameters[cancelCommandCommandID]; !exists {
+		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(cancelCommandCommandID)))
+	} else if len(parameters[cancelCommandCommandID]) != 1 {
+		validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v",
+			cliutil.FormatFlag(cancelCommandCommandID)))
+	} else {
+		// must be a 36 character UUID
+		commandID = parameters[cancelCommandCommandID][0]
+		if commandIdLen := len(commandID); commandIdLen != 36 {
+			validation = append(validation,
+				fmt.Sprintf("Invalid length for parameter %v.  Length was %v should be 36",
+					cliutil.FormatFlag(cancelCommandCommandID), commandIdLen))
+		}
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		if key != cancelCommandCommandID {
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation, commandID
+}
+
+// validateCommandState checks that the command was submitted and is still running
+func (CancelOfflineCommand) validateCommandState(commandID string) error {
+	commands := listOfflineCommands(appconfig.LocalCommandRoot,
+		appconfig.LocalCommandRootSubmitted,
+		appconfig.LocalCommandRootCompleted,
+		appconfig.LocalCommandRootInvalid)
+	for _, command := range commands {
+		if command.CommandId != commandID {
+			continue
+		}
+		if command.DocumentType != contracts.SendCommandOffline {
+			return fmt.Errorf("%v is the command id of a cancel request", commandID)
+		}
+		if command.State == commandStateInvalid {
+			return fmt.Errorf("Command %v was invalid and did not run", commandID)
+		}
+		if command.State == commandStateCompleted {
+			return fmt.Errorf("Command %v already completed with status %v", commandID, command.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("No submitted command found for command ID %v", commandID)
+}
+
+// waitForCancelStatus waits for the command to stop after the agent acknowledged the cancel request
+func (CancelOfflineCommand) waitForCancelStatus(commandID string) string {
+	var status contracts.ResultStatus
+	for i := 0; i < 10; i++ {
+		status, _ = getOfflineCommandResultStatus(appconfig.LocalCommandRootCompleted, commandID)
+		if isTerminalStatus(status) {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	switch {
+	case status == contracts.ResultStatusCancelled:
+		return fmt.Sprintf("successfully cancelled command %v", commandID)
+	case isTerminalStatus(status):
+		return fmt.Sprintf("cancel request acknowledged, command %v completed with status %v", commandID, status)
+	default:
+		return fmt.Sprintf("cancel request acknowledged, command %v is still stopping", commandID)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+const (
+	listCommands       = "list-offline-commands"
+	listCommandsOutput = "output"
+	outputText         = "text"
+	outputJson         = "json"
+)
+
+// states of the commands submitted to the local command folder
+const (
+	commandStatePending   = "Pending"
+	commandStateSubmitted = "Submitted"
+	commandStateCompleted = "Completed"
+	commandStateInvalid   = "Invalid"
+)
+
+const listCommandsHelp = `NAME:
+    {{.ListCommandsName}}
+
+DESCRIPTION
+SYNOPSIS
+    {{.ListCommandsName}}
+    [{{.OutputFlag}} <value>]
+
+PARAMETERS
+    {{.OutputFlag}} (string) Format of the output, either {{.OutputText}} (default) or {{.OutputJson}}.
+
+EXAMPLES
+    This example lists the commands submitted with {{.SendCommandName}} and {{.CancelCommandName}}.
+
+    Command:
+
+      {{.SsmCliName}} {{.ListCommandsName}}
+
+    Output:
+
+      COMMAND ID                            TYPE                STATE      STATUS    SUBMITTED             UPDATED
+      01234567-890a-bcde-f012-34567890abcd  SendCommandOffline  Completed  Success   2019-06-05T10:00:00Z  2019-06-05T10:00:03Z
+
+OUTPUT
+    The pending, submitted, completed and invalid commands with their status and timestamps
+`
+
+type listCommandsHelpParams struct {
+	SsmCliName        string
+	ListCommandsName  string
+	SendCommandName   string
+	CancelCommandName string
+	OutputFlag        string
+	OutputText        string
+	OutputJson        string
+}
+
+// offlineCommandInfo describes a command submitted to the local command folder
+type offlineCommandInfo struct {
+	CommandId     string                 `json:"CommandId,omitempty"`
+	DocumentName  string                 `json:"DocumentName"`
+	DocumentType  contracts.DocumentType `json:"DocumentType"`
+	State         string                 `json:"State"`
+	Status        contracts.ResultStatus `json:"Status,omitempty"`
+	SubmittedTime string                 `json:"SubmittedTime"`
+	UpdatedTime   string                 `json:"UpdatedTime,omitempty"`
+}
+
+func init() {
+	cliutil.Register(&ListOfflineCommands{})
+}
+
+type ListOfflineCommands struct {
+	helpText string
+}
+
+// Execute validates and executes the list-offline-commands cli command
+func (c *ListOfflineCommands) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, output := c.validateListCommandsInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	commands := listOfflineCommands(appconfig.LocalCommandRoot,
+		appconfig.LocalCommandRootSubmitted,
+		appconfig.LocalCommandRootCompleted,
+		appconfig.LocalCommandRootInvalid)
+	if output == outputJson {
+		if len(commands) == 0 {
+			return nil, "[]"
+		}
+		content, err := jsonutil.Marshal(commands)
+		if err != nil {
+			return err, ""
+		}
+		return nil, jsonutil.Indent(content)
+	}
+	return nil, formatOfflineCommands(commands)
+}
+
+// Help prints help for the list-offline-commands cli command
+func (c *ListOfflineCommands) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ListOfflineCommandsHelp").Parse(listCommandsHelp)
+		params := listCommandsHelpParams{cliutil.SsmCliName, listCommands, sendCommand, cancelCommand, cliutil.FormatFlag(listCommandsOutput), outputText, outputJson}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ListOfflineCommands) Name() string {
+	return listCommands
+}
+
+// validateListCommandsInput checks the subcommands and parameters for format and unsupported values
+func (ListOfflineCommands) validateListCommandsInput(subcommands []string, parameters map[string][]string) (validation []string, output string) {
+	validation = make([]string, 0)
+	output = outputText
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", listCommands, subcommands), "")
+		return validation, output // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	if values, exists := parameters[listCommandsOutput]; exists {
+		if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(listCommandsOutput)))
+		} else if output = strings.ToLower(values[0]); output != outputText && output != outputJson {
+			validation = append(validation, fmt.Sprintf("%v value must be %v or %v", cliutil.FormatFlag(listCommandsOutput), outputText, outputJson))
+		}
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		if key != listCommandsOutput {
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation, output
+}
+
+// listOfflineCommands looks for the commands in the local command folders, oldest first
+func listOfflineCommands(newDir, submittedDir, completedDir, invalidDir string) []offlineCommandInfo {
+	commands := make([]offlineCommandInfo, 0)
+
+	// documents which are not picked up by the agent yet have no command id
+	files, _ := fileutil.GetFileNames(newDir)
+	for _, file := range files {
+		commands = append(commands, offlineCommandInfo{
+			DocumentName:  file,
+			DocumentType:  getOfflineDocumentType(filepath.Join(newDir, file)),
+			State:         commandStatePending,
+			SubmittedTime: getModificationTime(filepath.Join(newDir, file)),
+		})
+	}
+
+	files, _ = fileutil.GetFileNames(submittedDir)
+	for _, file := range files {
+		documentName, commandId := splitProcessedDocumentName(file)
+		command := offlineCommandInfo{
+			CommandId:     commandId,
+			DocumentName:  documentName,
+			DocumentType:  getOfflineDocumentType(filepath.Join(submittedDir, file)),
+			State:         commandStateSubmitted,
+			SubmittedTime: getModificationTime(filepath.Join(submittedDir, file)),
+		}
+		if status, found := getOfflineCommandResultStatus(completedDir, commandId); found {
+			command.Status = status
+			command.UpdatedTime = getModificationTime(filepath.Join(completedDir, commandId))
+			if isTerminalStatus(status) {
+				command.State = commandStateCompleted
+			}
+		}
+		commands = append(commands, command)
+	}
+
+	files, _ = fileutil.GetFileNames(invalidDir)
+	for _, file := range files {
+		documentName, commandId := splitProcessedDocumentName(file)
+		commands = append(commands, offlineCommandInfo{
+			CommandId:     commandId,
+			DocumentName:  documentName,
+			DocumentType:  contracts.SendCommandOffline,
+			State:         commandStateInvalid,
+			SubmittedTime: getModificationTime(filepath.Join(invalidDir, file)),
+		})
+	}
+
+	// ISO 8601 UTC timestamps sort chronologically
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].SubmittedTime < commands[j].SubmittedTime
+	})
+	return commands
+}
+
+// formatOfflineCommands formats the commands as a table
+func formatOfflineCommands(commands []offlineCommandInfo) string {
+	if len(commands) == 0 {
+		return "No offline commands found"
+	}
+
+	buf := new(bytes.Buffer)
+	writer := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMAND ID\tTYPE\tSTATE\tSTATUS\tSUBMITTED\tUPDATED")
+	for _, command := range commands {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			valueOrDash(command.CommandId),
+			command.DocumentType,
+			command.State,
+			valueOrDash(string(command.Status)),
+			valueOrDash(command.SubmittedTime),
+			valueOrDash(command.UpdatedTime))
+	}
+	writer.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// splitProcessedDocumentName splits the name of a processed document into the document name and the command id suffix
+func splitProcessedDocumentName(file string) (documentName string, commandId string) {
+	if index := strings.LastIndex(file, "."); index >= 0 {
+		return file[:index], file[index+1:]
+	}
+	return file, ""
+}
+
+// getOfflineDocumentType tells apart the documents which cancel a command from the ones which run one
+func getOfflineDocumentType(documentPath string) contracts.DocumentType {
+	var payload messageContracts.OfflineCancelPayload
+	if err := jsonutil.UnmarshalFile(documentPath, &payload); err == nil && payload.CancelCommandID != "" {
+		return contracts.CancelCommandOffline
+	}
+	return contracts.SendCommandOffline
+}
+
+// getOfflineCommandResultStatus reads the status of the last reply the agent wrote for a command
+func getOfflineCommandResultStatus(completedDir string, commandId string) (status contracts.ResultStatus, found bool) {
+	if commandId == "" {
+		return "", false
+	}
+	var payload messageContracts.SendReplyPayload
+	if err := jsonutil.UnmarshalFile(filepath.Join(completedDir, commandId), &payload); err != nil {
+		return "", false
+	}
+	return payload.DocumentStatus, true
+}
+
+// isTerminalStatus returns true if the command does not run anymore
+func isTerminalStatus(status contracts.ResultStatus) bool {
+	return status != "" && status != contracts.ResultStatusNotStarted && status != contracts.ResultStatusInProgress
+}
+
+func getModificationTime(filePath string) string {
+	if modificationTime, err := fileutil.GetFileModificationTime(filePath); err == nil {
+		return times.ToIso8601UTC(modificationTime)
+	}
+	return ""
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCommandID       = "01234567-890a-bcde-f012-34567890abcd"
+	testCancelCommandID = "11234567-890a-bcde-f012-34567890abcd"
+	testInvalidID       = "21234567-890a-bcde-f012-34567890abcd"
+)
+
+func TestListOfflineCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localcommands")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	submittedDir := filepath.Join(dir, "submitted")
+	completedDir := filepath.Join(dir, "completed")
+	invalidDir := filepath.Join(dir, "invalid")
+	files := map[string]string{
+		filepath.Join(dir, "pending"):                                `{"schemaVersion": "2.0"}`,
+		filepath.Join(submittedDir, "command."+testCommandID):        `{"schemaVersion": "2.0"}`,
+		filepath.Join(submittedDir, "cancel."+testCancelCommandID):   `{"CancelCommandId": "` + testCommandID + `"}`,
+		filepath.Join(completedDir, testCommandID):                   `{"documentStatus": "Cancelled"}`,
+		filepath.Join(completedDir, testCancelCommandID):             `{"documentStatus": "InProgress"}`,
+		filepath.Join(invalidDir, "invalid.document."+testInvalidID): `not json`,
+	}
+	for path, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+
+	commands := listOfflineCommands(dir, submittedDir, completedDir, invalidDir)
+	assert.Len(t, commands, 4)
+
+	byState := make(map[string]offlineCommandInfo)
+	for _, command := range commands {
+		assert.NotEmpty(t, command.SubmittedTime)
+		byState[command.State+command.CommandId] = command
+	}
+
+	pending := byState[commandStatePending]
+	assert.Equal(t, "pending", pending.DocumentName)
+	assert.Equal(t, contracts.SendCommandOffline, pending.DocumentType)
+
+	completed := byState[commandStateCompleted+testCommandID]
+	assert.Equal(t, "command", completed.DocumentName)
+	assert.Equal(t, contracts.ResultStatusCancelled, completed.Status)
+	assert.NotEmpty(t, completed.UpdatedTime)
+
+	cancel := byState[commandStateSubmitted+testCancelCommandID]
+	assert.Equal(t, contracts.CancelCommandOffline, cancel.DocumentType)
+	assert.Equal(t, contracts.ResultStatusInProgress, cancel.Status)
+
+	invalid := byState[commandStateInvalid+testInvalidID]
+	assert.Equal(t, "invalid.document", invalid.DocumentName)
+
+	table := formatOfflineCommands(commands)
+	assert.Len(t, strings.Split(table, "\n"), 5)
+	assert.True(t, strings.HasPrefix(table, "COMMAND ID"))
+}
+
+func TestValidateListCommandsInput(t *testing.T) {
+	command := ListOfflineCommands{}
+
+	validation, output := command.validateListCommandsInput(nil, map[string][]string{})
+	assert.Empty(t, validation)
+	assert.Equal(t, outputText, output)
+
+	validation, output = command.validateListCommandsInput(nil, map[string][]string{listCommandsOutput: {"JSON"}})
+	assert.Empty(t, validation)
+	assert.Equal(t, outputJson, output)
+
+	validation, _ = command.validateListCommandsInput(nil, map[string][]string{listCommandsOutput: {"yaml"}, "foo": {}})
+	assert.Len(t, validation, 2)
+}
+
+func TestValidateCancelCommandInput(t *testing.T) {
+	command := CancelOfflineCommand{}
+
+	validation, commandID := command.validateCancelCommandInput(nil, map[string][]string{cancelCommandCommandID: {testCommandID}})
+	assert.Empty(t, validation)
+	assert.Equal(t, testCommandID, commandID)
+
+	validation, _ = command.validateCancelCommandInput(nil, map[string][]string{cancelCommandCommandID: {"short"}})
+	assert.Len(t, validation, 1)
+
+	validation, _ = command.validateCancelCommandInput(nil, map[string][]string{})
+	assert.Len(t, validation, 1)
+}
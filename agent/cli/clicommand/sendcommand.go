@@ -95,10 +95,12 @@ func (c *SendOfflineCommand) Execute(subcommands []string, parameters map[string
 		return err, ""
 	} else if contentString, err := jsonutil.Marshal(content); err != nil {
 		return err, ""
-	} else if err, documentName := c.submitCommandDocument(contentString); err != nil {
+	} else if err, documentName := submitCommandDocument(contentString); err != nil {
 		return err, ""
+	} else if commandId, err := waitForSubmitStatus(documentName); err != nil {
+		return nil, fmt.Sprintf("failed to submit document: %v", err)
 	} else {
-		return nil, c.waitForSubmitStatus(documentName)
+		return nil, fmt.Sprintf("successfully submitted with command id: %v", commandId)
 	}
 }
 
@@ -189,8 +191,8 @@ func (SendOfflineCommand) validateContent(content contracts.DocumentContent) err
 	return nil
 }
 
-// submitCommandDocument
-func (SendOfflineCommand) submitCommandDocument(content string) (error, string) {
+// submitCommandDocument writes a document to the local command folder for the agent to pick up
+func submitCommandDocument(content string) (error, string) {
 	documentName := uuid.NewV4().String()
 	documentPath := filepath.Join(appconfig.LocalCommandRoot, documentName)
 
@@ -202,30 +204,30 @@ func (SendOfflineCommand) submitCommandDocument(content string) (error, string)
 	return nil, documentName
 }
 
-// waitForSubmitStatus
-func (c *SendOfflineCommand) waitForSubmitStatus(documentName string) string {
+// waitForSubmitStatus waits for the agent to pick up a submitted document and returns the command id it was given
+func waitForSubmitStatus(documentName string) (commandId string, err error) {
 	for i := 0; i < 10; i++ {
-		if processed, commandId := c.isDocumentProcessed(documentName, appconfig.LocalCommandRootSubmitted); processed {
-			return fmt.Sprintf("successfully submitted with command id: %v", commandId)
+		if processed, commandId := isDocumentProcessed(documentName, appconfig.LocalCommandRootSubmitted); processed {
+			return commandId, nil
 		}
-		if processed, _ := c.isDocumentProcessed(documentName, appconfig.LocalCommandRootInvalid); processed {
-			return "failed to submit document: document was invalid"
+		if processed, _ := isDocumentProcessed(documentName, appconfig.LocalCommandRootInvalid); processed {
+			return "", errors.New("document was invalid")
 		}
 		time.Sleep(500 * time.Millisecond)
 	}
 	documentPath := filepath.Join(appconfig.LocalCommandRoot, documentName)
 	fileutil.DeleteFile(documentPath)
-	if processed, commandId := c.isDocumentProcessed(documentName, appconfig.LocalCommandRootSubmitted); processed {
-		return fmt.Sprintf("successfully submitted with command id: %v", commandId)
+	if processed, commandId := isDocumentProcessed(documentName, appconfig.LocalCommandRootSubmitted); processed {
+		return commandId, nil
 	}
-	if processed, _ := c.isDocumentProcessed(documentName, appconfig.LocalCommandRootInvalid); processed {
-		return "failed to submit document: document was invalid"
+	if processed, _ := isDocumentProcessed(documentName, appconfig.LocalCommandRootInvalid); processed {
+		return "", errors.New("document was invalid")
 	}
-	return "failed to submit document: timed out"
+	return "", errors.New("timed out")
 }
 
 // isDocumentProcessed checks for a document in the processed folder and returns the command id suffix
-func (SendOfflineCommand) isDocumentProcessed(documentName string, folder string) (bool, string) {
+func isDocumentProcessed(documentName string, folder string) (bool, string) {
 	files, _ := fileutil.GetFileNames(folder)
 	for _, file := range files {
 		if strings.HasPrefix(file, documentName) && strings.Contains(file, ".") {
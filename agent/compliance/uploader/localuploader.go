@@ -0,0 +1,68 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/compliance/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// LocalComplianceUploader writes the association compliance to a local file instead of uploading it
+type LocalComplianceUploader struct {
+	directory string
+}
+
+// NewLocalComplianceUploader returns an uploader which writes the association compliance to the local association directory
+func NewLocalComplianceUploader() *LocalComplianceUploader {
+	return &LocalComplianceUploader{directory: appconfig.LocalAssociationRootCompliance}
+}
+
+// CreateNewServiceIfUnHealthy does nothing as there is no service to reach
+func (u *LocalComplianceUploader) CreateNewServiceIfUnHealthy(log log.T) {}
+
+// UpdateAssociationCompliance writes the compliance of all the associations when the status of one is either SUCCESS / FAILED / TIMEDOUT
+func (u *LocalComplianceUploader) UpdateAssociationCompliance(associationID string, instanceID string, documentName string, documentVersion string, associationStatus string, executionTime time.Time) error {
+	if contracts.AssociationStatusTimedOut != associationStatus &&
+		contracts.AssociationStatusSuccess != associationStatus &&
+		contracts.AssociationStatusFailed != associationStatus {
+		return nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	model.UpdateAssociationComplianceItem(associationID, documentName, documentVersion, associationStatus, executionTime)
+	content, err := jsonutil.Marshal(model.GetAssociationComplianceEntries())
+	if err != nil {
+		return fmt.Errorf("Unable to update association compliance %v", err)
+	}
+	if err = fileutil.MakeDirs(u.directory); err != nil {
+		return fmt.Errorf("Unable to update association compliance %v", err)
+	}
+
+	fileName := filepath.Join(u.directory, AssociationComplianceItemName+".json")
+	if _, err = fileutil.WriteIntoFileWithPermissions(fileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
+		return fmt.Errorf("Unable to update association compliance %v", err)
+	}
+	return nil
+}
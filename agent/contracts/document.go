@@ -16,6 +16,8 @@ package contracts
 
 import (
 	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 )
 
 // DocumentType defines the type of document persists locally.
@@ -89,6 +91,10 @@ type IOConfiguration struct {
 	OutputS3BucketName     string
 	OutputS3KeyPrefix      string
 	CloudWatchConfig       CloudWatchConfiguration
+	// CommandID identifies the command or association execution in the output sinks
+	CommandID string
+	// OutputSinks are the additional destinations of the plugin output, without their credentials
+	OutputSinks []appconfig.OutputSinkCfg
 }
 
 // DocumentState represents information relevant to a command that gets executed by agent
@@ -155,6 +155,20 @@ const (
 	StopTypeHardStop StopType = "HardStop"
 )
 
+const (
+	// OnFailureAbort stops the execution of the remaining steps when a step fails
+	OnFailureAbort = "Abort"
+	// OnFailureContinue continues with the next step when a step fails
+	OnFailureContinue = "Continue"
+)
+
+const (
+	// StepOutputSourceStdout reads the output of a step from its standard output
+	StepOutputSourceStdout = "stdout"
+	// StepOutputSourceFile reads the output of a step from a JSON file written by the step
+	StepOutputSourceFile = "file"
+)
+
 // A Parameter in the DocumentContent of an MDS message.
 type Parameter struct {
 	DefaultVal     interface{} `json:"default" yaml:"default"`
@@ -173,14 +187,25 @@ type PluginConfig struct {
 
 // InstancePluginConfig stores plugin configuration
 type InstancePluginConfig struct {
-	Action        string              `json:"action" yaml:"action"` // plugin name
-	Inputs        interface{}         `json:"inputs" yaml:"inputs"` // Properties
-	MaxAttempts   int                 `json:"maxAttempts" yaml:"maxAttempts"`
-	Name          string              `json:"name" yaml:"name"` // unique identifier
-	OnFailure     string              `json:"onFailure" yaml:"onFailure"`
-	Settings      interface{}         `json:"settings" yaml:"settings"`
-	Timeout       int                 `json:"timeoutSeconds" yaml:"timeoutSeconds"`
-	Preconditions map[string][]string `json:"precondition" yaml:"precondition"`
+	Action        string                 `json:"action" yaml:"action"` // plugin name
+	Inputs        interface{}            `json:"inputs" yaml:"inputs"` // Properties
+	MaxAttempts   int                    `json:"maxAttempts" yaml:"maxAttempts"`
+	Name          string                 `json:"name" yaml:"name"` // unique identifier
+	OnFailure     string                 `json:"onFailure" yaml:"onFailure"`
+	Settings      interface{}            `json:"settings" yaml:"settings"`
+	Timeout       int                    `json:"timeoutSeconds" yaml:"timeoutSeconds"`
+	Preconditions map[string]interface{} `json:"precondition" yaml:"precondition"`
+	Outputs       []StepOutput           `json:"outputs" yaml:"outputs"`
+}
+
+// StepOutput declares a named output of a step which later steps reference as {{ steps.<name>.outputs.<key> }}.
+// The selector is a dot-separated path to the value in the JSON document, e.g. "instances.0.id".
+// Without a selector the output of the stdout source is the whole standard output.
+type StepOutput struct {
+	Name     string `json:"name" yaml:"name"`
+	Source   string `json:"source" yaml:"source"`
+	Path     string `json:"path" yaml:"path"`
+	Selector string `json:"selector" yaml:"selector"`
 }
 
 // DocumentContent object which represents ssm document content.
@@ -200,6 +225,10 @@ type SessionInputs struct {
 	CloudWatchLogGroupName      string `json:"cloudWatchLogGroupName" yaml:"cloudWatchLogGroupName"`
 	CloudWatchEncryptionEnabled bool   `json:"cloudWatchEncryptionEnabled" yaml:"cloudWatchEncryptionEnabled"`
 	KmsKeyId                    string `json:"kmsKeyId" yaml:"kmsKeyId"`
+	RunAsEnabled                bool   `json:"runAsEnabled" yaml:"runAsEnabled"`
+	RunAsDefaultUser            string `json:"runAsDefaultUser" yaml:"runAsDefaultUser"`
+	IdleSessionTimeout          string `json:"idleSessionTimeout" yaml:"idleSessionTimeout"`
+	MaxSessionDuration          string `json:"maxSessionDuration" yaml:"maxSessionDuration"`
 }
 
 // SessionDocumentContent object which represents ssm session content.
@@ -210,13 +239,14 @@ type SessionDocumentContent struct {
 	Inputs          SessionInputs         `json:"inputs" yaml:"inputs"`
 	Parameters      map[string]*Parameter `json:"parameters" yaml:"parameters"`
 	SessionCommands []*SessionCommand     `json:"sessionCommands" yaml:"sessionCommands"`
+	Properties      interface{}           `json:"properties" yaml:"properties"`
 }
 
 // SessionCommand object represents session manager commands with cross-platform preconditions.
 type SessionCommand struct {
-	Commands      string              `json:"commands" yaml:"commands"`
-	Preconditions map[string][]string `json:"precondition" yaml:"precondition"`
-	RunAsElevated bool                `json:"runAsElevated" yaml:"runAsElevated"`
+	Commands      string                 `json:"commands" yaml:"commands"`
+	Preconditions map[string]interface{} `json:"precondition" yaml:"precondition"`
+	RunAsElevated bool                   `json:"runAsElevated" yaml:"runAsElevated"`
 }
 
 // AdditionalInfo section in agent response
@@ -41,8 +41,14 @@ type PluginResult struct {
 	Error              string       `json:"error"`
 	StandardOutput     string       `json:"standardOutput"`
 	StandardError      string       `json:"standardError"`
+	FailureReason      string       `json:"failureReason,omitempty"`
+	// Outputs are the outputs the step declared, they are kept in the document state for the later steps
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
 }
 
+// FailureReasonOutOfMemory is the failure reason of plugins whose processes were killed for exceeding their memory limit
+const FailureReasonOutOfMemory = "OutOfMemory"
+
 // IPlugin is interface for authoring a functionality of work.
 // Every functionality of work is implemented as a plugin.
 type IPlugin interface {
@@ -79,7 +85,7 @@ type Configuration struct {
 	PluginName                  string
 	PluginID                    string
 	DefaultWorkingDirectory     string
-	Preconditions               map[string][]string
+	Preconditions               map[string]interface{}
 	IsPreconditionEnabled       bool
 	CurrentAssociations         []string
 	SessionId                   string
@@ -87,6 +93,15 @@ type Configuration struct {
 	KmsKeyId                    string
 	Commands                    string
 	RunAsElevated               bool
+	RunAsEnabled                bool
+	RunAsUser                   string
+	SessionOwner                string
+	IdleSessionTimeout          string
+	MaxSessionDuration          string
+	MaxAttempts                 int
+	OnFailure                   string
+	Timeout                     int
+	Outputs                     []StepOutput
 }
 
 // Plugin wraps the plugin configuration and plugin result.
@@ -43,6 +43,8 @@ type DocumentParserInfo struct {
 	DocumentId        string
 	DefaultWorkingDir string
 	CloudWatchConfig  contracts.CloudWatchConfiguration
+	SessionOwner      string
+	RunAsUser         string
 }
 
 // InitializeDocState is a method to obtain the state of the document.
@@ -84,12 +86,21 @@ func (docContent *DocContent) GetSchemaVersion() string {
 
 // GetIOConfiguration is a method used to get IO config from the document
 func (docContent *DocContent) GetIOConfiguration(parserInfo DocumentParserInfo) contracts.IOConfiguration {
-	return contracts.IOConfiguration{
+	ioConfig := contracts.IOConfiguration{
 		OrchestrationDirectory: parserInfo.OrchestrationDir,
 		OutputS3BucketName:     parserInfo.S3Bucket,
 		OutputS3KeyPrefix:      parserInfo.S3Prefix,
 		CloudWatchConfig:       parserInfo.CloudWatchConfig,
+		CommandID:              parserInfo.DocumentId,
+	}
+	// the output sinks are configured for the whole agent, the io configuration is persisted with the document state
+	// so the credentials of the sinks are left out and resolved again when the output is sent
+	if config, err := appconfig.Config(false); err == nil {
+		for _, sink := range config.OutputSinks {
+			ioConfig.OutputSinks = append(ioConfig.OutputSinks, sink.WithoutSecrets())
+		}
 	}
+	return ioConfig
 }
 
 // ParseDocument is a method used to parse documents that are not received by any service (MDS or State manager)
@@ -197,6 +208,16 @@ func replaceValidatedSessionParameters(
 		docContent.SessionCommands = resolvedSessionCommands
 	}
 
+	if docContent.Properties != nil {
+		resolvedProperties := parameters.ReplaceParameters(docContent.Properties, params, logger)
+
+		// Resolve SSM Parameters
+		if resolvedProperties, err = parameterstore.Resolve(logger, resolvedProperties); err != nil {
+			return err
+		}
+		docContent.Properties = resolvedProperties
+	}
+
 	inputs := docContent.Inputs
 	var rawData map[string]interface{}
 	if err = jsonutil.Remarshal(inputs, &rawData); err != nil {
@@ -318,6 +339,12 @@ func parsePluginStateForV20Schema(
 	// getPluginConfigurations converts from PluginConfig (structure from the MDS message) to plugin.Configuration (structure expected by the plugin)
 	for _, instancePluginConfig := range docContent.MainSteps {
 		pluginName := instancePluginConfig.Action
+		if err = validateOnFailure(instancePluginConfig.OnFailure); err != nil {
+			return pluginsInfo, err
+		}
+		if err = validateOutputs(instancePluginConfig.Outputs); err != nil {
+			return pluginsInfo, fmt.Errorf("Invalid outputs of step %v: %v", instancePluginConfig.Name, err)
+		}
 		config := contracts.Configuration{
 			Settings:                instancePluginConfig.Settings,
 			Properties:              instancePluginConfig.Inputs,
@@ -331,6 +358,10 @@ func parsePluginStateForV20Schema(
 			Preconditions:           instancePluginConfig.Preconditions,
 			IsPreconditionEnabled:   isPreconditionEnabled,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			MaxAttempts:             instancePluginConfig.MaxAttempts,
+			OnFailure:               instancePluginConfig.OnFailure,
+			Timeout:                 instancePluginConfig.Timeout,
+			Outputs:                 instancePluginConfig.Outputs,
 		}
 
 		var plugin contracts.PluginState
@@ -342,6 +373,43 @@ func parsePluginStateForV20Schema(
 	return
 }
 
+// validateOnFailure checks that the onFailure value of a step is one of the supported values
+func validateOnFailure(onFailure string) error {
+	switch {
+	case onFailure == "",
+		strings.EqualFold(onFailure, contracts.OnFailureAbort),
+		strings.EqualFold(onFailure, contracts.OnFailureContinue):
+		return nil
+	}
+	return fmt.Errorf("Invalid onFailure value %v, supported values are %v and %v", onFailure, contracts.OnFailureAbort, contracts.OnFailureContinue)
+}
+
+// validateOutputs checks that the outputs of a step have unique valid names and a supported source
+func validateOutputs(outputs []contracts.StepOutput) error {
+	names := make(map[string]bool)
+	for _, output := range outputs {
+		if !parameters.ValidStepOutputName(output.Name) {
+			return fmt.Errorf("invalid output name %q", output.Name)
+		}
+		if names[output.Name] {
+			return fmt.Errorf("duplicate output name %v", output.Name)
+		}
+		names[output.Name] = true
+
+		switch {
+		case output.Source == "", strings.EqualFold(output.Source, contracts.StepOutputSourceStdout):
+		case strings.EqualFold(output.Source, contracts.StepOutputSourceFile):
+			if output.Path == "" {
+				return fmt.Errorf("output %v has no path", output.Name)
+			}
+		default:
+			return fmt.Errorf("output %v has an invalid source %v, supported sources are %v and %v",
+				output.Name, output.Source, contracts.StepOutputSourceStdout, contracts.StepOutputSourceFile)
+		}
+	}
+	return nil
+}
+
 // parsePluginStateForStartSession initializes instancePluginsInfo for the docState. Used by startSession.
 func (sessionDocContent *SessionDocContent) parsePluginStateForStartSession(
 	parserInfo DocumentParserInfo,
@@ -350,6 +418,12 @@ func (sessionDocContent *SessionDocContent) parsePluginStateForStartSession(
 
 	// getPluginConfigurations converts from PluginConfig (structure from the MGS message) to plugin.Configuration (structure expected by the plugin)
 	pluginName := sessionDocContent.SessionType
+
+	// the user sent with the session takes precedence over the default user of the session document
+	runAsUser := parserInfo.RunAsUser
+	if runAsUser == "" {
+		runAsUser = sessionDocContent.Inputs.RunAsDefaultUser
+	}
 	if len(sessionDocContent.SessionCommands) > 0 {
 		for _, sessionCommandConfig := range sessionDocContent.SessionCommands {
 			config := contracts.Configuration{
@@ -367,10 +441,16 @@ func (sessionDocContent *SessionDocContent) parsePluginStateForStartSession(
 				CloudWatchLogGroup:          sessionDocContent.Inputs.CloudWatchLogGroupName,
 				CloudWatchEncryptionEnabled: sessionDocContent.Inputs.CloudWatchEncryptionEnabled,
 				KmsKeyId:                    sessionDocContent.Inputs.KmsKeyId,
+				RunAsEnabled:                sessionDocContent.Inputs.RunAsEnabled,
+				RunAsUser:                   runAsUser,
+				SessionOwner:                parserInfo.SessionOwner,
+				IdleSessionTimeout:          sessionDocContent.Inputs.IdleSessionTimeout,
+				MaxSessionDuration:          sessionDocContent.Inputs.MaxSessionDuration,
 				Commands:                    sessionCommandConfig.Commands,
 				IsPreconditionEnabled:       true,
 				Preconditions:               sessionCommandConfig.Preconditions,
 				RunAsElevated:               sessionCommandConfig.RunAsElevated,
+				Properties:                  sessionDocContent.Properties,
 			}
 
 			var plugin contracts.PluginState
@@ -395,6 +475,12 @@ func (sessionDocContent *SessionDocContent) parsePluginStateForStartSession(
 			CloudWatchLogGroup:          sessionDocContent.Inputs.CloudWatchLogGroupName,
 			CloudWatchEncryptionEnabled: sessionDocContent.Inputs.CloudWatchEncryptionEnabled,
 			KmsKeyId:                    sessionDocContent.Inputs.KmsKeyId,
+			RunAsEnabled:                sessionDocContent.Inputs.RunAsEnabled,
+			RunAsUser:                   runAsUser,
+			SessionOwner:                parserInfo.SessionOwner,
+			IdleSessionTimeout:          sessionDocContent.Inputs.IdleSessionTimeout,
+			MaxSessionDuration:          sessionDocContent.Inputs.MaxSessionDuration,
+			Properties:                  sessionDocContent.Properties,
 		}
 
 		var plugin contracts.PluginState
@@ -119,6 +119,83 @@ func TestParseDocument_ValidMainSteps(t *testing.T) {
 	assert.Equal(t, testWorkingDir, pluginInfoTest.Configuration.DefaultWorkingDirectory)
 }
 
+func TestParseDocument_StepExecutionSettings(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir: testOrchDir,
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+	}
+	testDocContent := DocContent{
+		SchemaVersion: "2.2",
+		MainSteps: []*contracts.InstancePluginConfig{
+			{
+				Action:      "aws:runShellScript",
+				Name:        "test",
+				MaxAttempts: 3,
+				OnFailure:   "Abort",
+				Timeout:     60,
+			},
+		},
+	}
+
+	pluginsInfo, err := testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	assert.Equal(t, 3, pluginsInfo[0].Configuration.MaxAttempts)
+	assert.Equal(t, contracts.OnFailureAbort, pluginsInfo[0].Configuration.OnFailure)
+	assert.Equal(t, 60, pluginsInfo[0].Configuration.Timeout)
+
+	testDocContent.MainSteps[0].OnFailure = "Retry"
+	_, err = testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid onFailure value")
+}
+
+func TestParseDocument_StepOutputs(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir: testOrchDir,
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+	}
+	outputs := []contracts.StepOutput{
+		{Name: "instanceId", Selector: "instances.0.id"},
+		{Name: "config", Source: "File", Path: "/tmp/config.json"},
+	}
+	testDocContent := DocContent{
+		SchemaVersion: "2.2",
+		MainSteps: []*contracts.InstancePluginConfig{
+			{
+				Action:  "aws:runShellScript",
+				Name:    "test",
+				Outputs: outputs,
+			},
+		},
+	}
+
+	pluginsInfo, err := testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, outputs, pluginsInfo[0].Configuration.Outputs)
+
+	invalidOutputs := [][]contracts.StepOutput{
+		{{Name: "instance.id"}},
+		{{Name: "id"}, {Name: "id"}},
+		{{Name: "id", Source: "file"}},
+		{{Name: "id", Source: "stderr"}},
+	}
+	for _, invalid := range invalidOutputs {
+		testDocContent.MainSteps[0].Outputs = invalid
+		_, err = testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid outputs of step test")
+	}
+}
+
 func TestInitializeDocState_Valid(t *testing.T) {
 	mockLog := log.NewMockLog()
 
@@ -268,6 +345,48 @@ func TestInitializeDocStateForStartSessionDocumentWithoutSessionCommands_Valid(t
 	assert.False(t, pluginInfo[0].Configuration.RunAsElevated)
 }
 
+func TestInitializeDocStateForStartSessionDocumentWithRunAs_Valid(t *testing.T) {
+	mockLog := log.NewMockLog()
+
+	sessionDocContent := &SessionDocContent{
+		SchemaVersion: "1.0",
+		Inputs:        contracts.SessionInputs{RunAsEnabled: true, RunAsDefaultUser: "ec2-user"},
+		SessionType:   appconfig.PluginNameStandardStream,
+	}
+
+	// the default user of the document is used when the session doesn't specify a user
+	testParserInfo := DocumentParserInfo{
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+		OrchestrationDir: testOrchDir,
+		SessionOwner:     "arn:aws:iam::123456789012:role/Deployer",
+	}
+	docState, err := InitializeDocState(mockLog,
+		contracts.StartSession,
+		sessionDocContent,
+		contracts.DocumentInfo{DocumentID: testSessionId, ClientId: testClientId},
+		testParserInfo,
+		nil)
+
+	assert.Nil(t, err)
+	config := docState.InstancePluginsInformation[0].Configuration
+	assert.True(t, config.RunAsEnabled)
+	assert.Equal(t, "ec2-user", config.RunAsUser)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/Deployer", config.SessionOwner)
+
+	// the user of the session takes precedence over the default user of the document
+	testParserInfo.RunAsUser = "deploy"
+	docState, err = InitializeDocState(mockLog,
+		contracts.StartSession,
+		sessionDocContent,
+		contracts.DocumentInfo{DocumentID: testSessionId, ClientId: testClientId},
+		testParserInfo,
+		nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "deploy", docState.InstancePluginsInformation[0].Configuration.RunAsUser)
+}
+
 func TestInitializeDocStateForStartSessionDocumentWithParameters_Valid(t *testing.T) {
 	mockLog := log.NewMockLog()
 
@@ -340,6 +459,46 @@ func TestInitializeDocStateForStartSessionDocumentWithParameters_Valid(t *testin
 	assert.True(t, pluginInfo[0].Configuration.RunAsElevated)
 }
 
+func TestInitializeDocStateForStartSessionDocumentWithProperties_Valid(t *testing.T) {
+	mockLog := log.NewMockLog()
+
+	testParserInfo := DocumentParserInfo{
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+		OrchestrationDir: testOrchDir,
+	}
+
+	portNumber := contracts.Parameter{
+		DefaultVal: "80",
+		ParamType:  "String",
+	}
+
+	parameters := map[string]*contracts.Parameter{
+		"portNumber": &portNumber,
+	}
+
+	sessionDocContent := &SessionDocContent{
+		SchemaVersion: "1.0",
+		Parameters:    parameters,
+		SessionType:   appconfig.PluginNamePort,
+		Properties:    map[string]interface{}{"portNumber": "{{ portNumber }}"},
+	}
+
+	docState, err := InitializeDocState(mockLog,
+		contracts.StartSession,
+		sessionDocContent,
+		contracts.DocumentInfo{DocumentID: testSessionId, ClientId: testClientId},
+		testParserInfo,
+		map[string]interface{}{"portNumber": "3306"})
+
+	assert.Nil(t, err)
+
+	pluginInfo := docState.InstancePluginsInformation
+	assert.Equal(t, 1, len(pluginInfo))
+	assert.Equal(t, appconfig.PluginNamePort, pluginInfo[0].Name)
+	assert.Equal(t, map[string]interface{}{"portNumber": "3306"}, pluginInfo[0].Configuration.Properties)
+}
+
 func TestParseDocument_EmptyDocContent(t *testing.T) {
 	mockLog := log.NewMockLog()
 	testParserInfo := DocumentParserInfo{
@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -43,9 +44,45 @@ type T interface {
 	//TODO: Remove Execute and rename NewExecute to Execute.
 	Execute(log.T, string, string, string, task.CancelFlag, int, string, []string) (io.Reader, io.Reader, int, []error)
 	NewExecute(log.T, string, io.Writer, io.Writer, task.CancelFlag, int, string, []string) (int, error)
+	NewExecuteWithOptions(log.T, string, io.Writer, io.Writer, task.CancelFlag, int, string, []string, ExecuteOptions) (int, error)
 	StartExe(log.T, string, io.Writer, io.Writer, task.CancelFlag, string, []string) (*os.Process, int, error)
 }
 
+// ExecuteOptions are the optional settings of the process executing a command
+type ExecuteOptions struct {
+	// Environment holds variables added to the environment of the process, the values are never logged
+	Environment map[string]string
+	// RunAsUser and RunAsGroup are the user and group the process runs as instead of the agent's, a group requires a user
+	RunAsUser  string
+	RunAsGroup string
+	// TerminationGracePeriod is how long a cancelled or timed out process has to exit after it is asked to terminate,
+	// the process is killed right away when it is zero
+	TerminationGracePeriod time.Duration
+	// ResourceLimits are enforced by placing the process in a dedicated control group, only linux supports them
+	ResourceLimits appconfig.ResourceLimitsCfg
+}
+
+// String describes the options without the values of the environment variables
+func (o ExecuteOptions) String() string {
+	names := make([]string, 0, len(o.Environment))
+	for name := range o.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("{Environment:%v RunAsUser:%v RunAsGroup:%v TerminationGracePeriod:%v ResourceLimits:%+v}",
+		names, o.RunAsUser, o.RunAsGroup, o.TerminationGracePeriod, o.ResourceLimits)
+}
+
+// OutOfMemoryError is returned when a process was killed because it exceeded its memory limit
+type OutOfMemoryError struct {
+	// Err is the error returned by the process
+	Err error
+}
+
+func (e *OutOfMemoryError) Error() string {
+	return fmt.Sprintf("process was killed because it exceeded its memory limit: %v", e.Err)
+}
+
 // ShellCommandExecuter is specially added for testing purposes
 type ShellCommandExecuter struct {
 }
@@ -173,6 +210,22 @@ func (ShellCommandExecuter) NewExecute(
 	return
 }
 
+// NewExecuteWithOptions executes a list of shell commands in the given working directory with the given process options.
+func (ShellCommandExecuter) NewExecuteWithOptions(
+	log log.T,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	cancelFlag task.CancelFlag,
+	executionTimeout int,
+	commandName string,
+	commandArguments []string,
+	options ExecuteOptions,
+) (exitCode int, err error) {
+	exitCode, err = ExecuteCommandWithOptions(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments, options)
+	return
+}
+
 // StartExe starts a list of shell commands in the given working directory.
 // Returns process started, an exit code (0 if successfully launch, 1 if error launching process), and a set of errors.
 // The errors need not be fatal - the output streams may still have data
@@ -254,6 +307,21 @@ func ExecuteCommand(log log.T,
 	commandName string,
 	commandArguments []string,
 ) (exitCode int, err error) {
+	return ExecuteCommandWithOptions(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments, ExecuteOptions{})
+}
+
+// ExecuteCommandWithOptions executes the given commands using the given working directory and process options.
+// Standard output and standard error are sent to the given writers.
+func ExecuteCommandWithOptions(log log.T,
+	cancelFlag task.CancelFlag,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	executionTimeout int,
+	commandName string,
+	commandArguments []string,
+	options ExecuteOptions,
+) (exitCode int, err error) {
 
 	stdoutInterruptable, stopStdout := newWriter(stdoutWriter)
 	stderrInterruptable, stopStderr := newWriter(stderrWriter)
@@ -284,9 +352,35 @@ func ExecuteCommand(log log.T,
 
 	// configure environment variables
 	prepareEnvironment(command)
+	appendEnvironment(command, options.Environment)
+
+	// configure the user and group of the process
+	if err = prepareRunAs(command, options.RunAsUser, options.RunAsGroup); err != nil {
+		log.Error("error occurred preparing the user of the command ", err)
+		exitCode = 1
+		return
+	}
+
+	// limit the resources of the process and its sub processes
+	var cgroup *processCgroup
+	var gate *commandGate
+	if !options.ResourceLimits.IsUnlimited() {
+		if cgroup, err = newProcessCgroup(options.ResourceLimits); err != nil {
+			log.Error("error occurred preparing the resource limits of the command ", err)
+			exitCode = 1
+			return
+		}
+		defer cgroup.remove(log)
+		if gate, err = newCommandGate(command); err != nil {
+			log.Error("error occurred preparing the resource limits of the command ", err)
+			exitCode = 1
+			return
+		}
+		defer gate.close()
+	}
 
 	log.Debug()
-	log.Debugf("Running in directory %v, command: %v %v", workingDir, commandName, commandArguments)
+	log.Debugf("Running in directory %v, command: %v %v, options: %v", workingDir, commandName, commandArguments, options)
 	log.Debug()
 	if err = command.Start(); err != nil {
 		log.Error("error occurred starting the command", err)
@@ -294,6 +388,19 @@ func ExecuteCommand(log log.T,
 		return
 	}
 
+	if cgroup != nil {
+		if err = cgroup.addProcess(command.Process.Pid); err == nil {
+			err = gate.open()
+		}
+		if err != nil {
+			log.Error("error occurred applying the resource limits to the command ", err)
+			killProcess(command.Process, &timeoutSignal{})
+			command.Wait()
+			exitCode = 1
+			return
+		}
+	}
+
 	signal := timeoutSignal{}
 
 	cancelled := make(chan bool, 1)
@@ -313,30 +420,28 @@ func ExecuteCommand(log log.T,
 
 	select {
 	case <-time.After(time.Duration(executionTimeout) * time.Second):
-		stopStdout <- true
-		stopStderr <- true
-		if err = killProcess(command.Process, &signal); err != nil {
+		var signalName string
+		if signalName, err = stopCommand(log, command, done, options.TerminationGracePeriod, stopStdout, stopStderr, &signal); err != nil {
 			exitCode = 1
 			log.Error(err)
 		} else {
 			// set appropriate exit code based on timeout
 			exitCode = appconfig.CommandStoppedPreemptivelyExitCode
-			err = &exec.ExitError{Stderr: []byte("Process timed out")}
-			log.Infof("The execution of command was timedout.")
+			err = &exec.ExitError{Stderr: []byte(fmt.Sprintf("Process timed out, stopped with %v", signalName))}
+			log.Infof("The execution of command was timedout and stopped with %v.", signalName)
 		}
 	case <-cancelled:
-		// task has been asked to cancel, kill process
+		// task has been asked to cancel, stop process
 		log.Debug("Process cancelled. Attempting to stop process.")
-		stopStdout <- true
-		stopStderr <- true
-		if err = killProcess(command.Process, &signal); err != nil {
+		var signalName string
+		if signalName, err = stopCommand(log, command, done, options.TerminationGracePeriod, stopStdout, stopStderr, &signal); err != nil {
 			exitCode = 1
 			log.Error(err)
 		} else {
 			// set appropriate exit code based on cancel
 			exitCode = appconfig.CommandStoppedPreemptivelyExitCode
-			err = &exec.ExitError{Stderr: []byte("Cancelled process")}
-			log.Infof("The execution of command was cancelled.")
+			err = &exec.ExitError{Stderr: []byte(fmt.Sprintf("Cancelled process, stopped with %v", signalName))}
+			log.Infof("The execution of command was cancelled and stopped with %v.", signalName)
 		}
 	case err = <-done:
 		log.Debug("Process completed.")
@@ -375,6 +480,10 @@ func ExecuteCommand(log log.T,
 				// do not return as the command could have been cancelled and also timedout
 			}
 		}
+		if err != nil && cgroup != nil && cgroup.oomKilled() {
+			log.Info("The process was killed because it exceeded its memory limit.")
+			err = &OutOfMemoryError{Err: err}
+		}
 	}
 	return
 }
@@ -417,34 +526,92 @@ func StartCommand(log log.T,
 	// the writer when it is a file handle and when the cancellable writer is assigned, it doesn't (by design) give
 	// a reference to the file handle to the process
 	cancelChannel := make(chan bool, 2)
-	go killProcessOnCancel(log, command, cancelChannel, cancelChannel, cancelFlag, &signal)
+	// waiting for the process releases it once it exits, and lets a cancellation skip the kill after it exited
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+	go killProcessOnCancel(log, command, exited, cancelChannel, cancelChannel, cancelFlag, configuredTerminationGracePeriod(log), &signal)
 
 	return
 }
 
+// configuredTerminationGracePeriod returns the termination grace period of the agent configuration
+func configuredTerminationGracePeriod(log log.T) time.Duration {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		log.Warnf("Failed to load the agent configuration, using the default termination grace period: %v", err)
+		return time.Duration(appconfig.DefaultTerminationGracePeriodSeconds) * time.Second
+	}
+	return time.Duration(config.RunScript.TerminationGracePeriodSeconds) * time.Second
+}
+
 // killProcessOnCancel waits for a cancel request.
-// If a cancel request is received, this method kills the underlying
-// process of the command. This will unblock the command.Wait() call.
-// If the task completed successfully this method returns with no action.
-func killProcessOnCancel(log log.T, command *exec.Cmd, cancelStdout chan bool, cancelStderr chan bool, cancelFlag task.CancelFlag, signal *timeoutSignal) {
+// If a cancel request is received, this method asks the underlying process of the command
+// to terminate and kills it when it is still running after the grace period.
+// If the task completed successfully or the process already exited this method returns with no action.
+func killProcessOnCancel(log log.T, command *exec.Cmd, exited <-chan error, cancelStdout chan bool, cancelStderr chan bool, cancelFlag task.CancelFlag, gracePeriod time.Duration, signal *timeoutSignal) {
 	cancelFlag.Wait()
 	if cancelFlag.Canceled() {
+		select {
+		case <-exited:
+			log.Debug("Process cancelled after it exited.")
+			return
+		default:
+		}
 		log.Debug("Process cancelled. Attempting to stop process.")
 
-		cancelStdout <- true
-		cancelStderr <- true
-		runtime.Gosched()
-
-		// task has been asked to cancel, kill process
-		if err := killProcess(command.Process, signal); err != nil {
+		if signalName, err := stopCommand(log, command, exited, gracePeriod, cancelStdout, cancelStderr, signal); err != nil {
 			log.Error(err)
 		} else {
-			log.Debug("Process stopped successfully.")
+			log.Debugf("Process stopped successfully with %v.", signalName)
 		}
 		return
 	}
 }
 
+// stopCommand stops the process of a cancelled or timed out command.
+// When a grace period is given, the process group is asked to terminate first and is killed
+// only if the process doesn't exit within the grace period. exited receives the result of
+// waiting for the process.
+// The output writers are disconnected before the process is killed.
+// Returns the name of the signal which ended the process, along with the reason when the process couldn't be asked
+// to terminate.
+func stopCommand(log log.T,
+	command *exec.Cmd,
+	exited <-chan error,
+	gracePeriod time.Duration,
+	stopStdout chan bool,
+	stopStderr chan bool,
+	signal *timeoutSignal,
+) (signalName string, err error) {
+	exitedGracefully := false
+	terminateFailure := ""
+	if gracePeriod > 0 {
+		if err = terminateProcess(command.Process); err != nil {
+			log.Infof("Failed to send %v to the process, killing it: %v", terminateSignalName, err)
+			terminateFailure = fmt.Sprintf(" since %v failed: %v", terminateSignalName, err)
+		} else {
+			log.Debugf("Sent %v to the process, waiting up to %v for it to exit", terminateSignalName, gracePeriod)
+			select {
+			case <-exited:
+				exitedGracefully = true
+			case <-time.After(gracePeriod):
+				log.Infof("The process did not exit within %v after %v, killing it", gracePeriod, terminateSignalName)
+			}
+		}
+	}
+
+	stopStdout <- true
+	stopStderr <- true
+	runtime.Gosched()
+
+	if exitedGracefully {
+		return terminateSignalName, nil
+	}
+	return killSignalName + terminateFailure, killProcess(command.Process, signal)
+}
+
 // prepareEnvironment adds ssm agent standard environment variables to the command
 func prepareEnvironment(command *exec.Cmd) {
 	env := os.Environ()
@@ -460,6 +627,18 @@ func prepareEnvironment(command *exec.Cmd) {
 	validateEnvironmentVariables(command)
 }
 
+// appendEnvironment adds the given variables to the environment of the command, in name order
+func appendEnvironment(command *exec.Cmd, environment map[string]string) {
+	names := make([]string, 0, len(environment))
+	for name := range environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command.Env = append(command.Env, fmtEnvVariable(name, environment[name]))
+	}
+}
+
 // fmtEnvVariable creates the string to append to the current set of environment variables.
 func fmtEnvVariable(name string, val string) string {
 	return fmt.Sprintf("%s=%s", name, val)
@@ -0,0 +1,64 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build integration,linux
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// runWithResourceLimits runs the script with the limits, the test is skipped when the agent can't create cgroups
+func runWithResourceLimits(t *testing.T, script string, limits appconfig.ResourceLimitsCfg) (exitCode int, stdout string, err error) {
+	if os.Getuid() != 0 {
+		t.Skip("creating cgroups requires root")
+	}
+	instanceTemp := instance
+	instance = &instanceInfoStub{instanceID: testInstanceID, regionName: testRegionName}
+	defer func() { instance = instanceTemp }()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	exitCode, err = ExecuteCommandWithOptions(log.NewMockLog(), task.NewChanneledCancelFlag(), os.TempDir(), &stdoutBuf, &stderrBuf, 30,
+		"sh", []string{"-c", script}, ExecuteOptions{ResourceLimits: limits})
+	return exitCode, stdoutBuf.String(), err
+}
+
+func TestExecuteCommandInCgroup(t *testing.T) {
+	exitCode, stdout, err := runWithResourceLimits(t, "cat /proc/self/cgroup", appconfig.ResourceLimitsCfg{MemoryLimitMB: 64, MaxProcesses: 16})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	// the processes started right away by the command are limited too
+	assert.Contains(t, stdout, "/"+cgroupParentName+"/command-")
+
+	// the cgroup is removed when the command ends
+	cgroups, _ := filepath.Glob(filepath.Join(cgroupRoot, "*", cgroupParentName, "command-*"))
+	assert.Empty(t, cgroups)
+}
+
+func TestExecuteCommandOutOfMemory(t *testing.T) {
+	exitCode, _, err := runWithResourceLimits(t, "python3 -c 'x = bytearray(256 * 1024 * 1024)'", appconfig.ResourceLimitsCfg{MemoryLimitMB: 32})
+
+	assert.NotEqual(t, 0, exitCode)
+	assert.IsType(t, &OutOfMemoryError{}, err)
+}
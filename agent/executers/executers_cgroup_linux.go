@@ -0,0 +1,435 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// cgroupParentName is the cgroup which holds the cgroups of the commands
+	cgroupParentName = "amazon-ssm-agent"
+	// cgroupAgentLeafName is the cgroup v2 which holds the processes of the agent below its delegated cgroup
+	cgroupAgentLeafName = "agent"
+	// cpuPeriodMicroseconds is the period of the cpu quota
+	cpuPeriodMicroseconds = 100000
+	cgroupRemoveAttempts  = 10
+	cgroupRemoveInterval  = 100 * time.Millisecond
+)
+
+// cgroupRoot is where the cgroup file systems are mounted
+var cgroupRoot = "/sys/fs/cgroup"
+
+// processCgroupFile lists the cgroups of the agent process
+var processCgroupFile = "/proc/self/cgroup"
+
+var cgroupSequence uint64
+
+// processCgroup is the control group which limits the resources of a command and its sub processes
+type processCgroup struct {
+	// paths holds the directory of the cgroup, cgroup v1 has one directory per controller
+	paths []string
+	// memoryPath is the directory of the cgroup which limits the memory, empty when memory is unlimited
+	memoryPath string
+	unified    bool
+}
+
+// newProcessCgroup creates a cgroup with the given limits, cgroup v2 is used when it is mounted
+// otherwise the cgroup v1 controllers are used.
+func newProcessCgroup(limits appconfig.ResourceLimitsCfg) (cgroup *processCgroup, err error) {
+	name := fmt.Sprintf("command-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupSequence, 1))
+	if _, err = os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		cgroup = &processCgroup{unified: true}
+		err = cgroup.createUnified(name, limits)
+	} else {
+		cgroup = &processCgroup{}
+		err = cgroup.createLegacy(name, limits)
+	}
+	if err != nil {
+		cgroup.removeDirectories()
+		return nil, fmt.Errorf("failed to create cgroup %v: %v", name, err)
+	}
+	return cgroup, nil
+}
+
+// createUnified creates the cgroup v2 of the command inside the cgroup of the agent, which systemd delegates
+// to the agent with Delegate=yes. The cgroups above the agent are never modified. Cgroups with processes can't
+// enable controllers for their children, so the processes of the agent are moved to a leaf cgroup first.
+func (c *processCgroup) createUnified(name string, limits appconfig.ResourceLimitsCfg) error {
+	agentPath, err := agentUnifiedCgroup()
+	if err != nil {
+		return err
+	}
+	parent := filepath.Join(agentPath, cgroupParentName)
+	path := filepath.Join(parent, name)
+
+	settings := make([][2]string, 0)
+	controllers := make([]string, 0)
+	if limits.CPUQuotaPercent > 0 {
+		controllers = append(controllers, "cpu")
+		settings = append(settings, [2]string{"cpu.max", fmt.Sprintf("%d %d", cpuQuota(limits.CPUQuotaPercent), cpuPeriodMicroseconds)})
+	}
+	if limits.MemoryLimitMB > 0 {
+		controllers = append(controllers, "memory")
+		settings = append(settings, [2]string{"memory.max", strconv.FormatInt(memoryLimitBytes(limits.MemoryLimitMB), 10)})
+		c.memoryPath = path
+	}
+	if limits.MaxProcesses > 0 {
+		controllers = append(controllers, "pids")
+		settings = append(settings, [2]string{"pids.max", strconv.Itoa(limits.MaxProcesses)})
+	}
+	if limits.IOWeight > 0 {
+		controllers = append(controllers, "io")
+		settings = append(settings, [2]string{"io.weight", fmt.Sprintf("default %d", limits.IOWeight)})
+	}
+
+	if err = moveToLeafCgroup(agentPath); err != nil {
+		return err
+	}
+	if err = enableControllers(agentPath, controllers); err != nil {
+		return fmt.Errorf("%v, the cgroup %v must be delegated to the agent with Delegate=yes", err, agentPath)
+	}
+	if err = os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	if err = enableControllers(parent, controllers); err != nil {
+		return err
+	}
+	if err = os.Mkdir(path, 0755); err != nil {
+		return err
+	}
+	c.paths = []string{path}
+	for _, setting := range settings {
+		if err := writeCgroupFile(path, setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+	// the memory limit would be worked around by swapping
+	if c.memoryPath != "" {
+		writeOptionalCgroupFile(path, "memory.swap.max", "0")
+	}
+	return nil
+}
+
+// agentUnifiedCgroup returns the cgroup v2 of the agent, the agent processes can already be in its leaf cgroup
+func agentUnifiedCgroup() (string, error) {
+	content, err := ioutil.ReadFile(processCgroupFile)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "0::") {
+			continue
+		}
+		relativePath := strings.TrimSpace(strings.TrimPrefix(line, "0::"))
+		if filepath.Base(relativePath) == cgroupAgentLeafName {
+			relativePath = filepath.Dir(relativePath)
+		}
+		if relativePath == "/" {
+			return "", fmt.Errorf("the agent runs in the root cgroup, which is not delegated to it")
+		}
+		return filepath.Join(cgroupRoot, relativePath), nil
+	}
+	return "", fmt.Errorf("the cgroup v2 of the agent is not listed in %v", processCgroupFile)
+}
+
+// moveToLeafCgroup moves the processes of the cgroup of the agent to its leaf cgroup,
+// the processes started afterwards by the agent, e.g. the document workers, inherit the leaf cgroup
+func moveToLeafCgroup(agentPath string) error {
+	pids := readCgroupProcesses(agentPath)
+	if len(pids) == 0 {
+		return nil
+	}
+	leafPath := filepath.Join(agentPath, cgroupAgentLeafName)
+	if err := os.Mkdir(leafPath, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	for _, pid := range pids {
+		// the processes which exited meanwhile are skipped
+		if err := writeCgroupFile(leafPath, "cgroup.procs", strconv.Itoa(pid)); err != nil && syscall.Kill(pid, 0) == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createLegacy creates one cgroup v1 per controller needed by the limits
+func (c *processCgroup) createLegacy(name string, limits appconfig.ResourceLimitsCfg) error {
+	create := func(controller string) (string, error) {
+		path := filepath.Join(cgroupRoot, controller, cgroupParentName, name)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return "", err
+		}
+		c.paths = append(c.paths, path)
+		return path, nil
+	}
+
+	if limits.CPUQuotaPercent > 0 {
+		path, err := create("cpu")
+		if err != nil {
+			return err
+		}
+		if err = writeCgroupFile(path, "cpu.cfs_period_us", strconv.Itoa(cpuPeriodMicroseconds)); err != nil {
+			return err
+		}
+		if err = writeCgroupFile(path, "cpu.cfs_quota_us", strconv.Itoa(cpuQuota(limits.CPUQuotaPercent))); err != nil {
+			return err
+		}
+	}
+	if limits.MemoryLimitMB > 0 {
+		path, err := create("memory")
+		if err != nil {
+			return err
+		}
+		limit := strconv.FormatInt(memoryLimitBytes(limits.MemoryLimitMB), 10)
+		if err = writeCgroupFile(path, "memory.limit_in_bytes", limit); err != nil {
+			return err
+		}
+		// the memory limit would be worked around by swapping
+		writeOptionalCgroupFile(path, "memory.memsw.limit_in_bytes", limit)
+		c.memoryPath = path
+	}
+	if limits.MaxProcesses > 0 {
+		path, err := create("pids")
+		if err != nil {
+			return err
+		}
+		if err = writeCgroupFile(path, "pids.max", strconv.Itoa(limits.MaxProcesses)); err != nil {
+			return err
+		}
+	}
+	if limits.IOWeight > 0 {
+		path, err := create("blkio")
+		if err != nil {
+			return err
+		}
+		// the weight file depends on the block I/O scheduler
+		weight := strconv.Itoa(legacyIOWeight(limits.IOWeight))
+		if _, err = os.Stat(filepath.Join(path, "blkio.weight")); err == nil {
+			err = writeCgroupFile(path, "blkio.weight", weight)
+		} else if _, err = os.Stat(filepath.Join(path, "blkio.bfq.weight")); err == nil {
+			err = writeCgroupFile(path, "blkio.bfq.weight", weight)
+		} else {
+			err = fmt.Errorf("the block I/O scheduler doesn't support I/O weights")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commandGate holds a command back until it is added to its cgroup,
+// otherwise the processes started right away by the command would escape the limits.
+type commandGate struct {
+	reader *os.File
+	writer *os.File
+}
+
+// newCommandGate starts the command through a shell which waits for the gate to open
+// before replacing itself with the command, the process id doesn't change.
+func newCommandGate(command *exec.Cmd) (gate *commandGate, err error) {
+	gate = &commandGate{}
+	if !strings.Contains(command.Path, string(os.PathSeparator)) {
+		// the command wasn't found, starting it fails anyway
+		return gate, nil
+	}
+	if gate.reader, gate.writer, err = os.Pipe(); err != nil {
+		return nil, err
+	}
+	command.ExtraFiles = append(command.ExtraFiles, gate.reader)
+	fd := 2 + len(command.ExtraFiles)
+	script := fmt.Sprintf(`read -r _ <&%d || exit 1; exec %d<&-; exec "$@"`, fd, fd)
+	command.Args = append([]string{"sh", "-c", script, "sh", command.Path}, command.Args[1:]...)
+	command.Path = "/bin/sh"
+	return gate, nil
+}
+
+// open lets the command run
+func (g *commandGate) open() error {
+	if g.writer == nil {
+		return nil
+	}
+	_, err := g.writer.Write([]byte("\n"))
+	g.close()
+	return err
+}
+
+// close closes the gate pipe, the command exits when the gate is closed before it is opened
+func (g *commandGate) close() {
+	if g.reader != nil {
+		g.reader.Close()
+	}
+	if g.writer != nil {
+		g.writer.Close()
+	}
+}
+
+// addProcess moves the process to the cgroup, the processes it starts afterwards inherit the cgroup
+func (c *processCgroup) addProcess(pid int) error {
+	for _, path := range c.paths {
+		if err := writeCgroupFile(path, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oomKilled returns true when the kernel killed a process of the cgroup for exceeding the memory limit
+func (c *processCgroup) oomKilled() bool {
+	if c.memoryPath == "" {
+		return false
+	}
+	eventsFile := "memory.oom_control"
+	if c.unified {
+		eventsFile = "memory.events"
+	}
+	file, err := os.Open(filepath.Join(c.memoryPath, eventsFile))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, _ := strconv.Atoi(fields[1])
+			return count > 0
+		}
+	}
+	return false
+}
+
+// remove kills the processes left in the cgroup and removes it
+func (c *processCgroup) remove(log log.T) {
+	for attempt := 0; attempt < cgroupRemoveAttempts; attempt++ {
+		for _, path := range c.paths {
+			for _, pid := range readCgroupProcesses(path) {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		if c.removeDirectories() {
+			return
+		}
+		time.Sleep(cgroupRemoveInterval)
+	}
+	log.Warnf("Failed to remove cgroup %v", c.paths)
+}
+
+// removeDirectories removes the directories of the cgroup, returns true when all of them are removed
+func (c *processCgroup) removeDirectories() bool {
+	removed := true
+	for _, path := range c.paths {
+		if err := syscall.Rmdir(path); err != nil && !os.IsNotExist(err) {
+			removed = false
+		}
+	}
+	return removed
+}
+
+// enableControllers enables the controllers for the children of the cgroup
+func enableControllers(path string, controllers []string) error {
+	available, err := ioutil.ReadFile(filepath.Join(path, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	enabled, err := ioutil.ReadFile(filepath.Join(path, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	for _, controller := range controllers {
+		if !containsField(string(enabled), controller) {
+			if !containsField(string(available), controller) {
+				return fmt.Errorf("the %v controller is not available", controller)
+			}
+			if err = writeCgroupFile(path, "cgroup.subtree_control", "+"+controller); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readCgroupProcesses(path string) (pids []int) {
+	content, err := ioutil.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return nil
+	}
+	for _, field := range strings.Fields(string(content)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+func writeCgroupFile(path string, name string, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(path, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to set %v to %v: %v", name, value, err)
+	}
+	return nil
+}
+
+// writeOptionalCgroupFile writes files which only exist with some kernel configurations
+func writeOptionalCgroupFile(path string, name string, value string) {
+	if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+		writeCgroupFile(path, name, value)
+	}
+}
+
+func containsField(content string, field string) bool {
+	for _, value := range strings.Fields(content) {
+		if value == field {
+			return true
+		}
+	}
+	return false
+}
+
+func cpuQuota(percent int) int {
+	return percent * cpuPeriodMicroseconds / 100
+}
+
+func memoryLimitBytes(megabytes int) int64 {
+	return int64(megabytes) * 1024 * 1024
+}
+
+// legacyIOWeight converts the cgroup v2 weight between 1 and 10000 to the blkio weight between 10 and 1000
+func legacyIOWeight(weight int) int {
+	weight = weight / 10
+	if weight < 10 {
+		return 10
+	}
+	if weight > 1000 {
+		return 1000
+	}
+	return weight
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+var testResourceLimits = appconfig.ResourceLimitsCfg{CPUQuotaPercent: 150, MemoryLimitMB: 256, MaxProcesses: 32, IOWeight: 500}
+
+// useTestCgroupRoot replaces the cgroup file systems by a temporary directory
+func useTestCgroupRoot(t *testing.T) (root string, restore func()) {
+	root, err := ioutil.TempDir("", "cgroup")
+	assert.NoError(t, err)
+	cgroupRootTemp := cgroupRoot
+	processCgroupFileTemp := processCgroupFile
+	cgroupRoot = root
+	processCgroupFile = filepath.Join(root, "self-cgroup")
+	return root, func() {
+		cgroupRoot = cgroupRootTemp
+		processCgroupFile = processCgroupFileTemp
+		os.RemoveAll(root)
+	}
+}
+
+// writeTestUnifiedCgroup creates a cgroup v2 directory with the given controllers
+func writeTestUnifiedCgroup(t *testing.T, path string, controllers string, subtreeControl string) {
+	assert.NoError(t, os.MkdirAll(path, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "cgroup.controllers"), []byte(controllers), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "cgroup.subtree_control"), []byte(subtreeControl), 0644))
+}
+
+func readTestCgroupFile(t *testing.T, path string, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(path, name))
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestNewProcessCgroupUnified(t *testing.T) {
+	root, restore := useTestCgroupRoot(t)
+	defer restore()
+	writeTestUnifiedCgroup(t, root, "cpuset cpu io memory pids", "cpu memory pids")
+	agentPath := filepath.Join(root, "system.slice", "amazon-ssm-agent.service")
+	writeTestUnifiedCgroup(t, agentPath, "cpu io memory pids", "cpu io memory")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(agentPath, "cgroup.procs"), []byte("4321\n"), 0644))
+	parent := filepath.Join(agentPath, cgroupParentName)
+	writeTestUnifiedCgroup(t, parent, "cpu io memory pids", "cpu memory pids")
+	assert.NoError(t, ioutil.WriteFile(processCgroupFile, []byte("0::/system.slice/amazon-ssm-agent.service\n"), 0644))
+
+	cgroup, err := newProcessCgroup(testResourceLimits)
+	assert.NoError(t, err)
+	assert.True(t, cgroup.unified)
+	assert.Len(t, cgroup.paths, 1)
+
+	path := cgroup.paths[0]
+	assert.Equal(t, parent, filepath.Dir(path))
+	assert.Equal(t, "150000 100000", readTestCgroupFile(t, path, "cpu.max"))
+	assert.Equal(t, "268435456", readTestCgroupFile(t, path, "memory.max"))
+	assert.Equal(t, "32", readTestCgroupFile(t, path, "pids.max"))
+	assert.Equal(t, "default 500", readTestCgroupFile(t, path, "io.weight"))
+	// the processes of the agent are moved to its leaf cgroup
+	assert.Equal(t, "4321", readTestCgroupFile(t, filepath.Join(agentPath, cgroupAgentLeafName), "cgroup.procs"))
+	// the controllers are enabled inside the cgroup of the agent only, the missing ones only
+	assert.Equal(t, "+pids", readTestCgroupFile(t, agentPath, "cgroup.subtree_control"))
+	assert.Equal(t, "+io", readTestCgroupFile(t, parent, "cgroup.subtree_control"))
+	assert.Equal(t, "cpu memory pids", readTestCgroupFile(t, root, "cgroup.subtree_control"))
+
+	assert.NoError(t, cgroup.addProcess(1234))
+	assert.Equal(t, "1234", readTestCgroupFile(t, path, "cgroup.procs"))
+
+	assert.False(t, cgroup.oomKilled())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644))
+	assert.True(t, cgroup.oomKilled())
+}
+
+func TestNewProcessCgroupUnifiedFromLeafCgroup(t *testing.T) {
+	root, restore := useTestCgroupRoot(t)
+	defer restore()
+	writeTestUnifiedCgroup(t, root, "cpu memory", "cpu memory")
+	agentPath := filepath.Join(root, "system.slice", "amazon-ssm-agent.service")
+	writeTestUnifiedCgroup(t, agentPath, "cpu memory", "cpu memory")
+	writeTestUnifiedCgroup(t, filepath.Join(agentPath, cgroupParentName), "cpu memory", "cpu memory")
+	// the worker started by the agent inherits the leaf cgroup
+	assert.NoError(t, ioutil.WriteFile(processCgroupFile, []byte("0::/system.slice/amazon-ssm-agent.service/agent\n"), 0644))
+
+	cgroup, err := newProcessCgroup(appconfig.ResourceLimitsCfg{MemoryLimitMB: 64})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(agentPath, cgroupParentName), filepath.Dir(cgroup.paths[0]))
+}
+
+func TestNewProcessCgroupUnifiedNotDelegated(t *testing.T) {
+	root, restore := useTestCgroupRoot(t)
+	defer restore()
+	writeTestUnifiedCgroup(t, root, "cpu memory pids", "cpu memory pids")
+	assert.NoError(t, ioutil.WriteFile(processCgroupFile, []byte("0::/\n"), 0644))
+
+	_, err := newProcessCgroup(appconfig.ResourceLimitsCfg{MemoryLimitMB: 64})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "root cgroup")
+	// the root cgroup is never modified
+	assert.Equal(t, "cpu memory pids", readTestCgroupFile(t, root, "cgroup.subtree_control"))
+}
+
+func TestNewProcessCgroupUnifiedMissingController(t *testing.T) {
+	root, restore := useTestCgroupRoot(t)
+	defer restore()
+	writeTestUnifiedCgroup(t, root, "cpu memory", "cpu memory")
+	writeTestUnifiedCgroup(t, filepath.Join(root, "agent.service"), "cpu memory", "")
+	assert.NoError(t, ioutil.WriteFile(processCgroupFile, []byte("1:name=systemd:/agent.service\n0::/agent.service\n"), 0644))
+
+	_, err := newProcessCgroup(appconfig.ResourceLimitsCfg{MaxProcesses: 10})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "pids")
+	assert.Contains(t, err.Error(), "Delegate=yes")
+}
+
+func TestNewProcessCgroupLegacy(t *testing.T) {
+	root, restore := useTestCgroupRoot(t)
+	defer restore()
+
+	// the I/O weight isn't supported by every block I/O scheduler
+	_, err := newProcessCgroup(appconfig.ResourceLimitsCfg{IOWeight: 500})
+	assert.Error(t, err)
+
+	cgroupSequenceTemp := cgroupSequence
+	blkioPath := filepath.Join(root, "blkio", cgroupParentName, fmt.Sprintf("command-%d-%d", os.Getpid(), cgroupSequenceTemp+1))
+	assert.NoError(t, os.MkdirAll(blkioPath, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(blkioPath, "blkio.weight"), []byte("100"), 0644))
+
+	cgroup, err := newProcessCgroup(testResourceLimits)
+	assert.NoError(t, err)
+	assert.False(t, cgroup.unified)
+	assert.Len(t, cgroup.paths, 4)
+
+	name := filepath.Base(cgroup.paths[0])
+	cpuPath := filepath.Join(root, "cpu", cgroupParentName, name)
+	assert.Equal(t, "100000", readTestCgroupFile(t, cpuPath, "cpu.cfs_period_us"))
+	assert.Equal(t, "150000", readTestCgroupFile(t, cpuPath, "cpu.cfs_quota_us"))
+	assert.Equal(t, "268435456", readTestCgroupFile(t, filepath.Join(root, "memory", cgroupParentName, name), "memory.limit_in_bytes"))
+	assert.Equal(t, "32", readTestCgroupFile(t, filepath.Join(root, "pids", cgroupParentName, name), "pids.max"))
+	assert.Equal(t, "50", readTestCgroupFile(t, filepath.Join(root, "blkio", cgroupParentName, name), "blkio.weight"))
+
+	// the process is added to the cgroup of every controller
+	assert.NoError(t, cgroup.addProcess(1234))
+	for _, path := range cgroup.paths {
+		assert.Equal(t, "1234", readTestCgroupFile(t, path, "cgroup.procs"))
+	}
+
+	memoryPath := filepath.Join(root, "memory", cgroupParentName, name)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(memoryPath, "memory.oom_control"), []byte("oom_kill_disable 0\nunder_oom 0\noom_kill 2\n"), 0644))
+	assert.True(t, cgroup.oomKilled())
+}
+
+func TestLegacyIOWeight(t *testing.T) {
+	assert.Equal(t, 10, legacyIOWeight(1))
+	assert.Equal(t, 10, legacyIOWeight(100))
+	assert.Equal(t, 500, legacyIOWeight(5000))
+	assert.Equal(t, 1000, legacyIOWeight(10000))
+}
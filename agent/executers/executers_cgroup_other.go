@@ -0,0 +1,58 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd netbsd openbsd windows
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// processCgroup is not supported on this platform
+type processCgroup struct{}
+
+// newProcessCgroup fails because resource limits rely on linux control groups
+func newProcessCgroup(limits appconfig.ResourceLimitsCfg) (*processCgroup, error) {
+	return nil, errors.New("resource limits are only supported on linux")
+}
+
+func (c *processCgroup) addProcess(pid int) error {
+	return nil
+}
+
+func (c *processCgroup) oomKilled() bool {
+	return false
+}
+
+func (c *processCgroup) remove(log log.T) {
+}
+
+// commandGate is not supported on this platform
+type commandGate struct{}
+
+func newCommandGate(command *exec.Cmd) (*commandGate, error) {
+	return nil, errors.New("resource limits are only supported on linux")
+}
+
+func (g *commandGate) open() error {
+	return nil
+}
+
+func (g *commandGate) close() {
+}
@@ -90,6 +90,22 @@ func TestEnvironmentVariables_None(t *testing.T) {
 	assert.Empty(t, getEnvVariableValue(command.Env, envVarRegionName))
 }
 
+func TestEnvironmentVariables_Options(t *testing.T) {
+	command := getTestCommand(t)
+	appendEnvironment(command, map[string]string{"FOO": "foo", "BAR": "bar"})
+
+	assert.Equal(t, []string{"BAR=bar", "FOO=foo"}, command.Env)
+}
+
+func TestExecuteOptionsHideEnvironmentValues(t *testing.T) {
+	options := ExecuteOptions{Environment: map[string]string{"PASSWORD": "secret-value"}, RunAsUser: "app"}
+
+	description := fmt.Sprintf("%v", options)
+	assert.NotContains(t, description, "secret-value")
+	assert.Contains(t, description, "PASSWORD")
+	assert.Contains(t, description, "app")
+}
+
 func TestQuoteShString(t *testing.T) {
 	var result string
 
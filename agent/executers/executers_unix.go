@@ -16,20 +16,143 @@
 package executers
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
+	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 )
 
+const (
+	// terminateSignalName is the signal which asks the process group to exit
+	terminateSignalName = "SIGTERM"
+	// killSignalName is the signal which ends the process group right away
+	killSignalName = "SIGKILL"
+)
+
 func prepareProcess(command *exec.Cmd) {
 	// make the process the leader of its process group
 	// (otherwise we cannot kill it properly)
 	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
 
+// prepareRunAs makes the process run as the given user and group, the primary group of the user when no group is given
+func prepareRunAs(command *exec.Cmd, runAsUser string, runAsGroup string) error {
+	if runAsUser == "" && runAsGroup == "" {
+		return nil
+	}
+
+	credential, account, err := runAsCredential(runAsUser, runAsGroup)
+	if err != nil {
+		return err
+	}
+	if account != nil {
+		// the process sees the home directory and name of the user instead of the agent's
+		command.Env = append(command.Env,
+			fmtEnvVariable("HOME", account.HomeDir),
+			fmtEnvVariable("USER", account.Username),
+			fmtEnvVariable("LOGNAME", account.Username))
+	}
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Credential = credential
+	return nil
+}
+
+// GrantRunAsAccess makes the file owned by the user and group a process started with the same ExecuteOptions runs as,
+// so that the process can use it even though the directories of the agent are only accessible to the agent.
+func GrantRunAsAccess(path string, runAsUser string, runAsGroup string) error {
+	if runAsUser == "" && runAsGroup == "" {
+		return nil
+	}
+
+	credential, _, err := runAsCredential(runAsUser, runAsGroup)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, int(credential.Uid), int(credential.Gid))
+}
+
+// runAsCredential returns the credential of the user and group, and the user account.
+// A group is only accepted along with a user, the process would otherwise keep the user of the agent.
+func runAsCredential(runAsUser string, runAsGroup string) (credential *syscall.Credential, account *user.User, err error) {
+	if runAsUser == "" {
+		return nil, nil, fmt.Errorf("running as group %v requires a user", runAsGroup)
+	}
+	if account, err = lookupUser(runAsUser); err != nil {
+		return nil, nil, err
+	}
+	credential = &syscall.Credential{Groups: supplementaryGroups(account)}
+	if credential.Uid, err = parseID(account.Uid); err != nil {
+		return nil, nil, fmt.Errorf("invalid uid of user %v: %v", runAsUser, err)
+	}
+	if credential.Gid, err = parseID(account.Gid); err != nil {
+		return nil, nil, fmt.Errorf("invalid gid of user %v: %v", runAsUser, err)
+	}
+	if runAsGroup != "" {
+		group, err := lookupGroup(runAsGroup)
+		if err != nil {
+			return nil, nil, err
+		}
+		if credential.Gid, err = parseID(group.Gid); err != nil {
+			return nil, nil, fmt.Errorf("invalid gid of group %v: %v", runAsGroup, err)
+		}
+	}
+	return credential, account, nil
+}
+
+// lookupUser finds a user by name or by uid
+func lookupUser(name string) (*user.User, error) {
+	if account, err := user.Lookup(name); err == nil {
+		return account, nil
+	}
+	if _, err := parseID(name); err == nil {
+		if account, err := user.LookupId(name); err == nil {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("user %v does not exist", name)
+}
+
+// lookupGroup finds a group by name or by gid
+func lookupGroup(name string) (*user.Group, error) {
+	if group, err := user.LookupGroup(name); err == nil {
+		return group, nil
+	}
+	if _, err := parseID(name); err == nil {
+		if group, err := user.LookupGroupId(name); err == nil {
+			return group, nil
+		}
+	}
+	return nil, fmt.Errorf("group %v does not exist", name)
+}
+
+// supplementaryGroups returns the groups the user is a member of, only its primary group when they can't be listed
+func supplementaryGroups(account *user.User) []uint32 {
+	groups := make([]uint32, 0)
+	groupIds, err := account.GroupIds()
+	if err != nil {
+		groupIds = []string{account.Gid}
+	}
+	for _, groupId := range groupIds {
+		if gid, err := parseID(groupId); err == nil {
+			groups = append(groups, gid)
+		}
+	}
+	return groups
+}
+
+func parseID(id string) (uint32, error) {
+	value, err := strconv.ParseUint(id, 10, 32)
+	return uint32(value), err
+}
+
 func killProcess(process *os.Process, signal *timeoutSignal) error {
 	//   NOTE: go only kills the process but not its sub processes.
 	//   The consequence is that command.Wait() does not return, for some reason.
@@ -40,7 +163,17 @@ func killProcess(process *os.Process, signal *timeoutSignal) error {
 	//   the shell we spawn the leader of its own process group and so
 	//   the kill here not just kills the shell but all its descendant
 	//   processes. [See manpage for kill(2)]
-	return syscall.Kill(-process.Pid, syscall.SIGKILL) // note the minus sign
+	err := syscall.Kill(-process.Pid, syscall.SIGKILL) // note the minus sign
+	if err == syscall.ESRCH {
+		// the process group already exited
+		return nil
+	}
+	return err
+}
+
+// terminateProcess asks all the processes in the process group to exit, see killProcess
+func terminateProcess(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGTERM) // note the minus sign
 }
 
 // Running powershell on linux erquired the HOME env variable to be set and to remove the TERM env variable
@@ -0,0 +1,187 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareRunAsNothing(t *testing.T) {
+	command := getTestCommand(t)
+	prepareProcess(command)
+
+	assert.NoError(t, prepareRunAs(command, "", ""))
+	assert.Nil(t, command.SysProcAttr.Credential)
+}
+
+func TestPrepareRunAsUser(t *testing.T) {
+	current, err := user.Current()
+	assert.NoError(t, err)
+
+	command := getTestCommand(t)
+	prepareProcess(command)
+
+	assert.NoError(t, prepareRunAs(command, current.Username, ""))
+	assert.NotNil(t, command.SysProcAttr.Credential)
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Equal(t, uint32(os.Getuid()), command.SysProcAttr.Credential.Uid)
+	assert.Equal(t, current.Gid, strconv.Itoa(int(command.SysProcAttr.Credential.Gid)))
+	assert.Equal(t, current.HomeDir, getEnvVariableValue(command.Env, "HOME"))
+	assert.Equal(t, current.Username, getEnvVariableValue(command.Env, "USER"))
+}
+
+func TestPrepareRunAsGroup(t *testing.T) {
+	current, err := user.Current()
+	assert.NoError(t, err)
+
+	command := getTestCommand(t)
+	prepareProcess(command)
+
+	// groups can be given by id
+	assert.NoError(t, prepareRunAs(command, current.Username, current.Gid))
+	assert.Equal(t, uint32(os.Getuid()), command.SysProcAttr.Credential.Uid)
+	assert.Equal(t, current.Gid, strconv.Itoa(int(command.SysProcAttr.Credential.Gid)))
+}
+
+func TestPrepareRunAsGroupWithoutUser(t *testing.T) {
+	current, err := user.Current()
+	assert.NoError(t, err)
+
+	command := getTestCommand(t)
+	prepareProcess(command)
+
+	// the process would keep the user of the agent, so the group alone is rejected
+	err = prepareRunAs(command, "", current.Gid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "requires a user")
+	assert.Nil(t, command.SysProcAttr.Credential)
+	assert.Error(t, GrantRunAsAccess(os.TempDir(), "", current.Gid))
+}
+
+func TestPrepareRunAsUnknownUser(t *testing.T) {
+	command := getTestCommand(t)
+	prepareProcess(command)
+
+	assert.Error(t, prepareRunAs(command, "ssm-agent-unknown-user", ""))
+	assert.Error(t, prepareRunAs(command, "root", "ssm-agent-unknown-group"))
+	assert.Nil(t, command.SysProcAttr.Credential)
+}
+
+func TestGrantRunAsAccess(t *testing.T) {
+	file, err := ioutil.TempFile("", "runas")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// the file is left alone when the process runs as the agent
+	assert.NoError(t, GrantRunAsAccess(file.Name(), "", ""))
+	assert.Error(t, GrantRunAsAccess(file.Name(), "ssm-agent-unknown-user", ""))
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of a file requires root")
+	}
+	account, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("user nobody does not exist")
+	}
+	assert.NoError(t, GrantRunAsAccess(file.Name(), account.Username, ""))
+	fileInfo, err := os.Stat(file.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, account.Uid, strconv.Itoa(int(fileInfo.Sys().(*syscall.Stat_t).Uid)))
+}
+
+// runAndCancel runs a script which traps SIGTERM and cancels it after it started
+func runAndCancel(t *testing.T, gracePeriod time.Duration) (exitCode int, stdout string, err error) {
+	instanceTemp := instance
+	instance = &instanceInfoStub{instanceID: testInstanceID, regionName: testRegionName}
+	defer func() { instance = instanceTemp }()
+
+	cancelFlag := task.NewChanneledCancelFlag()
+	go func() {
+		time.Sleep(time.Second)
+		cancelFlag.Set(task.Canceled)
+	}()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	exitCode, err = ExecuteCommandWithOptions(log.NewMockLog(), cancelFlag, os.TempDir(), &stdoutBuf, &stderrBuf, 30,
+		"sh", []string{"-c", "trap 'echo terminated; exit 0' TERM; while true; do sleep 0.1; done"},
+		ExecuteOptions{TerminationGracePeriod: gracePeriod})
+	return exitCode, stdoutBuf.String(), err
+}
+
+func TestExecuteCommandTerminatedGracefully(t *testing.T) {
+	exitCode, stdout, err := runAndCancel(t, 5*time.Second)
+
+	assert.Equal(t, appconfig.CommandStoppedPreemptivelyExitCode, exitCode)
+	assert.Contains(t, stdout, "terminated")
+	assert.IsType(t, &exec.ExitError{}, err)
+	assert.Contains(t, string(err.(*exec.ExitError).Stderr), terminateSignalName)
+}
+
+func TestExecuteCommandKilledWithoutGracePeriod(t *testing.T) {
+	exitCode, stdout, err := runAndCancel(t, 0)
+
+	assert.Equal(t, appconfig.CommandStoppedPreemptivelyExitCode, exitCode)
+	assert.NotContains(t, stdout, "terminated")
+	assert.IsType(t, &exec.ExitError{}, err)
+	assert.Contains(t, string(err.(*exec.ExitError).Stderr), killSignalName)
+}
+
+func TestKillProcessOnCancelAfterGracefulExit(t *testing.T) {
+	command := exec.Command("sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.1; done")
+	prepareProcess(command)
+	assert.NoError(t, command.Start())
+	time.Sleep(500 * time.Millisecond)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+	cancelChannel := make(chan bool, 2)
+
+	// the process exits on SIGTERM, so it is not waited for until the end of the grace period
+	start := time.Now()
+	killProcessOnCancel(log.NewMockLog(), command, exited, cancelChannel, cancelChannel, cancelFlag, time.Minute, &timeoutSignal{})
+	assert.True(t, time.Since(start) < 30*time.Second)
+}
+
+func TestStopCommandRecordsTerminateFailure(t *testing.T) {
+	command := exec.Command("sh", "-c", "exit 0")
+	prepareProcess(command)
+	assert.NoError(t, command.Run())
+
+	// the process group is gone, so it can't be asked to terminate and the kill is reported with the reason
+	stopChannel := make(chan bool, 2)
+	signalName, err := stopCommand(log.NewMockLog(), command, make(chan error), time.Minute, stopChannel, stopChannel, &timeoutSignal{})
+	assert.NoError(t, err)
+	assert.Equal(t, killSignalName+" since "+terminateSignalName+" failed: no such process", signalName)
+}
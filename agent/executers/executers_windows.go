@@ -16,16 +16,64 @@
 package executers
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"sync"
+	"syscall"
 )
 
 const (
 	CWConfigIndex = 2
 )
 
+const (
+	// terminateSignalName is the console event which asks the process group to exit
+	terminateSignalName = "CTRL_BREAK"
+	// killSignalName is the termination of the process
+	killSignalName = "TerminateProcess"
+
+	ctrlBreakEvent = 1
+)
+
+var (
+	modkernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	procGenerateConsoleCtrlEvent = modkernel32.NewProc("GenerateConsoleCtrlEvent")
+	procGetConsoleWindow         = modkernel32.NewProc("GetConsoleWindow")
+	procAttachConsole            = modkernel32.NewProc("AttachConsole")
+	procFreeConsole              = modkernel32.NewProc("FreeConsole")
+	procSetConsoleCtrlHandler    = modkernel32.NewProc("SetConsoleCtrlHandler")
+
+	// consoleLock serializes the attachments to the consoles of the processes, the agent can only be attached to one
+	consoleLock sync.Mutex
+	// ignoreCtrlBreakOnce installs the handler which keeps the agent from exiting on the CTRL_BREAK it sends
+	ignoreCtrlBreakOnce sync.Once
+	ignoreCtrlBreakErr  error
+)
+
+// hasConsole returns true when the agent is attached to a console, which is not the case of the agent service
+func hasConsole() bool {
+	window, _, _ := procGetConsoleWindow.Call()
+	return window != 0
+}
+
 func prepareProcess(command *exec.Cmd) {
-	// nothing to do on windows
+	// make the process the root of a new process group so it can receive CTRL_BREAK
+	command.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// prepareRunAs fails when a user or group is given as processes can't change user on windows
+func prepareRunAs(command *exec.Cmd, runAsUser string, runAsGroup string) error {
+	if runAsUser != "" || runAsGroup != "" {
+		return errors.New("running commands as another user or group is not supported on windows")
+	}
+	return nil
+}
+
+// GrantRunAsAccess is not needed on windows since processes can't run as another user or group
+func GrantRunAsAccess(path string, runAsUser string, runAsGroup string) error {
+	return prepareRunAs(nil, runAsUser, runAsGroup)
 }
 
 func killProcess(process *os.Process, signal *timeoutSignal) error {
@@ -35,6 +83,52 @@ func killProcess(process *os.Process, signal *timeoutSignal) error {
 	return process.Kill()
 }
 
+// terminateProcess sends CTRL_BREAK to the process group of the process.
+// Console events only reach the processes sharing the console of the sender. The processes started by the agent
+// service, which has no console, get a console of their own, so the agent attaches to it to send the event.
+func terminateProcess(process *os.Process) error {
+	if hasConsole() {
+		return sendCtrlBreak(process.Pid)
+	}
+
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	if err := ignoreCtrlBreak(); err != nil {
+		return err
+	}
+	if r, _, err := procAttachConsole.Call(uintptr(process.Pid)); r == 0 {
+		return fmt.Errorf("unable to attach to the console of the process: %v", err)
+	}
+	defer procFreeConsole.Call()
+	return sendCtrlBreak(process.Pid)
+}
+
+// sendCtrlBreak sends CTRL_BREAK to the process group whose root is the process with the given id
+func sendCtrlBreak(pid int) error {
+	if r, _, err := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(pid)); r == 0 {
+		return fmt.Errorf("unable to send %v to the process: %v", terminateSignalName, err)
+	}
+	return nil
+}
+
+// ignoreCtrlBreak installs a console handler which handles CTRL_BREAK so that the agent, which shares the console of the
+// process while it sends the event, doesn't exit. The agent service only gets the CTRL_BREAK events it sends itself,
+// the other events are left to the handlers of the runtime.
+func ignoreCtrlBreak() error {
+	ignoreCtrlBreakOnce.Do(func() {
+		handler := syscall.NewCallback(func(ctrlType uint32) uintptr {
+			if ctrlType == ctrlBreakEvent {
+				return 1
+			}
+			return 0
+		})
+		if r, _, err := procSetConsoleCtrlHandler.Call(handler, 1); r == 0 {
+			ignoreCtrlBreakErr = fmt.Errorf("unable to install the console handler of the agent: %v", err)
+		}
+	})
+	return ignoreCtrlBreakErr
+}
+
 // Running powershell on linux required the HOME env variable to be set and to remove the TERM env variable
 func validateEnvironmentVariables(command *exec.Cmd) {
 }
@@ -37,11 +37,31 @@ const (
 	hardStopTimeout       = time.Second * 5
 )
 
+// Execution statuses of the core modules
+const (
+	ModuleStatusNotStarted = "NotStarted"
+	ModuleStatusRunning    = "Running"
+	ModuleStatusStopping   = "Stopping"
+	ModuleStatusStopped    = "Stopped"
+	ModuleStatusFailed     = "Failed"
+)
+
 type ICoreManager interface {
 	// Start executes the registered core modules
 	Start()
 	// Stop requests the core modules to stop executing
 	Stop()
+	// GetModuleStatuses returns the execution status of the registered core modules
+	GetModuleStatuses() []ModuleStatus
+}
+
+// ModuleStatus is the execution status of a core module
+type ModuleStatus struct {
+	Name   string
+	Status string
+	Error  string
+	// Since is when the module entered the status
+	Since time.Time
 }
 
 // CoreManager encapsulates the logic for configuring, starting and stopping core modules
@@ -50,6 +70,9 @@ type CoreManager struct {
 	coreModules         coremodules.ModuleRegistry
 	cloudwatchPublisher *cloudwatchlogspublisher.CloudWatchPublisher
 	rebooter            rebooter.IRebootType
+	statusLock          sync.RWMutex
+	// moduleStatuses are indexed like coreModules, modules without status haven't been started
+	moduleStatuses map[int]ModuleStatus
 }
 
 // NewCoreManager creates a new core module manager.
@@ -246,7 +269,10 @@ func (c *CoreManager) executeCoreModules() {
 				c.context.Log().Errorf("error occurred trying to start core module. Plugin name: %v. Error: %v",
 					module.ModuleName(),
 					err)
+				c.setModuleStatus(i, ModuleStatusFailed, err)
+				return
 			}
+			c.setModuleStatus(i, ModuleStatusRunning, nil)
 		}(i)
 	}
 }
@@ -267,11 +293,15 @@ func (c *CoreManager) stopCoreModules(stopType contracts.StopType) {
 			}
 
 			module := c.coreModules[i]
+			c.setModuleStatus(i, ModuleStatusStopping, nil)
 			if err := module.ModuleRequestStop(stopType); err != nil {
 				log.Errorf("Plugin (%v) failed to stop with error: %v",
 					module.ModuleName(),
 					err)
+				c.setModuleStatus(i, ModuleStatusFailed, err)
+				return
 			}
+			c.setModuleStatus(i, ModuleStatusStopped, nil)
 
 		}(&wg, i)
 	}
@@ -285,6 +315,38 @@ func (c *CoreManager) stopCoreModules(stopType contracts.StopType) {
 	}
 }
 
+// GetModuleStatuses returns the execution status of the registered core modules
+func (c *CoreManager) GetModuleStatuses() []ModuleStatus {
+	c.statusLock.RLock()
+	defer c.statusLock.RUnlock()
+
+	statuses := make([]ModuleStatus, len(c.coreModules))
+	for i, module := range c.coreModules {
+		status, ok := c.moduleStatuses[i]
+		if !ok {
+			status.Status = ModuleStatusNotStarted
+		}
+		status.Name = module.ModuleName()
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// setModuleStatus records the execution status of the core module at the given index
+func (c *CoreManager) setModuleStatus(i int, status string, err error) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+
+	if c.moduleStatuses == nil {
+		c.moduleStatuses = make(map[int]ModuleStatus)
+	}
+	moduleStatus := ModuleStatus{Status: status, Since: time.Now()}
+	if err != nil {
+		moduleStatus.Error = err.Error()
+	}
+	c.moduleStatuses[i] = moduleStatus
+}
+
 // watchForReboot watches for reboot events and request core modules to stop when necessary
 func (c *CoreManager) watchForReboot() {
 	log := c.context.Log()
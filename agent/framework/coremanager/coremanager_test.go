@@ -13,6 +13,7 @@
 package coremanager
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -133,6 +134,29 @@ func (suite *CoreManagerTestSuite) TestCoreManager_Stop() {
 	suite.moduleMock.AssertNotCalled(suite.T(), "ModuleName")
 }
 
+// Testing the execution status of the core modules
+func (suite *CoreManagerTestSuite) TestCoreManager_GetModuleStatuses() {
+	cm := suite.coreManager.(*CoreManager)
+	failingModule := new(moduleMock.ICoreModule)
+	failingModule.On("ModuleExecute", mock.Anything).Return(errors.New("failed to start"))
+	failingModule.On("ModuleName").Return("TestFailingModule")
+	cm.coreModules = append(cm.coreModules, failingModule)
+
+	statuses := cm.GetModuleStatuses()
+	suite.Equal(2, len(statuses))
+	suite.Equal("TestExecuteModule", statuses[0].Name)
+	suite.Equal(ModuleStatusNotStarted, statuses[0].Status)
+
+	cm.executeCoreModules()
+	time.Sleep(100 * time.Millisecond)
+	statuses = cm.GetModuleStatuses()
+	suite.Equal(ModuleStatusRunning, statuses[0].Status)
+	suite.False(statuses[0].Since.IsZero())
+	suite.Equal("TestFailingModule", statuses[1].Name)
+	suite.Equal(ModuleStatusFailed, statuses[1].Status)
+	suite.Equal("failed to start", statuses[1].Error)
+}
+
 func TestCoreManagerTestSuite(t *testing.T) {
 	suite.Run(t, new(CoreManagerTestSuite))
 }
@@ -14,6 +14,7 @@
 // Code generated by mockery v1.0.0. DO NOT EDIT.
 package mocks
 
+import coremanager "github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 import mock "github.com/stretchr/testify/mock"
 
 // ICoreManager is an autogenerated mock type for the ICoreManager type
@@ -21,6 +22,22 @@ type ICoreManager struct {
 	mock.Mock
 }
 
+// GetModuleStatuses provides a mock function with given fields:
+func (_m *ICoreManager) GetModuleStatuses() []coremanager.ModuleStatus {
+	ret := _m.Called()
+
+	var r0 []coremanager.ModuleStatus
+	if rf, ok := ret.Get(0).(func() []coremanager.ModuleStatus); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]coremanager.ModuleStatus)
+		}
+	}
+
+	return r0
+}
+
 // Start provides a mock function with given fields:
 func (_m *ICoreManager) Start() {
 	_m.Called()
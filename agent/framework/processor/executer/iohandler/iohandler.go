@@ -83,12 +83,14 @@ type IOHandler interface {
 	GetStdout() string
 	GetStderr() string
 	GetExitCode() int
+	GetFailureReason() string
 	GetStdoutWriter() multiwriter.DocumentIOMultiWriter
 	GetStderrWriter() multiwriter.DocumentIOMultiWriter
 	GetIOConfig() contracts.IOConfiguration
 
 	SetStatus(contracts.ResultStatus)
 	SetExitCode(int)
+	SetFailureReason(string)
 	SetOutput(interface{})
 	SetStdout(string)
 	SetStderr(string)
@@ -98,6 +100,8 @@ type IOHandler interface {
 type DefaultIOHandler struct {
 	ExitCode int
 	Status   contracts.ResultStatus
+	// FailureReason tells apart failures which need a distinct handling, for e.g. contracts.FailureReasonOutOfMemory
+	FailureReason string
 	//private members - not exposed directly to plugins because they shouldn't write to these
 	stdout   string
 	stderr   string
@@ -163,10 +167,20 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OrchestrationDirectory: fullPath,
 	}
 
+	// Initialize the output sinks enabled by the agent configuration
+	sinkInfo := iomodule.SinkInfo{}
+	if len(filePath) > 0 {
+		sinkInfo.PluginName = filePath[0]
+		sinkInfo.PluginID = filePath[len(filePath)-1]
+	}
+	stdoutModules := []iomodule.IOModule{stdoutFile, stdoutConsole}
+	sinkInfo.Stream = iomodule.StreamStdout
+	stdoutModules = append(stdoutModules, iomodule.NewSinks(log, out.ioConfig, sinkInfo)...)
+
 	log.Debug("Initializing the Stdout Multi-writer with file and console listeners")
 	// Get a multi-writer for standard output
 	out.StdoutWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StdoutWriter, stdoutFile, stdoutConsole)
+	out.RegisterOutputSource(log, out.StdoutWriter, stdoutModules...)
 
 	// Initialize file error module
 	stderrFile := iomodule.File{
@@ -185,10 +199,14 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OrchestrationDirectory: fullPath,
 	}
 
+	stderrModules := []iomodule.IOModule{stderrFile, stderrConsole}
+	sinkInfo.Stream = iomodule.StreamStderr
+	stderrModules = append(stderrModules, iomodule.NewSinks(log, out.ioConfig, sinkInfo)...)
+
 	log.Debug("Initializing the Stderr Multi-writer with file and console listeners")
 	// Get a multi-writer for standard error
 	out.StderrWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StderrWriter, stderrFile, stderrConsole)
+	out.RegisterOutputSource(log, out.StderrWriter, stderrModules...)
 }
 
 // RegisterOutputSource returns a new output source by creating a multiwriter for the output modules.
@@ -254,6 +272,11 @@ func (out DefaultIOHandler) GetExitCode() int {
 	return out.ExitCode
 }
 
+// GetFailureReason returns the failure reason
+func (out DefaultIOHandler) GetFailureReason() string {
+	return out.FailureReason
+}
+
 // GetStderr returns the stderr
 func (out DefaultIOHandler) GetStderr() string {
 	return out.stderr
@@ -294,6 +317,11 @@ func (out *DefaultIOHandler) SetExitCode(exitCode int) {
 	out.ExitCode = exitCode
 }
 
+// SetFailureReason sets the failure reason
+func (out *DefaultIOHandler) SetFailureReason(reason string) {
+	out.FailureReason = reason
+}
+
 // SetOutput sets the output
 func (out *DefaultIOHandler) SetOutput(output interface{}) {
 	out.output = output
@@ -322,6 +350,9 @@ func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 		out.ExitCode = mergeOutput.GetExitCode()
 	}
 	out.Status = contracts.MergeResultStatus(out.Status, mergeOutput.GetStatus())
+	if out.FailureReason == "" {
+		out.FailureReason = mergeOutput.GetFailureReason()
+	}
 }
 
 // MarkAsFailed Failed marks plugin as Failed
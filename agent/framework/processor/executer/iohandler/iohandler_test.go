@@ -174,3 +174,15 @@ func TestAppendFormat(t *testing.T) {
 	assert.Contains(t, output.GetStdout(), testStringFormatted)
 	assert.Contains(t, output.GetStderr(), testStringFormatted)
 }
+
+func TestMergeFailureReason(t *testing.T) {
+	output := DefaultIOHandler{}
+	propOutput := DefaultIOHandler{}
+
+	propOutput.SetFailureReason(contracts.FailureReasonOutOfMemory)
+	propOutput.MarkAsFailed(fmt.Errorf("Error message"))
+	output.Merge(log.NewMockLog(), &propOutput)
+
+	assert.Equal(t, contracts.FailureReasonOutOfMemory, output.GetFailureReason())
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+}
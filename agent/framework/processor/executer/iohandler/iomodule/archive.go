@@ -0,0 +1,157 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// ArchiveSinkType is the type of the archive output sink
+	ArchiveSinkType = "archive"
+
+	archiveFileName         = "output.jsonl"
+	archiveLockFileSuffix   = ".lock"
+	defaultArchiveMaxSizeMB = 10
+	defaultArchiveMaxFiles  = 5
+)
+
+// defaultArchiveDirectory is where the archive is written when the Directory property is not set
+var defaultArchiveDirectory = filepath.Join(appconfig.DefaultDataStorePath, "outputarchive")
+
+// archiveFiles holds the open archive of each directory, the plugins of a document share the archive
+var archiveFiles = make(map[string]*archiveFile)
+var archiveFilesLock sync.Mutex
+
+func init() {
+	RegisterSink(ArchiveSinkType, newArchive)
+}
+
+// Archive writes the output as JSON lines to a local file which is rotated when it reaches its maximum size
+type Archive struct {
+	Directory    string
+	MaxSizeBytes int64
+	MaxFiles     int
+	Info         SinkInfo
+}
+
+// newArchive creates the archive sink from the Directory, MaxSizeMB and MaxFiles properties
+func newArchive(log log.T, properties map[string]string, info SinkInfo) (IOModule, error) {
+	return Archive{
+		Directory:    stringProperty(properties, "Directory", defaultArchiveDirectory),
+		MaxSizeBytes: int64(intProperty(log, properties, "MaxSizeMB", defaultArchiveMaxSizeMB)) * 1024 * 1024,
+		MaxFiles:     intProperty(log, properties, "MaxFiles", defaultArchiveMaxFiles),
+		Info:         info,
+	}, nil
+}
+
+// Read reads from the stream and appends each line to the archive.
+func (archive Archive) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	file, err := getArchiveFile(archive.Directory)
+	if err != nil {
+		log.Errorf("Failed to open the output archive in %v: %v", archive.Directory, err)
+		return
+	}
+
+	err = readLines(reader, func(line string) {
+		record, err := json.Marshal(newOutputRecord(archive.Info, line))
+		if err != nil {
+			log.Errorf("Failed to format the output archive record: %v", err)
+			return
+		}
+		if err = file.write(append(record, '\n'), archive.MaxSizeBytes, archive.MaxFiles); err != nil {
+			log.Errorf("Failed to write to the output archive: %v", err)
+		}
+	})
+	if err != nil {
+		log.Error("Error with the reader while reading the stream")
+	}
+}
+
+// archiveFile is the current file of an archive. The archive is shared with the document workers running
+// in other processes, so the records are written under a file lock and the archive is rotated based on the
+// size of the file on disk. The file is not kept open so that other processes can rotate it.
+type archiveFile struct {
+	lock     sync.Mutex
+	path     string
+	lockFile *os.File
+}
+
+func getArchiveFile(directory string) (*archiveFile, error) {
+	archiveFilesLock.Lock()
+	defer archiveFilesLock.Unlock()
+
+	if archive, found := archiveFiles[directory]; found {
+		return archive, nil
+	}
+	if err := fileutil.MakeDirs(directory); err != nil {
+		return nil, err
+	}
+	archive := &archiveFile{path: filepath.Join(directory, archiveFileName)}
+	var err error
+	if archive.lockFile, err = os.OpenFile(archive.path+archiveLockFileSuffix, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess); err != nil {
+		return nil, err
+	}
+	archiveFiles[directory] = archive
+	return archive, nil
+}
+
+// write appends the record, the file is rotated first when the record would exceed the maximum size
+func (a *archiveFile) write(record []byte, maxSizeBytes int64, maxFiles int) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := lockArchiveFile(a.lockFile); err != nil {
+		return err
+	}
+	defer unlockArchiveFile(a.lockFile)
+
+	if fileInfo, err := os.Stat(a.path); err == nil && fileInfo.Size() > 0 && fileInfo.Size()+int64(len(record)) > maxSizeBytes {
+		if err = a.rotate(maxFiles); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(a.path, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(record)
+	return err
+}
+
+// rotate renames output.jsonl to output.jsonl.1, output.jsonl.1 to output.jsonl.2 and so on,
+// the oldest file is removed when there are more than maxFiles files.
+func (a *archiveFile) rotate(maxFiles int) error {
+	os.Remove(fmt.Sprintf("%v.%d", a.path, maxFiles-1))
+	for index := maxFiles - 2; index > 0; index-- {
+		os.Rename(fmt.Sprintf("%v.%d", a.path, index), fmt.Sprintf("%v.%d", a.path, index+1))
+	}
+	if maxFiles > 1 {
+		return os.Rename(a.path, a.path+".1")
+	}
+	return os.Remove(a.path)
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockArchiveFile waits until the archive is not locked by another process and locks it
+func lockArchiveFile(lockFile *os.File) error {
+	return syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX)
+}
+
+func unlockArchiveFile(lockFile *os.File) error {
+	return syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+}
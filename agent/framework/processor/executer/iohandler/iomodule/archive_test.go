@@ -0,0 +1,104 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outputarchive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := Archive{Directory: dir, MaxSizeBytes: 1024 * 1024, MaxFiles: 3, Info: testSinkInfo}
+	readWithModule(archive, "hello\nworld\n")
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, archiveFileName))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	var record outputRecord
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "world", record.Line)
+	assert.Equal(t, testSinkInfo.CommandID, record.CommandID)
+	assert.Equal(t, testSinkInfo.PluginID, record.PluginID)
+	assert.Equal(t, StreamStdout, record.Stream)
+	assert.NotEmpty(t, record.Time)
+}
+
+func TestArchiveRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outputarchive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// every record is bigger than the maximum size, so every record rotates the archive
+	archive := Archive{Directory: dir, MaxSizeBytes: 10, MaxFiles: 3, Info: testSinkInfo}
+	readWithModule(archive, "one\ntwo\nthree\nfour\n")
+
+	files, err := filepath.Glob(filepath.Join(dir, archiveFileName+"*"))
+	assert.NoError(t, err)
+	// the lock file is kept next to the archive files
+	assert.Len(t, files, 4)
+	assert.Contains(t, files, filepath.Join(dir, archiveFileName+archiveLockFileSuffix))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, archiveFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"line":"four"`)
+	content, err = ioutil.ReadFile(filepath.Join(dir, archiveFileName+".2"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"line":"two"`)
+}
+
+func TestArchiveRotationSharedWithOtherProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outputarchive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := Archive{Directory: dir, MaxSizeBytes: 200, MaxFiles: 2, Info: testSinkInfo}
+	readWithModule(archive, "one\n")
+
+	// another process appended to the archive, its size on disk decides the rotation
+	path := filepath.Join(dir, archiveFileName)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	file.WriteString(strings.Repeat("x", 200) + "\n")
+	file.Close()
+
+	readWithModule(archive, "two\n")
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"line":"two"`)
+	assert.NotContains(t, string(content), "xxx")
+	content, err = ioutil.ReadFile(path + ".1")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "xxx")
+
+	// another process rotated the archive, the records go to the new file
+	assert.NoError(t, os.Rename(path, path+".1"))
+	readWithModule(archive, "three\n")
+	content, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"line":"three"`)
+	assert.NotContains(t, string(content), `"line":"two"`)
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+// Names of the output streams
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// SinkInfo describes the plugin output an output sink receives
+type SinkInfo struct {
+	CommandID  string
+	PluginName string
+	PluginID   string
+	Stream     string
+}
+
+// SinkFactory creates the output module of a sink from the properties of its configuration
+type SinkFactory func(log log.T, properties map[string]string, info SinkInfo) (IOModule, error)
+
+// configuredOutputSinks returns the output sinks of the agent configuration, including their credentials
+var configuredOutputSinks = func() ([]appconfig.OutputSinkCfg, error) {
+	config, err := appconfig.Config(false)
+	return config.OutputSinks, err
+}
+
+var sinkFactories = make(map[string]SinkFactory)
+var sinkFactoriesLock sync.RWMutex
+
+// RegisterSink makes an output sink available to the OutputSinks of the agent configuration
+func RegisterSink(sinkType string, factory SinkFactory) {
+	sinkFactoriesLock.Lock()
+	defer sinkFactoriesLock.Unlock()
+	sinkFactories[strings.ToLower(sinkType)] = factory
+}
+
+// NewSinks creates the output modules of the sinks enabled by the io configuration,
+// sinks which are unknown or fail to initialize are skipped.
+func NewSinks(log log.T, ioConfig contracts.IOConfiguration, info SinkInfo) (modules []IOModule) {
+	sinkFactoriesLock.RLock()
+	defer sinkFactoriesLock.RUnlock()
+
+	info.CommandID = ioConfig.CommandID
+	if len(ioConfig.OutputSinks) == 0 {
+		return nil
+	}
+	configuredSinks, err := configuredOutputSinks()
+	if err != nil {
+		log.Warnf("Failed to load the credentials of the output sinks: %v", err)
+	}
+	for _, sink := range ioConfig.OutputSinks {
+		sink = resolveSinkSecrets(sink, configuredSinks)
+		factory, found := sinkFactories[strings.ToLower(sink.Type)]
+		if !found {
+			log.Warnf("Unknown output sink type %v", sink.Type)
+			continue
+		}
+		module, err := factory(log, sink.Properties, info)
+		if err != nil {
+			log.Errorf("Failed to initialize the %v output sink: %v", sink.Type, err)
+			continue
+		}
+		modules = append(modules, module)
+	}
+	return modules
+}
+
+// resolveSinkSecrets returns the configured sink the sink was copied from without its credentials,
+// the sink itself when the configuration changed since
+func resolveSinkSecrets(sink appconfig.OutputSinkCfg, configuredSinks []appconfig.OutputSinkCfg) appconfig.OutputSinkCfg {
+	for _, configuredSink := range configuredSinks {
+		if reflect.DeepEqual(configuredSink.WithoutSecrets(), sink) {
+			return configuredSink
+		}
+	}
+	return sink
+}
+
+// outputRecord is a line of plugin output sent to the archive and webhook sinks
+type outputRecord struct {
+	Time       string `json:"time"`
+	CommandID  string `json:"commandId"`
+	PluginName string `json:"pluginName"`
+	PluginID   string `json:"pluginId"`
+	Stream     string `json:"stream"`
+	Line       string `json:"line"`
+}
+
+func newOutputRecord(info SinkInfo, line string) outputRecord {
+	return outputRecord{
+		Time:       times.ToIso8601UTC(time.Now()),
+		CommandID:  info.CommandID,
+		PluginName: info.PluginName,
+		PluginID:   info.PluginID,
+		Stream:     info.Stream,
+		Line:       line,
+	}
+}
+
+// readLines calls handle for each line of the stream until the stream is closed
+func readLines(reader io.Reader, handle func(line string)) error {
+	bufferedReader := bufio.NewReader(reader)
+	for {
+		line, err := bufferedReader.ReadString('\n')
+		if len(line) > 0 {
+			handle(strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// intProperty returns the property as a positive number, or the default value when it is not set or invalid
+func intProperty(log log.T, properties map[string]string, name string, defaultValue int) int {
+	value, found := properties[name]
+	if !found || value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Warnf("Invalid output sink property %v value %v, using %v", name, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// stringProperty returns the property, or the default value when it is not set
+func stringProperty(properties map[string]string, name string, defaultValue string) string {
+	if value, found := properties[name]; found && value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSinkInfo = SinkInfo{CommandID: "command-id", PluginName: "aws:runShellScript", PluginID: "step", Stream: StreamStdout}
+
+// readWithModule writes the output to the module and waits until the module read all of it
+func readWithModule(module IOModule, output string) {
+	r, w := io.Pipe()
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		module.Read(logger, r)
+	}()
+
+	w.Write([]byte(output))
+	w.Close()
+	wg.Wait()
+}
+
+func TestNewSinks(t *testing.T) {
+	ioConfig := contracts.IOConfiguration{
+		CommandID: "command-id",
+		OutputSinks: []appconfig.OutputSinkCfg{
+			{Type: "Archive", Properties: map[string]string{"Directory": "testdata/archive", "MaxFiles": "2"}},
+			{Type: "unknown"},
+			{Type: WebhookSinkType, Properties: map[string]string{"Url": "ftp://example.com"}},
+			{Type: WebhookSinkType, Properties: map[string]string{"Url": "https://example.com/output"}},
+		},
+	}
+
+	modules := NewSinks(logger, ioConfig, SinkInfo{PluginID: "step", Stream: StreamStderr})
+	assert.Len(t, modules, 2)
+
+	archive := modules[0].(Archive)
+	assert.Equal(t, "testdata/archive", archive.Directory)
+	assert.Equal(t, 2, archive.MaxFiles)
+	assert.Equal(t, int64(defaultArchiveMaxSizeMB*1024*1024), archive.MaxSizeBytes)
+	assert.Equal(t, SinkInfo{CommandID: "command-id", PluginID: "step", Stream: StreamStderr}, archive.Info)
+
+	webhook := modules[1].(Webhook)
+	assert.Equal(t, defaultWebhookBatchSize, webhook.BatchSize)
+}
+
+func TestNewSinksResolvesSecrets(t *testing.T) {
+	configuredSink := appconfig.OutputSinkCfg{
+		Type:       WebhookSinkType,
+		Properties: map[string]string{"Url": "https://example.com/output", "Authorization": "Bearer token"},
+	}
+	configuredOutputSinksTemp := configuredOutputSinks
+	configuredOutputSinks = func() ([]appconfig.OutputSinkCfg, error) {
+		return []appconfig.OutputSinkCfg{configuredSink}, nil
+	}
+	defer func() { configuredOutputSinks = configuredOutputSinksTemp }()
+
+	// the persisted io configuration holds the sinks without their credentials
+	persistedSink := configuredSink.WithoutSecrets()
+	assert.NotContains(t, persistedSink.Properties, "Authorization")
+	assert.Contains(t, configuredSink.Properties, "Authorization")
+
+	modules := NewSinks(logger, contracts.IOConfiguration{OutputSinks: []appconfig.OutputSinkCfg{persistedSink}}, testSinkInfo)
+	assert.Len(t, modules, 1)
+	assert.Equal(t, "Bearer token", modules[0].(Webhook).Authorization)
+
+	// the credentials of a sink which changed since are not resolved
+	persistedSink.Properties["Url"] = "https://example.com/other"
+	modules = NewSinks(logger, contracts.IOConfiguration{OutputSinks: []appconfig.OutputSinkCfg{persistedSink}}, testSinkInfo)
+	assert.Len(t, modules, 1)
+	assert.Empty(t, modules[0].(Webhook).Authorization)
+}
+
+func TestReadLines(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("first\r\nsecond\n"))
+		w.Write([]byte("third without newline"))
+		w.Close()
+	}()
+
+	lines := make([]string, 0)
+	assert.NoError(t, readLines(r, func(line string) { lines = append(lines, line) }))
+	assert.Equal(t, []string{"first", "second", "third without newline"}, lines)
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// SyslogSinkType is the type of the syslog output sink
+	SyslogSinkType = "syslog"
+	// JournaldSinkType is the type of the journald output sink
+	JournaldSinkType = "journald"
+
+	defaultSyslogNetwork    = "unixgram"
+	defaultSyslogAddress    = "/dev/log"
+	defaultSyslogFacility   = "user"
+	defaultSyslogIdentifier = "amazon-ssm-agent"
+	defaultJournaldSocket   = "/run/systemd/journal/socket"
+
+	// syslogStructuredDataID identifies the structured data of the syslog messages
+	syslogStructuredDataID = "ssm@32473"
+
+	severityError = 3
+	severityInfo  = 6
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func init() {
+	RegisterSink(SyslogSinkType, newSyslog)
+	RegisterSink(JournaldSinkType, newJournald)
+}
+
+// Syslog sends each line of the output as a RFC 5424 message with the command id, plugin id and stream as structured data
+type Syslog struct {
+	Network    string
+	Address    string
+	Facility   int
+	Identifier string
+	Info       SinkInfo
+}
+
+// newSyslog creates the syslog sink from the Network, Address, Facility and Identifier properties,
+// messages are sent to the local syslog daemon by default
+func newSyslog(log log.T, properties map[string]string, info SinkInfo) (IOModule, error) {
+	facilityName := strings.ToLower(stringProperty(properties, "Facility", defaultSyslogFacility))
+	facility, found := syslogFacilities[facilityName]
+	if !found {
+		return nil, fmt.Errorf("unknown syslog facility %v", facilityName)
+	}
+	return Syslog{
+		Network:    stringProperty(properties, "Network", defaultSyslogNetwork),
+		Address:    stringProperty(properties, "Address", defaultSyslogAddress),
+		Facility:   facility,
+		Identifier: stringProperty(properties, "Identifier", defaultSyslogIdentifier),
+		Info:       info,
+	}, nil
+}
+
+// Read reads from the stream and sends each line to syslog.
+func (s Syslog) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	connection, err := net.Dial(s.Network, s.Address)
+	if err != nil {
+		log.Errorf("Failed to connect to syslog at %v: %v", s.Address, err)
+		return
+	}
+	defer connection.Close()
+
+	hostname, _ := os.Hostname()
+	// stream oriented transports need the messages to be framed, see RFC 6587
+	framed := strings.HasPrefix(s.Network, "tcp")
+	err = readLines(reader, func(line string) {
+		message := s.formatMessage(hostname, time.Now(), line)
+		if framed {
+			message = fmt.Sprintf("%d %s", len(message), message)
+		}
+		if _, err := connection.Write([]byte(message)); err != nil {
+			log.Errorf("Failed to send the output to syslog: %v", err)
+		}
+	})
+	if err != nil {
+		log.Error("Error with the reader while reading the stream")
+	}
+}
+
+// formatMessage formats the line as a RFC 5424 message
+func (s Syslog) formatMessage(hostname string, timestamp time.Time, line string) string {
+	return fmt.Sprintf("<%d>1 %s %s %s %d - [%s commandId=\"%s\" pluginId=\"%s\" stream=\"%s\"] %s",
+		s.Facility*8+streamSeverity(s.Info.Stream),
+		timestamp.UTC().Format(time.RFC3339Nano),
+		nilValue(hostname),
+		nilValue(s.Identifier),
+		os.Getpid(),
+		syslogStructuredDataID,
+		escapeParamValue(s.Info.CommandID),
+		escapeParamValue(s.Info.PluginID),
+		escapeParamValue(s.Info.Stream),
+		line)
+}
+
+// Journald sends each line of the output to the systemd journal with the command id, plugin id and stream as fields
+type Journald struct {
+	Socket     string
+	Identifier string
+	Info       SinkInfo
+}
+
+// newJournald creates the journald sink from the Socket and Identifier properties
+func newJournald(log log.T, properties map[string]string, info SinkInfo) (IOModule, error) {
+	return Journald{
+		Socket:     stringProperty(properties, "Socket", defaultJournaldSocket),
+		Identifier: stringProperty(properties, "Identifier", defaultSyslogIdentifier),
+		Info:       info,
+	}, nil
+}
+
+// Read reads from the stream and sends each line to the journal.
+func (j Journald) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	connection, err := net.Dial("unixgram", j.Socket)
+	if err != nil {
+		log.Errorf("Failed to connect to journald at %v: %v", j.Socket, err)
+		return
+	}
+	defer connection.Close()
+
+	err = readLines(reader, func(line string) {
+		if _, err := connection.Write(j.formatEntry(line)); err != nil {
+			log.Errorf("Failed to send the output to journald: %v", err)
+		}
+	})
+	if err != nil {
+		log.Error("Error with the reader while reading the stream")
+	}
+}
+
+// formatEntry formats the line with the native journal protocol
+func (j Journald) formatEntry(line string) []byte {
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "MESSAGE=%s\n", line)
+	fmt.Fprintf(&entry, "PRIORITY=%d\n", streamSeverity(j.Info.Stream))
+	fmt.Fprintf(&entry, "SYSLOG_IDENTIFIER=%s\n", j.Identifier)
+	fmt.Fprintf(&entry, "SSM_COMMAND_ID=%s\n", j.Info.CommandID)
+	fmt.Fprintf(&entry, "SSM_PLUGIN_NAME=%s\n", j.Info.PluginName)
+	fmt.Fprintf(&entry, "SSM_PLUGIN_ID=%s\n", j.Info.PluginID)
+	fmt.Fprintf(&entry, "SSM_STREAM=%s\n", j.Info.Stream)
+	return entry.Bytes()
+}
+
+// streamSeverity logs the standard error as errors
+func streamSeverity(stream string) int {
+	if stream == StreamStderr {
+		return severityError
+	}
+	return severityInfo
+}
+
+// escapeParamValue escapes the characters which can't appear in the structured data parameter values
+func escapeParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func nilValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
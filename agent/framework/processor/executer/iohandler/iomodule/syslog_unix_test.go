@@ -0,0 +1,94 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenUnixgram receives the datagrams sent to a temporary socket
+func listenUnixgram(t *testing.T) (socket string, connection net.PacketConn, cleanup func()) {
+	dir, err := ioutil.TempDir("", "syslog")
+	assert.NoError(t, err)
+	socket = filepath.Join(dir, "socket")
+	connection, err = net.ListenPacket("unixgram", socket)
+	assert.NoError(t, err)
+	return socket, connection, func() {
+		connection.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func receiveDatagrams(t *testing.T, connection net.PacketConn, count int) (datagrams []string) {
+	buffer := make([]byte, 4096)
+	for i := 0; i < count; i++ {
+		connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := connection.ReadFrom(buffer)
+		assert.NoError(t, err)
+		datagrams = append(datagrams, string(buffer[:n]))
+	}
+	return datagrams
+}
+
+func TestSyslog(t *testing.T) {
+	socket, connection, cleanup := listenUnixgram(t)
+	defer cleanup()
+
+	info := testSinkInfo
+	info.Stream = StreamStderr
+	module, err := newSyslog(logger, map[string]string{"Address": socket, "Facility": "local0"}, info)
+	assert.NoError(t, err)
+	readWithModule(module, "first\nsecond\n")
+
+	messages := receiveDatagrams(t, connection, 2)
+	// local0 (16) * 8 + error (3) = 131
+	assert.True(t, strings.HasPrefix(messages[0], "<131>1 "))
+	assert.Contains(t, messages[0], ` amazon-ssm-agent `)
+	assert.Contains(t, messages[0], `[ssm@32473 commandId="command-id" pluginId="step" stream="stderr"] first`)
+	assert.True(t, strings.HasSuffix(messages[1], "] second"))
+
+	_, err = newSyslog(logger, map[string]string{"Facility": "unknown"}, info)
+	assert.Error(t, err)
+}
+
+func TestSyslogEscapeParamValue(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\]`, escapeParamValue(`a"b\c]`))
+}
+
+func TestJournald(t *testing.T) {
+	socket, connection, cleanup := listenUnixgram(t)
+	defer cleanup()
+
+	module, err := newJournald(logger, map[string]string{"Socket": socket}, testSinkInfo)
+	assert.NoError(t, err)
+	readWithModule(module, "hello\n")
+
+	entry := receiveDatagrams(t, connection, 1)[0]
+	assert.Contains(t, entry, "MESSAGE=hello\n")
+	assert.Contains(t, entry, "PRIORITY=6\n")
+	assert.Contains(t, entry, "SSM_COMMAND_ID=command-id\n")
+	assert.Contains(t, entry, "SSM_PLUGIN_ID=step\n")
+	assert.Contains(t, entry, "SSM_STREAM=stdout\n")
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// WebhookSinkType is the type of the webhook output sink
+	WebhookSinkType = "webhook"
+
+	defaultWebhookBatchSize            = 100
+	defaultWebhookFlushIntervalSeconds = 5
+	defaultWebhookMaxRetries           = 3
+	defaultWebhookTimeoutSeconds       = 10
+	// defaultWebhookDeliveryTimeoutSeconds bounds how long the plugin waits for its last batches once its output ended
+	defaultWebhookDeliveryTimeoutSeconds = 30
+	// webhookQueueSize is the number of batches waiting to be sent before the new batches are dropped
+	webhookQueueSize = 10
+)
+
+// webhookRetryDelay is the delay before the first retry, it doubles with every retry
+var webhookRetryDelay = time.Second
+
+func init() {
+	RegisterSink(WebhookSinkType, newWebhook)
+}
+
+// Webhook posts the output as batches of JSON records to an HTTP endpoint
+type Webhook struct {
+	URL           string
+	Authorization string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	// DeliveryTimeout is how long the last batches are sent for once the output ended, including the retries
+	DeliveryTimeout time.Duration
+	Info            SinkInfo
+	client          *http.Client
+}
+
+// newWebhook creates the webhook sink from the Url, Authorization, BatchSize, FlushIntervalSeconds,
+// MaxRetries, TimeoutSeconds and DeliveryTimeoutSeconds properties
+func newWebhook(log log.T, properties map[string]string, info SinkInfo) (IOModule, error) {
+	webhookURL := stringProperty(properties, "Url", "")
+	parsedURL, err := url.Parse(webhookURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webhook url %q", webhookURL)
+	}
+	// the credentials are never sent in clear text
+	authorization := stringProperty(properties, "Authorization", "")
+	if authorization != "" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("the webhook authorization is only sent to https urls")
+	}
+	timeout := time.Duration(intProperty(log, properties, "TimeoutSeconds", defaultWebhookTimeoutSeconds)) * time.Second
+	return Webhook{
+		URL:           webhookURL,
+		Authorization: authorization,
+		BatchSize:     intProperty(log, properties, "BatchSize", defaultWebhookBatchSize),
+		FlushInterval: time.Duration(intProperty(log, properties, "FlushIntervalSeconds", defaultWebhookFlushIntervalSeconds)) * time.Second,
+		MaxRetries:    intProperty(log, properties, "MaxRetries", defaultWebhookMaxRetries),
+		DeliveryTimeout: time.Duration(
+			intProperty(log, properties, "DeliveryTimeoutSeconds", defaultWebhookDeliveryTimeoutSeconds)) * time.Second,
+		Info:   info,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Read reads from the stream and posts the lines when the batch is full or the flush interval elapsed.
+// The batches are posted in the background so that a slow endpoint doesn't hold back the plugin,
+// batches are dropped when too many of them are waiting or when they are not sent within the delivery timeout.
+func (webhook Webhook) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		if err := readLines(reader, func(line string) { lines <- line }); err != nil {
+			log.Error("Error with the reader while reading the stream")
+		}
+	}()
+
+	batches := make(chan []outputRecord, webhookQueueSize)
+	sent := make(chan bool, 1)
+	stop := make(chan bool)
+	go func() {
+		for batch := range batches {
+			if err := webhook.post(log, batch, stop); err != nil {
+				log.Errorf("Failed to post %v lines of output to the webhook: %v", len(batch), err)
+			}
+		}
+		sent <- true
+	}()
+
+	batch := make([]outputRecord, 0, webhook.BatchSize)
+	queue := func() {
+		if len(batch) == 0 {
+			return
+		}
+		select {
+		case batches <- batch:
+		default:
+			log.Warnf("Dropping %v lines of output, the webhook is not keeping up", len(batch))
+		}
+		batch = make([]outputRecord, 0, webhook.BatchSize)
+	}
+
+	ticker := time.NewTicker(webhook.FlushInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case line, open := <-lines:
+			if !open {
+				done = true
+				break
+			}
+			batch = append(batch, newOutputRecord(webhook.Info, line))
+			if len(batch) >= webhook.BatchSize {
+				queue()
+			}
+		case <-ticker.C:
+			queue()
+		}
+	}
+	queue()
+
+	// wait for the last batches so that the output is sent before the plugin completes,
+	// the wait is bounded so that an unreachable endpoint doesn't hold back the plugin through every retry
+	close(batches)
+	deliveryTimer := time.NewTimer(webhook.DeliveryTimeout)
+	defer deliveryTimer.Stop()
+	select {
+	case <-sent:
+	case <-deliveryTimer.C:
+		log.Warnf("The output was not posted to the webhook within %v, dropping the remaining output", webhook.DeliveryTimeout)
+		close(stop)
+	}
+}
+
+// post sends the batch, failed requests are retried with an exponential backoff
+// except when the endpoint rejected the batch, nothing is sent anymore once stop is closed
+func (webhook Webhook) post(log log.T, batch []outputRecord, stop chan bool) error {
+	select {
+	case <-stop:
+		return fmt.Errorf("the delivery timeout elapsed")
+	default:
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		if retryable, err = webhook.send(body); err == nil || !retryable || attempt >= webhook.MaxRetries {
+			return err
+		}
+		log.Debugf("Retrying to post the output to the webhook in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return err
+		}
+		delay *= 2
+	}
+}
+
+func (webhook Webhook) send(body []byte) (retryable bool, err error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if webhook.Authorization != "" {
+		request.Header.Set("Authorization", webhook.Authorization)
+	}
+
+	response, err := webhook.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with %v", response.Status)
+	default:
+		return false, fmt.Errorf("webhook rejected the output with %v", response.Status)
+	}
+}
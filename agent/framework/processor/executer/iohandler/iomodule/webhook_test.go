@@ -0,0 +1,130 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iomodule implements the output modules
+package iomodule
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookServer records the batches it receives, it fails the first requests with the given status
+type webhookServer struct {
+	lock     sync.Mutex
+	batches  [][]outputRecord
+	requests int
+	failures int
+	status   int
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests++
+	if s.requests <= s.failures {
+		w.WriteHeader(s.status)
+		return
+	}
+	var batch []outputRecord
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.batches = append(s.batches, batch)
+}
+
+// newTestWebhook creates the webhook sink of the https test server
+func newTestWebhook(t *testing.T, server *httptest.Server) Webhook {
+	module, err := newWebhook(logger, map[string]string{"Url": server.URL, "Authorization": "Bearer token", "BatchSize": "2", "MaxRetries": "2"}, testSinkInfo)
+	assert.NoError(t, err)
+	webhook := module.(Webhook)
+	webhook.client = server.Client()
+	return webhook
+}
+
+func TestWebhookBatches(t *testing.T) {
+	handler := &webhookServer{}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	readWithModule(newTestWebhook(t, server), "one\ntwo\nthree\n")
+
+	assert.Len(t, handler.batches, 2)
+	assert.Len(t, handler.batches[0], 2)
+	assert.Equal(t, "three", handler.batches[1][0].Line)
+	assert.Equal(t, testSinkInfo.CommandID, handler.batches[1][0].CommandID)
+}
+
+func TestWebhookRetry(t *testing.T) {
+	webhookRetryDelayTemp := webhookRetryDelay
+	webhookRetryDelay = time.Millisecond
+	defer func() { webhookRetryDelay = webhookRetryDelayTemp }()
+
+	handler := &webhookServer{failures: 2, status: http.StatusServiceUnavailable}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	readWithModule(newTestWebhook(t, server), "one\n")
+	assert.Equal(t, 3, handler.requests)
+	assert.Len(t, handler.batches, 1)
+
+	// rejected batches are not retried
+	handler = &webhookServer{failures: 5, status: http.StatusForbidden}
+	server = httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	readWithModule(newTestWebhook(t, server), "one\n")
+	assert.Equal(t, 1, handler.requests)
+	assert.Empty(t, handler.batches)
+}
+
+func TestWebhookDeliveryTimeout(t *testing.T) {
+	webhookRetryDelayTemp := webhookRetryDelay
+	webhookRetryDelay = time.Hour
+	defer func() { webhookRetryDelay = webhookRetryDelayTemp }()
+
+	handler := &webhookServer{failures: 5, status: http.StatusServiceUnavailable}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	webhook := newTestWebhook(t, server)
+	webhook.DeliveryTimeout = 100 * time.Millisecond
+	start := time.Now()
+	readWithModule(webhook, "one\n")
+
+	// the plugin doesn't wait for the retries of the unreachable endpoint
+	assert.True(t, time.Since(start) < time.Minute)
+	assert.Equal(t, 1, handler.requests)
+	assert.Empty(t, handler.batches)
+}
+
+func TestNewWebhookInvalidUrl(t *testing.T) {
+	_, err := newWebhook(logger, map[string]string{}, testSinkInfo)
+	assert.Error(t, err)
+	_, err = newWebhook(logger, map[string]string{"Url": "file:///etc/passwd"}, testSinkInfo)
+	assert.Error(t, err)
+
+	// the authorization is not sent in clear text
+	_, err = newWebhook(logger, map[string]string{"Url": "http://example.com/output", "Authorization": "Bearer token"}, testSinkInfo)
+	assert.Error(t, err)
+	_, err = newWebhook(logger, map[string]string{"Url": "http://example.com/output"}, testSinkInfo)
+	assert.NoError(t, err)
+}
@@ -104,6 +104,12 @@ func (m *MockIOHandler) GetStatus() contracts.ResultStatus {
 	return args.Get(0).(contracts.ResultStatus)
 }
 
+// GetFailureReason is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetFailureReason() string {
+	args := m.Called()
+	return args.String(0)
+}
+
 // GetStdout is a mocked method that just returns what mock tells it to.
 func (m *MockIOHandler) GetStdout() string {
 	args := m.Called()
@@ -150,6 +156,11 @@ func (m *MockIOHandler) SetExitCode(code int) {
 	m.Called(code)
 }
 
+// SetFailureReason is a mocked method that acknowledges that the function has been called.
+func (m *MockIOHandler) SetFailureReason(reason string) {
+	m.Called(reason)
+}
+
 // SetOutput is a mocked method that acknowledges that the function has been called.
 func (m *MockIOHandler) SetOutput(out interface{}) {
 	m.Called(out)
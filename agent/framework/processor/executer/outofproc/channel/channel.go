@@ -1,7 +1,10 @@
 package channel
 
 import (
+	"crypto/sha1"
+	"fmt"
 	"path"
+	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -58,3 +61,57 @@ func CreateFileChannel(log log.T, mode Mode, filename string) (Channel, error, b
 	f, err := NewFileWatcherChannel(log, mode, path.Join(appconfig.DefaultDataStorePath, instanceID, defaultFileChannelPath, filename))
 	return f, err, false
 }
+
+//CreateChannel creates the channel of the configured type, master decides the type of the channel
+//worker connects to the socket channel if master created one, and falls back to the file channel otherwise
+//return the channel and the found flag
+func CreateChannel(log log.T, channelType string, mode Mode, name string) (Channel, error, bool) {
+	if mode == ModeWorker {
+		channelType = appconfig.IPCChannelTypeFile
+		if address, err := socketAddress(name); err == nil && fileutil.Exists(address) {
+			channelType = appconfig.IPCChannelTypeSocket
+		}
+	}
+	if channelType == appconfig.IPCChannelTypeSocket {
+		return CreateSocketChannel(log, mode, name)
+	}
+	if mode == ModeMaster {
+		//remove the socket left behind when the channel type was changed, otherwise worker would wait on it
+		if address, err := socketAddress(name); err == nil && fileutil.Exists(address) {
+			removeAddress(address)
+		}
+	}
+	return CreateFileChannel(log, mode, name)
+}
+
+//create a socket channel under the default channel root dir, the found flag indicates the socket already exists
+func CreateSocketChannel(log log.T, mode Mode, name string) (Channel, error, bool) {
+	address, err := socketAddress(name)
+	if err != nil {
+		log.Errorf("failed to load instance ID: %v", err)
+		return nil, err, false
+	}
+	if err = createIfNotExist(filepath.Dir(address)); err != nil {
+		log.Errorf("failed to create channel root directory: %v", err)
+		return nil, err, false
+	}
+	found := fileutil.Exists(address)
+	if found {
+		log.Infof("channel: %v found", name)
+	}
+	s, err := NewSocketChannel(log, mode, address)
+	if err != nil {
+		return nil, err, found
+	}
+	return s, nil, found
+}
+
+//socketAddress hashes the channel name to keep the socket path within the length limit of Unix domain sockets
+func socketAddress(name string) (string, error) {
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.Sum([]byte(name))
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, defaultFileChannelPath, fmt.Sprintf("%x.sock", hash[:10])), nil
+}
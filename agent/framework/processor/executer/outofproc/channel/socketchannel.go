@@ -0,0 +1,408 @@
+package channel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	frameTypeHello byte = 1
+	frameTypeData  byte = 2
+	frameTypeAck   byte = 3
+
+	//frame header is type (1 byte) + sequence number (8 bytes) + payload length (4 bytes), big endian
+	frameHeaderSize = 13
+	maxFramePayload = 64 * 1024 * 1024
+
+	defaultReconnectInterval = 100 * time.Millisecond
+	defaultCloseFlushTimeout = 5 * time.Second
+)
+
+//ipcConn is a connected socket or named pipe
+type ipcConn interface {
+	io.ReadWriteCloser
+}
+
+//ipcListener accepts connections on a socket or named pipe
+type ipcListener interface {
+	Accept() (ipcConn, error)
+	Close() error
+}
+
+type frame struct {
+	frameType byte
+	sequence  uint64
+	payload   []byte
+}
+
+/*
+	socketChannel transmits datagrams over a Unix domain socket (named pipe on Windows) with length-prefixed framing.
+	Master listens on the address and worker connects to it, the worker keeps reconnecting when the connection breaks
+	so that a restarted master can reattach to a running worker.
+	Every datagram is acknowledged by the receiver, unacknowledged datagrams are sent again on reconnection, which makes
+	the delivery at-least-once across reconnections.
+*/
+type socketChannel struct {
+	logger        log.T
+	mode          Mode
+	address       string
+	listener      ipcListener
+	onMessageChan chan string
+	//session identifies this end of the channel, the receiver resets its sequence when the peer session changes
+	session string
+	//done is closed when the channel is requested to close
+	done chan struct{}
+	//readers tracks the go routines delivering messages to onMessageChan
+	readers sync.WaitGroup
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	//conn is the current connection, nil while disconnected
+	conn ipcConn
+	//outbox holds the frames waiting to be written to the current connection
+	outbox  []frame
+	writing bool
+	//unacked holds the data frames which were not acknowledged by the peer yet
+	unacked     []frame
+	sendSeq     uint64
+	peerSession string
+	recvSeq     uint64
+	//delivering counts the received datagrams which are not acknowledged yet
+	delivering int
+	closed     bool
+}
+
+//NewSocketChannel creates a socket channel on the given address, master listens on the address and worker connects to it
+func NewSocketChannel(logger log.T, mode Mode, address string) (*socketChannel, error) {
+	ch := &socketChannel{
+		logger:        logger,
+		mode:          mode,
+		address:       address,
+		onMessageChan: make(chan string, defaultChannelBufferSize),
+		session:       fmt.Sprintf("%v-%v", os.Getpid(), time.Now().UnixNano()),
+		done:          make(chan struct{}),
+	}
+	ch.cond = sync.NewCond(&ch.mu)
+
+	if mode == ModeMaster {
+		listener, err := listen(address)
+		if err != nil {
+			logger.Errorf("failed to listen on %v: %v", address, err)
+			return nil, err
+		}
+		ch.listener = listener
+		go ch.accept()
+	} else {
+		go ch.connect()
+	}
+	return ch, nil
+}
+
+//Send queues the datagram for transmission, it is sent once the peer is connected
+func (ch *socketChannel) Send(rawJson string) error {
+	if len(rawJson) > maxFramePayload {
+		return fmt.Errorf("message size %v exceeds the limit of %v bytes", len(rawJson), maxFramePayload)
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		return errors.New("channel already closed")
+	}
+	ch.sendSeq++
+	f := frame{frameType: frameTypeData, sequence: ch.sendSeq, payload: []byte(rawJson)}
+	ch.unacked = append(ch.unacked, f)
+	if ch.conn != nil {
+		ch.outbox = append(ch.outbox, f)
+		ch.cond.Broadcast()
+	}
+	return nil
+}
+
+func (ch *socketChannel) GetMessage() <-chan string {
+	return ch.onMessageChan
+}
+
+// Close the socket channel
+// wait a bounded time for the sent messages to be acknowledged, then release the connection and close the message channel
+func (ch *socketChannel) Close() {
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return
+	}
+	ch.closed = true
+	ch.mu.Unlock()
+	log := ch.logger
+	log.Infof("channel %v requested close", ch.address)
+
+	ch.waitForAcknowledgement(defaultCloseFlushTimeout)
+	close(ch.done)
+	if ch.listener != nil {
+		ch.listener.Close()
+	}
+	ch.mu.Lock()
+	if ch.conn != nil {
+		ch.conn.Close()
+		ch.conn = nil
+	}
+	ch.outbox = nil
+	ch.cond.Broadcast()
+	ch.mu.Unlock()
+
+	go func() {
+		ch.readers.Wait()
+		close(ch.onMessageChan)
+		log.Infof("channel %v closed", ch.address)
+	}()
+}
+
+func (ch *socketChannel) Destroy() {
+	ch.Close()
+	//only master can remove the socket at destroy
+	if ch.mode == ModeMaster {
+		ch.logger.Debug("master removing socket...")
+		if err := removeAddress(ch.address); err != nil && !os.IsNotExist(err) {
+			ch.logger.Errorf("failed to remove socket %v : %v", ch.address, err)
+		}
+	}
+}
+
+//waitForAcknowledgement waits until the sent messages are acknowledged and the pending acknowledgements are written, or the timeout expires
+//worker waits for the master to reconnect within the timeout, master stops waiting once the worker is disconnected
+func (ch *socketChannel) waitForAcknowledgement(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ch.mu.Lock()
+		pending := len(ch.unacked)
+		flushed := pending == 0 && ch.delivering == 0 && len(ch.outbox) == 0 && !ch.writing
+		disconnected := ch.conn == nil
+		ch.mu.Unlock()
+		if flushed {
+			return
+		}
+		if ch.mode == ModeMaster && disconnected || time.Now().After(deadline) {
+			if pending > 0 {
+				ch.logger.Warnf("channel %v closing with %v unacknowledged messages", ch.address, pending)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//accept attaches every verified incoming connection, a new connection replaces the previous one
+func (ch *socketChannel) accept() {
+	log := ch.logger
+	log.Debugf("%v listener started on address: %v", ch.mode, ch.address)
+	for {
+		conn, err := ch.listener.Accept()
+		if err != nil {
+			select {
+			case <-ch.done:
+				return
+			case <-time.After(defaultReconnectInterval):
+				log.Errorf("failed to accept connection: %v", err)
+				continue
+			}
+		}
+		if err = verifyPeer(conn); err != nil {
+			log.Errorf("rejected connection on %v: %v", ch.address, err)
+			conn.Close()
+			continue
+		}
+		ch.attach(conn)
+	}
+}
+
+//connect keeps the worker connected to the master until the channel is closed
+func (ch *socketChannel) connect() {
+	log := ch.logger
+	log.Debugf("%v connecting to address: %v", ch.mode, ch.address)
+	for {
+		if conn, err := dial(ch.address); err != nil {
+			log.Debugf("failed to connect to %v: %v", ch.address, err)
+		} else if err = verifyPeer(conn); err != nil {
+			log.Errorf("rejected connection to %v: %v", ch.address, err)
+			conn.Close()
+		} else {
+			disconnected := ch.attach(conn)
+			select {
+			case <-disconnected:
+				log.Infof("connection to %v lost, reconnecting", ch.address)
+			case <-ch.done:
+				return
+			}
+		}
+		select {
+		case <-ch.done:
+			return
+		case <-time.After(defaultReconnectInterval):
+		}
+	}
+}
+
+//attach makes the connection current, introduces this end and sends the messages the peer has not acknowledged yet
+//the returned go channel is closed when the connection breaks
+func (ch *socketChannel) attach(conn ipcConn) <-chan struct{} {
+	disconnected := make(chan struct{})
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	//connections are still attached while Close waits for the acknowledgements
+	select {
+	case <-ch.done:
+		conn.Close()
+		close(disconnected)
+		return disconnected
+	default:
+	}
+	if ch.conn != nil {
+		ch.conn.Close()
+	}
+	ch.conn = conn
+	ch.outbox = append([]frame{{frameType: frameTypeHello, payload: []byte(ch.session)}}, ch.unacked...)
+	ch.cond.Broadcast()
+	ch.logger.Debugf("channel %v connected, resending %v messages", ch.address, len(ch.unacked))
+
+	ch.readers.Add(1)
+	go ch.read(conn, disconnected)
+	go ch.write(conn)
+	return disconnected
+}
+
+//detach drops the connection if it's still the current one
+func (ch *socketChannel) detach(conn ipcConn) {
+	ch.mu.Lock()
+	if ch.conn == conn {
+		ch.conn = nil
+		ch.outbox = nil
+		ch.cond.Broadcast()
+	}
+	ch.mu.Unlock()
+	conn.Close()
+}
+
+//write sends the frames in the outbox to the connection, writes are done in a separate go routine so that neither Send nor the acknowledgements block on the peer
+func (ch *socketChannel) write(conn ipcConn) {
+	for {
+		ch.mu.Lock()
+		for ch.conn == conn && len(ch.outbox) == 0 {
+			ch.cond.Wait()
+		}
+		if ch.conn != conn {
+			ch.mu.Unlock()
+			return
+		}
+		f := ch.outbox[0]
+		ch.outbox = ch.outbox[1:]
+		ch.writing = true
+		ch.mu.Unlock()
+
+		err := writeFrame(conn, f)
+		ch.mu.Lock()
+		ch.writing = false
+		if err != nil {
+			//the reader still drains the acknowledgements the peer sent before closing, and releases the connection at EOF
+			ch.logger.Debugf("failed to write to %v: %v", ch.address, err)
+			if ch.conn == conn {
+				ch.conn = nil
+				ch.outbox = nil
+			}
+			ch.mu.Unlock()
+			return
+		}
+		ch.mu.Unlock()
+	}
+}
+
+//read delivers the received datagrams in order and acknowledges them, datagrams already received from the same peer session are dropped
+func (ch *socketChannel) read(conn ipcConn, disconnected chan struct{}) {
+	defer func() {
+		ch.detach(conn)
+		close(disconnected)
+		ch.readers.Done()
+	}()
+	for {
+		f, err := readFrame(conn)
+		if err != nil {
+			if err != io.EOF {
+				ch.logger.Debugf("failed to read from %v: %v", ch.address, err)
+			}
+			return
+		}
+		switch f.frameType {
+		case frameTypeHello:
+			ch.mu.Lock()
+			if peerSession := string(f.payload); peerSession != ch.peerSession {
+				ch.peerSession = peerSession
+				ch.recvSeq = 0
+			}
+			ch.mu.Unlock()
+		case frameTypeAck:
+			ch.mu.Lock()
+			acked := 0
+			for acked < len(ch.unacked) && ch.unacked[acked].sequence <= f.sequence {
+				acked++
+			}
+			ch.unacked = ch.unacked[acked:]
+			ch.mu.Unlock()
+		case frameTypeData:
+			ch.mu.Lock()
+			duplicate := f.sequence <= ch.recvSeq
+			ch.delivering++
+			ch.mu.Unlock()
+			if !duplicate {
+				select {
+				case ch.onMessageChan <- string(f.payload):
+				case <-ch.done:
+					return
+				}
+			}
+			ch.mu.Lock()
+			ch.delivering--
+			if !duplicate {
+				ch.recvSeq = f.sequence
+			}
+			if ch.conn == conn {
+				ch.outbox = append(ch.outbox, frame{frameType: frameTypeAck, sequence: f.sequence})
+				ch.cond.Broadcast()
+			}
+			ch.mu.Unlock()
+		default:
+			ch.logger.Errorf("received unknown frame type %v on %v", f.frameType, ch.address)
+			return
+		}
+	}
+}
+
+func writeFrame(w io.Writer, f frame) error {
+	buf := make([]byte, frameHeaderSize+len(f.payload))
+	buf[0] = f.frameType
+	binary.BigEndian.PutUint64(buf[1:9], f.sequence)
+	binary.BigEndian.PutUint32(buf[9:13], uint32(len(f.payload)))
+	copy(buf[frameHeaderSize:], f.payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) (f frame, err error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	f.frameType = header[0]
+	f.sequence = binary.BigEndian.Uint64(header[1:9])
+	length := binary.BigEndian.Uint32(header[9:13])
+	if length > maxFramePayload {
+		return f, fmt.Errorf("frame size %v exceeds the limit of %v bytes", length, maxFramePayload)
+	}
+	f.payload = make([]byte, length)
+	_, err = io.ReadFull(r, f.payload)
+	return
+}
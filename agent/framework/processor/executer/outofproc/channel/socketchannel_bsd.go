@@ -0,0 +1,8 @@
+// +build darwin freebsd netbsd openbsd
+
+package channel
+
+//verifyPeer relies on the permissions of the socket, which only allow the owner of the socket to connect
+func verifyPeer(conn ipcConn) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+// +build linux
+
+package channel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+//verifyPeer checks the credentials of the process on the other end of the socket, only root and the user of this process are trusted
+func verifyPeer(conn ipcConn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("unexpected connection type %T", conn)
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return fmt.Errorf("failed to get peer credentials: %v", credErr)
+	}
+	if cred.Uid != 0 && int(cred.Uid) != os.Geteuid() {
+		return fmt.Errorf("peer process %v is owned by untrusted user %v", cred.Pid, cred.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package channel
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSocketAddress(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "socketchannel")
+	assert.NoError(t, err)
+	return filepath.Join(dir, "test.sock"), func() { os.RemoveAll(dir) }
+}
+
+func newTestSocketChannel(t *testing.T, mode Mode, address string) *socketChannel {
+	ch, err := NewSocketChannel(log.NewMockLog(), mode, address)
+	assert.NoError(t, err)
+	return ch
+}
+
+func sendAll(t *testing.T, ch Channel, messages []string) {
+	for _, msg := range messages {
+		assert.NoError(t, ch.Send(msg))
+	}
+}
+
+func receiveAll(t *testing.T, ch Channel, expected []string) {
+	for _, msg := range expected {
+		select {
+		case received := <-ch.GetMessage():
+			assert.Equal(t, msg, received)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %v", msg)
+		}
+	}
+}
+
+func TestSocketChannelDuplexTransmission(t *testing.T) {
+	address, cleanup := newSocketAddress(t)
+	defer cleanup()
+
+	master := newTestSocketChannel(t, ModeMaster, address)
+	worker := newTestSocketChannel(t, ModeWorker, address)
+	sendAll(t, master, []string{"s000", "s001", "s002"})
+	sendAll(t, worker, []string{"r000", "r001", "r002"})
+
+	receiveAll(t, worker, []string{"s000", "s001", "s002"})
+	receiveAll(t, master, []string{"r000", "r001", "r002"})
+
+	worker.Close()
+	master.Destroy()
+	_, ok := <-master.GetMessage()
+	assert.False(t, ok)
+	_, ok = <-worker.GetMessage()
+	assert.False(t, ok)
+	_, err := os.Stat(address)
+	assert.True(t, os.IsNotExist(err))
+}
+
+//worker keeps dialing until master starts listening
+func TestSocketChannelWorkerStartsFirst(t *testing.T) {
+	address, cleanup := newSocketAddress(t)
+	defer cleanup()
+
+	worker := newTestSocketChannel(t, ModeWorker, address)
+	sendAll(t, worker, []string{"r000", "r001"})
+	time.Sleep(3 * defaultReconnectInterval)
+
+	master := newTestSocketChannel(t, ModeMaster, address)
+	receiveAll(t, master, []string{"r000", "r001"})
+
+	worker.Close()
+	master.Destroy()
+}
+
+//a restarted master reattaches to the running worker and receives the messages sent in between exactly once
+func TestSocketChannelMasterReconnect(t *testing.T) {
+	address, cleanup := newSocketAddress(t)
+	defer cleanup()
+
+	master := newTestSocketChannel(t, ModeMaster, address)
+	worker := newTestSocketChannel(t, ModeWorker, address)
+	sendAll(t, worker, []string{"r000"})
+	receiveAll(t, master, []string{"r000"})
+
+	master.Close()
+	//socket is kept at close so that the worker can reattach
+	_, err := os.Stat(address)
+	assert.NoError(t, err)
+	sendAll(t, worker, []string{"r001", "r002"})
+
+	master = newTestSocketChannel(t, ModeMaster, address)
+	receiveAll(t, master, []string{"r001", "r002"})
+	sendAll(t, master, []string{"s000"})
+	receiveAll(t, worker, []string{"s000"})
+
+	select {
+	case msg := <-master.GetMessage():
+		t.Fatalf("received duplicate message %v", msg)
+	case <-time.After(3 * defaultReconnectInterval):
+	}
+
+	worker.Close()
+	master.Destroy()
+}
+
+func TestSocketChannelLargeMessage(t *testing.T) {
+	address, cleanup := newSocketAddress(t)
+	defer cleanup()
+
+	master := newTestSocketChannel(t, ModeMaster, address)
+	worker := newTestSocketChannel(t, ModeWorker, address)
+	large := strings.Repeat("x", 4*1024*1024)
+	sendAll(t, worker, []string{large, "r001"})
+	receiveAll(t, master, []string{large, "r001"})
+
+	worker.Close()
+	master.Destroy()
+}
+
+func TestSocketChannelSendAfterClose(t *testing.T) {
+	address, cleanup := newSocketAddress(t)
+	defer cleanup()
+
+	master := newTestSocketChannel(t, ModeMaster, address)
+	master.Destroy()
+	assert.Error(t, master.Send("s000"))
+}
+
+func TestFrameEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frame{frameType: frameTypeData, sequence: 42, payload: []byte("{}")}))
+	assert.NoError(t, writeFrame(&buf, frame{frameType: frameTypeAck, sequence: 42}))
+
+	f, err := readFrame(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, frame{frameType: frameTypeData, sequence: 42, payload: []byte("{}")}, f)
+	f, err = readFrame(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, frameTypeAck, f.frameType)
+	assert.Equal(t, uint64(42), f.sequence)
+	assert.Empty(t, f.payload)
+
+	//frames announcing a payload above the limit are rejected
+	header := make([]byte, frameHeaderSize)
+	header[0] = frameTypeData
+	header[9] = 0xff
+	_, err = readFrame(bytes.NewReader(header))
+	assert.Error(t, err)
+}
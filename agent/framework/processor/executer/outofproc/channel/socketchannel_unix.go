@@ -0,0 +1,44 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package channel
+
+import (
+	"net"
+	"os"
+)
+
+const defaultSocketMode = 0600
+
+type unixListener struct {
+	*net.UnixListener
+}
+
+func (l unixListener) Accept() (ipcConn, error) {
+	return l.AcceptUnix()
+}
+
+//listen removes the socket left behind by a previous master and listens on a new socket which only the owner can connect to
+//the socket file is kept on close so that the worker keeps trying to reconnect until the channel is destroyed
+func listen(address string) (ipcListener, error) {
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: address, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+	if err = os.Chmod(address, defaultSocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return unixListener{listener}, nil
+}
+
+func dial(address string) (ipcConn, error) {
+	return net.DialUnix("unix", nil, &net.UnixAddr{Name: address, Net: "unix"})
+}
+
+func removeAddress(address string) error {
+	return os.Remove(address)
+}
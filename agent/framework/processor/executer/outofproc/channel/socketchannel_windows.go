@@ -0,0 +1,256 @@
+// +build windows
+
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	pipePrefix = `\\.\pipe\amazon-ssm-agent-`
+
+	pipeAccessDuplex          = 0x3
+	fileFlagFirstPipeInstance = 0x80000
+	pipeTypeByte              = 0x0
+	pipeRejectRemoteClients   = 0x8
+	pipeUnlimitedInstances    = 255
+	pipeBufferSize            = 64 * 1024
+	processQueryLimitedInfo   = 0x1000
+	errorPipeConnected        = syscall.Errno(535)
+	errorNoData               = syscall.Errno(232)
+	errorPipeNotConnected     = syscall.Errno(233)
+	sddlRevision1             = 1
+	localSystemSid            = "S-1-5-18"
+	//only LocalSystem and the owner of the pipe are granted access
+	pipeSecurityDescriptor = "D:P(A;;GA;;;SY)(A;;GA;;;OW)"
+)
+
+var (
+	modkernel32                        = syscall.NewLazyDLL("kernel32.dll")
+	modadvapi32                        = syscall.NewLazyDLL("advapi32.dll")
+	procCreateNamedPipeW               = modkernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe               = modkernel32.NewProc("ConnectNamedPipe")
+	procDisconnectNamedPipe            = modkernel32.NewProc("DisconnectNamedPipe")
+	procGetNamedPipeClientProcessId    = modkernel32.NewProc("GetNamedPipeClientProcessId")
+	procGetNamedPipeServerProcessId    = modkernel32.NewProc("GetNamedPipeServerProcessId")
+	procCancelIoEx                     = modkernel32.NewProc("CancelIoEx")
+	procConvertStringSecurityDescToSec = modadvapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+)
+
+//pipeName derives the name of the pipe from the marker file of the channel
+func pipeName(address string) string {
+	return pipePrefix + strings.TrimSuffix(filepath.Base(address), filepath.Ext(address))
+}
+
+type pipeConn struct {
+	handle syscall.Handle
+	server bool
+	once   sync.Once
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	var n uint32
+	if err := syscall.ReadFile(c.handle, b, &n, nil); err != nil {
+		if err == syscall.ERROR_BROKEN_PIPE || err == errorPipeNotConnected {
+			return int(n), io.EOF
+		}
+		return int(n), err
+	}
+	if n == 0 && len(b) > 0 {
+		return 0, io.EOF
+	}
+	return int(n), nil
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		var n uint32
+		if err := syscall.WriteFile(c.handle, b[written:], &n, nil); err != nil {
+			return written, err
+		}
+		written += int(n)
+	}
+	return written, nil
+}
+
+//Close cancels the pending reads and writes before the handle is released
+func (c *pipeConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		procCancelIoEx.Call(uintptr(c.handle), 0)
+		if c.server {
+			procDisconnectNamedPipe.Call(uintptr(c.handle))
+		}
+		err = syscall.CloseHandle(c.handle)
+	})
+	return err
+}
+
+type pipeListener struct {
+	name   string
+	mu     sync.Mutex
+	next   syscall.Handle
+	closed bool
+}
+
+func createPipe(name string, first bool) (syscall.Handle, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	var sd uintptr
+	sddl, _ := syscall.UTF16PtrFromString(pipeSecurityDescriptor)
+	if r, _, err := procConvertStringSecurityDescToSec.Call(uintptr(unsafe.Pointer(sddl)), sddlRevision1, uintptr(unsafe.Pointer(&sd)), 0); r == 0 {
+		return syscall.InvalidHandle, fmt.Errorf("failed to create pipe security descriptor: %v", err)
+	}
+	defer syscall.LocalFree(syscall.Handle(sd))
+	sa := syscall.SecurityAttributes{SecurityDescriptor: sd}
+	sa.Length = uint32(unsafe.Sizeof(sa))
+
+	openMode := uint32(pipeAccessDuplex)
+	if first {
+		//fail if the pipe is already created by another process
+		openMode |= fileFlagFirstPipeInstance
+	}
+	h, _, err := procCreateNamedPipeW.Call(
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(openMode),
+		uintptr(pipeTypeByte|pipeRejectRemoteClients),
+		pipeUnlimitedInstances,
+		pipeBufferSize,
+		pipeBufferSize,
+		0,
+		uintptr(unsafe.Pointer(&sa)))
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return syscall.InvalidHandle, err
+	}
+	return syscall.Handle(h), nil
+}
+
+//Accept waits for a client to connect to the pending pipe instance, and creates the next instance
+func (l *pipeListener) Accept() (ipcConn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, errors.New("listener closed")
+	}
+	handle := l.next
+	l.mu.Unlock()
+
+	r, _, err := procConnectNamedPipe.Call(uintptr(handle), 0)
+	if r == 0 && err != errorPipeConnected && err != errorNoData {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		syscall.CloseHandle(handle)
+		return nil, errors.New("listener closed")
+	}
+	if l.next, err = createPipe(l.name, false); err != nil {
+		l.closed = true
+	}
+	if r == 0 && err == errorNoData {
+		//client disconnected before the connection was accepted
+		(&pipeConn{handle: handle, server: true}).Close()
+		return nil, io.EOF
+	}
+	return &pipeConn{handle: handle, server: true}, nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	procCancelIoEx.Call(uintptr(l.next), 0)
+	return syscall.CloseHandle(l.next)
+}
+
+//listen creates the named pipe of the channel, the marker file at the address indicates the channel exists
+func listen(address string) (ipcListener, error) {
+	if err := ioutil.WriteFile(address, []byte{}, defaultFileWriteMode); err != nil {
+		return nil, err
+	}
+	name := pipeName(address)
+	handle, err := createPipe(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeListener{name: name, next: handle}, nil
+}
+
+func dial(address string) (ipcConn, error) {
+	namePtr, err := syscall.UTF16PtrFromString(pipeName(address))
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(namePtr, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeConn{handle: handle}, nil
+}
+
+func removeAddress(address string) error {
+	return os.Remove(address)
+}
+
+//verifyPeer checks the process on the other end of the pipe runs as LocalSystem or the same user as this process
+func verifyPeer(conn ipcConn) error {
+	pc, ok := conn.(*pipeConn)
+	if !ok {
+		return fmt.Errorf("unexpected connection type %T", conn)
+	}
+	var pid uint32
+	proc := procGetNamedPipeServerProcessId
+	if pc.server {
+		proc = procGetNamedPipeClientProcessId
+	}
+	if r, _, err := proc.Call(uintptr(pc.handle), uintptr(unsafe.Pointer(&pid))); r == 0 {
+		return fmt.Errorf("failed to get peer process id: %v", err)
+	}
+	peerSid, err := processUserSid(pid)
+	if err != nil {
+		return fmt.Errorf("failed to get user of peer process %v: %v", pid, err)
+	}
+	ownSid, err := processUserSid(uint32(os.Getpid()))
+	if err != nil {
+		return err
+	}
+	if peerSid != ownSid && peerSid != localSystemSid {
+		return fmt.Errorf("peer process %v is owned by untrusted user %v", pid, peerSid)
+	}
+	return nil
+}
+
+func processUserSid(pid uint32) (string, error) {
+	process, err := syscall.OpenProcess(processQueryLimitedInfo, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(process)
+	var token syscall.Token
+	if err = syscall.OpenProcessToken(process, syscall.TOKEN_QUERY, &token); err != nil {
+		return "", err
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	return user.User.Sid.String()
+}
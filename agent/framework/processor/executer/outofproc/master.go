@@ -35,7 +35,11 @@ type OutOfProcExecuter struct {
 }
 
 var channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
-	return channel.CreateFileChannel(log, mode, documentID)
+	channelType := appconfig.DefaultIPCChannelType
+	if config, err := appconfig.Config(false); err == nil {
+		channelType = config.Agent.IPCChannelType
+	}
+	return channel.CreateChannel(log, channelType, mode, documentID)
 }
 
 var processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
@@ -26,6 +26,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
@@ -95,7 +96,7 @@ func createFileChannelAndExecutePlugin(context context.T, channelName string) {
 	log := context.Log()
 	log.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
-	ipc, err, _ := channel.CreateFileChannel(log, channel.ModeWorker, channelName)
+	ipc, err, _ := channel.CreateChannel(log, "", channel.ModeWorker, channelName)
 	if err != nil {
 		log.Errorf("failed to create channel: %v", err)
 		return
@@ -108,7 +109,10 @@ func createFileChannelAndExecutePlugin(context context.T, channelName string) {
 	stopTimer := make(chan bool)
 	pipeline := messaging.NewWorkerBackend(context, sessionPluginRunner)
 	//TODO wait for sigterm or send fail message to the channel?
-	if err = messaging.Messaging(log, ipc, pipeline, stopTimer); err != nil {
+	err = messaging.Messaging(log, ipc, pipeline, stopTimer)
+	//hand over the metrics of the session to the agent before exiting
+	metrics.Spool(log)
+	if err != nil {
 		log.Errorf("messaging worker encountered error: %v", err)
 		//If ipc messaging broke, there's nothing session worker process can do, exit immediately
 		return
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -67,7 +68,7 @@ func main() {
 	}
 	logger.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
-	ipc, err, _ := channel.CreateFileChannel(logger, channel.ModeWorker, channelName)
+	ipc, err, _ := channel.CreateChannel(logger, "", channel.ModeWorker, channelName)
 	if err != nil {
 		logger.Errorf("failed to create channel: %v", err)
 		logger.Close()
@@ -80,7 +81,10 @@ func main() {
 	stopTimer := make(chan bool)
 	pipeline := messaging.NewWorkerBackend(ctx, pluginRunner)
 	//TODO wait for sigterm or send fail message to the channel?
-	if err = messaging.Messaging(ctx.Log(), ipc, pipeline, stopTimer); err != nil {
+	err = messaging.Messaging(ctx.Log(), ipc, pipeline, stopTimer)
+	//hand over the metrics of the document to the agent before exiting
+	metrics.Spool(logger)
+	if err != nil {
 		logger.Errorf("messaging worker encountered error: %v", err)
 		//If ipc messaging broke, there's nothing worker process can do, exit immediately
 		logger.Close()
@@ -32,6 +32,8 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/rundocument"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/runscript"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/updatessmagent"
+	"github.com/aws/amazon-ssm-agent/agent/session/plugins/noninteractivecommands"
+	"github.com/aws/amazon-ssm-agent/agent/session/plugins/port"
 	"github.com/aws/amazon-ssm-agent/agent/session/plugins/sessionplugin"
 	"github.com/aws/amazon-ssm-agent/agent/session/plugins/shell"
 )
@@ -194,6 +196,12 @@ func loadSessionPlugins() {
 	shellPluginName := appconfig.PluginNameStandardStream
 	sessionPlugins[shellPluginName] = SessionPluginFactory{shell.NewPlugin}
 
+	portPluginName := appconfig.PluginNamePort
+	sessionPlugins[portPluginName] = SessionPluginFactory{port.NewPlugin}
+
+	nonInteractiveCommandsPluginName := appconfig.PluginNameNonInteractiveCommands
+	sessionPlugins[nonInteractiveCommandsPluginName] = SessionPluginFactory{noninteractivecommands.NewPlugin}
+
 	registeredPlugins = &sessionPlugins
 }
 
@@ -0,0 +1,261 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+)
+
+// Supported precondition operators
+const (
+	preconditionStringEquals              = "StringEquals"
+	preconditionStringNotEquals           = "StringNotEquals"
+	preconditionStringLike                = "StringLike"
+	preconditionVersionGreaterThanOrEqual = "VersionGreaterThanOrEqual"
+	preconditionExists                    = "Exists"
+	preconditionAnd                       = "And"
+	preconditionOr                        = "Or"
+)
+
+// Supported precondition operands
+const (
+	operandPlatformType    = "platformType"
+	operandPlatformName    = "platformName"
+	operandPlatformVersion = "platformVersion"
+	operandArchitecture    = "architecture"
+	operandTagPrefix       = "tag:"
+	operandEnvPrefix       = "env:"
+	operandFilePrefix      = "file:"
+)
+
+// dependencies used to resolve the value of precondition operands
+var (
+	getPlatformType    = platform.PlatformType
+	getPlatformName    = platform.PlatformName
+	getPlatformVersion = platform.PlatformVersion
+	getInstanceTag     = platform.InstanceTag
+	lookupEnv          = os.LookupEnv
+	fileExists         = fileutil.Exists
+	architecture       = runtime.GOARCH
+)
+
+// Evaluate precondition and return precondition result and unrecognized preconditions (if any)
+// All the preconditions of a step must be satisfied for the step to be executed.
+func evaluatePreconditions(
+	log log.T,
+	preconditions map[string]interface{},
+) (bool, []string) {
+
+	var isAllowed = true
+	var unrecognizedPreconditionList []string
+
+	for key, value := range preconditions {
+		allowed, unrecognized := evaluatePrecondition(log, key, value)
+		isAllowed = isAllowed && allowed
+		unrecognizedPreconditionList = append(unrecognizedPreconditionList, unrecognized...)
+	}
+
+	return isAllowed, unrecognizedPreconditionList
+}
+
+// evaluatePrecondition evaluates a single operator and its operands, nested preconditions are evaluated for And and Or.
+// Unrecognized preconditions do not prevent the step from being allowed, they are reported as failures instead.
+func evaluatePrecondition(log log.T, key string, value interface{}) (isAllowed bool, unrecognizedPreconditionList []string) {
+	unrecognized := []string{fmt.Sprintf("\"%s\": %v", key, value)}
+
+	switch key {
+	case preconditionAnd, preconditionOr:
+		conditions, ok := toPreconditionList(value)
+		if !ok || len(conditions) == 0 {
+			return true, unrecognized
+		}
+
+		isAllowed = key == preconditionAnd
+		for _, condition := range conditions {
+			allowed, nestedUnrecognized := evaluatePreconditions(log, condition)
+			unrecognizedPreconditionList = append(unrecognizedPreconditionList, nestedUnrecognized...)
+			if key == preconditionAnd {
+				isAllowed = isAllowed && allowed
+			} else {
+				isAllowed = isAllowed || allowed
+			}
+		}
+		return isAllowed, unrecognizedPreconditionList
+
+	case preconditionExists:
+		operands, ok := toStringList(value)
+		if !ok || len(operands) != 1 || !isPreconditionOperand(operands[0]) {
+			return true, unrecognized
+		}
+		_, found := getOperandValue(log, operands[0])
+		return found, nil
+
+	case preconditionStringEquals, preconditionStringNotEquals, preconditionStringLike, preconditionVersionGreaterThanOrEqual:
+		operands, ok := toStringList(value)
+		if !ok || len(operands) != 2 {
+			return true, unrecognized
+		}
+
+		// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
+		// and "StringEquals": ["Windows", "platformType"] are valid
+		var operand, expected string
+		switch {
+		case isPreconditionOperand(operands[0]) && !isPreconditionOperand(operands[1]):
+			operand, expected = operands[0], operands[1]
+		case !isPreconditionOperand(operands[0]) && isPreconditionOperand(operands[1]):
+			operand, expected = operands[1], operands[0]
+		default:
+			return true, unrecognized
+		}
+
+		// file operand can only be checked for existence
+		if strings.HasPrefix(operand, operandFilePrefix) {
+			return true, unrecognized
+		}
+		if key == preconditionStringLike {
+			if _, err := path.Match(expected, ""); err != nil {
+				return true, unrecognized
+			}
+		}
+
+		actual, found := getOperandValue(log, operand)
+		log.Debugf("Precondition operand %s = %s", operand, actual)
+		return compareOperandValue(log, key, actual, found, expected), nil
+
+	default:
+		// mark for unrecognizedPrecondition (which is a form of failure)
+		return true, unrecognized
+	}
+}
+
+// compareOperandValue compares the value of the operand on the instance with the value expected by the precondition.
+// String comparisons are case insensitive.
+func compareOperandValue(log log.T, operator string, actual string, found bool, expected string) bool {
+	switch operator {
+	case preconditionStringEquals:
+		return found && strings.EqualFold(actual, expected)
+	case preconditionStringNotEquals:
+		return !found || !strings.EqualFold(actual, expected)
+	case preconditionStringLike:
+		matched, _ := path.Match(strings.ToLower(expected), strings.ToLower(actual))
+		return found && matched
+	case preconditionVersionGreaterThanOrEqual:
+		if !found {
+			return false
+		}
+		result, err := updateutil.VersionCompare(actual, expected)
+		if err != nil {
+			log.Warnf("Failed to compare version %s with %s: %v", actual, expected, err)
+			return false
+		}
+		return result >= 0
+	}
+	return false
+}
+
+// isPreconditionOperand returns true if the value is an operand supported in preconditions
+func isPreconditionOperand(value string) bool {
+	switch value {
+	case operandPlatformType, operandPlatformName, operandPlatformVersion, operandArchitecture:
+		return true
+	}
+	for _, prefix := range []string{operandTagPrefix, operandEnvPrefix, operandFilePrefix} {
+		if strings.HasPrefix(value, prefix) && len(value) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// getOperandValue returns the value of the operand on this instance and whether the value is present
+func getOperandValue(log log.T, operand string) (value string, found bool) {
+	var err error
+	switch {
+	case operand == operandPlatformType:
+		value, err = getPlatformType(log)
+	case operand == operandPlatformName:
+		value, err = getPlatformName(log)
+	case operand == operandPlatformVersion:
+		value, err = getPlatformVersion(log)
+	case operand == operandArchitecture:
+		value = architecture
+	case strings.HasPrefix(operand, operandTagPrefix):
+		value, err = getInstanceTag(strings.TrimPrefix(operand, operandTagPrefix))
+	case strings.HasPrefix(operand, operandEnvPrefix):
+		return lookupEnv(strings.TrimPrefix(operand, operandEnvPrefix))
+	case strings.HasPrefix(operand, operandFilePrefix):
+		return "", fileExists(strings.TrimPrefix(operand, operandFilePrefix))
+	}
+
+	if err != nil {
+		log.Debugf("Failed to get value of precondition operand %s: %v", operand, err)
+		return "", false
+	}
+	return value, value != ""
+}
+
+// toStringList converts the operands of a precondition to a list of strings
+func toStringList(value interface{}) ([]string, bool) {
+	switch list := value.(type) {
+	case []string:
+		return list, true
+	case []interface{}:
+		operands := make([]string, len(list))
+		for i, item := range list {
+			operand, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			operands[i] = operand
+		}
+		return operands, true
+	}
+	return nil, false
+}
+
+// toPreconditionList converts the operands of And and Or to a list of preconditions
+func toPreconditionList(value interface{}) ([]map[string]interface{}, bool) {
+	switch list := value.(type) {
+	case []map[string]interface{}:
+		return list, true
+	case []interface{}:
+		conditions := make([]map[string]interface{}, len(list))
+		for i, item := range list {
+			switch condition := item.(type) {
+			case map[string]interface{}:
+				conditions[i] = condition
+			case map[interface{}]interface{}:
+				// nested maps of documents in yaml format have keys of type interface{}
+				conditions[i] = make(map[string]interface{})
+				for key, value := range condition {
+					conditions[i][fmt.Sprint(key)] = value
+				}
+			default:
+				return nil, false
+			}
+		}
+		return conditions, true
+	}
+	return nil, false
+}
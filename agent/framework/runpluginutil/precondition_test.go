@@ -0,0 +1,124 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type preconditionTestCase struct {
+	precondition    string
+	isAllowed       bool
+	isUnrecognized  bool
+	testDescription string
+}
+
+func setPreconditionOperandsMock() func() {
+	origPlatformType, origPlatformName, origPlatformVersion := getPlatformType, getPlatformName, getPlatformVersion
+	origInstanceTag, origLookupEnv, origFileExists, origArchitecture := getInstanceTag, lookupEnv, fileExists, architecture
+
+	getPlatformType = func(log log.T) (string, error) { return "linux", nil }
+	getPlatformName = func(log log.T) (string, error) { return "Ubuntu", nil }
+	getPlatformVersion = func(log log.T) (string, error) { return "18.04", nil }
+	getInstanceTag = func(key string) (string, error) {
+		if key == "Environment" {
+			return "Production", nil
+		}
+		return "", fmt.Errorf("tag %s not found", key)
+	}
+	lookupEnv = func(key string) (string, bool) {
+		if key == "DEPLOY_STAGE" {
+			return "beta", true
+		}
+		return "", false
+	}
+	fileExists = func(filePath string) bool { return filePath == "/etc/os-release" }
+	architecture = "amd64"
+
+	return func() {
+		getPlatformType, getPlatformName, getPlatformVersion = origPlatformType, origPlatformName, origPlatformVersion
+		getInstanceTag, lookupEnv, fileExists, architecture = origInstanceTag, origLookupEnv, origFileExists, origArchitecture
+	}
+}
+
+func TestEvaluatePreconditions(t *testing.T) {
+	defer setPreconditionOperandsMock()()
+
+	testCases := []preconditionTestCase{
+		{`{"StringEquals": ["platformType", "Linux"]}`, true, false, "platform type matches"},
+		{`{"StringEquals": ["Windows", "platformType"]}`, false, false, "platform type does not match"},
+		{`{"StringEquals": ["platformName", "ubuntu"]}`, true, false, "platform name is case insensitive"},
+		{`{"StringNotEquals": ["platformName", "CentOS"]}`, true, false, "platform name not equals"},
+		{`{"StringNotEquals": ["env:MISSING", "beta"]}`, true, false, "missing operand is not equal"},
+		{`{"StringLike": ["platformVersion", "18.*"]}`, true, false, "platform version like"},
+		{`{"StringLike": ["platformName", "Amazon*"]}`, false, false, "platform name not like"},
+		{`{"StringLike": ["platformName", "[Ubuntu"]}`, true, true, "invalid pattern"},
+		{`{"VersionGreaterThanOrEqual": ["platformVersion", "16.04"]}`, true, false, "version greater"},
+		{`{"VersionGreaterThanOrEqual": ["platformVersion", "18.04"]}`, true, false, "version equal"},
+		{`{"VersionGreaterThanOrEqual": ["platformVersion", "18.10"]}`, false, false, "version smaller"},
+		{`{"StringEquals": ["architecture", "amd64"]}`, true, false, "architecture matches"},
+		{`{"StringEquals": ["tag:Environment", "Production"]}`, true, false, "instance tag matches"},
+		{`{"StringEquals": ["tag:Team", "Production"]}`, false, false, "instance tag does not exist"},
+		{`{"StringEquals": ["env:DEPLOY_STAGE", "beta"]}`, true, false, "environment variable matches"},
+		{`{"Exists": ["env:DEPLOY_STAGE"]}`, true, false, "environment variable exists"},
+		{`{"Exists": ["env:MISSING"]}`, false, false, "environment variable does not exist"},
+		{`{"Exists": ["file:/etc/os-release"]}`, true, false, "file exists"},
+		{`{"Exists": ["file:/etc/redhat-release"]}`, false, false, "file does not exist"},
+		{`{"StringEquals": ["file:/etc/os-release", "true"]}`, true, true, "file can only be checked for existence"},
+		{`{"Exists": ["platformName", "Ubuntu"]}`, true, true, "exists with too many operands"},
+		{`{"StringEquals": ["platformName", "platformType"]}`, true, true, "two operands"},
+		{`{"StringEquals": ["foo", "Linux"]}`, true, true, "unknown operand"},
+		{`{"StringMatches": ["platformName", "Ubuntu"]}`, true, true, "unknown operator"},
+		{`{"StringEquals": ["platformType", "Linux"], "StringLike": ["platformName", "Ubuntu"]}`, true, false, "multiple preconditions"},
+		{`{"StringEquals": ["platformType", "Linux"], "StringLike": ["platformName", "RedHat"]}`, false, false, "multiple preconditions one fails"},
+		{`{"And": [{"StringEquals": ["platformType", "Linux"]}, {"VersionGreaterThanOrEqual": ["platformVersion", "16.04"]}]}`, true, false, "and matches"},
+		{`{"And": [{"StringEquals": ["platformType", "Linux"]}, {"StringEquals": ["platformName", "RedHat"]}]}`, false, false, "and does not match"},
+		{`{"Or": [{"StringEquals": ["platformName", "Amazon Linux"]}, {"StringEquals": ["platformName", "Ubuntu"]}]}`, true, false, "or matches"},
+		{`{"Or": [{"StringEquals": ["platformName", "Amazon Linux"]}, {"StringEquals": ["platformName", "RedHat"]}]}`, false, false, "or does not match"},
+		{`{"Or": [{"And": [{"StringEquals": ["platformName", "Ubuntu"]}, {"StringLike": ["platformVersion", "18.*"]}]}, {"StringEquals": ["platformName", "RedHat"]}]}`, true, false, "nested combinators"},
+		{`{"Or": [{"StringEquals": ["platformName", "Ubuntu"]}, {"foo": ["platformName", "RedHat"]}]}`, true, true, "nested unknown operator"},
+		{`{"And": []}`, true, true, "empty combinator"},
+		{`{"And": ["platformType", "Linux"]}`, true, true, "combinator without preconditions"},
+	}
+
+	for _, testCase := range testCases {
+		var preconditions map[string]interface{}
+		err := json.Unmarshal([]byte(testCase.precondition), &preconditions)
+		assert.Nil(t, err, testCase.testDescription)
+
+		isAllowed, unrecognized := evaluatePreconditions(log.NewMockLog(), preconditions)
+		assert.Equal(t, testCase.isAllowed, isAllowed, testCase.testDescription)
+		assert.Equal(t, testCase.isUnrecognized, len(unrecognized) > 0, testCase.testDescription)
+	}
+}
+
+func TestEvaluatePreconditionsWithYamlMap(t *testing.T) {
+	defer setPreconditionOperandsMock()()
+
+	preconditions := map[string]interface{}{
+		"Or": []interface{}{
+			map[interface{}]interface{}{"StringEquals": []interface{}{"platformName", "Ubuntu"}},
+			map[interface{}]interface{}{"StringEquals": []interface{}{"platformName", "RedHat"}},
+		},
+	}
+	isAllowed, unrecognized := evaluatePreconditions(log.NewMockLog(), preconditions)
+	assert.True(t, isAllowed)
+	assert.Empty(t, unrecognized)
+}
@@ -15,6 +15,7 @@
 package runpluginutil
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -26,7 +27,8 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
-	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
+	"github.com/aws/amazon-ssm-agent/agent/parameters"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -35,6 +37,20 @@ const (
 	executeStep string = "execute"
 	skipStep    string = "skip"
 	failStep    string = "fail"
+
+	// unknownPluginMetricName is reported for the plugins this agent doesn't know, to bound the number of series
+	unknownPluginMetricName = "unknown"
+
+	// cancelCheckInterval is how often the cancel flag of the document is polled while a step with a timeout runs
+	cancelCheckInterval = 100 * time.Millisecond
+)
+
+var (
+	pluginExecutions = metrics.NewCounter("ssm_agent_plugin_executions_total",
+		"Number of plugin steps completed, by plugin name and result status.", "plugin", "status")
+	pluginDuration = metrics.NewHistogram("ssm_agent_plugin_duration_seconds",
+		"Duration of the plugin steps which were run, including their retries.",
+		metrics.DefaultDurationBuckets, "plugin")
 )
 
 // TODO: rename to RCPlugin, this represents RCPlugin interface.
@@ -75,7 +91,9 @@ var allPlugins = map[string]struct{}{
 
 // allSessionPlugins is the list of all known session plugins.
 var allSessionPlugins = map[string]struct{}{
-	appconfig.PluginNameStandardStream: {},
+	appconfig.PluginNameStandardStream:         {},
+	appconfig.PluginNamePort:                   {},
+	appconfig.PluginNameNonInteractiveCommands: {},
 }
 
 // Assign method to global variables to allow unittest to override
@@ -99,6 +117,12 @@ func RunPlugins(
 	//Contains the logStreamPrefix without the pluginID
 	logStreamPrefix := ioConfig.CloudWatchConfig.LogStreamPrefix
 
+	// abortedBy is the id of the failed step which requested the remaining steps to be skipped
+	var abortedBy string
+
+	// stepOutputs holds the outputs of the completed steps for the references of the later steps, indexed by step id
+	stepOutputs := make(map[string]map[string]interface{})
+
 	for _, pluginState := range plugins {
 		pluginID := pluginState.Id     // the identifier of the plugin
 		pluginName := pluginState.Name // the name of the plugin
@@ -128,6 +152,24 @@ func RunPlugins(
 		default:
 			context.Log().Debugf("plugin - %v already executed, skipping...",
 				pluginName)
+			// the outputs of the steps completed before a reboot are restored from the document state
+			if len(pluginOutput.Outputs) > 0 {
+				stepOutputs[pluginID] = pluginOutput.Outputs
+			}
+			if abortedBy == "" && shouldAbort(pluginState.Configuration, pluginOutput.Status) {
+				abortedBy = pluginID
+			}
+			continue
+		}
+
+		if abortedBy != "" {
+			logMessage := fmt.Sprintf("Step execution skipped due to failure of step %v with onFailure set to %v", abortedBy, contracts.OnFailureAbort)
+			context.Log().Info(logMessage)
+			pluginOutputs[pluginID].Status = contracts.ResultStatusSkipped
+			pluginOutputs[pluginID].Code = 0
+			pluginOutputs[pluginID].Output = logMessage
+			pluginOutputs[pluginID].EndDateTime = time.Now()
+			resChan <- *pluginOutputs[pluginID]
 			continue
 		}
 
@@ -170,10 +212,25 @@ func RunPlugins(
 			configuration.IsPreconditionEnabled,
 			configuration.Preconditions)
 
+		if operation == executeStep {
+			var err error
+			if configuration.Properties, err = parameters.ReplaceStepOutputs(configuration.Properties, stepOutputs, context.Log()); err != nil {
+				operation, logMessage = failStep, fmt.Sprintf("Step %v references outputs which are not available: %v", pluginID, err)
+			}
+		}
+
+		pluginMetricName := pluginName
+		if !isKnown {
+			pluginMetricName = unknownPluginMetricName
+		}
+
 		switch operation {
 		case executeStep:
 			context.Log().Infof("Running plugin %s", pluginName)
-			r = runPlugin(context, pluginFactory, pluginName, configuration, cancelFlag, ioConfig)
+			stepStart := time.Now()
+			stdout := newStepStdout(ioConfig, pluginName, configuration)
+			r = runStep(context, pluginFactory, pluginName, configuration, cancelFlag, ioConfig)
+			pluginDuration.Observe(time.Since(stepStart).Seconds(), pluginMetricName)
 			pluginOutputs[pluginID].Code = r.Code
 			pluginOutputs[pluginID].Status = r.Status
 			pluginOutputs[pluginID].Error = r.Error
@@ -181,6 +238,17 @@ func RunPlugins(
 			pluginOutputs[pluginID].StandardOutput = r.StandardOutput
 			pluginOutputs[pluginID].StandardError = r.StandardError
 			pluginOutputs[pluginID].StepName = r.StepName
+			// the steps which requested a reboot report their outputs too
+			if (r.Status == contracts.ResultStatusSuccess || r.Status == contracts.ResultStatusSuccessAndReboot) && len(configuration.Outputs) > 0 {
+				if outputs, err := collectStepOutputs(configuration, stdout); err != nil {
+					context.Log().Error(err)
+					pluginOutputs[pluginID].Status = contracts.ResultStatusFailed
+					pluginOutputs[pluginID].Error = err.Error()
+				} else {
+					pluginOutputs[pluginID].Outputs = outputs
+					stepOutputs[pluginID] = outputs
+				}
+			}
 
 		case skipStep:
 			context.Log().Info(logMessage)
@@ -188,7 +256,7 @@ func RunPlugins(
 			pluginOutputs[pluginID].Code = 0
 			pluginOutputs[pluginID].Output = logMessage
 		case failStep:
-			err := fmt.Errorf(logMessage)
+			err := errors.New(logMessage)
 			pluginOutputs[pluginID].Status = contracts.ResultStatusFailed
 			pluginOutputs[pluginID].Error = err.Error()
 			context.Log().Error(err)
@@ -201,6 +269,7 @@ func RunPlugins(
 
 		// set end time.
 		pluginOutputs[pluginID].EndDateTime = time.Now()
+		pluginExecutions.Inc(pluginMetricName, string(pluginOutputs[pluginID].Status))
 		context.Log().Infof("Sending plugin %v completion message", pluginID)
 
 		// truncate the result and send it back to buffer channel.
@@ -216,11 +285,101 @@ func RunPlugins(
 			// do not execute the the next plugin
 			break
 		}
+
+		if shouldAbort(configuration, pluginOutputs[pluginID].Status) {
+			context.Log().Infof("Step %v failed with onFailure set to %v, skipping the remaining steps", pluginID, contracts.OnFailureAbort)
+			abortedBy = pluginID
+		}
 	}
 
 	return
 }
 
+// shouldAbort returns true if the step failed and requested the remaining steps not to be executed
+func shouldAbort(config contracts.Configuration, status contracts.ResultStatus) bool {
+	return isStepFailed(status) && strings.EqualFold(config.OnFailure, contracts.OnFailureAbort)
+}
+
+// isStepFailed returns true if the status of the step is considered a failure
+func isStepFailed(status contracts.ResultStatus) bool {
+	return status == contracts.ResultStatusFailed || status == contracts.ResultStatusTimedOut
+}
+
+// runStep runs the plugin until it succeeds or the maxAttempts of the step are exhausted
+func runStep(
+	context context.T,
+	factory PluginFactory,
+	pluginName string,
+	config contracts.Configuration,
+	cancelFlag task.CancelFlag,
+	ioConfig contracts.IOConfiguration) (res contracts.PluginResult) {
+
+	maxAttempts := config.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		res = runPluginWithTimeout(context, factory, pluginName, config, cancelFlag, ioConfig)
+		if !isStepFailed(res.Status) || attempt >= maxAttempts || cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			return
+		}
+		context.Log().Infof("Step %v finished with status %v, retrying attempt %v of %v", config.PluginID, res.Status, attempt+1, maxAttempts)
+	}
+}
+
+// runPluginWithTimeout runs the plugin and cancels it if it does not complete within the timeout of the step
+func runPluginWithTimeout(
+	context context.T,
+	factory PluginFactory,
+	pluginName string,
+	config contracts.Configuration,
+	cancelFlag task.CancelFlag,
+	ioConfig contracts.IOConfiguration) (res contracts.PluginResult) {
+
+	if config.Timeout <= 0 {
+		return runPlugin(context, factory, pluginName, config, cancelFlag, ioConfig)
+	}
+
+	stepCancelFlag := task.NewChanneledCancelFlag()
+	stepDone := make(chan bool)
+	defer close(stepDone)
+	go propagateCancel(cancelFlag, stepCancelFlag, stepDone)
+
+	timeout := time.Duration(config.Timeout) * time.Second
+	timer := time.AfterFunc(timeout, func() {
+		context.Log().Infof("Step %v exceeded its timeout of %v seconds, cancelling", config.PluginID, config.Timeout)
+		stepCancelFlag.Set(task.Canceled)
+	})
+
+	res = runPlugin(context, factory, pluginName, config, stepCancelFlag, ioConfig)
+	if !timer.Stop() && stepCancelFlag.Canceled() && !cancelFlag.Canceled() && !cancelFlag.ShutDown() {
+		res.Status = contracts.ResultStatusTimedOut
+		res.Code = appconfig.CommandStoppedPreemptivelyExitCode
+		res.Error = fmt.Sprintf("Step timed out after %v seconds", config.Timeout)
+	}
+	// release any goroutine of the plugin still waiting on the step cancel flag
+	stepCancelFlag.Set(task.Completed)
+	return
+}
+
+// propagateCancel propagates the cancellation of the document to the step until the step is done.
+// The cancel flag of the document is polled since waiting on it would block until the whole document completes.
+func propagateCancel(cancelFlag task.CancelFlag, stepCancelFlag task.CancelFlag, stepDone chan bool) {
+	ticker := time.NewTicker(cancelCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stepDone:
+			return
+		case <-ticker.C:
+			if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+				stepCancelFlag.Set(cancelFlag.State())
+				return
+			}
+		}
+	}
+}
+
 func runPlugin(
 	context context.T,
 	factory PluginFactory,
@@ -306,6 +465,7 @@ func runPlugin(
 	res.Output = output.GetOutput()
 	res.StandardOutput = output.GetStdout()
 	res.StandardError = output.GetStderr()
+	res.FailureReason = output.GetFailureReason()
 
 	return
 }
@@ -343,7 +503,7 @@ func getStepExecutionOperation(
 	isSupported bool,
 	isPluginHandlerFound bool,
 	isPreconditionEnabled bool,
-	preconditions map[string][]string,
+	preconditions map[string]interface{},
 ) (string, string) {
 	log.Debugf("isSupported flag = %t", isSupported)
 	log.Debugf("isPluginHandlerFound flag = %t", isPluginHandlerFound)
@@ -418,53 +578,6 @@ func getStepExecutionOperation(
 	}
 }
 
-// Evaluate precondition and return precondition result and unrecognized preconditions (if any)
-func evaluatePreconditions(
-	log log.T,
-	preconditions map[string][]string,
-) (bool, []string) {
-
-	var isAllowed = true
-	var unrecognizedPreconditionList []string
-
-	// For current release, we only support "StringEquals" operator and "platformType"
-	// operand, so explicitly checking for those and number of operands must be 2
-	for key, value := range preconditions {
-		switch key {
-		case "StringEquals":
-			// Platform type of OS on the instance
-			instancePlatformType, _ := platform.PlatformType(log)
-			log.Debugf("OS platform type of this instance = %s", instancePlatformType)
-
-			if len(value) != 2 ||
-				(strings.Compare(value[0], "platformType") == 0 && strings.Compare(value[1], "platformType") == 0) ||
-				(strings.Compare(value[0], "platformType") != 0 && strings.Compare(value[1], "platformType") != 0) {
-
-				unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": %v", key, value))
-			} else {
-				// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
-				// and "StringEquals": ["Windows", "platformType"] are valid
-				var platformTypeValue string
-				if strings.Compare(value[0], "platformType") == 0 {
-					platformTypeValue = value[1]
-				} else {
-					platformTypeValue = value[0]
-				}
-
-				if strings.Compare(instancePlatformType, strings.ToLower(platformTypeValue)) != 0 {
-					// if precondition doesn't match for platformType, mark step for skip
-					isAllowed = false
-				}
-			}
-		default:
-			// mark for unrecognizedPrecondition (which is a form of failure)
-			unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": %v", key, value))
-		}
-	}
-
-	return isAllowed, unrecognizedPreconditionList
-}
-
 // Returns the Property's ID field from v1.2 documents or the Name field of a Step in v2.x documents.
 // This is required to generate the correct stdout/stderr s3 url
 func getStepName(pluginName string, config contracts.Configuration) (stepName string, err error) {
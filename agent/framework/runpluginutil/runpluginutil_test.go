@@ -15,13 +15,19 @@
 package runpluginutil
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -499,7 +505,7 @@ func TestRunPluginsWithCompatiblePrecondition(t *testing.T) {
 	defaultTime := time.Now()
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "Linux"}}
 
 	for index, name := range pluginNames {
 
@@ -589,7 +595,7 @@ func TestRunPluginsWithCompatiblePreconditionWithValueFirst(t *testing.T) {
 	defaultTime := time.Now()
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"Linux", "platformType"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"Linux", "platformType"}}
 
 	for index, name := range pluginNames {
 
@@ -678,7 +684,7 @@ func TestRunPluginsWithIncompatiblePrecondition(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Windows"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "Windows"}}
 
 	for index, name := range pluginNames {
 
@@ -767,7 +773,7 @@ func TestRunPluginsWithCompatiblePreconditionButMissingPluginHandler(t *testing.
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "Linux"}}
 
 	for index, name := range pluginNames {
 
@@ -854,7 +860,7 @@ func TestRunPluginsWithMoreThanOnePrecondition(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{
+	preconditions := map[string]interface{}{
 		"StringEquals": []string{"platformType", "Linux"},
 		"foo":          []string{"operand1", "operand2"},
 	}
@@ -952,7 +958,7 @@ func TestRunPluginsWithUnrecognizedPreconditionOperator(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"foo": []string{"platformType", "Linux"}}
+	preconditions := map[string]interface{}{"foo": []string{"platformType", "Linux"}}
 
 	for index, name := range pluginNames {
 
@@ -1048,7 +1054,7 @@ func TestRunPluginsWithUnrecognizedPreconditionOperand(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"foo", "Linux"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"foo", "Linux"}}
 
 	for index, name := range pluginNames {
 
@@ -1145,7 +1151,7 @@ func TestRunPluginsWithUnrecognizedPreconditionDuplicateVariable(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "platformType"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "platformType"}}
 
 	for index, name := range pluginNames {
 
@@ -1242,7 +1248,7 @@ func TestRunPluginsWithMoreThanTwoPreconditionOperands(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux", "foo"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "Linux", "foo"}}
 
 	for index, name := range pluginNames {
 
@@ -1339,7 +1345,7 @@ func TestRunPluginsWithUnknownPlugin(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := map[string]interface{}{"StringEquals": []string{"platformType", "Linux"}}
 
 	for index, name := range pluginNames {
 
@@ -1550,3 +1556,304 @@ func TestGetStepNameError(t *testing.T) {
 	_, err := getStepName(inputPluginName, config)
 	assert.Nil(t, err)
 }
+
+// failPlugin marks the output of the mocked plugin as failed
+func failPlugin(args mock.Arguments) {
+	args.Get(3).(iohandler.IOHandler).MarkAsFailed(fmt.Errorf("plugin failed"))
+}
+
+// succeedPlugin marks the output of the mocked plugin as succeeded
+func succeedPlugin(args mock.Arguments) {
+	args.Get(3).(iohandler.IOHandler).MarkAsSucceeded()
+}
+
+// waitForCancel blocks the mocked plugin until its cancel flag is set and marks the output as cancelled
+func waitForCancel(args mock.Arguments) {
+	args.Get(2).(task.CancelFlag).Wait()
+	args.Get(3).(iohandler.IOHandler).MarkAsCancelled()
+}
+
+// runStepsWithConfigs runs the given steps with mocked plugins and returns the outputs and the results sent on the channel
+func runStepsWithConfigs(t *testing.T, configs []contracts.Configuration, pluginInstances map[string]*PluginMock) (map[string]*contracts.PluginResult, []contracts.PluginResult) {
+	pluginRegistry := PluginRegistry{}
+	pluginStates := make([]contracts.PluginState, len(configs))
+	for index, config := range configs {
+		pluginFactory := new(PluginFactoryMock)
+		pluginFactory.On("Create", mock.Anything).Return(pluginInstances[config.PluginName], nil)
+		pluginRegistry[config.PluginName] = pluginFactory
+		pluginStates[index] = contracts.PluginState{
+			Name:          config.PluginName,
+			Id:            config.PluginID,
+			Configuration: config,
+		}
+	}
+
+	// keep the output files of the steps apart from the other tests
+	orchestrationDir, err := ioutil.TempDir("", "runpluginutil")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+
+	ch := make(chan contracts.PluginResult, len(configs))
+	ioConfig := contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir}
+	outputs := RunPlugins(context.NewMockDefault(), pluginStates, ioConfig, pluginRegistry, ch, task.NewChanneledCancelFlag())
+	close(ch)
+
+	var results []contracts.PluginResult
+	for result := range ch {
+		results = append(results, result)
+	}
+	return outputs, results
+}
+
+// Failing step is retried until maxAttempts is reached
+func TestRunPluginsRetriesFailedStep(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return()
+
+	outputs, results := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, MaxAttempts: 3},
+	}, pluginInstances)
+
+	pluginInstances[testPlugin1].AssertNumberOfCalls(t, "Execute", 3)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Len(t, results, 1)
+}
+
+// Step is not retried once it succeeds
+func TestRunPluginsStopsRetryingOnSuccess(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return().Once()
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(succeedPlugin).Return()
+
+	outputs, _ := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, MaxAttempts: 3},
+	}, pluginInstances)
+
+	pluginInstances[testPlugin1].AssertNumberOfCalls(t, "Execute", 2)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+}
+
+// Executions, statuses and durations of the steps are recorded per plugin name
+func TestRunPluginsRecordsMetrics(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	const metricsPlugin = "metricsPlugin"
+	pluginInstances := map[string]*PluginMock{metricsPlugin: new(PluginMock)}
+	pluginInstances[metricsPlugin].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return().Once()
+	pluginInstances[metricsPlugin].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(succeedPlugin).Return()
+
+	runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: "step1", PluginName: metricsPlugin, OnFailure: contracts.OnFailureContinue},
+		{PluginID: "step2", PluginName: metricsPlugin},
+	}, pluginInstances)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, metrics.DefaultRegistry.WriteText(&buffer))
+	assert.Contains(t, buffer.String(), "ssm_agent_plugin_executions_total{plugin=\"metricsPlugin\",status=\"Failed\"} 1\n")
+	assert.Contains(t, buffer.String(), "ssm_agent_plugin_executions_total{plugin=\"metricsPlugin\",status=\"Success\"} 1\n")
+	assert.Contains(t, buffer.String(), "ssm_agent_plugin_duration_seconds_count{plugin=\"metricsPlugin\"} 2\n")
+}
+
+// Remaining steps are skipped when a step with onFailure Abort fails
+func TestRunPluginsWithOnFailureAbort(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return()
+
+	outputs, results := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, OnFailure: "abort"},
+		{PluginID: testPlugin2, PluginName: testPlugin2},
+	}, pluginInstances)
+
+	pluginInstances[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+	assert.Contains(t, outputs[testPlugin2].Output, testPlugin1)
+	assert.Len(t, results, 2)
+}
+
+// Remaining steps are executed when a step with onFailure Continue fails
+func TestRunPluginsWithOnFailureContinue(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return()
+	pluginInstances[testPlugin2].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(succeedPlugin).Return()
+
+	outputs, _ := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, OnFailure: contracts.OnFailureContinue},
+		{PluginID: testPlugin2, PluginName: testPlugin2},
+	}, pluginInstances)
+
+	pluginInstances[testPlugin2].AssertExpectations(t)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+}
+
+// Step is cancelled and marked as timed out when it exceeds timeoutSeconds
+func TestRunPluginsWithStepTimeout(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(waitForCancel).Return()
+
+	outputs, _ := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, Timeout: 1, OnFailure: contracts.OnFailureAbort},
+		{PluginID: testPlugin2, PluginName: testPlugin2},
+	}, pluginInstances)
+
+	assert.Equal(t, contracts.ResultStatusTimedOut, outputs[testPlugin1].Status)
+	assert.Contains(t, outputs[testPlugin1].Error, "timed out")
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+}
+
+// The cancellation of the document is propagated to the step, and the propagation stops once the step is done
+func TestPropagateCancel(t *testing.T) {
+	cancelFlag := task.NewChanneledCancelFlag()
+	stepCancelFlag := task.NewChanneledCancelFlag()
+	stopped := make(chan bool)
+	go func() {
+		propagateCancel(cancelFlag, stepCancelFlag, make(chan bool))
+		stopped <- true
+	}()
+	cancelFlag.Set(task.ShutDown)
+	<-stopped
+	assert.True(t, stepCancelFlag.ShutDown())
+
+	cancelFlag = task.NewChanneledCancelFlag()
+	stepCancelFlag = task.NewChanneledCancelFlag()
+	stepDone := make(chan bool)
+	go func() {
+		propagateCancel(cancelFlag, stepCancelFlag, stepDone)
+		stopped <- true
+	}()
+	close(stepDone)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "propagation didn't stop once the step was done")
+	}
+	assert.Equal(t, task.State(0), stepCancelFlag.State())
+}
+
+// printInstance writes a JSON document to the standard output of the mocked plugin and marks it as succeeded
+func printInstance(args mock.Arguments) {
+	output := args.Get(3).(iohandler.IOHandler)
+	output.AppendInfo(`{"instances": [{"id": "i-123"}]}`)
+	output.MarkAsSucceeded()
+}
+
+// Outputs of a step are collected and substituted in the properties of the later steps
+func TestRunPluginsPassesStepOutputs(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	var properties interface{}
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(printInstance).Return()
+	pluginInstances[testPlugin2].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		properties = args.Get(1).(contracts.Configuration).Properties
+		succeedPlugin(args)
+	}).Return()
+
+	outputs, results := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, Outputs: []contracts.StepOutput{{Name: "instanceId", Selector: "instances.0.id"}}},
+		{PluginID: testPlugin2, PluginName: testPlugin2, Properties: map[string]interface{}{"runCommand": "echo {{ steps.plugin1.outputs.instanceId }}"}},
+	}, pluginInstances)
+
+	assert.Equal(t, map[string]interface{}{"instanceId": "i-123"}, outputs[testPlugin1].Outputs)
+	assert.Equal(t, map[string]interface{}{"instanceId": "i-123"}, results[0].Outputs)
+	assert.Equal(t, map[string]interface{}{"runCommand": "echo i-123"}, properties)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+}
+
+// Outputs are parsed from the whole standard output, which is truncated in the result, and also collected on reboot
+func TestRunPluginsCollectsOutputsOfLongOutputOnReboot(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	padding := strings.Repeat(" ", iohandler.DefaultOutputConfig().MaxStdoutLength)
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		output := args.Get(3).(iohandler.IOHandler)
+		output.AppendInfo(`{"padding": "` + padding + `", "id": "i-123"}`)
+		output.MarkAsSuccessWithReboot()
+	}).Return()
+
+	outputs, results := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, Outputs: []contracts.StepOutput{{Name: "instanceId", Selector: "id"}}},
+	}, pluginInstances)
+
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, outputs[testPlugin1].Status)
+	assert.Equal(t, map[string]interface{}{"instanceId": "i-123"}, outputs[testPlugin1].Outputs)
+	assert.Contains(t, results[0].StandardOutput, iohandler.DefaultOutputConfig().OutputTruncatedSuffix)
+}
+
+// Step fails when its declared outputs can't be found
+func TestRunPluginsFailsStepWithMissingOutput(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(printInstance).Return()
+
+	outputs, _ := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, Outputs: []contracts.StepOutput{{Name: "instanceId", Selector: "instances.1.id"}}},
+	}, pluginInstances)
+
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Contains(t, outputs[testPlugin1].Error, "instanceId")
+	assert.Nil(t, outputs[testPlugin1].Outputs)
+}
+
+// Step referencing the outputs of a step which didn't produce them fails without being executed
+func TestRunPluginsWithUnavailableStepOutputs(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	pluginInstances := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	pluginInstances[testPlugin1].On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(failPlugin).Return()
+
+	outputs, _ := runStepsWithConfigs(t, []contracts.Configuration{
+		{PluginID: testPlugin1, PluginName: testPlugin1, Outputs: []contracts.StepOutput{{Name: "instanceId"}}},
+		{PluginID: testPlugin2, PluginName: testPlugin2, Properties: map[string]interface{}{"runCommand": "{{ steps.plugin1.outputs.instanceId }}"}},
+	}, pluginInstances)
+
+	pluginInstances[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin2].Status)
+	assert.Contains(t, outputs[testPlugin2].Error, "steps.plugin1.outputs.instanceId")
+}
+
+// Outputs of the steps completed before a reboot are restored from the document state
+func TestRunPluginsRestoresStepOutputs(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	var properties interface{}
+	plugin := new(PluginMock)
+	plugin.On("Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		properties = args.Get(1).(contracts.Configuration).Properties
+		succeedPlugin(args)
+	}).Return()
+	pluginFactory := new(PluginFactoryMock)
+	pluginFactory.On("Create", mock.Anything).Return(plugin, nil)
+
+	pluginStates := []contracts.PluginState{
+		{
+			Name:   testPlugin1,
+			Id:     testPlugin1,
+			Result: contracts.PluginResult{Status: contracts.ResultStatusSuccess, Outputs: map[string]interface{}{"count": float64(3)}},
+		},
+		{
+			Name:          testPlugin2,
+			Id:            testPlugin2,
+			Configuration: contracts.Configuration{PluginID: testPlugin2, Properties: map[string]interface{}{"count": "{{steps.plugin1.outputs.count}}"}},
+		},
+	}
+	ch := make(chan contracts.PluginResult, len(pluginStates))
+	outputs := RunPlugins(context.NewMockDefault(), pluginStates, contracts.IOConfiguration{}, PluginRegistry{testPlugin2: pluginFactory}, ch, task.NewChanneledCancelFlag())
+	close(ch)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+	assert.Equal(t, map[string]interface{}{"count": float64(3)}, properties)
+}
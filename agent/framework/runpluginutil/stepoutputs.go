@@ -0,0 +1,147 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+)
+
+// stepStdout is the standard output of the current execution of a step, read from the file of the orchestration
+// directory which holds the whole output, unlike the plugin result. The file also holds the output of the executions
+// before a reboot, so only the part written after offset is read.
+type stepStdout struct {
+	path   string
+	offset int64
+}
+
+// newStepStdout returns the standard output of the step which is about to be executed
+func newStepStdout(ioConfig contracts.IOConfiguration, pluginName string, config contracts.Configuration) stepStdout {
+	stepName, _ := getStepName(pluginName, config)
+	stdout := stepStdout{
+		path: filepath.Join(fileutil.BuildPath(ioConfig.OrchestrationDirectory, pluginName, stepName), iohandler.DefaultOutputConfig().StdoutFileName),
+	}
+	if fileInfo, err := os.Stat(stdout.path); err == nil {
+		stdout.offset = fileInfo.Size()
+	}
+	return stdout
+}
+
+// read returns the output written by the step since it started, nothing when the step didn't write any output
+func (s stepStdout) read() (string, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = file.Seek(s.offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadAll(file)
+	return string(content), err
+}
+
+// collectStepOutputs extracts the outputs declared by the step from its standard output or the files it wrote
+func collectStepOutputs(config contracts.Configuration, stdout stepStdout) (map[string]interface{}, error) {
+	var stdoutContent string
+	stdoutRead := false
+	outputs := make(map[string]interface{})
+	for _, output := range config.Outputs {
+		if !stdoutRead && !strings.EqualFold(output.Source, contracts.StepOutputSourceFile) {
+			var err error
+			if stdoutContent, err = stdout.read(); err != nil {
+				return nil, fmt.Errorf("Failed to read the standard output of step %v: %v", config.PluginID, err)
+			}
+			stdoutRead = true
+		}
+		value, err := getStepOutput(output, stdoutContent)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get output %v of step %v: %v", output.Name, config.PluginID, err)
+		}
+		outputs[output.Name] = value
+	}
+	return outputs, nil
+}
+
+// getStepOutput reads the output, the file source and the selector require a JSON document
+func getStepOutput(output contracts.StepOutput, stdout string) (value interface{}, err error) {
+	var content []byte
+	if strings.EqualFold(output.Source, contracts.StepOutputSourceFile) {
+		if !filepath.IsAbs(output.Path) {
+			return nil, fmt.Errorf("the path %v is not absolute", output.Path)
+		}
+		if content, err = ioutil.ReadFile(output.Path); err != nil {
+			return nil, err
+		}
+	} else {
+		if output.Selector == "" {
+			return strings.TrimSpace(stdout), nil
+		}
+		content = []byte(stdout)
+	}
+
+	var document interface{}
+	if err = json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("the %v is not a JSON document: %v", sourceDescription(output), err)
+	}
+	return selectValue(document, output.Selector)
+}
+
+// selectValue returns the value at the dot-separated path of object keys and array indexes
+func selectValue(document interface{}, selector string) (interface{}, error) {
+	if selector == "" {
+		return document, nil
+	}
+	value := document
+	for _, key := range strings.Split(selector, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, found := node[key]
+			if !found {
+				return nil, fmt.Errorf("selector %v did not match, key %v not found", selector, key)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("selector %v did not match, index %v out of range", selector, key)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("selector %v did not match, cannot select %v from a value which is not an object or an array", selector, key)
+		}
+	}
+	return value, nil
+}
+
+func sourceDescription(output contracts.StepOutput) string {
+	if strings.EqualFold(output.Source, contracts.StepOutputSourceFile) {
+		return "file " + output.Path
+	}
+	return "standard output"
+}
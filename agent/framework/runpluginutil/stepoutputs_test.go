@@ -0,0 +1,119 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStepOutputFromStdout(t *testing.T) {
+	stdout := `{"instances": [{"id": "i-123", "count": 2}]}`
+
+	value, err := getStepOutput(contracts.StepOutput{Name: "stdout"}, "  some output\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "some output", value)
+
+	value, err = getStepOutput(contracts.StepOutput{Name: "id", Selector: "instances.0.id"}, stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, "i-123", value)
+
+	value, err = getStepOutput(contracts.StepOutput{Name: "instance", Source: "stdout", Selector: "instances.0"}, stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "i-123", "count": float64(2)}, value)
+
+	for _, selector := range []string{"instances.1", "instances.first", "instances.0.id.value", "images"} {
+		_, err = getStepOutput(contracts.StepOutput{Name: "id", Selector: selector}, stdout)
+		assert.Error(t, err, selector)
+	}
+
+	_, err = getStepOutput(contracts.StepOutput{Name: "id", Selector: "id"}, "not json")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "standard output is not a JSON document")
+}
+
+func TestGetStepOutputFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stepoutputs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outputs.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"version": "1.2"}`), 0600))
+
+	value, err := getStepOutput(contracts.StepOutput{Name: "version", Source: "file", Path: path, Selector: "version"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2", value)
+
+	value, err = getStepOutput(contracts.StepOutput{Name: "all", Source: "File", Path: path}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"version": "1.2"}, value)
+
+	_, err = getStepOutput(contracts.StepOutput{Name: "missing", Source: "file", Path: filepath.Join(dir, "missing.json")}, "")
+	assert.Error(t, err)
+
+	_, err = getStepOutput(contracts.StepOutput{Name: "relative", Source: "file", Path: "outputs.json"}, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not absolute")
+}
+
+// writeStepStdout writes the standard output of a step to a temporary file
+func writeStepStdout(t *testing.T, content string) (stdout stepStdout, cleanUp func()) {
+	dir, err := ioutil.TempDir("", "stepoutputs")
+	assert.NoError(t, err)
+	stdout = stepStdout{path: filepath.Join(dir, "stdout")}
+	assert.NoError(t, ioutil.WriteFile(stdout.path, []byte(content), 0600))
+	return stdout, func() { os.RemoveAll(dir) }
+}
+
+func TestCollectStepOutputs(t *testing.T) {
+	config := contracts.Configuration{
+		PluginID: "step",
+		Outputs:  []contracts.StepOutput{{Name: "text"}, {Name: "id", Selector: "id"}},
+	}
+
+	stdout, cleanUp := writeStepStdout(t, `{"id": 7}`)
+	defer cleanUp()
+	outputs, err := collectStepOutputs(config, stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"text": `{"id": 7}`, "id": float64(7)}, outputs)
+
+	assert.NoError(t, ioutil.WriteFile(stdout.path, []byte(`{"name": 7}`), 0600))
+	_, err = collectStepOutputs(config, stdout)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to get output id of step step")
+}
+
+func TestCollectStepOutputsOfCurrentExecution(t *testing.T) {
+	config := contracts.Configuration{PluginID: "step", Outputs: []contracts.StepOutput{{Name: "id", Selector: "id"}}}
+
+	// the output of the execution before a reboot is left out
+	before := `{"id": 1}`
+	stdout, cleanUp := writeStepStdout(t, before+`{"id": 2}`)
+	defer cleanUp()
+	stdout.offset = int64(len(before))
+	outputs, err := collectStepOutputs(config, stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": float64(2)}, outputs)
+
+	// a step without any output has an empty standard output
+	stdout.path = filepath.Join(filepath.Dir(stdout.path), "missing")
+	outputs, err = collectStepOutputs(contracts.Configuration{Outputs: []contracts.StepOutput{{Name: "text"}}}, stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"text": ""}, outputs)
+}
@@ -16,6 +16,7 @@ package health
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -31,6 +32,7 @@ type IHealthCheck interface {
 	ModuleExecute(context context.T) (err error)
 	ModuleRequestStop(stopType contracts.StopType) (err error)
 	GetAgentState() (a AgentState, err error)
+	GetLastHealthStatus() HealthStatus
 }
 
 // HealthCheck encapsulates the logic on configuring, starting and stopping core modules
@@ -39,6 +41,8 @@ type HealthCheck struct {
 	healthCheckStopPolicy *sdkutil.StopPolicy
 	healthJob             *scheduler.Job
 	service               ssm.Service
+	statusLock            sync.RWMutex
+	lastStatus            HealthStatus
 }
 
 const (
@@ -59,6 +63,13 @@ const (
 	Passive AgentState = 0
 )
 
+// HealthStatus is the outcome of the last health report or ping sent by the agent
+type HealthStatus struct {
+	AgentState AgentState
+	CheckTime  time.Time
+	Error      error
+}
+
 // NewHealthCheck creates a new health check core module.
 // Only one health core module must exist at a time
 func NewHealthCheck(context context.T, svc ssm.Service) *HealthCheck {
@@ -97,6 +108,7 @@ func (h *HealthCheck) updateHealth() {
 	if _, err = h.service.UpdateInstanceInformation(log, version.Version, "Active", AgentName); err != nil {
 		sdkutil.HandleAwsError(log, err, h.healthCheckStopPolicy)
 	}
+	h.recordHealthStatus(err)
 	return
 }
 
@@ -164,8 +176,28 @@ func (h *HealthCheck) ping() (err error) {
 
 // GetAgentState returns the state of the agent. It is the caller's responsibility to log the error
 func (h *HealthCheck) GetAgentState() (a AgentState, err error) {
-	if err = h.ping(); err != nil {
+	err = h.ping()
+	if state := h.recordHealthStatus(err); state == Passive {
 		return Passive, err
 	}
 	return Active, err
 }
+
+// GetLastHealthStatus returns the outcome of the last health check without contacting the service
+func (h *HealthCheck) GetLastHealthStatus() HealthStatus {
+	h.statusLock.RLock()
+	defer h.statusLock.RUnlock()
+	return h.lastStatus
+}
+
+// recordHealthStatus keeps the outcome of a health check, the agent is active when the service could be reached
+func (h *HealthCheck) recordHealthStatus(err error) AgentState {
+	state := Active
+	if err != nil {
+		state = Passive
+	}
+	h.statusLock.Lock()
+	defer h.statusLock.Unlock()
+	h.lastStatus = HealthStatus{AgentState: state, CheckTime: time.Now(), Error: err}
+	return state
+}
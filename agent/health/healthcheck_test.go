@@ -128,6 +128,11 @@ func (suite *HealthCheckTestSuite) TestGetAgentStateActive() {
 	// Assert the status is Active and the error is nil.
 	assert.Equal(suite.T(), agentState, Active, "agent state should be active")
 	assert.Nil(suite.T(), err, "GatAgentState function should always return nil as error")
+	// Assert the outcome of the ping is kept
+	status := suite.healthCheck.GetLastHealthStatus()
+	assert.Equal(suite.T(), Active, status.AgentState)
+	assert.Nil(suite.T(), status.Error)
+	assert.False(suite.T(), status.CheckTime.IsZero())
 }
 
 // Testing the GetAgentState method which should return Passive status
@@ -138,6 +143,10 @@ func (suite *HealthCheckTestSuite) TestGetAgentStatePassive() {
 	// Assert the status is Passive and h.ping() function return an error.
 	assert.Equal(suite.T(), agentState, Passive, "agent state should be Passive")
 	assert.NotNil(suite.T(), err, "GetAgentStatePassive should return error message UpdatesWithError")
+	// Assert the failed ping is kept
+	status := suite.healthCheck.GetLastHealthStatus()
+	assert.Equal(suite.T(), Passive, status.AgentState)
+	assert.Equal(suite.T(), err, status.Error)
 }
 
 //Execute the test suite
@@ -45,6 +45,20 @@ func (_m *IHealthCheck) GetAgentState() (health.AgentState, error) {
 	return r0, r1
 }
 
+// GetLastHealthStatus provides a mock function with given fields:
+func (_m *IHealthCheck) GetLastHealthStatus() health.HealthStatus {
+	ret := _m.Called()
+
+	var r0 health.HealthStatus
+	if rf, ok := ret.Get(0).(func() health.HealthStatus); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(health.HealthStatus)
+	}
+
+	return r0
+}
+
 // ModuleExecute provides a mock function with given fields: _a0
 func (_m *IHealthCheck) ModuleExecute(_a0 context.T) error {
 	ret := _m.Called(_a0)
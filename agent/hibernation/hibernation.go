@@ -17,6 +17,7 @@
 package hibernation
 
 import (
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -29,6 +30,7 @@ import (
 // Hibernate holds information about the current agent state
 type IHibernate interface {
 	ExecuteHibernation() health.AgentState
+	IsHibernating() bool
 }
 
 type Hibernate struct {
@@ -43,6 +45,9 @@ type Hibernate struct {
 
 	seelogger seelog.LoggerInterface
 	isLogged  bool
+
+	hibernatingLock sync.RWMutex
+	hibernating     bool
 }
 
 // modeChan is a channel that tracks the status of the agent
@@ -76,6 +81,9 @@ func NewHibernateMode(healthModule health.IHealthCheck, context context.T) *Hibe
 
 // ExecuteHibernation Starts the hibernate mode by blocking agent start and by scheduling health pings
 func (m *Hibernate) ExecuteHibernation() health.AgentState {
+	m.setHibernating(true)
+	defer m.setHibernating(false)
+
 	next := time.Duration(initialPingRate) * time.Second
 	m.seelogger.Info("Agent is in hibernate mode. Reducing logging. Logging will be reduced to one log per backoff period")
 	// Wait backoff time and then schedule health pings
@@ -101,6 +109,19 @@ loop:
 	}
 }
 
+// IsHibernating returns true while the agent waits in hibernate mode for the service to be reachable
+func (m *Hibernate) IsHibernating() bool {
+	m.hibernatingLock.RLock()
+	defer m.hibernatingLock.RUnlock()
+	return m.hibernating
+}
+
+func (m *Hibernate) setHibernating(hibernating bool) {
+	m.hibernatingLock.Lock()
+	defer m.hibernatingLock.Unlock()
+	m.hibernating = hibernating
+}
+
 func (m *Hibernate) healthCheck() {
 	status, err := m.healthModule.GetAgentState()
 	if err != nil && !m.isLogged {
@@ -32,6 +32,7 @@ func TestHibernation_ExecuteHibernation_AgentTurnsActive(t *testing.T) {
 
 	hibernate := NewHibernateMode(healthMock, ctx)
 	hibernate.scheduleBackOff = fakeScheduler
+	assert.False(t, hibernate.IsHibernating())
 	for i := 0; i < 4; i++ {
 		modeChan <- health.Passive
 	}
@@ -40,6 +41,8 @@ func TestHibernation_ExecuteHibernation_AgentTurnsActive(t *testing.T) {
 		status = h.ExecuteHibernation()
 		assert.Equal(t, health.Active, status)
 	}(hibernate)
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, hibernate.IsHibernating())
 	modeChan <- health.Active
 }
 
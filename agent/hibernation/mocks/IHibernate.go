@@ -36,3 +36,17 @@ func (_m *IHibernate) ExecuteHibernation() health.AgentState {
 
 	return r0
 }
+
+// IsHibernating provides a mock function with given fields:
+func (_m *IHibernate) IsHibernating() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
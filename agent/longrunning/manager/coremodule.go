@@ -220,10 +220,17 @@ func (m *Manager) ModuleExecute(context context.T) (err error) {
 			out := iohandler.NewDefaultIOHandler(log, ioConfig)
 			defer out.Close(log)
 			out.Init(log, p.Info.Name)
+			//adopt the process of the plugin if it kept running while the agent was stopped
+			restoreProcessId(p)
 			p.Handler.Start(m.context, p.Info.Configuration, "", task.NewChanneledCancelFlag(), out)
 			out.Close(log)
+			p.Info.State.ProcessId = processId(p)
+			m.runningPlugins[pluginName] = p.Info
 			m.registeredPlugins[pluginName] = p
 		}
+		if err = dataStore.Write(m.runningPlugins); err != nil {
+			log.Errorf("Failed to update datastore - because of %s", err)
+		}
 	} else {
 		log.Infof("there aren't any long running plugin to execute")
 
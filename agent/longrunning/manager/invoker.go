@@ -97,6 +97,7 @@ func (m *Manager) StartPlugin(name, configuration string, orchestrationDir strin
 	p.Info.State = plugin.PluginState{
 		LastConfigurationModifiedTime: time.Now(),
 		IsEnabled:                     true,
+		ProcessId:                     processId(p),
 	}
 
 	// TODO move persisting out of executing logic
@@ -45,9 +45,10 @@ func (m *Manager) ensurePluginsAreRunning() {
 		for n := range m.runningPlugins {
 			p, isRegistered := m.registeredPlugins[n]
 			if isRegistered && !p.Handler.IsRunning(m.context) {
-				log.Infof("Starting %s since it wasn't running before")
+				log.Infof("Starting %s since it wasn't running before", n)
+				name := n
 				//todo: we arent using task pools anymore -> change the following implementation
-				m.startPlugin.Submit(m.context.Log(), n, func(cancelFlag task.CancelFlag) {
+				m.startPlugin.Submit(m.context.Log(), name, func(cancelFlag task.CancelFlag) {
 					instanceID, _ := platform.InstanceID()
 					orchestrationRootDir := filepath.Join(
 						appconfig.DefaultDataStorePath,
@@ -66,6 +67,7 @@ func (m *Manager) ensurePluginsAreRunning() {
 					out.Init(log, p.Info.Name)
 					p.Handler.Start(m.context, p.Info.Configuration, "", cancelFlag, out)
 					out.Close(log)
+					m.persistProcessId(name, p)
 				})
 			}
 		}
@@ -74,6 +76,41 @@ func (m *Manager) ensurePluginsAreRunning() {
 	}
 }
 
+// persistProcessId updates the process id of a restarted plugin in the datastore
+func (m *Manager) persistProcessId(name string, p plugin.Plugin) {
+	if _, isProcessPlugin := p.Handler.(plugin.ProcessPlugin); !isProcessPlugin {
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	info, isRunningPlugin := m.runningPlugins[name]
+	if !isRunningPlugin {
+		return
+	}
+	info.State.ProcessId = processId(p)
+	m.runningPlugins[name] = info
+	if err := dataStore.Write(m.runningPlugins); err != nil {
+		m.context.Log().Errorf("Failed to update datastore - because of %s", err)
+	}
+}
+
+// processId returns the id of the process supervised by the plugin, or 0 if the plugin doesn't supervise a process
+func processId(p plugin.Plugin) int {
+	if processPlugin, isProcessPlugin := p.Handler.(plugin.ProcessPlugin); isProcessPlugin {
+		return processPlugin.ProcessId()
+	}
+	return 0
+}
+
+// restoreProcessId hands the persisted process id over to the plugin so it can adopt its process after an agent restart
+func restoreProcessId(p plugin.Plugin) {
+	if processPlugin, isProcessPlugin := p.Handler.(plugin.ProcessPlugin); isProcessPlugin && p.Info.State.ProcessId > 0 {
+		processPlugin.SetProcessId(p.Info.State.ProcessId)
+	}
+}
+
 // stopLifeCycleManagementJob stops periodic health checks of long running plugins
 func (m *Manager) stopLifeCycleManagementJob() {
 	if m.managingLifeCycleJob != nil {
@@ -31,6 +31,7 @@ import (
 type PluginState struct {
 	LastConfigurationModifiedTime time.Time
 	IsEnabled                     bool
+	ProcessId                     int
 }
 
 //PluginInfo reflects information about long running plugins
@@ -54,6 +55,13 @@ type LongRunningPlugin interface {
 	Stop(context context.T, cancelFlag task.CancelFlag) error
 }
 
+//ProcessPlugin is implemented by long running plugins which supervise a process that outlives the agent.
+//The process id is persisted by lrpm manager so the process can be adopted again after an agent restart.
+type ProcessPlugin interface {
+	ProcessId() int
+	SetProcessId(pid int)
+}
+
 //PluginSettings reflects settings that can be applied to long running plugins like aws:cloudWatch
 type PluginSettings struct {
 	StartType string
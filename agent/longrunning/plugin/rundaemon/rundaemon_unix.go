@@ -17,11 +17,36 @@
 package rundaemon
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
+// MinWaitBetweenRetries 60 seconds
+// A daemon which exits within this time from its startup is considered failed and restarted with backoff
+const MinWaitBetweenRetries = 60 * time.Second
+
+// MaxWaitBetweenRetries 30 minutes
+// The backoff between restarts of a failing daemon doubles up to this value
+const MaxWaitBetweenRetries = 30 * time.Minute
+
+// DefaultStopTimeout is the time given to the daemon to exit after SIGTERM before it is killed
+const DefaultStopTimeout = 10 * time.Second
+
+// stopTimeout is the time given to the daemon to exit after SIGTERM, replaced in unit tests
+var stopTimeout = DefaultStopTimeout
+
 // Plugin is the type for the configureDaemon plugin.
 type Plugin struct {
 	iohandler.PluginConfig
@@ -31,19 +56,103 @@ type Plugin struct {
 	Name string
 	// CommandLine is the command line to launch the daemon (On Windows, ame of executable or a powershell script)
 	CommandLine string
+	// Process is the daemon process, either started by this plugin or adopted after an agent restart
+	Process *os.Process
+	// ProcessStateLock lock is used to protect access to daemon state updates
+	ProcessStateLock sync.Mutex
+
+	// processExited is closed when the daemon process started by this plugin exits
+	processExited chan struct{}
+	// startTime is the time the daemon process was last started
+	startTime time.Time
+	// retryCount is the number of consecutive times the daemon exited within MinWaitBetweenRetries
+	retryCount int
+	// nextStartTime is the earliest time a failing daemon may be started again
+	nextStartTime time.Time
 }
 
 // IsRunning checks if the daemon is alive
 func (p *Plugin) IsRunning(context context.T) bool {
-	log := context.Log()
-	log.Infof("IsRunning check for daemon %v", p.Name)
-	return false // TODO:DAEMON check to see if process is alive (false for now to force regular restarts and see the logs
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	return p.isProcessRunning()
+}
+
+// ProcessId returns the process id of the daemon, or 0 if the daemon is not running
+func (p *Plugin) ProcessId() int {
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	if !p.isProcessRunning() {
+		return 0
+	}
+	return p.Process.Pid
+}
+
+// SetProcessId adopts the daemon process which was started before the agent restarted, if it is still running
+func (p *Plugin) SetProcessId(pid int) {
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	if pid <= 0 || p.isProcessRunning() || !isDaemonProcess(pid, p.ExeLocation) {
+		return
+	}
+	p.Process, _ = os.FindProcess(pid)
+	p.processExited = nil
 }
 
 // Start starts the daemon
 func (p *Plugin) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
 	log := context.Log()
-	log.Infof("Starting %v Command: %v Config: %v", p.Name, p.CommandLine, configuration)
+	if configuration == "" {
+		configuration = p.CommandLine
+	}
+
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+
+	if p.isProcessRunning() {
+		log.Infof("Daemon %v is already running with pid %v", p.Name, p.Process.Pid)
+		return nil
+	}
+	if now := time.Now(); now.Before(p.nextStartTime) {
+		log.Infof("Daemon %v failed %v times, waiting %v before starting it again", p.Name, p.retryCount, p.nextStartTime.Sub(now))
+		return nil
+	}
+
+	log.Infof("Starting %v Command: %v", p.Name, configuration)
+	daemonInvoke := exec.Command("sh", "-c", configuration)
+	daemonInvoke.Dir = p.ExeLocation
+	// run the daemon in its own process group so the whole group can be signalled on stop
+	daemonInvoke.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, stderr, err := p.openOutputFiles(log, orchestrationDir)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+	daemonInvoke.Stdout = stdout
+	daemonInvoke.Stderr = stderr
+
+	if err = daemonInvoke.Start(); err != nil {
+		log.Errorf("Error starting daemon %v: %v", p.Name, err)
+		p.recordExit(log)
+		return err
+	}
+	log.Infof("Started daemon %v with pid %v", p.Name, daemonInvoke.Process.Pid)
+
+	processExited := make(chan struct{})
+	p.Process = daemonInvoke.Process
+	p.processExited = processExited
+	p.startTime = time.Now()
+
+	go func() {
+		err := daemonInvoke.Wait()
+		log.Infof("Daemon %v with pid %v exited: %v", p.Name, daemonInvoke.Process.Pid, err)
+		p.ProcessStateLock.Lock()
+		defer p.ProcessStateLock.Unlock()
+		close(processExited)
+		p.recordExit(log)
+	}()
 	return nil
 }
 
@@ -51,5 +160,141 @@ func (p *Plugin) Start(context context.T, configuration string, orchestrationDir
 func (p *Plugin) Stop(context context.T, cancelFlag task.CancelFlag) error {
 	log := context.Log()
 	log.Infof("Stopping %v", p.Name)
+
+	p.ProcessStateLock.Lock()
+	process, processExited := p.Process, p.processExited
+	running := p.isProcessRunning()
+	p.ProcessStateLock.Unlock()
+
+	if !running {
+		log.Infof("Daemon %v is not running", p.Name)
+		return nil
+	}
+
+	// signal the process group of the daemon so that child processes are stopped as well
+	log.Infof("Sending SIGTERM to daemon %v with pid %v", p.Name, process.Pid)
+	if err := syscall.Kill(-process.Pid, syscall.SIGTERM); err != nil {
+		log.Warnf("Failed to send SIGTERM to daemon %v: %v", p.Name, err)
+	}
+	if !waitForExit(process.Pid, processExited, stopTimeout) {
+		log.Infof("Daemon %v did not exit within %v, sending SIGKILL", p.Name, stopTimeout)
+		if err := syscall.Kill(-process.Pid, syscall.SIGKILL); err != nil {
+			return fmt.Errorf("failed to kill daemon %v: %v", p.Name, err)
+		}
+		waitForExit(process.Pid, processExited, stopTimeout)
+	}
+
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	if p.Process == process {
+		p.Process = nil
+	}
+	// an explicit stop resets the backoff of the daemon
+	p.retryCount = 0
+	p.nextStartTime = time.Time{}
 	return nil
 }
+
+// isProcessRunning returns true if the daemon process is alive, must be called with ProcessStateLock held
+func (p *Plugin) isProcessRunning() bool {
+	if p.Process == nil {
+		return false
+	}
+	if p.processExited != nil {
+		select {
+		case <-p.processExited:
+			return false
+		default:
+			return true
+		}
+	}
+	// the process was adopted after an agent restart and is not a child of the agent
+	return syscall.Kill(p.Process.Pid, 0) == nil
+}
+
+// recordExit updates the backoff of the daemon after its process exited, must be called with ProcessStateLock held
+func (p *Plugin) recordExit(log log.T) {
+	p.Process = nil
+	if time.Since(p.startTime) >= MinWaitBetweenRetries {
+		p.retryCount = 0
+		p.nextStartTime = time.Time{}
+		return
+	}
+
+	p.retryCount++
+	backoff := MaxWaitBetweenRetries
+	if p.retryCount <= 6 {
+		backoff = MinWaitBetweenRetries << uint(p.retryCount-1)
+	}
+	if backoff > MaxWaitBetweenRetries {
+		backoff = MaxWaitBetweenRetries
+	}
+	p.nextStartTime = time.Now().Add(backoff)
+	log.Infof("Daemon %v exited %v times within %v of its startup, next start after %v", p.Name, p.retryCount, MinWaitBetweenRetries, backoff)
+}
+
+// openOutputFiles opens the files capturing stdout and stderr of the daemon in the orchestration directory
+func (p *Plugin) openOutputFiles(log log.T, orchestrationDir string) (stdout *os.File, stderr *os.File, err error) {
+	if orchestrationDir == "" {
+		orchestrationDir = filepath.Join(appconfig.DefaultDataStorePath, appconfig.LongRunningPluginsLocation)
+	}
+	outputDir := fileutil.BuildPath(orchestrationDir, p.Name)
+	if err = fileutil.MakeDirs(outputDir); err != nil {
+		log.Errorf("Failed to create output directory %v for daemon %v: %v", outputDir, p.Name, err)
+		return
+	}
+
+	stdoutFileName, stderrFileName := p.StdoutFileName, p.StderrFileName
+	if stdoutFileName == "" {
+		stdoutFileName = "stdout"
+	}
+	if stderrFileName == "" {
+		stderrFileName = "stderr"
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if stdout, err = os.OpenFile(filepath.Join(outputDir, stdoutFileName), flags, appconfig.ReadWriteAccess); err != nil {
+		return
+	}
+	if stderr, err = os.OpenFile(filepath.Join(outputDir, stderrFileName), flags, appconfig.ReadWriteAccess); err != nil {
+		stdout.Close()
+	}
+	return
+}
+
+// waitForExit waits until the daemon process exits or the timeout expires and returns whether the process exited
+func waitForExit(pid int, processExited chan struct{}, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		if processExited != nil {
+			select {
+			case <-processExited:
+				return true
+			case <-deadline:
+				return false
+			}
+		}
+		if syscall.Kill(pid, 0) != nil {
+			return true
+		}
+		select {
+		case <-deadline:
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// isDaemonProcess checks that the process with the given pid is alive and runs in the daemon package location.
+// This guards against the pid having been reused by an unrelated process while the agent was not running.
+func isDaemonProcess(pid int, exeLocation string) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	cwd, err := os.Readlink(fmt.Sprintf("/proc/%v/cwd", pid))
+	if err != nil {
+		// procfs is not available on every platform, rely on the process being alive
+		return !fileutil.Exists("/proc/self")
+	}
+	return filepath.Clean(cwd) == filepath.Clean(exeLocation)
+}
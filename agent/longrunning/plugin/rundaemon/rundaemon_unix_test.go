@@ -0,0 +1,152 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rundaemon implements rundaemon plugin and its configuration
+package rundaemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPlugin(t *testing.T, commandLine string) (*Plugin, string) {
+	dir, err := ioutil.TempDir("", "rundaemon")
+	assert.Nil(t, err)
+	exeLocation, _ := filepath.EvalSymlinks(dir)
+	return &Plugin{ExeLocation: exeLocation, Name: "TestDaemon", CommandLine: commandLine}, dir
+}
+
+func waitUntil(condition func() bool) bool {
+	for i := 0; i < 50; i++ {
+		if condition() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func TestStartAndStopDaemon(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p, dir := newTestPlugin(t, "sleep 30")
+	defer os.RemoveAll(dir)
+
+	err := p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.True(t, p.IsRunning(ctx))
+	pid := p.ProcessId()
+	assert.NotZero(t, pid)
+
+	// starting a running daemon is a no-op
+	err = p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, pid, p.ProcessId())
+
+	err = p.Stop(ctx, task.NewChanneledCancelFlag())
+	assert.Nil(t, err)
+	assert.False(t, p.IsRunning(ctx))
+	assert.Zero(t, p.ProcessId())
+}
+
+func TestStopDaemonIgnoringSigterm(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p, dir := newTestPlugin(t, "trap '' TERM; while true; do sleep 1; done")
+	defer os.RemoveAll(dir)
+
+	origStopTimeout := stopTimeout
+	stopTimeout = time.Second
+	defer func() { stopTimeout = origStopTimeout }()
+
+	err := p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.True(t, p.IsRunning(ctx))
+
+	err = p.Stop(ctx, task.NewChanneledCancelFlag())
+	assert.Nil(t, err)
+	assert.False(t, p.IsRunning(ctx))
+}
+
+func TestDaemonOutputIsCaptured(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p, dir := newTestPlugin(t, "")
+	defer os.RemoveAll(dir)
+
+	err := p.Start(ctx, "echo hello; echo failure >&2", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.True(t, waitUntil(func() bool { return !p.IsRunning(ctx) }))
+
+	stdout, err := ioutil.ReadFile(filepath.Join(dir, p.Name, "stdout"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(stdout))
+	stderr, err := ioutil.ReadFile(filepath.Join(dir, p.Name, "stderr"))
+	assert.Nil(t, err)
+	assert.Equal(t, "failure\n", string(stderr))
+}
+
+func TestFailingDaemonIsRestartedWithBackoff(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p, dir := newTestPlugin(t, "exit 1")
+	defer os.RemoveAll(dir)
+
+	err := p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.True(t, waitUntil(func() bool { return !p.IsRunning(ctx) }))
+
+	p.ProcessStateLock.Lock()
+	assert.Equal(t, 1, p.retryCount)
+	assert.True(t, p.nextStartTime.After(time.Now().Add(MinWaitBetweenRetries/2)))
+	p.ProcessStateLock.Unlock()
+
+	// the daemon is not started again before the backoff expires
+	p.CommandLine = "sleep 30"
+	err = p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.False(t, p.IsRunning(ctx))
+
+	p.ProcessStateLock.Lock()
+	p.nextStartTime = time.Now()
+	p.ProcessStateLock.Unlock()
+	err = p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	assert.True(t, p.IsRunning(ctx))
+	p.Stop(ctx, task.NewChanneledCancelFlag())
+}
+
+func TestSetProcessIdAdoptsRunningDaemon(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p, dir := newTestPlugin(t, "sleep 30")
+	defer os.RemoveAll(dir)
+
+	err := p.Start(ctx, "", dir, task.NewChanneledCancelFlag(), nil)
+	assert.Nil(t, err)
+	defer p.Stop(ctx, task.NewChanneledCancelFlag())
+
+	adopted := &Plugin{ExeLocation: p.ExeLocation, Name: p.Name}
+	adopted.SetProcessId(p.ProcessId())
+	assert.True(t, adopted.IsRunning(ctx))
+	assert.Equal(t, p.ProcessId(), adopted.ProcessId())
+
+	// a process running in another location is not adopted
+	other := &Plugin{ExeLocation: os.TempDir(), Name: p.Name}
+	other.SetProcessId(p.ProcessId())
+	assert.False(t, other.IsRunning(ctx))
+}
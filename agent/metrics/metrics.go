@@ -0,0 +1,306 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	// labelSeparator joins the label values of a series, it can't appear in a label value of the agent
+	labelSeparator = "\xff"
+)
+
+// DefaultDurationBuckets are the histogram buckets in seconds used for the durations of the agent
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// DefaultRegistry holds the metrics of the agent and of its worker processes
+var DefaultRegistry = NewRegistry()
+
+// Registry holds a set of metric families
+type Registry struct {
+	lock     sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family holds all the series of a metric, one per combination of label values
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	buckets    []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+// series holds the value of a metric for one combination of label values
+type series struct {
+	labelValues  []string
+	value        float64
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+// Counter is a metric which only goes up
+type Counter struct {
+	family *family
+}
+
+// Gauge is a metric which goes up and down
+type Gauge struct {
+	family *family
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	family *family
+}
+
+// NewCounter creates a counter and registers it in the default registry
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labelNames...)
+}
+
+// NewGauge creates a gauge and registers it in the default registry
+func NewGauge(name string, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labelNames...)
+}
+
+// NewHistogram creates a histogram and registers it in the default registry
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labelNames...)
+}
+
+// NewCounter creates a counter and registers it in the registry
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	return &Counter{family: r.register(name, help, typeCounter, nil, labelNames)}
+}
+
+// NewGauge creates a gauge and registers it in the registry
+func (r *Registry) NewGauge(name string, help string, labelNames ...string) *Gauge {
+	return &Gauge{family: r.register(name, help, typeGauge, nil, labelNames)}
+}
+
+// NewHistogram creates a histogram with the given upper bounds and registers it in the registry
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	sortedBuckets := append([]float64{}, buckets...)
+	sort.Float64s(sortedBuckets)
+	return &Histogram{family: r.register(name, help, typeHistogram, sortedBuckets, labelNames)}
+}
+
+// register adds a metric family to the registry, metrics are registered once when their package is initialized
+func (r *Registry) register(name string, help string, metricType string, buckets []float64, labelNames []string) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("metric %v is already registered", name))
+	}
+	f := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// Inc increments the counter of the given label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the given non negative value to the counter of the given label values
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.family.update(labelValues, func(s *series) { s.value += value })
+}
+
+// Inc increments the gauge of the given label values by one
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decrements the gauge of the given label values by one
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Add adds the given value to the gauge of the given label values
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.family.update(labelValues, func(s *series) { s.value += value })
+}
+
+// Set sets the gauge of the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.family.update(labelValues, func(s *series) { s.value = value })
+}
+
+// Observe adds an observation to the histogram of the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.family.update(labelValues, func(s *series) {
+		for i, upperBound := range h.family.buckets {
+			if value <= upperBound {
+				s.bucketCounts[i]++
+			}
+		}
+		s.sum += value
+		s.count++
+	})
+}
+
+// update applies the given change to the series of the given label values under the lock of the family
+func (f *family) update(labelValues []string, change func(s *series)) {
+	if len(labelValues) != len(f.labelNames) {
+		// a programming error which shouldn't take the agent down
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	change(f.getSeries(labelValues))
+}
+
+// getSeries returns the series of the given label values and creates it if needed, the lock must be held
+func (f *family) getSeries(labelValues []string) *series {
+	key := strings.Join(labelValues, labelSeparator)
+	s, found := f.series[key]
+	if !found {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.metricType == typeHistogram {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteText writes all the metrics of the registry in Prometheus text exposition format, sorted by name and labels
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	r.lock.RUnlock()
+	sort.Strings(names)
+
+	writer := bufio.NewWriter(w)
+	for _, name := range names {
+		r.lock.RLock()
+		f := r.families[name]
+		r.lock.RUnlock()
+		f.writeText(writer)
+	}
+	return writer.Flush()
+}
+
+// writeText writes the help, the type and the samples of the family
+func (f *family) writeText(w *bufio.Writer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.metricType)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 && len(f.labelNames) == 0 && f.metricType != typeHistogram {
+		// a metric without labels is reported before it is first updated
+		fmt.Fprintf(w, "%v 0\n", f.name)
+		return
+	}
+
+	bucketLabelNames := append(append([]string{}, f.labelNames...), "le")
+	for _, key := range keys {
+		s := f.series[key]
+		if f.metricType != typeHistogram {
+			fmt.Fprintf(w, "%v%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues), formatValue(s.value))
+			continue
+		}
+		bucketLabelValues := append(append([]string{}, s.labelValues...), "")
+		for i, upperBound := range f.buckets {
+			bucketLabelValues[len(s.labelValues)] = formatValue(upperBound)
+			fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, formatLabels(bucketLabelNames, bucketLabelValues), s.bucketCounts[i])
+		}
+		bucketLabelValues[len(s.labelValues)] = "+Inf"
+		labels := formatLabels(bucketLabelNames, bucketLabelValues)
+		fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, labels, s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", f.name, formatLabels(f.labelNames, s.labelValues), s.count)
+	}
+}
+
+// formatLabels formats the labels of a sample, e.g. {plugin="aws:runShellScript",status="Success"}
+func formatLabels(labelNames []string, labelValues []string) string {
+	if len(labelNames) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labelNames))
+	for i, labelName := range labelNames {
+		pairs[i] = fmt.Sprintf("%v=\"%v\"", labelName, escapeLabelValue(labelValues[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue formats a sample value the way Prometheus parses it
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(labelValue string) string {
+	return labelValueEscaper.Replace(labelValue)
+}
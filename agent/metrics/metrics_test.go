@@ -0,0 +1,84 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeText(t *testing.T, registry *Registry) string {
+	var buffer bytes.Buffer
+	assert.NoError(t, registry.WriteText(&buffer))
+	return buffer.String()
+}
+
+func TestWriteTextCounterAndGauge(t *testing.T) {
+	registry := NewRegistry()
+	executions := registry.NewCounter("plugin_executions_total", "Plugin executions.", "plugin", "status")
+	queued := registry.NewGauge("queued_jobs", "Jobs waiting\nfor a worker.")
+
+	executions.Inc("aws:runShellScript", "Success")
+	executions.Inc("aws:runShellScript", "Success")
+	executions.Inc("aws:runShellScript", "Failed")
+	executions.Add(-1, "aws:runShellScript", "Failed")
+	executions.Inc(`quote"back\slash`, "Success")
+	// label values which don't match the label names are ignored
+	executions.Inc("aws:runShellScript")
+
+	assert.Equal(t, `# HELP plugin_executions_total Plugin executions.
+# TYPE plugin_executions_total counter
+plugin_executions_total{plugin="aws:runShellScript",status="Failed"} 1
+plugin_executions_total{plugin="aws:runShellScript",status="Success"} 2
+plugin_executions_total{plugin="quote\"back\\slash",status="Success"} 1
+# HELP queued_jobs Jobs waiting\nfor a worker.
+# TYPE queued_jobs gauge
+queued_jobs 0
+`, writeText(t, registry))
+
+	queued.Inc()
+	queued.Inc()
+	queued.Dec()
+	assert.Contains(t, writeText(t, registry), "\nqueued_jobs 1\n")
+	queued.Set(0.5)
+	assert.Contains(t, writeText(t, registry), "\nqueued_jobs 0.5\n")
+}
+
+func TestWriteTextHistogram(t *testing.T) {
+	registry := NewRegistry()
+	duration := registry.NewHistogram("poll_duration_seconds", "Poll duration.", []float64{1, 0.1}, "service")
+
+	duration.Observe(0.05, "MessageProcessor")
+	duration.Observe(0.5, "MessageProcessor")
+	duration.Observe(2, "MessageProcessor")
+
+	assert.Equal(t, `# HELP poll_duration_seconds Poll duration.
+# TYPE poll_duration_seconds histogram
+poll_duration_seconds_bucket{service="MessageProcessor",le="0.1"} 1
+poll_duration_seconds_bucket{service="MessageProcessor",le="1"} 2
+poll_duration_seconds_bucket{service="MessageProcessor",le="+Inf"} 3
+poll_duration_seconds_sum{service="MessageProcessor"} 2.55
+poll_duration_seconds_count{service="MessageProcessor"} 3
+`, writeText(t, registry))
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("errors_total", "Errors.")
+	assert.Panics(t, func() { registry.NewGauge("errors_total", "Errors.") })
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+)
+
+const (
+	name = "Metrics"
+	// MetricsPath is the resource which returns the metrics of the agent
+	MetricsPath = "/metrics"
+	// textContentType is the content type of the Prometheus text exposition format
+	textContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	readTimeout  = 10 * time.Second
+	writeTimeout = 30 * time.Second
+)
+
+// spoolMergeInterval is the interval at which the spool is merged when the metrics aren't scraped
+var spoolMergeInterval = time.Minute
+
+// Server serves the metrics of the agent in Prometheus text format over HTTP on a loopback address
+type Server struct {
+	context       context.T
+	listenAddress string
+
+	lock       sync.Mutex
+	listener   net.Listener
+	httpServer *http.Server
+	stopMerge  chan bool
+	mergeDone  chan bool
+}
+
+// NewServer creates a metrics server for the address configured in appconfig
+func NewServer(context context.T) *Server {
+	return &Server{
+		context:       context.With("[" + name + "]"),
+		listenAddress: context.AppConfig().Agent.MetricsListenAddress,
+	}
+}
+
+// Start enables the spooling of the worker processes and serves the metrics in the background
+func (s *Server) Start() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+
+	log := s.context.Log()
+	if err = EnableSpool(); err != nil {
+		log.Warnf("Failed to create metrics spool directory %v, metrics of documents and sessions won't be reported: %v", SpoolDir, err)
+	}
+	// the workers which completed while the agent was stopped left their metrics in the spool
+	MergeSpool(log)
+	if s.listener, err = net.Listen("tcp", s.listenAddress); err != nil {
+		return fmt.Errorf("failed to listen on metrics address %v: %v", s.listenAddress, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(MetricsPath, s.handleMetrics)
+	s.httpServer = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+
+	log.Infof("Serving agent metrics on http://%v%v", s.listener.Addr(), MetricsPath)
+	go func(httpServer *http.Server, listener net.Listener) {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Metrics endpoint stopped unexpectedly: %v", err)
+		}
+	}(s.httpServer, s.listener)
+
+	s.stopMerge = make(chan bool)
+	s.mergeDone = make(chan bool)
+	go s.mergeSpoolPeriodically(spoolMergeInterval, s.stopMerge, s.mergeDone)
+	return nil
+}
+
+// mergeSpoolPeriodically merges the spool until it is stopped, so that the spool files don't pile up between scrapes
+func (s *Server) mergeSpoolPeriodically(interval time.Duration, stop chan bool, done chan bool) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			MergeSpool(s.context.Log())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops serving the metrics, the spool directory is kept for the worker processes which are still running
+func (s *Server) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener == nil {
+		return
+	}
+
+	s.context.Log().Info("Stopping metrics endpoint")
+	close(s.stopMerge)
+	<-s.mergeDone
+	s.httpServer.Close()
+	s.listener = nil
+	s.httpServer = nil
+	s.stopMerge = nil
+	s.mergeDone = nil
+}
+
+// handleMetrics returns the metrics of the agent, including the metrics spooled by the worker processes since the last scrape
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log := s.context.Log()
+	MergeSpool(log)
+
+	var buffer bytes.Buffer
+	if err := DefaultRegistry.WriteText(&buffer); err != nil {
+		log.Errorf("Failed to write metrics: %v", err)
+		http.Error(w, "failed to write metrics", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", textContentType)
+	w.Write(buffer.Bytes())
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestServer() *Server {
+	config := appconfig.SsmagentConfig{}
+	config.Agent.MetricsListenAddress = "127.0.0.1:0"
+	ctx := &context.Mock{}
+	ctx.On("Log").Return(logMock)
+	ctx.On("AppConfig").Return(config)
+	ctx.On("With", mock.AnythingOfType("string")).Return(ctx)
+	return NewServer(ctx)
+}
+
+func TestServerServesMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	origSpoolDir := SpoolDir
+	SpoolDir = filepath.Join(dir, "metrics")
+	defer func() { SpoolDir = origSpoolDir }()
+
+	counter := DefaultRegistry.NewCounter("server_test_total", "Server test.", "source")
+	defer func() {
+		DefaultRegistry.lock.Lock()
+		delete(DefaultRegistry.families, "server_test_total")
+		DefaultRegistry.lock.Unlock()
+	}()
+	counter.Inc("agent")
+
+	server := newTestServer()
+	assert.NoError(t, server.Start())
+	defer server.Stop()
+	url := "http://" + server.listener.Addr().String() + MetricsPath
+
+	// the metrics spooled by a worker are reported by the agent
+	worker := NewRegistry()
+	worker.NewCounter("server_test_total", "Server test.", "source").Inc("worker")
+	assert.NoError(t, worker.spool(SpoolDir))
+
+	response, err := http.Get(url)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, textContentType, response.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "server_test_total{source=\"agent\"} 1\nserver_test_total{source=\"worker\"} 1\n")
+
+	response, err = http.Post(url, "text/plain", strings.NewReader(""))
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func TestServerStop(t *testing.T) {
+	origSpoolDir := SpoolDir
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	SpoolDir = filepath.Join(dir, "metrics")
+	defer func() { SpoolDir = origSpoolDir }()
+
+	server := newTestServer()
+	assert.NoError(t, server.Start())
+	url := "http://" + server.listener.Addr().String() + MetricsPath
+	server.Stop()
+	server.Stop()
+
+	_, err = http.Get(url)
+	assert.Error(t, err)
+	// the spool directory is kept for the workers which are still running
+	_, err = os.Stat(SpoolDir)
+	assert.NoError(t, err)
+}
+
+func TestServerMergesSpoolWithoutScrapes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	origSpoolDir, origInterval := SpoolDir, spoolMergeInterval
+	SpoolDir = filepath.Join(dir, "metrics")
+	spoolMergeInterval = 10 * time.Millisecond
+	defer func() { SpoolDir, spoolMergeInterval = origSpoolDir, origInterval }()
+
+	DefaultRegistry.NewCounter("server_merge_test_total", "Server merge test.")
+	defer func() {
+		DefaultRegistry.lock.Lock()
+		delete(DefaultRegistry.families, "server_merge_test_total")
+		DefaultRegistry.lock.Unlock()
+	}()
+
+	// the metrics spooled while the agent was stopped are merged when it starts
+	worker := NewRegistry()
+	worker.NewCounter("server_merge_test_total", "Server merge test.").Inc()
+	assert.NoError(t, os.MkdirAll(SpoolDir, 0700))
+	assert.NoError(t, worker.spool(SpoolDir))
+
+	server := newTestServer()
+	assert.NoError(t, server.Start())
+	defer server.Stop()
+	assert.Contains(t, writeText(t, DefaultRegistry), "server_merge_test_total 1\n")
+
+	// the metrics spooled while the agent runs are merged periodically
+	assert.NoError(t, worker.spool(SpoolDir))
+	for i := 0; i < 100; i++ {
+		if fileInfos, _ := ioutil.ReadDir(SpoolDir); len(fileInfos) == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fileInfos, _ := ioutil.ReadDir(SpoolDir)
+	assert.Equal(t, 0, len(fileInfos))
+}
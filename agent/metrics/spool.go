@@ -0,0 +1,236 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Documents and sessions run in worker processes which exit once they complete.
+// Before exiting, a worker spools its counters and histograms to a file which the agent merges into its own registry.
+// The spool directory only exists while metrics are enabled in the agent, so workers don't need to load the agent configuration.
+// The agent merges the spool when it starts, periodically and on every scrape, the workers stop spooling once
+// maxSpoolFiles files are waiting so that the directory stays bounded while the agent doesn't merge it.
+
+const (
+	spoolFileExtension = ".json"
+	spoolTmpExtension  = ".tmp"
+)
+
+// SpoolDir is the directory where the worker processes spool their metrics
+var SpoolDir = filepath.Join(appconfig.DefaultDataStorePath, appconfig.MetricsRootDirName)
+
+// maxSpoolFiles is the number of spool files after which the worker processes discard their metrics
+var maxSpoolFiles = 1000
+
+// staleSpoolAge is the age after which the partial spool files of the workers which died while spooling are removed
+var staleSpoolAge = time.Hour
+
+// mergeLock prevents concurrent scrapes from merging the same spool file twice
+var mergeLock sync.Mutex
+
+// spoolFamily is the serialized form of a metric family in a spool file
+type spoolFamily struct {
+	Name   string
+	Type   string
+	Series []spoolSeries
+}
+
+// spoolSeries is the serialized form of a series in a spool file
+type spoolSeries struct {
+	LabelValues  []string
+	Value        float64  `json:",omitempty"`
+	BucketCounts []uint64 `json:",omitempty"`
+	Sum          float64  `json:",omitempty"`
+	Count        uint64   `json:",omitempty"`
+}
+
+// EnableSpool creates the spool directory so that the worker processes spool their metrics
+func EnableSpool() error {
+	return os.MkdirAll(SpoolDir, appconfig.ReadWriteExecuteAccess)
+}
+
+// DisableSpool removes the spool directory and the metrics left in it so that the worker processes stop spooling
+func DisableSpool() error {
+	return os.RemoveAll(SpoolDir)
+}
+
+// Spool writes the counters and histograms of the default registry to the spool directory.
+// It is called by the worker processes before they exit and does nothing unless metrics are enabled in the agent.
+func Spool(log log.T) {
+	if _, err := os.Stat(SpoolDir); err != nil {
+		return
+	}
+	if err := DefaultRegistry.spool(SpoolDir); err != nil {
+		log.Warnf("Failed to spool metrics: %v", err)
+	}
+}
+
+// MergeSpool adds the metrics spooled by the worker processes to the default registry and removes the spool files
+func MergeSpool(log log.T) {
+	mergeLock.Lock()
+	defer mergeLock.Unlock()
+	if err := DefaultRegistry.mergeSpool(log, SpoolDir); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to merge spooled metrics: %v", err)
+	}
+}
+
+// spool writes the counters and histograms of the registry to a new file in the given directory,
+// the file is renamed once it is complete so that the agent never merges a partial file
+func (r *Registry) spool(dir string) error {
+	families := r.snapshot()
+	if len(families) == 0 {
+		return nil
+	}
+	if isSpoolFull(dir) {
+		return fmt.Errorf("%v spool files are waiting to be merged by the agent", maxSpoolFiles)
+	}
+	content, err := json.Marshal(families)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%v-%v", os.Getpid(), time.Now().UnixNano())
+	tmpPath := filepath.Join(dir, name+spoolTmpExtension)
+	if err = ioutil.WriteFile(tmpPath, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, filepath.Join(dir, name+spoolFileExtension)); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// isSpoolFull returns true if the directory holds maxSpoolFiles files or more, or can't be read
+func isSpoolFull(dir string) bool {
+	spoolDir, err := os.Open(dir)
+	if err != nil {
+		return true
+	}
+	defer spoolDir.Close()
+	names, _ := spoolDir.Readdirnames(maxSpoolFiles)
+	return len(names) >= maxSpoolFiles
+}
+
+// snapshot returns the series of the counters and histograms which have been updated,
+// gauges describe the state of a process and aren't spooled
+func (r *Registry) snapshot() []spoolFamily {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var families []spoolFamily
+	for _, f := range r.families {
+		if f.metricType == typeGauge {
+			continue
+		}
+		f.lock.Lock()
+		spooled := spoolFamily{Name: f.name, Type: f.metricType}
+		for _, s := range f.series {
+			spooled.Series = append(spooled.Series, spoolSeries{
+				LabelValues:  s.labelValues,
+				Value:        s.value,
+				BucketCounts: append([]uint64{}, s.bucketCounts...),
+				Sum:          s.sum,
+				Count:        s.count,
+			})
+		}
+		f.lock.Unlock()
+		if len(spooled.Series) > 0 {
+			families = append(families, spooled)
+		}
+	}
+	return families
+}
+
+// mergeSpool merges and removes the spool files in the given directory
+func (r *Registry) mergeSpool(log log.T, dir string) error {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() && strings.HasSuffix(fileInfo.Name(), spoolTmpExtension) && time.Since(fileInfo.ModTime()) > staleSpoolAge {
+			os.Remove(filepath.Join(dir, fileInfo.Name()))
+			continue
+		}
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), spoolFileExtension) {
+			continue
+		}
+		path := filepath.Join(dir, fileInfo.Name())
+		if err = r.mergeFile(path); err != nil {
+			log.Warnf("Discarding spooled metrics %v: %v", fileInfo.Name(), err)
+		}
+		if err = os.Remove(path); err != nil {
+			log.Warnf("Failed to remove spooled metrics %v: %v", fileInfo.Name(), err)
+		}
+	}
+	return nil
+}
+
+// mergeFile adds the series of a spool file to the registry,
+// metrics which the agent doesn't know, or knows with another type or buckets, are skipped
+func (r *Registry) mergeFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var families []spoolFamily
+	if err = json.Unmarshal(content, &families); err != nil {
+		return err
+	}
+
+	for _, spooled := range families {
+		r.lock.RLock()
+		f, found := r.families[spooled.Name]
+		r.lock.RUnlock()
+		if !found || f.metricType != spooled.Type || f.metricType == typeGauge {
+			continue
+		}
+		for _, spooledSeries := range spooled.Series {
+			f.merge(spooledSeries)
+		}
+	}
+	return nil
+}
+
+// merge adds a spooled series to the series of the family with the same label values
+func (f *family) merge(spooled spoolSeries) {
+	if len(spooled.LabelValues) != len(f.labelNames) {
+		return
+	}
+	if f.metricType == typeHistogram && len(spooled.BucketCounts) != len(f.buckets) {
+		return
+	}
+	f.update(spooled.LabelValues, func(s *series) {
+		s.value += spooled.Value
+		for i, bucketCount := range spooled.BucketCounts {
+			s.bucketCounts[i] += bucketCount
+		}
+		s.sum += spooled.Sum
+		s.count += spooled.Count
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics implements the counters, gauges and histograms of the agent internals
+// and serves them in Prometheus text format.
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+// newTestRegistry creates the registry of an agent or a worker process with the same metrics
+func newTestRegistry() (*Registry, *Counter, *Gauge, *Histogram) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("executions_total", "Executions.", "plugin")
+	gauge := registry.NewGauge("queued_jobs", "Queued jobs.")
+	histogram := registry.NewHistogram("duration_seconds", "Duration.", []float64{1, 10}, "plugin")
+	return registry, counter, gauge, histogram
+}
+
+func TestSpoolAndMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// nothing is spooled before the worker updates its metrics
+	worker, workerCounter, workerGauge, workerHistogram := newTestRegistry()
+	assert.NoError(t, worker.spool(dir))
+	fileInfos, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 0, len(fileInfos))
+
+	workerCounter.Inc("aws:runShellScript")
+	workerGauge.Set(5)
+	workerHistogram.Observe(2, "aws:runShellScript")
+	assert.NoError(t, worker.spool(dir))
+	assert.NoError(t, worker.spool(dir))
+	// files which are being written and unreadable files are never merged twice
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1-1"+spoolTmpExtension), []byte("[{"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1-2"+spoolFileExtension), []byte("[{"), 0600))
+
+	agent, agentCounter, _, agentHistogram := newTestRegistry()
+	agentCounter.Inc("aws:runShellScript")
+	agentHistogram.Observe(20, "aws:runShellScript")
+	assert.NoError(t, agent.mergeSpool(logMock, dir))
+
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{plugin="aws:runShellScript",le="1"} 0
+duration_seconds_bucket{plugin="aws:runShellScript",le="10"} 2
+duration_seconds_bucket{plugin="aws:runShellScript",le="+Inf"} 3
+duration_seconds_sum{plugin="aws:runShellScript"} 24
+duration_seconds_count{plugin="aws:runShellScript"} 3
+# HELP executions_total Executions.
+# TYPE executions_total counter
+executions_total{plugin="aws:runShellScript"} 3
+# HELP queued_jobs Queued jobs.
+# TYPE queued_jobs gauge
+queued_jobs 0
+`, writeText(t, agent))
+
+	fileInfos, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(fileInfos))
+	assert.Equal(t, "1-1"+spoolTmpExtension, fileInfos[0].Name())
+}
+
+func TestMergeSkipsUnknownMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	worker := NewRegistry()
+	worker.NewCounter("executions_total", "Executions.", "plugin", "status").Inc("aws:runShellScript", "Success")
+	worker.NewCounter("unknown_total", "Unknown.").Inc()
+	worker.NewHistogram("duration_seconds", "Duration.", []float64{1}, "plugin").Observe(2, "aws:runShellScript")
+	assert.NoError(t, worker.spool(dir))
+
+	agent, _, _, _ := newTestRegistry()
+	assert.NoError(t, agent.mergeSpool(logMock, dir))
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+# HELP executions_total Executions.
+# TYPE executions_total counter
+# HELP queued_jobs Queued jobs.
+# TYPE queued_jobs gauge
+queued_jobs 0
+`, writeText(t, agent))
+}
+
+func TestSpoolIsBounded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	origMaxSpoolFiles := maxSpoolFiles
+	maxSpoolFiles = 2
+	defer func() { maxSpoolFiles = origMaxSpoolFiles }()
+
+	worker, workerCounter, _, _ := newTestRegistry()
+	workerCounter.Inc("aws:runShellScript")
+	assert.NoError(t, worker.spool(dir))
+	assert.NoError(t, worker.spool(dir))
+	assert.Error(t, worker.spool(dir))
+	fileInfos, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 2, len(fileInfos))
+
+	// the partial files of the workers which died while spooling are removed once they are stale
+	stalePath := filepath.Join(dir, "1-1"+spoolTmpExtension)
+	assert.NoError(t, ioutil.WriteFile(stalePath, []byte("[{"), 0600))
+	staleTime := time.Now().Add(-2 * staleSpoolAge)
+	assert.NoError(t, os.Chtimes(stalePath, staleTime, staleTime))
+
+	agent, _, _, _ := newTestRegistry()
+	assert.NoError(t, agent.mergeSpool(logMock, dir))
+	fileInfos, _ = ioutil.ReadDir(dir)
+	assert.Equal(t, 0, len(fileInfos))
+	assert.NoError(t, worker.spool(dir))
+}
+
+func TestSpoolDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	origSpoolDir := SpoolDir
+	SpoolDir = filepath.Join(dir, "metrics")
+	defer func() { SpoolDir = origSpoolDir }()
+
+	counter := DefaultRegistry.NewCounter("spool_test_total", "Spool test.")
+	defer func() {
+		DefaultRegistry.lock.Lock()
+		delete(DefaultRegistry.families, "spool_test_total")
+		DefaultRegistry.lock.Unlock()
+	}()
+	counter.Inc()
+
+	// workers don't spool unless the agent enabled metrics
+	Spool(logMock)
+	_, err = os.Stat(SpoolDir)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, EnableSpool())
+	Spool(logMock)
+	fileInfos, err := ioutil.ReadDir(SpoolDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(fileInfos))
+
+	assert.NoError(t, DisableSpool())
+	_, err = os.Stat(SpoolDir)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -39,8 +39,7 @@ const paramNameRegex = "^[a-zA-Z0-9]+$"
 //
 // Returns a new object with replaced parameters.
 func ReplaceParameters(input interface{}, parameters map[string]interface{}, logger log.T) interface{} {
-	switch input := input.(type) {
-	case string:
+	return replaceStrings(input, func(input string) interface{} {
 		// handle single parameter case first
 		for parameterName, parameterValue := range parameters {
 			if isSingleParameterString(input, parameterName) {
@@ -58,12 +57,20 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 			input = ReplaceParameter(input, parameterName, parameterValueString)
 		}
 		return input
+	}, logger)
+}
+
+// replaceStrings traverses an arbitrarily complex input object and replaces each string with the value returned by replace.
+func replaceStrings(input interface{}, replace func(string) interface{}, logger log.T) interface{} {
+	switch input := input.(type) {
+	case string:
+		return replace(input)
 
 	case []interface{}:
 		// for slices, recursively replace parameters on each element of the slice
 		out := make([]interface{}, len(input))
 		for i, v := range input {
-			out[i] = ReplaceParameters(v, parameters, logger)
+			out[i] = replaceStrings(v, replace, logger)
 		}
 		return out
 
@@ -71,7 +78,7 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 		// this case is not caught by the one above because map cannot be converted to interface{}
 		out := make([]map[string]interface{}, len(input))
 		for i, v := range input {
-			out[i] = ReplaceParameters(v, parameters, logger).(map[string]interface{})
+			out[i] = replaceStrings(v, replace, logger).(map[string]interface{})
 		}
 		return out
 
@@ -79,7 +86,7 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 		// for maps, recursively replace parameters on each value in the map
 		out := make(map[string]interface{})
 		for k, v := range input {
-			out[k] = ReplaceParameters(v, parameters, logger)
+			out[k] = replaceStrings(v, replace, logger)
 		}
 		return out
 
@@ -88,7 +95,7 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 		for k, v := range input {
 			switch k := k.(type) {
 			case string:
-				out[k] = ReplaceParameters(v, parameters, logger)
+				out[k] = replaceStrings(v, replace, logger)
 			}
 		}
 		return out
@@ -0,0 +1,83 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package parameters provides utilities to parse ssm document parameters
+package parameters
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const stepOutputNameRegex = "^[a-zA-Z0-9_-]+$"
+
+// stepOutputReferenceRegex matches the references to the outputs of previous steps, {{ steps.<name>.outputs.<key> }},
+// step names can contain dots unlike output names, so the step name ends at the last .outputs. of the reference
+var stepOutputReferenceRegex = regexp.MustCompile(`{{\s*steps\.([a-zA-Z0-9_.-]+)\.outputs\.([a-zA-Z0-9_-]+)\s*}}`)
+
+var stepOutputNameValidator = regexp.MustCompile(stepOutputNameRegex)
+
+// ReplaceStepOutputs traverses the input object like ReplaceParameters and replaces the references given as
+// {{ steps.<name>.outputs.<key> }} with the outputs of the previous steps, indexed by step name then output name.
+//
+// Returns an error listing the references which could not be resolved, they are left as is in the returned object.
+func ReplaceStepOutputs(input interface{}, outputs map[string]map[string]interface{}, logger log.T) (interface{}, error) {
+	unresolved := make(map[string]bool)
+	lookup := func(match []string) (value interface{}, found bool) {
+		if value, found = outputs[match[1]][match[2]]; !found {
+			unresolved[fmt.Sprintf("steps.%v.outputs.%v", match[1], match[2])] = true
+		}
+		return
+	}
+
+	result := replaceStrings(input, func(input string) interface{} {
+		// a string which only holds the reference is replaced with the value itself
+		if match := stepOutputReferenceRegex.FindStringSubmatch(input); match != nil && match[0] == input {
+			if value, found := lookup(match); found {
+				return value
+			}
+			return input
+		}
+
+		return stepOutputReferenceRegex.ReplaceAllStringFunc(input, func(reference string) string {
+			value, found := lookup(stepOutputReferenceRegex.FindStringSubmatch(reference))
+			if !found {
+				return reference
+			}
+			valueString, err := convertToString(value)
+			if err != nil {
+				logger.Error(err)
+			}
+			return valueString
+		})
+	}, logger)
+
+	if len(unresolved) > 0 {
+		references := make([]string, 0, len(unresolved))
+		for reference := range unresolved {
+			references = append(references, reference)
+		}
+		sort.Strings(references)
+		return result, fmt.Errorf("unresolved step outputs %v", strings.Join(references, ", "))
+	}
+	return result, nil
+}
+
+// ValidStepOutputName checks whether the name can be used as a step output name in the references.
+func ValidStepOutputName(name string) bool {
+	return stepOutputNameValidator.MatchString(name)
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// package parameters provides utilities to parse ssm document parameters
+package parameters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testStepOutputs = map[string]map[string]interface{}{
+	"createInstance": {"instanceId": "i-123", "tags": map[string]interface{}{"env": "test"}},
+}
+
+func TestReplaceStepOutputs(t *testing.T) {
+	input := map[string]interface{}{
+		"instance": "{{ steps.createInstance.outputs.instanceId }}",
+		"tags":     "{{steps.createInstance.outputs.tags}}",
+		"commands": []interface{}{
+			"echo {{ steps.createInstance.outputs.instanceId }} {{ steps.createInstance.outputs.tags }}",
+			"echo {{ instanceId }}",
+		},
+	}
+
+	result, err := ReplaceStepOutputs(input, testStepOutputs, logger)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"instance": "i-123",
+		"tags":     map[string]interface{}{"env": "test"},
+		"commands": []interface{}{
+			`echo i-123 {"env":"test"}`,
+			"echo {{ instanceId }}",
+		},
+	}, result)
+}
+
+func TestReplaceStepOutputsUnresolved(t *testing.T) {
+	input := []interface{}{
+		"{{ steps.createInstance.outputs.name }}",
+		"echo {{ steps.deleteInstance.outputs.instanceId }} {{ steps.createInstance.outputs.instanceId }}",
+	}
+
+	result, err := ReplaceStepOutputs(input, testStepOutputs, logger)
+
+	assert.Error(t, err)
+	assert.Equal(t, "unresolved step outputs steps.createInstance.outputs.name, steps.deleteInstance.outputs.instanceId", err.Error())
+	assert.Equal(t, []interface{}{
+		"{{ steps.createInstance.outputs.name }}",
+		"echo {{ steps.deleteInstance.outputs.instanceId }} i-123",
+	}, result)
+}
+
+func TestReplaceStepOutputsOfStepNameWithDots(t *testing.T) {
+	outputs := map[string]map[string]interface{}{"install.v2": {"version": "2.1"}}
+
+	result, err := ReplaceStepOutputs("echo {{ steps.install.v2.outputs.version }}", outputs, logger)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "echo 2.1", result)
+}
+
+func TestValidStepOutputName(t *testing.T) {
+	assert.True(t, ValidStepOutputName("instance_id-2"))
+	assert.False(t, ValidStepOutputName(""))
+	assert.False(t, ValidStepOutputName("instance.id"))
+	assert.False(t, ValidStepOutputName("{{id}}"))
+}
@@ -122,6 +122,18 @@ func SetAvailabilityZone(availabilityZone string) error {
 	return nil
 }
 
+// InstanceTag returns the value of the instance tag with the given key from instance metadata.
+// Tags are only available on EC2 instances which allow access to tags in instance metadata.
+func InstanceTag(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("invalid tag key")
+	}
+	if managedInstance.InstanceID() != "" {
+		return "", fmt.Errorf("instance tags are not available for managed instances")
+	}
+	return metadata.GetMetadata("tags/instance/" + key)
+}
+
 // IsManagedInstance returns if the current instance is managed instance
 func IsManagedInstance() (bool, error) {
 	instanceId, err := InstanceID()
@@ -241,3 +241,19 @@ func TestFetchAvailabilityZoneForEc2Instance(t *testing.T) {
 	assert.Equal(t, value, actualOutput)
 	assert.Equal(t, nil, actualError)
 }
+
+func TestInstanceTagForEc2Instance(t *testing.T) {
+	metadata = validMetadata
+	managedInstance = invalidRegistration
+	actualOutput, actualError := InstanceTag("Name")
+	var value, _ = metadata.GetMetadata("tags/instance/Name")
+	assert.Equal(t, value, actualOutput)
+	assert.Equal(t, nil, actualError)
+}
+
+func TestInstanceTagForOnPremisesInstance(t *testing.T) {
+	metadata = validMetadata
+	managedInstance = validRegistration
+	_, actualError := InstanceTag("Name")
+	assert.NotNil(t, actualError)
+}
@@ -139,7 +139,7 @@ func (inst *Installer) readScriptAction(action *Action, workingDir string, orche
 		BookKeepingFileName:     inst.config.BookKeepingFileName,
 		PluginName:              pluginFullName,
 		PluginID:                inst.version,
-		Preconditions:           make(map[string][]string),
+		Preconditions:           make(map[string]interface{}),
 		IsPreconditionEnabled:   false,
 		DefaultWorkingDirectory: workingDir,
 	}
@@ -23,8 +23,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitreporesource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource/privategithub"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/httpresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/s3resource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/ssmdocresource"
@@ -43,6 +45,8 @@ const (
 	GitHub      = "GitHub"      //Github represents the source type "GitHub" from where the resource can be downloaded
 	S3          = "S3"          //S3 represents the source type "S3" from where the resource is being downloaded
 	SSMDocument = "SSMDocument" //SSMDocument represents the source type as SSM Document
+	HTTP        = "HTTP"        //HTTP represents the source type "HTTP" from where the resource is being downloaded
+	Git         = "Git"         //Git represents the source type "Git" for any git repository the resource is cloned from
 
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
 
@@ -87,6 +91,10 @@ func newRemoteResource(log log.T, SourceType string, SourceInfo string) (resourc
 		return s3resource.NewS3Resource(log, SourceInfo)
 	case SSMDocument:
 		return ssmdocresource.NewSSMDocResource(SourceInfo)
+	case HTTP:
+		return httpresource.NewHTTPResource(log, SourceInfo)
+	case Git:
+		return gitreporesource.NewGitRepoResource(log, SourceInfo)
 	default:
 		return nil, fmt.Errorf("Invalid SourceType - %v", SourceType)
 	}
@@ -205,7 +213,8 @@ func validateInput(input *DownloadContentPlugin) (valid bool, err error) {
 		return false, errors.New("SourceType must be specified")
 	}
 	//ensure all entries are valid
-	if input.SourceType != GitHub && input.SourceType != S3 && input.SourceType != SSMDocument {
+	if input.SourceType != GitHub && input.SourceType != S3 && input.SourceType != SSMDocument &&
+		input.SourceType != HTTP && input.SourceType != Git {
 		return false, errors.New("Unsupported source type")
 	}
 	// ensure non-empty source info
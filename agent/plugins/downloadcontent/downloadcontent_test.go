@@ -88,6 +88,32 @@ func TestNewRemoteResource_SSMDocument(t *testing.T) {
 
 }
 
+func TestNewRemoteResource_HTTP(t *testing.T) {
+
+	locationInfo := `{
+		"url" : "https://artifacts.example.com/agent.zip",
+		"sha256" : "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+		}`
+	remoteresource, err := newRemoteResource(logger, "HTTP", locationInfo)
+
+	assert.NotNil(t, remoteresource)
+	assert.NoError(t, err)
+
+}
+
+func TestNewRemoteResource_Git(t *testing.T) {
+
+	locationInfo := `{
+		"repository" : "https://git.example.com/org/repo.git",
+		"ref" : "main"
+		}`
+	remoteresource, err := newRemoteResource(logger, "Git", locationInfo)
+
+	assert.NotNil(t, remoteresource)
+	assert.NoError(t, err)
+
+}
+
 func TestNewPlugin_RunCopyContent(t *testing.T) {
 
 	fileMock := filemock.FileSystemMock{}
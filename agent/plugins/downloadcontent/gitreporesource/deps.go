@@ -0,0 +1,57 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gitreporesource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/ssmparameterresolver"
+
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// dependency on the git executable and parameter store
+type gitRepoDeps interface {
+	RunGit(log log.T, workingDir string, env []string, args ...string) (string, error)
+	ResolveParameters(log log.T, text string) (string, error)
+}
+
+type gitRepoDepImpl struct{}
+
+var dep gitRepoDeps = &gitRepoDepImpl{}
+
+// RunGit runs git with the arguments in the working directory and returns its combined output
+func (gitRepoDepImpl) RunGit(log log.T, workingDir string, env []string, args ...string) (string, error) {
+	log.Debugf("Running git %v", strings.Join(args, " "))
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workingDir
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git %v failed: %v %v", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// ResolveParameters resolves the ssm-secure parameter references in the text
+// NOTE: Do not log the resolved value
+func (gitRepoDepImpl) ResolveParameters(log log.T, text string) (string, error) {
+	service := ssmparameterresolver.NewService()
+	return ssmparameterresolver.ResolveParametersInText(&service, log, text, ssmparameterresolver.ResolveOptions{
+		IgnoreSecureParameters: false,
+	})
+}
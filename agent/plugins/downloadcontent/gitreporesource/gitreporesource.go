@@ -0,0 +1,339 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package gitreporesource implements the methods to access resources from any git repository
+package gitreporesource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultRef      = "HEAD" //defaultRef is the ref checked out when none is specified, i.e. the default branch
+	defaultUsername = "git"  //defaultUsername is the user name sent along with the token for https repositories
+)
+
+// ssmSecureStringPattern is the pattern of the parameters holding the credentials, i.e. {{ ssm-secure:parameter-name }}
+var ssmSecureStringPattern = regexp.MustCompile(`^\s*{{\s*(ssm-secure:[\w-./]+)\s*}}\s*$`)
+
+// commitPattern matches the refs which may be a full or an abbreviated commit sha
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// gitVersionPattern matches the output of git version, for e.g. git version 2.39.2 or git version 2.35.1.windows.2
+var gitVersionPattern = regexp.MustCompile(`git version (\d+)\.(\d+)`)
+
+// scpLikeURLPattern matches repositories in the scp-like ssh syntax, for e.g. git@example.com:org/repo.git
+var scpLikeURLPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+
+// GitRepoResource is a struct for the remote resource of type Git
+type GitRepoResource struct {
+	Info GitRepoInfo
+}
+
+// GitRepoInfo represents the sourceInfo type sent by runcommand
+type GitRepoInfo struct {
+	Repository    string `json:"repository"`
+	Ref           string `json:"ref"`
+	Path          string `json:"path"`
+	Username      string `json:"username"`
+	TokenInfo     string `json:"tokenInfo"`
+	PrivateSSHKey string `json:"privateSSHKey"`
+}
+
+// NewGitRepoResource is a constructor of type GitRepoResource
+func NewGitRepoResource(log log.T, info string) (git *GitRepoResource, err error) {
+	var gitRepoInfo GitRepoInfo
+	if gitRepoInfo, err = parseSourceInfo(info); err != nil {
+		return nil, err
+	}
+
+	return &GitRepoResource{
+		Info: gitRepoInfo,
+	}, nil
+}
+
+// parseSourceInfo unmarshals the information in sourceInfo of type GitRepoInfo and returns it
+func parseSourceInfo(sourceInfo string) (gitRepoInfo GitRepoInfo, err error) {
+
+	if err = jsonutil.Unmarshal(sourceInfo, &gitRepoInfo); err != nil {
+		return gitRepoInfo, fmt.Errorf("Source Info could not be unmarshalled for source type Git. Please check JSON format of SourceInfo - %v", err)
+	}
+
+	gitRepoInfo.Repository = strings.TrimSpace(gitRepoInfo.Repository)
+	gitRepoInfo.Ref = strings.TrimSpace(gitRepoInfo.Ref)
+	if gitRepoInfo.Ref == "" {
+		gitRepoInfo.Ref = defaultRef
+	}
+	if gitRepoInfo.Username == "" {
+		gitRepoInfo.Username = defaultUsername
+	}
+	// path is relative to the root of the repository, "" and "." check out the whole repository
+	if gitRepoInfo.Path = strings.TrimSpace(gitRepoInfo.Path); gitRepoInfo.Path != "" {
+		gitRepoInfo.Path = strings.Trim(path.Clean(filepath.ToSlash(gitRepoInfo.Path)), "/")
+	}
+	if gitRepoInfo.Path == "." {
+		gitRepoInfo.Path = ""
+	}
+
+	return
+}
+
+// DownloadRemoteResource fetches the ref of the repository with a shallow clone and places the content of the path in the destination
+func (git *GitRepoResource) DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destPath string) (err error, result *remoteresource.DownloadResult) {
+	if destPath == "" {
+		destPath = appconfig.DownloadRoot
+	}
+	log.Infof("Downloading %v of git repository %v at %v", git.Info.Path, git.Info.Repository, git.Info.Ref)
+
+	if err = filesys.MakeDirs(destPath); err != nil {
+		return fmt.Errorf("Failed to create destination directory %v - %v", destPath, err), nil
+	}
+	// the checkout is placed next to the destination so that its content can be moved instead of copied
+	var checkoutDir string
+	if checkoutDir, err = ioutil.TempDir(filepath.Dir(filepath.Clean(destPath)), ".gitcheckout"); err != nil {
+		return fmt.Errorf("Failed to create directory for the git checkout - %v", err), nil
+	}
+	defer os.RemoveAll(checkoutDir)
+
+	var env []string
+	var cleanup func()
+	if env, cleanup, err = git.credentialsEnv(log); err != nil {
+		return err, nil
+	}
+	defer cleanup()
+
+	if err = git.checkout(log, checkoutDir, env); err != nil {
+		return err, nil
+	}
+
+	sourceDir := filepath.Join(checkoutDir, filepath.FromSlash(git.Info.Path))
+	if !fileutil.Exists(sourceDir) {
+		return fmt.Errorf("Path %v does not exist in git repository at %v", git.Info.Path, git.Info.Ref), nil
+	}
+
+	result = &remoteresource.DownloadResult{}
+	if result.Files, err = moveContent(sourceDir, destPath); err != nil {
+		return fmt.Errorf("Failed to move content of git repository to %v - %v", destPath, err), nil
+	}
+	return nil, result
+}
+
+// ValidateLocationInfo ensures that the required parameters of SourceInfo are specified
+func (git *GitRepoResource) ValidateLocationInfo() (valid bool, err error) {
+	// Repository is a mandatory input
+	if git.Info.Repository == "" {
+		return false, errors.New("Repository for Git SourceType must be specified")
+	}
+	// values starting with - would be interpreted as options by git
+	if strings.HasPrefix(git.Info.Repository, "-") {
+		return false, errors.New("Repository for Git SourceType is invalid")
+	}
+	if strings.HasPrefix(git.Info.Ref, "-") {
+		return false, errors.New("Ref for Git SourceType is invalid")
+	}
+	if git.Info.Path == ".." || strings.HasPrefix(git.Info.Path, "../") {
+		return false, errors.New("Path for Git SourceType must be within the repository")
+	}
+
+	if git.Info.TokenInfo != "" {
+		if !isHTTPRepository(git.Info.Repository) {
+			return false, errors.New("TokenInfo can only be used with https repositories")
+		}
+		if !ssmSecureStringPattern.MatchString(git.Info.TokenInfo) {
+			return false, errors.New("Format of specifying ssm parameter used for tokenInfo is incorrect. " +
+				"Please specify parameter as '{{ ssm-secure:parameter-name }}'")
+		}
+	}
+	if git.Info.PrivateSSHKey != "" {
+		if !isSSHRepository(git.Info.Repository) {
+			return false, errors.New("PrivateSSHKey can only be used with ssh repositories")
+		}
+		if !ssmSecureStringPattern.MatchString(git.Info.PrivateSSHKey) {
+			return false, errors.New("Format of specifying ssm parameter used for privateSSHKey is incorrect. " +
+				"Please specify parameter as '{{ ssm-secure:parameter-name }}'")
+		}
+	}
+
+	return true, nil
+}
+
+// checkout fetches the ref with depth 1 into the checkout directory, only the path is checked out if specified
+func (git *GitRepoResource) checkout(log log.T, checkoutDir string, env []string) (err error) {
+	if _, err = dep.RunGit(log, checkoutDir, env, "init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err = dep.RunGit(log, checkoutDir, env, "remote", "add", "origin", git.Info.Repository); err != nil {
+		return err
+	}
+	if git.Info.Path != "" {
+		if _, err = dep.RunGit(log, checkoutDir, env, "config", "core.sparseCheckout", "true"); err != nil {
+			return err
+		}
+		sparseCheckoutFile := filepath.Join(checkoutDir, ".git", "info", "sparse-checkout")
+		if err = fileutil.MakeDirs(filepath.Dir(sparseCheckoutFile)); err != nil {
+			return err
+		}
+		if err = fileutil.WriteAllText(sparseCheckoutFile, "/"+git.Info.Path+"\n"); err != nil {
+			return err
+		}
+	}
+	checkoutRef := "FETCH_HEAD"
+	if _, err = dep.RunGit(log, checkoutDir, env, "fetch", "--quiet", "--depth", "1", "origin", git.Info.Ref); err != nil {
+		if !commitPattern.MatchString(git.Info.Ref) {
+			return fmt.Errorf("Failed to fetch %v from git repository %v - %v", git.Info.Ref, git.Info.Repository, err)
+		}
+		// abbreviated commits can't be fetched by sha, and some servers don't allow fetching the commits which aren't
+		// advertised, the whole repository is fetched instead and the commit is checked out of its history
+		log.Infof("Failed to fetch commit %v, fetching the whole repository - %v", git.Info.Ref, err)
+		if _, err = dep.RunGit(log, checkoutDir, env, "fetch", "--quiet", "--tags", "origin", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+			return fmt.Errorf("Failed to fetch %v from git repository %v - %v", git.Info.Ref, git.Info.Repository, err)
+		}
+		checkoutRef = git.Info.Ref
+	}
+	if _, err = dep.RunGit(log, checkoutDir, env, "checkout", "--quiet", checkoutRef); err != nil {
+		return fmt.Errorf("Failed to checkout %v of git repository %v - %v", git.Info.Ref, git.Info.Repository, err)
+	}
+	return nil
+}
+
+// credentialsEnv returns the environment passing the credentials of the repository to git.
+// Credentials are passed through the environment so they don't show up in the process list.
+func (git *GitRepoResource) credentialsEnv(log log.T) (env []string, cleanup func(), err error) {
+	cleanup = func() {}
+	// never prompt for credentials which aren't configured
+	env = append(env, "GIT_TERMINAL_PROMPT=0")
+
+	if git.Info.TokenInfo != "" {
+		var token string
+		if token, err = dep.ResolveParameters(log, git.Info.TokenInfo); err != nil {
+			return nil, cleanup, fmt.Errorf("Could not resolve ssm parameter for tokenInfo. Error - %v", err)
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(git.Info.Username + ":" + token))
+		header := "Authorization: Basic " + credentials
+
+		var major, minor int
+		if major, minor, err = gitVersion(log); err != nil {
+			return nil, cleanup, err
+		}
+		switch {
+		case versionAtLeast(major, minor, 2, 31):
+			env = append(env,
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.extraHeader",
+				"GIT_CONFIG_VALUE_0="+header)
+		case versionAtLeast(major, minor, 2, 9):
+			// older versions don't read GIT_CONFIG_COUNT, they read the configuration which git -c passes to its
+			// sub processes instead, the header contains neither quotes nor backslashes which would need escaping
+			env = append(env, "GIT_CONFIG_PARAMETERS='http.extraHeader="+header+"'")
+		default:
+			return nil, cleanup, fmt.Errorf("TokenInfo requires git 2.9 or later, the installed git version is %v.%v", major, minor)
+		}
+	}
+
+	if git.Info.PrivateSSHKey != "" {
+		var privateKey string
+		if privateKey, err = dep.ResolveParameters(log, git.Info.PrivateSSHKey); err != nil {
+			return nil, cleanup, fmt.Errorf("Could not resolve ssm parameter for privateSSHKey. Error - %v", err)
+		}
+		// the key is written outside of the checkout so it never ends up in the downloaded content
+		var keyFile *os.File
+		if keyFile, err = ioutil.TempFile("", "gitkey"); err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.Remove(keyFile.Name()) }
+		_, err = keyFile.WriteString(strings.TrimSpace(privateKey) + "\n")
+		keyFile.Close()
+		if err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%v' -o IdentitiesOnly=yes -o BatchMode=yes -o StrictHostKeyChecking=accept-new", keyFile.Name()))
+	}
+	return env, cleanup, nil
+}
+
+// gitVersion returns the major and minor version of the installed git
+func gitVersion(log log.T) (major int, minor int, err error) {
+	var output string
+	if output, err = dep.RunGit(log, "", nil, "version"); err != nil {
+		return 0, 0, fmt.Errorf("Failed to get the version of git - %v", err)
+	}
+	matches := gitVersionPattern.FindStringSubmatch(output)
+	if matches == nil {
+		return 0, 0, fmt.Errorf("Failed to parse the version of git %v", strings.TrimSpace(output))
+	}
+	major, _ = strconv.Atoi(matches[1])
+	minor, _ = strconv.Atoi(matches[2])
+	return major, minor, nil
+}
+
+// versionAtLeast returns true if the version is the required version or a later one
+func versionAtLeast(major int, minor int, requiredMajor int, requiredMinor int) bool {
+	return major > requiredMajor || (major == requiredMajor && minor >= requiredMinor)
+}
+
+// isHTTPRepository returns true if the repository is accessed over http(s)
+func isHTTPRepository(repository string) bool {
+	return strings.HasPrefix(repository, "https://") || strings.HasPrefix(repository, "http://")
+}
+
+// isSSHRepository returns true if the repository is accessed over ssh
+func isSSHRepository(repository string) bool {
+	return strings.HasPrefix(repository, "ssh://") || scpLikeURLPattern.MatchString(repository)
+}
+
+// moveContent moves the files under the source directory into the destination directory, the .git directory is skipped
+func moveContent(sourceDir string, destDir string) (files []string, err error) {
+	err = filepath.Walk(sourceDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		relativePath, err := filepath.Rel(sourceDir, filePath)
+		if err != nil {
+			return err
+		}
+		destFilePath := filepath.Join(destDir, relativePath)
+		if info.IsDir() {
+			return fileutil.MakeDirs(destFilePath)
+		}
+		if err = os.Rename(filePath, destFilePath); err != nil {
+			return err
+		}
+		// symbolic links are moved along but only regular files are part of the result
+		if info.Mode().IsRegular() {
+			files = append(files, destFilePath)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,311 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gitreporesource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var logMock = log.NewMockLog()
+
+type resolverStub struct {
+	gitRepoDepImpl
+	values map[string]string
+}
+
+func (r resolverStub) ResolveParameters(log log.T, text string) (string, error) {
+	for ref, value := range r.values {
+		text = strings.Replace(text, "{{ "+ref+" }}", value, -1)
+	}
+	return text, nil
+}
+
+// versionStub reports the version of git
+type versionStub struct {
+	resolverStub
+	version string
+}
+
+func (v versionStub) RunGit(log log.T, workingDir string, env []string, args ...string) (string, error) {
+	if args[0] == "version" {
+		return v.version, nil
+	}
+	return v.resolverStub.RunGit(log, workingDir, env, args...)
+}
+
+// testRepository is a bare repository on disk with two commits, the first one is tagged v1
+type testRepository struct {
+	dir          string
+	url          string
+	firstCommit  string
+	secondCommit string
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	filePath := filepath.Join(dir, filepath.FromSlash(name))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0700))
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(content), 0600))
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, _ := ioutil.TempDir("", "gitrepo")
+	workDir := filepath.Join(dir, "work")
+	bareDir := filepath.Join(dir, "repo.git")
+	os.MkdirAll(workDir, 0700)
+
+	runGit(t, dir, "init", "--quiet", "--bare", bareDir)
+	runGit(t, workDir, "init", "--quiet")
+	writeFile(t, workDir, "README.md", "readme v1")
+	writeFile(t, workDir, "scripts/install.sh", "echo install v1")
+	writeFile(t, workDir, "scripts/lib/common.sh", "echo common")
+	runGit(t, workDir, "add", ".")
+	runGit(t, workDir, "commit", "--quiet", "-m", "first")
+	runGit(t, workDir, "tag", "v1")
+	firstCommit := runGit(t, workDir, "rev-parse", "HEAD")
+
+	writeFile(t, workDir, "README.md", "readme v2")
+	writeFile(t, workDir, "scripts/install.sh", "echo install v2")
+	runGit(t, workDir, "commit", "--quiet", "-am", "second")
+	secondCommit := runGit(t, workDir, "rev-parse", "HEAD")
+	runGit(t, workDir, "push", "--quiet", "--tags", bareDir, "HEAD:refs/heads/main")
+
+	return &testRepository{
+		dir:          dir,
+		url:          "file://" + filepath.ToSlash(bareDir),
+		firstCommit:  firstCommit,
+		secondCommit: secondCommit,
+	}
+}
+
+func download(t *testing.T, info string) (destDir string, files []string, err error) {
+	resource, err := NewGitRepoResource(logMock, info)
+	assert.NoError(t, err)
+	valid, err := resource.ValidateLocationInfo()
+	assert.True(t, valid)
+	assert.NoError(t, err)
+
+	parentDir, _ := ioutil.TempDir("", "gitdest")
+	destDir = filepath.Join(parentDir, "content")
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destDir)
+	if result != nil {
+		files = result.Files
+		sort.Strings(files)
+	}
+	return
+}
+
+func readFile(destDir string, name string) string {
+	content, _ := ioutil.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+	return string(content)
+}
+
+func TestGitRepoResource_DownloadDefaultBranch(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	destDir, files, err := download(t, fmt.Sprintf(`{"repository": "%v"}`, repo.url))
+	defer os.RemoveAll(filepath.Dir(destDir))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(destDir, "README.md"),
+		filepath.Join(destDir, "scripts", "install.sh"),
+		filepath.Join(destDir, "scripts", "lib", "common.sh"),
+	}, files)
+	assert.Equal(t, "echo install v2", readFile(destDir, "scripts/install.sh"))
+	assert.False(t, filemanager.FileSystemImpl{}.Exists(filepath.Join(destDir, ".git")))
+}
+
+func TestGitRepoResource_DownloadTagAndCommit(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	for _, ref := range []string{"v1", repo.firstCommit, "main"} {
+		destDir, _, err := download(t, fmt.Sprintf(`{"repository": "%v", "ref": "%v"}`, repo.url, ref))
+		defer os.RemoveAll(filepath.Dir(destDir))
+
+		assert.NoError(t, err, ref)
+		if ref == "main" {
+			assert.Equal(t, "readme v2", readFile(destDir, "README.md"), ref)
+		} else {
+			assert.Equal(t, "readme v1", readFile(destDir, "README.md"), ref)
+		}
+	}
+}
+
+func TestGitRepoResource_DownloadAbbreviatedCommit(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	// an abbreviated commit can't be fetched by sha, the whole repository is fetched instead
+	destDir, _, err := download(t, fmt.Sprintf(`{"repository": "%v", "ref": "%v"}`, repo.url, repo.firstCommit[:10]))
+	defer os.RemoveAll(filepath.Dir(destDir))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "readme v1", readFile(destDir, "README.md"))
+
+	destDir, _, err = download(t, fmt.Sprintf(`{"repository": "%v", "ref": "abcdef1234"}`, repo.url))
+	defer os.RemoveAll(filepath.Dir(destDir))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to checkout abcdef1234")
+}
+
+func TestGitRepoResource_DownloadSubPath(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	destDir, files, err := download(t, fmt.Sprintf(`{"repository": "%v", "ref": "v1", "path": "scripts/"}`, repo.url))
+	defer os.RemoveAll(filepath.Dir(destDir))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(destDir, "install.sh"),
+		filepath.Join(destDir, "lib", "common.sh"),
+	}, files)
+	assert.Equal(t, "echo install v1", readFile(destDir, "install.sh"))
+}
+
+func TestGitRepoResource_DownloadMissingPathOrRef(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	destDir, _, err := download(t, fmt.Sprintf(`{"repository": "%v", "path": "docs"}`, repo.url))
+	defer os.RemoveAll(filepath.Dir(destDir))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Path docs does not exist")
+
+	destDir, _, err = download(t, fmt.Sprintf(`{"repository": "%v", "ref": "v9"}`, repo.url))
+	defer os.RemoveAll(filepath.Dir(destDir))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to fetch v9")
+}
+
+func TestGitRepoResource_ValidateLocationInfo(t *testing.T) {
+	testCases := []struct {
+		info  string
+		error string
+	}{
+		{`{"repository": "https://git.example.com/org/repo.git", "tokenInfo": "{{ ssm-secure:token }}"}`, ""},
+		{`{"repository": "git@git.example.com:org/repo.git", "privateSSHKey": "{{ssm-secure:/git/key}}"}`, ""},
+		{`{"repository": "ssh://git@git.example.com/org/repo.git", "ref": "v1.0", "path": "scripts"}`, ""},
+		{`{"ref": "main"}`, "Repository for Git SourceType must be specified"},
+		{`{"repository": "--upload-pack=touch"}`, "Repository for Git SourceType is invalid"},
+		{`{"repository": "https://git.example.com/org/repo.git", "ref": "--help"}`, "Ref for Git SourceType is invalid"},
+		{`{"repository": "https://git.example.com/org/repo.git", "path": "../../etc"}`, "Path for Git SourceType must be within the repository"},
+		{`{"repository": "git@git.example.com:org/repo.git", "tokenInfo": "{{ ssm-secure:token }}"}`, "TokenInfo can only be used with https repositories"},
+		{`{"repository": "https://git.example.com/org/repo.git", "tokenInfo": "token"}`, "Format of specifying ssm parameter used for tokenInfo is incorrect"},
+		{`{"repository": "https://git.example.com/org/repo.git", "privateSSHKey": "{{ ssm-secure:key }}"}`, "PrivateSSHKey can only be used with ssh repositories"},
+		{`{"repository": "ssh://git.example.com/repo.git", "privateSSHKey": "{{ ssm:key }}"}`, "Format of specifying ssm parameter used for privateSSHKey is incorrect"},
+	}
+
+	for _, testCase := range testCases {
+		resource, err := NewGitRepoResource(logMock, testCase.info)
+		assert.NoError(t, err)
+		valid, err := resource.ValidateLocationInfo()
+		if testCase.error == "" {
+			assert.True(t, valid, testCase.info)
+			assert.NoError(t, err, testCase.info)
+		} else {
+			assert.False(t, valid, testCase.info)
+			assert.Contains(t, err.Error(), testCase.error, testCase.info)
+		}
+	}
+}
+
+func TestGitRepoResource_CredentialsEnv(t *testing.T) {
+	origDep := dep
+	resolver := resolverStub{values: map[string]string{"ssm-secure:token": "secret-token", "ssm-secure:key": "private-key"}}
+	dep = versionStub{resolverStub: resolver, version: "git version 2.39.2\n"}
+	defer func() { dep = origDep }()
+
+	resource, _ := NewGitRepoResource(logMock, `{"repository": "https://git.example.com/org/repo.git", "tokenInfo": "{{ ssm-secure:token }}"}`)
+	env, cleanup, err := resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	cleanup()
+	credentials := base64.StdEncoding.EncodeToString([]byte("git:secret-token"))
+	assert.Contains(t, env, "GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials)
+
+	resource, _ = NewGitRepoResource(logMock, `{"repository": "git@git.example.com:org/repo.git", "privateSSHKey": "{{ ssm-secure:key }}"}`)
+	env, cleanup, err = resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	sshCommand := env[len(env)-1]
+	assert.True(t, strings.HasPrefix(sshCommand, "GIT_SSH_COMMAND=ssh -i '"))
+	keyFile := strings.Split(sshCommand, "'")[1]
+	assert.Equal(t, "private-key\n", readFile(filepath.Dir(keyFile), filepath.Base(keyFile)))
+	cleanup()
+	_, err = os.Stat(keyFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGitRepoResource_CredentialsEnvOfOlderGit(t *testing.T) {
+	origDep := dep
+	resolver := resolverStub{values: map[string]string{"ssm-secure:token": "secret-token"}}
+	defer func() { dep = origDep }()
+	resource, _ := NewGitRepoResource(logMock, `{"repository": "https://git.example.com/org/repo.git", "tokenInfo": "{{ ssm-secure:token }}"}`)
+	credentials := base64.StdEncoding.EncodeToString([]byte("git:secret-token"))
+
+	dep = versionStub{resolverStub: resolver, version: "git version 2.25.1\n"}
+	env, _, err := resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	assert.Contains(t, env, "GIT_CONFIG_PARAMETERS='http.extraHeader=Authorization: Basic "+credentials+"'")
+	for _, variable := range env {
+		assert.False(t, strings.HasPrefix(variable, "GIT_CONFIG_COUNT="))
+	}
+
+	dep = versionStub{resolverStub: resolver, version: "git version 2.31.1.windows.1\n"}
+	env, _, err = resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	assert.Contains(t, env, "GIT_CONFIG_COUNT=1")
+
+	dep = versionStub{resolverStub: resolver, version: "git version 1.8.3.1\n"}
+	_, _, err = resource.credentialsEnv(logMock)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TokenInfo requires git 2.9 or later")
+}
+
+func TestGitRepoResource_GitConfigParametersAreRead(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cmd := exec.Command("git", "config", "--get", "http.extraheader")
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_PARAMETERS='http.extraHeader=Authorization: Basic Z2l0OnRva2Vu'")
+	output, err := cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "Authorization: Basic Z2l0OnRva2Vu", strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package httpresource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/ssmparameterresolver"
+)
+
+// dependency on parameter store to resolve secure values of the source info
+type httpDeps interface {
+	ResolveParameters(log log.T, text string) (string, error)
+}
+
+type httpDepImpl struct{}
+
+var dep httpDeps = &httpDepImpl{}
+
+// ResolveParameters resolves the ssm and ssm-secure parameter references in the text
+// NOTE: Do not log the resolved value
+func (httpDepImpl) ResolveParameters(log log.T, text string) (string, error) {
+	service := ssmparameterresolver.NewService()
+	return ssmparameterresolver.ResolveParametersInText(&service, log, text, ssmparameterresolver.ResolveOptions{
+		IgnoreSecureParameters: false,
+	})
+}
@@ -0,0 +1,293 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package httpresource implements the methods to access resources from http(s) servers
+package httpresource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	AuthMethodNone   = "None"   //AuthMethodNone represents a download without authentication
+	AuthMethodBasic  = "Basic"  //AuthMethodBasic represents a download using basic authentication
+	AuthMethodBearer = "Bearer" //AuthMethodBearer represents a download using a bearer token
+
+	maxRedirects = 10
+)
+
+// HTTPResource is a struct for the remote resource of type HTTP
+type HTTPResource struct {
+	client *http.Client
+	Info   HTTPInfo
+}
+
+// HTTPInfo represents the sourceInfo type sent by runcommand
+type HTTPInfo struct {
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	AuthMethod     string            `json:"authMethod"`
+	Username       string            `json:"username"`
+	Password       string            `json:"password"`
+	Token          string            `json:"token"`
+	SHA256         string            `json:"sha256"`
+	ExtractArchive bool              `json:"extractArchive"`
+}
+
+// NewHTTPResource is a constructor of type HTTPResource
+func NewHTTPResource(log log.T, info string) (resource *HTTPResource, err error) {
+	var httpInfo HTTPInfo
+	if httpInfo, err = parseSourceInfo(info); err != nil {
+		return nil, err
+	}
+
+	return &HTTPResource{
+		client: newHTTPClient(),
+		Info:   httpInfo,
+	}, nil
+}
+
+// parseSourceInfo unmarshals the information in sourceInfo of type HTTPInfo and returns it
+func parseSourceInfo(sourceInfo string) (httpInfo HTTPInfo, err error) {
+
+	if err = jsonutil.Unmarshal(sourceInfo, &httpInfo); err != nil {
+		return httpInfo, fmt.Errorf("Source Info could not be unmarshalled for source type HTTP. Please check JSON format of SourceInfo - %v", err)
+	}
+
+	// Trimming the url to remove any unnecessary spaces
+	httpInfo.URL = strings.TrimSpace(httpInfo.URL)
+	if httpInfo.AuthMethod == "" {
+		httpInfo.AuthMethod = AuthMethodNone
+	}
+
+	return
+}
+
+// newHTTPClient creates the client used for downloads. Redirects are followed as long as they don't downgrade https to http.
+// The headers and the credentials of the source info are only sent to the host of the url, they can hold secrets.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %v redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "https" && via[0].URL.Scheme == "https" {
+				return fmt.Errorf("redirect from https to %v is not allowed", req.URL.Scheme)
+			}
+			// the http client only drops the standard sensitive headers, and keeps them for subdomains
+			if req.URL.Host != via[0].URL.Host {
+				req.Header = make(http.Header)
+			}
+			return nil
+		},
+	}
+}
+
+// DownloadRemoteResource downloads the file from the http(s) url, verifies its hash and extracts it if requested
+func (h *HTTPResource) DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destPath string) (err error, result *remoteresource.DownloadResult) {
+	if destPath == "" {
+		destPath = appconfig.DownloadRoot
+	}
+	log.Info("Downloading HTTP artifact from url - ", h.Info.URL)
+
+	var request *http.Request
+	if request, err = h.newRequest(log); err != nil {
+		return err, nil
+	}
+
+	// An archive is extracted into the destination directory, a file keeps its name when downloaded to a directory
+	fileName := path.Base(request.URL.Path)
+	localFilePath := destPath
+	if h.Info.ExtractArchive || filesys.Exists(destPath) && filesys.IsDirectory(destPath) || os.IsPathSeparator(destPath[len(destPath)-1]) {
+		localFilePath = filepath.Join(destPath, fileName)
+	}
+	if err = filesys.MakeDirs(filepath.Dir(localFilePath)); err != nil {
+		return fmt.Errorf("Failed to create directory for the HTTP download - %v", err), nil
+	}
+
+	if err = h.download(log, request, localFilePath); err != nil {
+		return err, nil
+	}
+
+	result = &remoteresource.DownloadResult{}
+	if !h.Info.ExtractArchive {
+		result.Files = append(result.Files, localFilePath)
+		return nil, result
+	}
+
+	// the archive is removed once its content is extracted
+	defer filesys.DeleteFile(localFilePath)
+	if err = extractArchive(log, localFilePath, destPath); err != nil {
+		return fmt.Errorf("Failed to extract archive %v - %v", fileName, err), nil
+	}
+	if result.Files, err = listFiles(destPath, localFilePath); err != nil {
+		return err, nil
+	}
+	return nil, result
+}
+
+// ValidateLocationInfo ensures that the required parameters of SourceInfo are specified
+func (h *HTTPResource) ValidateLocationInfo() (valid bool, err error) {
+	// URL is a mandatory input
+	if h.Info.URL == "" {
+		return false, errors.New("HTTP url in SourceInfo must be specified")
+	}
+	fileURL, err := url.Parse(h.Info.URL)
+	if err != nil {
+		return false, fmt.Errorf("HTTP url in SourceInfo could not be parsed - %v", err)
+	}
+	if fileURL.Scheme != "http" && fileURL.Scheme != "https" {
+		return false, errors.New("HTTP url in SourceInfo must use http or https scheme")
+	}
+	if path.Base(fileURL.Path) == "/" || path.Base(fileURL.Path) == "." {
+		return false, errors.New("HTTP url in SourceInfo must point to a file")
+	}
+
+	// The hash is mandatory since the content of an http url is not guaranteed to be immutable
+	if h.Info.SHA256 == "" {
+		return false, errors.New("SHA256 hash of the HTTP source must be specified")
+	}
+	if hash, err := hex.DecodeString(h.Info.SHA256); err != nil || len(hash) != 32 {
+		return false, errors.New("SHA256 hash of the HTTP source must be a hex encoded 256 bit value")
+	}
+
+	switch h.Info.AuthMethod {
+	case AuthMethodNone:
+	case AuthMethodBasic:
+		if h.Info.Username == "" {
+			return false, errors.New("Username must be specified for Basic authentication")
+		}
+	case AuthMethodBearer:
+		if h.Info.Token == "" {
+			return false, errors.New("Token must be specified for Bearer authentication")
+		}
+	default:
+		return false, fmt.Errorf("Unsupported authMethod %v, supported values are %v, %v and %v", h.Info.AuthMethod, AuthMethodNone, AuthMethodBasic, AuthMethodBearer)
+	}
+	// credentials are never sent in plain text
+	if h.Info.AuthMethod != AuthMethodNone && fileURL.Scheme != "https" {
+		return false, fmt.Errorf("%v authentication requires an https url", h.Info.AuthMethod)
+	}
+
+	return true, nil
+}
+
+// newRequest creates the download request with the headers and credentials of the source info.
+// Values of headers and credentials can reference ssm parameters, for e.g. {{ ssm-secure:parameter-name }}
+func (h *HTTPResource) newRequest(log log.T) (request *http.Request, err error) {
+	if request, err = http.NewRequest("GET", h.Info.URL, nil); err != nil {
+		return nil, err
+	}
+
+	for name, value := range h.Info.Headers {
+		if value, err = resolveParameters(log, value); err != nil {
+			return nil, fmt.Errorf("Could not resolve ssm parameter in header %v. Error - %v", name, err)
+		}
+		request.Header.Set(name, value)
+	}
+
+	switch h.Info.AuthMethod {
+	case AuthMethodBasic:
+		var password string
+		if password, err = resolveParameters(log, h.Info.Password); err != nil {
+			return nil, fmt.Errorf("Could not resolve ssm parameter for password. Error - %v", err)
+		}
+		request.SetBasicAuth(h.Info.Username, password)
+	case AuthMethodBearer:
+		var token string
+		if token, err = resolveParameters(log, h.Info.Token); err != nil {
+			return nil, fmt.Errorf("Could not resolve ssm parameter for token. Error - %v", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request, nil
+}
+
+// download writes the response of the request to the local file and verifies its SHA256 hash
+func (h *HTTPResource) download(log log.T, request *http.Request, localFilePath string) (err error) {
+	var resp *http.Response
+	if resp, err = h.client.Do(request); err != nil {
+		return fmt.Errorf("HTTP download failed - %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP download failed. status:%v statuscode:%v", resp.Status, resp.StatusCode)
+	}
+	if _, err = artifact.FileCopy(log, localFilePath, resp.Body); err != nil {
+		fileutil.DeleteFile(localFilePath)
+		return fmt.Errorf("Failed to write the HTTP download to %v - %v", localFilePath, err)
+	}
+
+	var hash string
+	if hash, err = artifact.Sha256HashValue(log, localFilePath); err != nil {
+		fileutil.DeleteFile(localFilePath)
+		return fmt.Errorf("Failed to compute SHA256 hash of the HTTP download - %v", err)
+	}
+	if !strings.EqualFold(hash, h.Info.SHA256) {
+		fileutil.DeleteFile(localFilePath)
+		return fmt.Errorf("SHA256 hash of the HTTP download does not match, expected %v but was %v", h.Info.SHA256, hash)
+	}
+	return nil
+}
+
+// resolveParameters resolves the ssm parameter references in the value, values without references are returned as is
+func resolveParameters(log log.T, value string) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	return dep.ResolveParameters(log, value)
+}
+
+// extractArchive extracts a zip or tar.gz archive into the destination directory
+func extractArchive(log log.T, archivePath string, destPath string) error {
+	lowerPath := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lowerPath, ".zip"):
+		return fileutil.Unzip(archivePath, destPath)
+	case strings.HasSuffix(lowerPath, ".tar.gz"), strings.HasSuffix(lowerPath, ".tgz"):
+		return fileutil.Uncompress(log, archivePath, destPath)
+	}
+	return errors.New("unsupported archive format, supported formats are .zip, .tar.gz and .tgz")
+}
+
+// listFiles returns all the files under the directory except the given file
+func listFiles(dir string, exclude string) (files []string, err error) {
+	err = filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filePath != exclude {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package httpresource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var logMock = log.NewMockLog()
+
+type resolverStub struct {
+	values map[string]string
+}
+
+func (r resolverStub) ResolveParameters(log log.T, text string) (string, error) {
+	for ref, value := range r.values {
+		text = strings.Replace(text, "{{ "+ref+" }}", value, -1)
+	}
+	return text, nil
+}
+
+func sha256Hex(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func newTestResource(t *testing.T, server *httptest.Server, info string) *HTTPResource {
+	resource, err := NewHTTPResource(logMock, info)
+	assert.NoError(t, err)
+	resource.client.Transport = server.Client().Transport
+	valid, err := resource.ValidateLocationInfo()
+	assert.True(t, valid)
+	assert.NoError(t, err)
+	return resource
+}
+
+func TestHTTPResource_ValidateLocationInfo(t *testing.T) {
+	hash := sha256Hex([]byte("content"))
+	testCases := []struct {
+		info  string
+		error string
+	}{
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "` + hash + `"}`, ""},
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "` + hash + `", "authMethod": "Bearer", "token": "{{ ssm-secure:token }}"}`, ""},
+		{`{"sha256": "` + hash + `"}`, "HTTP url in SourceInfo must be specified"},
+		{`{"url": "ftp://artifacts.example.com/agent.zip", "sha256": "` + hash + `"}`, "HTTP url in SourceInfo must use http or https scheme"},
+		{`{"url": "https://artifacts.example.com/", "sha256": "` + hash + `"}`, "HTTP url in SourceInfo must point to a file"},
+		{`{"url": "https://artifacts.example.com/agent.zip"}`, "SHA256 hash of the HTTP source must be specified"},
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "abc"}`, "SHA256 hash of the HTTP source must be a hex encoded 256 bit value"},
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "` + hash + `", "authMethod": "Basic"}`, "Username must be specified for Basic authentication"},
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "` + hash + `", "authMethod": "Bearer"}`, "Token must be specified for Bearer authentication"},
+		{`{"url": "https://artifacts.example.com/agent.zip", "sha256": "` + hash + `", "authMethod": "Digest"}`, "Unsupported authMethod Digest"},
+		{`{"url": "http://artifacts.example.com/agent.zip", "sha256": "` + hash + `", "authMethod": "Basic", "username": "user"}`, "Basic authentication requires an https url"},
+	}
+
+	for _, testCase := range testCases {
+		resource, err := NewHTTPResource(logMock, testCase.info)
+		assert.NoError(t, err)
+		valid, err := resource.ValidateLocationInfo()
+		if testCase.error == "" {
+			assert.True(t, valid, testCase.info)
+			assert.NoError(t, err, testCase.info)
+		} else {
+			assert.False(t, valid, testCase.info)
+			assert.Contains(t, err.Error(), testCase.error, testCase.info)
+		}
+	}
+}
+
+func TestHTTPResource_DownloadWithHeadersAndBasicAuth(t *testing.T) {
+	content := []byte("echo hello")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "deploy" || password != "secret" || r.Header.Get("X-Api-Key") != "key-value" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	origDep := dep
+	dep = resolverStub{values: map[string]string{"ssm-secure:password": "secret", "ssm-secure:apikey": "key-value"}}
+	defer func() { dep = origDep }()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{
+		"url": "%v/scripts/install.sh",
+		"sha256": "%v",
+		"headers": {"X-Api-Key": "{{ ssm-secure:apikey }}"},
+		"authMethod": "Basic",
+		"username": "deploy",
+		"password": "{{ ssm-secure:password }}"
+	}`, server.URL, sha256Hex(content)))
+
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(destDir, "install.sh")}, result.Files)
+	downloaded, _ := ioutil.ReadFile(filepath.Join(destDir, "install.sh"))
+	assert.Equal(t, content, downloaded)
+}
+
+func TestHTTPResource_DownloadFollowsRedirectWithBearerToken(t *testing.T) {
+	content := []byte("binary content")
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-value" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/latest/tool" {
+			http.Redirect(w, r, server.URL+"/v2/tool", http.StatusFound)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+	destFile := filepath.Join(destDir, "bin", "mytool")
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{
+		"url": "%v/latest/tool",
+		"sha256": "%v",
+		"authMethod": "Bearer",
+		"token": "token-value"
+	}`, server.URL, sha256Hex(content)))
+
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{destFile}, result.Files)
+	downloaded, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestHTTPResource_DownloadRedirectToOtherHostDropsHeaders(t *testing.T) {
+	content := []byte("binary content")
+	var receivedHeaders http.Header
+	mirror := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header
+		w.Write(content)
+	}))
+	defer mirror.Close()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key-value" || r.Header.Get("Authorization") != "Bearer token-value" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, mirror.URL+"/v2/tool", http.StatusFound)
+	}))
+	defer server.Close()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{
+		"url": "%v/latest/tool",
+		"sha256": "%v",
+		"headers": {"X-Api-Key": "key-value"},
+		"authMethod": "Bearer",
+		"token": "token-value"
+	}`, server.URL, sha256Hex(content)))
+
+	err, _ := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, filepath.Join(destDir, "mytool"))
+	assert.NoError(t, err)
+	assert.NotNil(t, receivedHeaders)
+	assert.Empty(t, receivedHeaders.Get("X-Api-Key"))
+	assert.Empty(t, receivedHeaders.Get("Authorization"))
+}
+
+func TestHTTPResource_DownloadHashMismatch(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered content"))
+	}))
+	defer server.Close()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{"url": "%v/install.sh", "sha256": "%v"}`, server.URL, sha256Hex([]byte("content"))))
+
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destDir+string(os.PathSeparator))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("SHA256 hash of the HTTP download does not match, expected %v but was %v",
+		sha256Hex([]byte("content")), sha256Hex([]byte("tampered content"))))
+	assert.Nil(t, result)
+	_, statErr := os.Stat(filepath.Join(destDir, "install.sh"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestHTTPResource_DownloadFailedStatus(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{"url": "%v/install.sh", "sha256": "%v"}`, server.URL, sha256Hex([]byte("content"))))
+
+	err, _ := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destDir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "statuscode:404")
+}
+
+func TestHTTPResource_DownloadAndExtractArchive(t *testing.T) {
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for name, body := range map[string]string{"run.sh": "echo run", "config/settings.json": "{}"} {
+		file, _ := zipWriter.Create(name)
+		file.Write([]byte(body))
+	}
+	zipWriter.Close()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	}))
+	defer server.Close()
+
+	destDir, _ := ioutil.TempDir("", "httpresource")
+	defer os.RemoveAll(destDir)
+
+	resource := newTestResource(t, server, fmt.Sprintf(`{"url": "%v/bundle.zip", "sha256": "%v", "extractArchive": true}`, server.URL, sha256Hex(archive.Bytes())))
+
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destDir)
+	assert.NoError(t, err)
+	sort.Strings(result.Files)
+	assert.Equal(t, []string{filepath.Join(destDir, "config", "settings.json"), filepath.Join(destDir, "run.sh")}, result.Files)
+	content, _ := ioutil.ReadFile(filepath.Join(destDir, "config", "settings.json"))
+	assert.Equal(t, "{}", string(content))
+	_, statErr := os.Stat(filepath.Join(destDir, "bundle.zip"))
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
@@ -41,6 +42,13 @@ const (
 	Max_Time_TO_Back_Off = 30
 )
 
+// uploadSizeBuckets are the upper bounds in bytes of the inventory upload size histogram
+var uploadSizeBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+var uploadSize = metrics.NewHistogram("ssm_agent_inventory_upload_bytes",
+	"Size of the inventory items uploaded with PutInventory, serialized as JSON.",
+	uploadSizeBuckets)
+
 // T represents contracts for SSM Inventory data uploader
 type T interface {
 	SendDataToSSM(context context.T, items []*ssm.InventoryItem) (err error)
@@ -127,6 +135,9 @@ func (u *InventoryUploader) SendDataToSSM(context context.T, items []*ssm.Invent
 	time.Sleep(time.Duration(getRandomBackOffTime(context, instanceID)) * time.Second)
 	log.Debugf("Calling PutInventory API with parameters - %v", params)
 	if u.ssm != nil {
+		if content, marshalErr := json.Marshal(items); marshalErr == nil {
+			uploadSize.Observe(float64(len(content)))
+		}
 		resp, err = u.ssm.PutInventory(params)
 
 		if err != nil {
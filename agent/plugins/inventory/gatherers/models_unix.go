@@ -23,6 +23,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/service"
 )
 
 var supportedGathererNames = []string{
@@ -32,4 +33,5 @@ var supportedGathererNames = []string{
 	network.GathererName,
 	file.GathererName,
 	instancedetailedinformation.GathererName,
+	service.GathererName,
 }
@@ -19,7 +19,6 @@ import (
 
 	"os/exec"
 
-	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
@@ -71,6 +70,7 @@ func LogError(log log.T, err error) {
 	log.Error(err)
 }
 
+// decoupling exec.Command for easy testability
 var cmdExecutor = executeCommand
 
 func executeCommand(command string, args ...string) ([]byte, error) {
@@ -116,10 +116,3 @@ func collectDataFromPowershell(log log.T, powershellCommand string, serviceInfo
 	}
 	return
 }
-
-func collectServiceData(context context.T, config model.Config) (data []model.ServiceData, err error) {
-	log := context.Log()
-	log.Infof("collectServiceData called")
-	err = collectDataFromPowershell(log, serviceInfoScript, &data)
-	return
-}
@@ -59,7 +59,8 @@ func TestServiceData(t *testing.T) {
 	contextMock := context.NewMockDefault()
 	cmdExecutor = createMockTestExecuteCommand(testServiceOutput, nil)
 
-	data, err := collectServiceData(contextMock, model.Config{})
+	var data []model.ServiceData
+	err := collectDataFromPowershell(contextMock.Log(), serviceInfoScript, &data)
 
 	assert.Nil(t, err)
 	assert.Equal(t, data, testServiceOutputData)
@@ -70,7 +71,8 @@ func TestServiceDataCmdErr(t *testing.T) {
 	contextMock := context.NewMockDefault()
 	cmdExecutor = createMockTestExecuteCommand("", errors.New("error"))
 
-	data, err := collectServiceData(contextMock, model.Config{})
+	var data []model.ServiceData
+	err := collectDataFromPowershell(contextMock.Log(), serviceInfoScript, &data)
 
 	assert.NotNil(t, err)
 	assert.Nil(t, data)
@@ -81,7 +83,8 @@ func TestServiceDataInvalidOutput(t *testing.T) {
 	contextMock := context.NewMockDefault()
 	cmdExecutor = createMockTestExecuteCommand("Invalid", nil)
 
-	data, err := collectServiceData(contextMock, model.Config{})
+	var data []model.ServiceData
+	err := collectDataFromPowershell(contextMock.Log(), serviceInfoScript, &data)
 
 	assert.NotNil(t, err)
 	assert.Nil(t, data)
@@ -93,7 +96,8 @@ func TestServiceDataInvalidMarker(t *testing.T) {
 	contextMock := context.NewMockDefault()
 	cmdExecutor = createMockTestExecuteCommand(testServiceOutputIncorrect, nil)
 
-	data, err := collectServiceData(contextMock, model.Config{})
+	var data []model.ServiceData
+	err := collectDataFromPowershell(contextMock.Log(), serviceInfoScript, &data)
 
 	assert.NotNil(t, err)
 	assert.Nil(t, data)
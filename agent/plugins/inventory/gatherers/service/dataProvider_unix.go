@@ -0,0 +1,294 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	systemctlCmd          = "systemctl"
+	systemdServiceSuffix  = ".service"
+	systemdTemplateSuffix = "@" + systemdServiceSuffix
+
+	// ServiceTypeSysV is the service type reported for SysV init scripts
+	ServiceTypeSysV = "sysv"
+	// StatusRunning, StatusStopped, StatusStartPending and StatusStopPending are the states reported for the services,
+	// they match the states of the Windows services
+	StatusRunning      = "Running"
+	StatusStopped      = "Stopped"
+	StatusStartPending = "StartPending"
+	StatusStopPending  = "StopPending"
+	// StartTypeEnabled and StartTypeDisabled tell whether a SysV init script is started at boot
+	StartTypeEnabled  = "enabled"
+	StartTypeDisabled = "disabled"
+)
+
+var (
+	// systemdRuntimeDir exists only if the system was booted with systemd, see sd_booted(3)
+	systemdRuntimeDir = "/run/systemd/system"
+	sysVInitDir       = "/etc/init.d"
+	// sysVStartLinksPattern matches the start links of the multi-user runlevels
+	sysVStartLinksPattern = "/etc/rc[2-5].d/S[0-9][0-9]"
+
+	systemdProperties = []string{"Id", "LoadState", "Description", "ActiveState", "SubState", "UnitFileState", "MainPID",
+		"FragmentPath", "Type", "Requires", "Wants", "RequiredBy", "WantedBy"}
+
+	// systemdStatuses maps the active states of the systemd units to the service states, the units which failed are stopped
+	systemdStatuses = map[string]string{
+		"active":       StatusRunning,
+		"reloading":    StatusRunning,
+		"inactive":     StatusStopped,
+		"failed":       StatusStopped,
+		"activating":   StatusStartPending,
+		"deactivating": StatusStopPending,
+	}
+
+	// sysVStatusTimeout bounds the time the status action of an init script may take
+	sysVStatusTimeout = 10 * time.Second
+	// statusExecutor runs the status action of the init scripts, decoupled for easy testability
+	statusExecutor = runStatusCommand
+
+	// files in the init directory which are not services
+	sysVIgnoredFiles = map[string]bool{"functions": true, "README": true, "rc": true, "rcS": true, "skeleton": true, "halt": true, "killall": true}
+)
+
+// collectServiceData collects the services managed by systemd, and falls back to SysV init scripts on systems without systemd
+func collectServiceData(context context.T, config model.Config) (data []model.ServiceData, err error) {
+	log := context.Log()
+	log.Infof("collectServiceData called")
+
+	if isDirectory(systemdRuntimeDir) {
+		if data, err = collectSystemdServices(log); err == nil {
+			return
+		}
+		log.Errorf("Failed to collect systemd services, falling back to SysV init scripts - %v", err)
+	}
+	return collectSysVServices(log)
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// collectSystemdServices lists the installed and the loaded service units, and reads their properties
+func collectSystemdServices(log log.T) (data []model.ServiceData, err error) {
+	var output []byte
+	units := make(map[string]bool)
+
+	// unit files include the services which are installed but not loaded
+	if output, err = cmdExecutor(systemctlCmd, "list-unit-files", "--type=service", "--no-legend", "--no-pager"); err != nil {
+		return nil, fmt.Errorf("Failed to list unit files - %v %v", err, string(output))
+	}
+	addUnitNames(units, string(output))
+	// loaded units include the services generated at runtime which have no unit file
+	if output, err = cmdExecutor(systemctlCmd, "list-units", "--type=service", "--all", "--no-legend", "--no-pager", "--plain"); err != nil {
+		return nil, fmt.Errorf("Failed to list units - %v %v", err, string(output))
+	}
+	addUnitNames(units, string(output))
+	if len(units) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := append([]string{"show", "--no-pager", "--property=" + strings.Join(systemdProperties, ",")}, names...)
+	if output, err = cmdExecutor(systemctlCmd, args...); err != nil {
+		return nil, fmt.Errorf("Failed to read unit properties - %v %v", err, string(output))
+	}
+	data = parseSystemdProperties(string(output))
+	log.Debugf("Collected %v systemd services", len(data))
+	return
+}
+
+// addUnitNames adds the service names in the first column of systemctl list output, templates are skipped since they are not services themselves
+func addUnitNames(units map[string]bool, output string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if strings.HasSuffix(name, systemdServiceSuffix) && !strings.HasSuffix(name, systemdTemplateSuffix) {
+			units[name] = true
+		}
+	}
+}
+
+// parseSystemdProperties converts the output of systemctl show, which prints the properties of each unit as key=value lines
+// separated by an empty line
+func parseSystemdProperties(output string) (data []model.ServiceData) {
+	properties := make(map[string]string)
+	flush := func() {
+		if properties["Id"] != "" && properties["LoadState"] != "not-found" {
+			data = append(data, toServiceData(properties))
+		}
+		properties = make(map[string]string)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			properties[line[:i]] = line[i+1:]
+		}
+	}
+	flush()
+	return
+}
+
+func toServiceData(properties map[string]string) model.ServiceData {
+	status, found := systemdStatuses[properties["ActiveState"]]
+	if !found {
+		status = StatusStopped
+	}
+	// systemd reports 0 for the units which have no main process
+	mainPID := properties["MainPID"]
+	if mainPID == "0" {
+		mainPID = ""
+	}
+	return model.ServiceData{
+		Name:               strings.TrimSuffix(properties["Id"], systemdServiceSuffix),
+		DisplayName:        properties["Description"],
+		Status:             status,
+		SubState:           properties["SubState"],
+		StartType:          properties["UnitFileState"],
+		ServiceType:        properties["Type"],
+		MainPID:            mainPID,
+		UnitFilePath:       properties["FragmentPath"],
+		ServicesDependedOn: joinLists(properties["Requires"], properties["Wants"]),
+		DependentServices:  joinLists(properties["RequiredBy"], properties["WantedBy"]),
+	}
+}
+
+func joinLists(lists ...string) string {
+	var items []string
+	for _, list := range lists {
+		items = append(items, strings.Fields(list)...)
+	}
+	return strings.Join(items, " ")
+}
+
+// collectSysVServices reads the init scripts, their state is queried with the status action of the script
+func collectSysVServices(log log.T) (data []model.ServiceData, err error) {
+	var files []os.FileInfo
+	if files, err = ioutil.ReadDir(sysVInitDir); err != nil {
+		if os.IsNotExist(err) {
+			log.Infof("No SysV init directory %v found", sysVInitDir)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Failed to read SysV init directory - %v", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if !file.Mode().IsRegular() || file.Mode().Perm()&0111 == 0 || sysVIgnoredFiles[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		scriptPath := filepath.Join(sysVInitDir, name)
+		service := readLSBHeader(log, scriptPath)
+		service.Name = name
+		service.ServiceType = ServiceTypeSysV
+		service.UnitFilePath = scriptPath
+
+		// LSB init scripts exit with 0 when the service is running
+		service.Status = StatusStopped
+		if err := statusExecutor(scriptPath, sysVStatusTimeout); err == nil {
+			service.Status = StatusRunning
+		} else {
+			log.Debugf("Service %v is not running - %v", name, err)
+		}
+		service.StartType = StartTypeDisabled
+		if links, _ := filepath.Glob(sysVStartLinksPattern + name); len(links) > 0 {
+			service.StartType = StartTypeEnabled
+		}
+		data = append(data, service)
+	}
+	log.Debugf("Collected %v SysV services", len(data))
+	return data, nil
+}
+
+// runStatusCommand runs the status action of the init script, the script is killed if it doesn't exit within the timeout
+// since a hung script would block the whole inventory collection
+func runStatusCommand(scriptPath string, timeout time.Duration) error {
+	// the output isn't read so that the wait doesn't depend on the children of the script which inherit it
+	command := exec.Command(scriptPath, "status")
+	if err := command.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(timeout, func() {
+		command.Process.Kill()
+	})
+	err := command.Wait()
+	if !timer.Stop() {
+		return fmt.Errorf("status of %v timed out after %v", scriptPath, timeout)
+	}
+	return err
+}
+
+// readLSBHeader reads the description and the dependencies from the LSB header of the init script
+func readLSBHeader(log log.T, scriptPath string) (service model.ServiceData) {
+	file, err := os.Open(scriptPath)
+	if err != nil {
+		log.Debugf("Failed to read init script %v - %v", scriptPath, err)
+		return
+	}
+	defer file.Close()
+
+	var description string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "### END INIT INFO" {
+			break
+		}
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		switch {
+		case strings.HasPrefix(line, "Short-Description:"):
+			service.DisplayName = strings.TrimSpace(strings.TrimPrefix(line, "Short-Description:"))
+		case strings.HasPrefix(line, "Description:"):
+			description = strings.TrimSpace(strings.TrimPrefix(line, "Description:"))
+		case strings.HasPrefix(line, "Required-Start:"):
+			service.ServicesDependedOn = joinLists(strings.TrimPrefix(line, "Required-Start:"))
+		}
+	}
+	if service.DisplayName == "" {
+		service.DisplayName = description
+	}
+	return
+}
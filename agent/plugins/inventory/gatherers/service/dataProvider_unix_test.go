@@ -0,0 +1,270 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUnitFilesOutput = `amazon-ssm-agent.service   enabled  enabled
+getty@.service             enabled  enabled
+nfs-server.service         disabled disabled
+`
+
+var testUnitsOutput = `amazon-ssm-agent.service loaded active   running amazon-ssm-agent
+missing.service          not-found inactive dead missing.service
+run-generated.service    loaded active   exited  Generated service
+`
+
+var testShowOutput = `Id=amazon-ssm-agent.service
+LoadState=loaded
+Description=amazon-ssm-agent
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+MainPID=2843
+FragmentPath=/etc/systemd/system/amazon-ssm-agent.service
+Type=simple
+Requires=sysinit.target system.slice
+Wants=network-online.target
+RequiredBy=
+WantedBy=multi-user.target
+
+Id=missing.service
+LoadState=not-found
+ActiveState=inactive
+
+Id=nfs-server.service
+LoadState=loaded
+Description=NFS server and services
+ActiveState=inactive
+SubState=dead
+UnitFileState=disabled
+MainPID=0
+FragmentPath=/usr/lib/systemd/system/nfs-server.service
+Type=oneshot
+Requires=rpcbind.socket
+Wants=
+RequiredBy=
+WantedBy=
+
+Id=run-generated.service
+LoadState=loaded
+Description=Generated service
+ActiveState=active
+SubState=exited
+UnitFileState=
+MainPID=0
+FragmentPath=
+Type=oneshot
+`
+
+var testSystemdServiceData = []model.ServiceData{
+	{
+		Name:               "amazon-ssm-agent",
+		DisplayName:        "amazon-ssm-agent",
+		Status:             StatusRunning,
+		SubState:           "running",
+		StartType:          "enabled",
+		ServiceType:        "simple",
+		MainPID:            "2843",
+		UnitFilePath:       "/etc/systemd/system/amazon-ssm-agent.service",
+		ServicesDependedOn: "sysinit.target system.slice network-online.target",
+		DependentServices:  "multi-user.target",
+	},
+	{
+		Name:               "nfs-server",
+		DisplayName:        "NFS server and services",
+		Status:             StatusStopped,
+		SubState:           "dead",
+		StartType:          "disabled",
+		ServiceType:        "oneshot",
+		UnitFilePath:       "/usr/lib/systemd/system/nfs-server.service",
+		ServicesDependedOn: "rpcbind.socket",
+	},
+	{
+		Name:        "run-generated",
+		DisplayName: "Generated service",
+		Status:      StatusRunning,
+		SubState:    "exited",
+		ServiceType: "oneshot",
+	},
+}
+
+// setupTestDirs points the systemd and SysV locations to a temporary directory
+func setupTestDirs(t *testing.T, systemd bool) (root string, cleanup func()) {
+	root, err := ioutil.TempDir("", "service")
+	assert.NoError(t, err)
+	origSystemdDir, origInitDir, origPattern := systemdRuntimeDir, sysVInitDir, sysVStartLinksPattern
+	systemdRuntimeDir = filepath.Join(root, "run", "systemd", "system")
+	sysVInitDir = filepath.Join(root, "init.d")
+	sysVStartLinksPattern = filepath.Join(root, "rc[2-5].d", "S[0-9][0-9]")
+	if systemd {
+		os.MkdirAll(systemdRuntimeDir, 0700)
+	}
+	return root, func() {
+		systemdRuntimeDir, sysVInitDir, sysVStartLinksPattern = origSystemdDir, origInitDir, origPattern
+		cmdExecutor = executeCommand
+		statusExecutor = runStatusCommand
+		os.RemoveAll(root)
+	}
+}
+
+func TestCollectServiceDataSystemd(t *testing.T) {
+	_, cleanup := setupTestDirs(t, true)
+	defer cleanup()
+
+	var shownUnits []string
+	var shownProperties string
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		assert.Equal(t, systemctlCmd, command)
+		switch args[0] {
+		case "list-unit-files":
+			return []byte(testUnitFilesOutput), nil
+		case "list-units":
+			return []byte(testUnitsOutput), nil
+		case "show":
+			shownProperties = args[2]
+			shownUnits = args[3:]
+			return []byte(testShowOutput), nil
+		}
+		return nil, errors.New("unexpected command")
+	}
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, testSystemdServiceData, data)
+	assert.Equal(t, []string{"amazon-ssm-agent.service", "missing.service", "nfs-server.service", "run-generated.service"}, shownUnits)
+	for _, property := range []string{"SubState", "MainPID", "FragmentPath"} {
+		assert.Contains(t, strings.Split(strings.TrimPrefix(shownProperties, "--property="), ","), property)
+	}
+}
+
+func writeInitScript(t *testing.T, dir string, name string, content string, mode os.FileMode) {
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), mode))
+}
+
+func TestCollectServiceDataSysVFallback(t *testing.T) {
+	root, cleanup := setupTestDirs(t, true)
+	defer cleanup()
+
+	writeInitScript(t, sysVInitDir, "sshd", `#!/bin/sh
+### BEGIN INIT INFO
+# Provides: sshd
+# Required-Start: $local_fs $network $syslog
+# Short-Description: Start up the OpenSSH server daemon
+# Description: SSH is a protocol for secure remote shell access.
+### END INIT INFO
+`, 0755)
+	writeInitScript(t, sysVInitDir, "crond", "#!/bin/sh\n# Description: Cron daemon\n", 0755)
+	writeInitScript(t, sysVInitDir, "functions", "#!/bin/sh\n", 0755)
+	writeInitScript(t, sysVInitDir, "README", "not a script", 0644)
+	os.MkdirAll(filepath.Join(root, "rc3.d"), 0700)
+	os.Symlink(filepath.Join(sysVInitDir, "sshd"), filepath.Join(root, "rc3.d", "S55sshd"))
+
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		assert.Equal(t, systemctlCmd, command)
+		return []byte("Failed to connect to bus"), errors.New("exit status 1")
+	}
+	statusExecutor = func(scriptPath string, timeout time.Duration) error {
+		assert.Equal(t, sysVStatusTimeout, timeout)
+		if strings.HasSuffix(scriptPath, "sshd") {
+			return nil
+		}
+		return errors.New("exit status 3")
+	}
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServiceData{
+		{
+			Name:         "crond",
+			DisplayName:  "Cron daemon",
+			Status:       StatusStopped,
+			StartType:    StartTypeDisabled,
+			ServiceType:  ServiceTypeSysV,
+			UnitFilePath: filepath.Join(sysVInitDir, "crond"),
+		},
+		{
+			Name:               "sshd",
+			DisplayName:        "Start up the OpenSSH server daemon",
+			Status:             StatusRunning,
+			StartType:          StartTypeEnabled,
+			ServiceType:        ServiceTypeSysV,
+			ServicesDependedOn: "$local_fs $network $syslog",
+			UnitFilePath:       filepath.Join(sysVInitDir, "sshd"),
+		},
+	}, data)
+}
+
+func TestCollectServiceDataNoServiceManager(t *testing.T) {
+	_, cleanup := setupTestDirs(t, false)
+	defer cleanup()
+
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		t.Errorf("unexpected command %v", command)
+		return nil, nil
+	}
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestToServiceDataStatus(t *testing.T) {
+	for activeState, status := range map[string]string{
+		"active":       StatusRunning,
+		"reloading":    StatusRunning,
+		"inactive":     StatusStopped,
+		"failed":       StatusStopped,
+		"activating":   StatusStartPending,
+		"deactivating": StatusStopPending,
+		"unknown":      StatusStopped,
+	} {
+		service := toServiceData(map[string]string{"Id": "test.service", "ActiveState": activeState})
+		assert.Equal(t, "test", service.Name)
+		assert.Equal(t, status, service.Status, activeState)
+	}
+}
+
+func TestRunStatusCommand(t *testing.T) {
+	root, cleanup := setupTestDirs(t, false)
+	defer cleanup()
+
+	writeInitScript(t, root, "running", "#!/bin/sh\nexit 0\n", 0755)
+	writeInitScript(t, root, "stopped", "#!/bin/sh\nexit 3\n", 0755)
+	writeInitScript(t, root, "hung", "#!/bin/sh\nexec sleep 30\n", 0755)
+
+	assert.NoError(t, runStatusCommand(filepath.Join(root, "running"), time.Second))
+	assert.Error(t, runStatusCommand(filepath.Join(root, "stopped"), time.Second))
+
+	start := time.Now()
+	err := runStatusCommand(filepath.Join(root, "hung"), 100*time.Millisecond)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
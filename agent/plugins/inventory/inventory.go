@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"time"
 
@@ -58,6 +60,7 @@ const (
 	errorMsgForInabilityToSendDataToSSM       = "inventory data could not be uploaded to Systems Manager. Additional troubleshooting information - %v"
 	msgWhenNoDataToReturnForInventoryPlugin   = "Inventory policy has been successfully applied but there is no inventory data to upload to SSM"
 	successfulMsgForInventoryPlugin           = "Inventory policy has been successfully applied and collected inventory data has been uploaded to SSM"
+	partialSuccessMsgForInventoryPlugin       = "Inventory policy has been applied and the data of the successful gatherers has been uploaded to SSM, data of the failed gatherers was not uploaded"
 )
 
 // PluginInput represents configuration which is applied to inventory plugin during execution.
@@ -153,12 +156,16 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 		return
 	}
 
-	//execute all eligible gatherers with their respective config
-	if items, err = p.RunGatherers(gatherers); err != nil {
-		log.Info(err.Error())
-		output.SetExitCode(1)
-		output.AppendError(err.Error())
-		return
+	//execute all eligible gatherers with their respective config, failed gatherers are reported after uploading the
+	//data of the successful ones
+	var gathererErr error
+	if items, gathererErr = p.RunGatherers(gatherers); gathererErr != nil {
+		log.Info(gathererErr.Error())
+		output.AppendError(gathererErr.Error())
+		if len(items) == 0 {
+			output.SetExitCode(1)
+			return
+		}
 	}
 
 	//check if there is data to send to SSM
@@ -203,8 +210,7 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 	}
 
 	log.Infof("%v uploaded inventory data to SSM", Name())
-	output.SetExitCode(0)
-	output.AppendInfo(successfulMsgForInventoryPlugin)
+	reportUploadResult(output, gathererErr)
 
 	return
 }
@@ -218,12 +224,15 @@ func (p Plugin) ApplyInventoryFrequentCollector(context context.T, gatherers map
 	var err error
 
 	//execute all specified gatherers with their respective config
-	if items, err = p.RunGatherers(gatherers); err != nil {
-		log.Debugf("failed at RunGatherers, error : %#v", err)
-		log.Info(err.Error())
-		output.SetExitCode(1)
-		output.AppendError(err.Error())
-		return
+	var gathererErr error
+	if items, gathererErr = p.RunGatherers(gatherers); gathererErr != nil {
+		log.Debugf("failed at RunGatherers, error : %#v", gathererErr)
+		log.Info(gathererErr.Error())
+		output.AppendError(gathererErr.Error())
+		if len(items) == 0 {
+			output.SetExitCode(1)
+			return
+		}
 	}
 
 	//check if there is data to send to SSM
@@ -258,12 +267,22 @@ func (p Plugin) ApplyInventoryFrequentCollector(context context.T, gatherers map
 	}
 
 	log.Infof("%v uploaded inventory data from frequent collector to SSM", Name())
-	output.SetExitCode(0)
-	output.AppendInfo(successfulMsgForInventoryPlugin)
+	reportUploadResult(output, gathererErr)
 
 	return
 }
 
+// reportUploadResult reports a successful upload, the upload is partially successful if some of the gatherers failed
+func reportUploadResult(output iohandler.IOHandler, gathererErr error) {
+	if gathererErr != nil {
+		output.SetExitCode(1)
+		output.AppendInfo(partialSuccessMsgForInventoryPlugin)
+		return
+	}
+	output.SetExitCode(0)
+	output.AppendInfo(successfulMsgForInventoryPlugin)
+}
+
 // shouldRetryWithNonOptimizedData will return true if the Exception occurred is one of ItemContentMismatchException
 // or InvalidItemContentException and will retry sending data to SSM. It will return false, if any other error occurs.
 func shouldRetryWithNonOptimizedData(err error, log log.T) bool {
@@ -422,49 +441,125 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 	return
 }
 
-// RunGatherers execute given array of gatherers and accordingly returns. It returns error if gatherer is not
-// registered or if at any stage the data returned breaches size limit
-func (p *Plugin) RunGatherers(gatherers map[gatherers.T]model.Config) (items []model.Item, err error) {
+// gathererResult holds the outcome of a single gatherer run
+type gathererResult struct {
+	name  string
+	items []model.Item
+	err   error
+}
 
-	//NOTE: Currently all gatherers will be invoked in synchronous & sequential fashion.
-	//Parallel execution of gatherers hinges upon inventory plugin becoming a long running plugin - which will be
-	//mainly for custom inventory gatherer to send data independently of associate.
+// gathererWorkerLimit bounds the number of gatherers running concurrently
+var gathererWorkerLimit = 4
 
-	var gItems []model.Item
+// gathererTimeout returns the time a gatherer can run before it's requested to stop and its data is dropped
+func (p *Plugin) gathererTimeout() time.Duration {
+	minutes := p.context.AppConfig().Ssm.InventoryGathererTimeoutMinutes
+	if minutes <= 0 {
+		minutes = appconfig.DefaultSsmInventoryGathererTimeoutMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
 
+// RunGatherers executes the given gatherers concurrently with a bounded worker pool. A gatherer which fails, times out
+// or breaches the size limit only drops its own inventory type, the items of the other gatherers are returned along with
+// an error which lists the failed gatherers.
+func (p *Plugin) RunGatherers(gatherers map[gatherers.T]model.Config) (items []model.Item, err error) {
+	return p.runGatherers(gatherers, p.gathererTimeout())
+}
+
+// runGatherers executes the gatherers with the given timeout, see RunGatherers
+func (p *Plugin) runGatherers(gatherers map[gatherers.T]model.Config, timeout time.Duration) (items []model.Item, err error) {
 	log := p.context.Log()
 
+	results := make(chan gathererResult, len(gatherers))
+	workers := make(chan struct{}, gathererWorkerLimit)
 	for gatherer, config := range gatherers {
-		name := gatherer.Name()
-		log.Infof("Invoking gatherer - %v", name)
-		start := time.Now()
-
-		if gItems, err = gatherer.Run(p.context, config); err != nil {
-			err = fmt.Errorf("Encountered error while executing %v. Error - %v", name, err.Error())
-			break
-
-		} else {
-			elapsed := time.Since(start)
-			log.Infof("execution time for gatherer - %v: %s", name, elapsed)
-
-			items = append(items, gItems...)
-
+		gatherer, config := gatherer, config
+		go func() {
+			workers <- struct{}{}
+			result, finished := p.runGatherer(gatherer, config, timeout)
+			results <- result
+			// gatherers can't be interrupted, a gatherer which timed out keeps its worker until it returns so that
+			// the number of running gatherers stays within the limit
+			<-finished
+			<-workers
+		}()
+	}
+
+	collected := make([]gathererResult, 0, len(gatherers))
+	for range gatherers {
+		collected = append(collected, <-results)
+	}
+	// merge in a stable order so that the size limit drops the same inventory types across runs
+	sort.Slice(collected, func(i, j int) bool { return collected[i].name < collected[j].name })
+
+	var failures []string
+	for _, result := range collected {
+		if result.err == nil {
 			//TODO: Each gatherer shall check each item's size and stop collecting if size exceed immediately
-			//TODO: only check the total item size at this function, whenever total size exceed, stop
-			//TODO: immediately and raise association error
-			//return error if collected data breaches size limit
-			for _, v := range gItems {
-				if !p.VerifyInventoryDataSize(v, items) {
-					err = log.Errorf("the size of the collected data exceeded the maximum allowable size")
+			merged := append(append([]model.Item{}, items...), result.items...)
+			for _, v := range result.items {
+				if !p.VerifyInventoryDataSize(v, merged) {
+					result.err = fmt.Errorf("the size of the data collected by %v exceeded the maximum allowable size", result.name)
 					break
 				}
 			}
 		}
+		if result.err != nil {
+			log.Error(result.err.Error())
+			failures = append(failures, result.err.Error())
+			continue
+		}
+		items = append(items, result.items...)
 	}
 
+	if len(failures) > 0 {
+		err = fmt.Errorf("%v of %v gatherers failed - %v", len(failures), len(collected), strings.Join(failures, "; "))
+	}
 	return
 }
 
+// runGatherer runs the gatherer until it completes or the timeout expires, in which case the gatherer is requested to
+// stop and its data is discarded. The returned channel is closed once the gatherer returns.
+func (p *Plugin) runGatherer(gatherer gatherers.T, config model.Config, timeout time.Duration) (gathererResult, <-chan struct{}) {
+	log := p.context.Log()
+	name := gatherer.Name()
+	log.Infof("Invoking gatherer - %v", name)
+	start := time.Now()
+
+	done := make(chan gathererResult, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("Gatherer %v panicked: %v\n%v", name, r, string(debug.Stack()))
+				done <- gathererResult{name: name, err: fmt.Errorf("%v", r)}
+			}
+		}()
+		items, err := gatherer.Run(p.context, config)
+		done <- gathererResult{name: name, items: items, err: err}
+	}()
+
+	var result gathererResult
+	select {
+	case result = <-done:
+	case <-time.After(timeout):
+		log.Infof("gatherer - %v did not complete within %v, requesting stop", name, timeout)
+		if err := gatherer.RequestStop(contracts.StopTypeSoftStop); err != nil {
+			log.Debugf("failed to stop gatherer - %v: %v", name, err)
+		}
+		result = gathererResult{name: name, err: fmt.Errorf("timed out after %v", timeout)}
+	}
+
+	if result.err != nil {
+		result.err = fmt.Errorf("Encountered error while executing %v. Error - %v", name, result.err.Error())
+		return result, finished
+	}
+	log.Infof("execution time for gatherer - %v: %s", name, time.Since(start))
+	return result, finished
+}
+
 // VerifyInventoryDataSize returns true if size of collected inventory data is within size restrictions placed by SSM,
 // else false.
 func (p *Plugin) VerifyInventoryDataSize(item model.Item, items []model.Item) bool {
@@ -17,8 +17,11 @@ package inventory
 import (
 	"bytes"
 	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -26,6 +29,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // MockInventoryPlugin returns mock inventory plugin
@@ -155,6 +159,155 @@ func TestRunGatherers(t *testing.T) {
 	assert.NotNil(t, err, "%v should throw errors", errorProneGatherer)
 }
 
+func TestRunGatherersPartialSuccess(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	config := model.Config{Collection: "Enabled"}
+
+	errorFreeGatherer := gatherers.NewMockDefault()
+	errorFreeGatherer.On("Name").Return("ErrorFree-1")
+	errorFreeGatherer.On("Run", p.context, config).Return(MockInventoryItems(), nil)
+
+	errorProneGatherer := gatherers.NewMockDefault()
+	errorProneGatherer.On("Name").Return("ErrorProne-1")
+	errorProneGatherer.On("Run", p.context, config).Return([]model.Item{}, fmt.Errorf("fake error"))
+
+	largeDataGatherer := gatherers.NewMockDefault()
+	largeDataGatherer.On("Name").Return("LargeData-1")
+	largeDataGatherer.On("Run", p.context, config).Return([]model.Item{LargeInventoryItem(4 * 1024 * 1024)}, nil)
+
+	panickingGatherer := gatherers.NewMockDefault()
+	panickingGatherer.On("Name").Return("Panicking-1")
+	panickingGatherer.On("Run", p.context, config).Run(func(mock.Arguments) { panic("fake panic") })
+
+	items, err := p.RunGatherers(map[gatherers.T]model.Config{
+		errorFreeGatherer:  config,
+		errorProneGatherer: config,
+		largeDataGatherer:  config,
+		panickingGatherer:  config,
+	})
+
+	assert.Equal(t, MockInventoryItems(), items)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "3 of 4 gatherers failed")
+	assert.Contains(t, err.Error(), "Encountered error while executing ErrorProne-1. Error - fake error")
+	assert.Contains(t, err.Error(), "the size of the data collected by LargeData-1 exceeded the maximum allowable size")
+	assert.Contains(t, err.Error(), "Encountered error while executing Panicking-1. Error - fake panic")
+}
+
+func TestRunGathererTimeout(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	config := model.Config{Collection: "Enabled"}
+	stop := make(chan bool)
+
+	slowGatherer := gatherers.NewMockDefault()
+	slowGatherer.On("Name").Return("Slow-1")
+	slowGatherer.On("Run", p.context, config).Return(MockInventoryItems(), nil).Run(func(mock.Arguments) { <-stop })
+	slowGatherer.On("RequestStop", contracts.StopTypeSoftStop).Return(nil).Run(func(mock.Arguments) { close(stop) })
+
+	fastGatherer := gatherers.NewMockDefault()
+	fastGatherer.On("Name").Return("Fast-1")
+	fastGatherer.On("Run", p.context, config).Return([]model.Item{{Name: "Fast:Name", Content: "Fast:Content"}}, nil)
+
+	result, finished := p.runGatherer(slowGatherer, config, 100*time.Millisecond)
+	<-finished
+
+	assert.Nil(t, result.items)
+	assert.Error(t, result.err)
+	assert.Contains(t, result.err.Error(), "Encountered error while executing Slow-1. Error - timed out after 100ms")
+	slowGatherer.AssertCalled(t, "RequestStop", contracts.StopTypeSoftStop)
+
+	result, finished = p.runGatherer(fastGatherer, config, 100*time.Millisecond)
+	<-finished
+
+	assert.NoError(t, result.err)
+	assert.Equal(t, []model.Item{{Name: "Fast:Name", Content: "Fast:Content"}}, result.items)
+	fastGatherer.AssertNotCalled(t, "RequestStop", contracts.StopTypeSoftStop)
+}
+
+func TestRunGatherersTimedOutGathererKeepsWorker(t *testing.T) {
+	origLimit := gathererWorkerLimit
+	gathererWorkerLimit = 1
+	defer func() { gathererWorkerLimit = origLimit }()
+
+	p, _ := MockInventoryPlugin(nil, nil)
+	config := model.Config{Collection: "Enabled"}
+	var running, maxRunning int32
+	track := func() {
+		if current := atomic.AddInt32(&running, 1); current > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, current)
+		}
+	}
+	release := make(chan bool)
+	time.AfterFunc(300*time.Millisecond, func() { close(release) })
+
+	// the slow gatherer ignores the stop request and returns only once it's released
+	slowGatherer := gatherers.NewMockDefault()
+	slowGatherer.On("Name").Return("Slow-1")
+	slowGatherer.On("Run", p.context, config).Return(MockInventoryItems(), nil).Run(func(mock.Arguments) {
+		track()
+		<-release
+		atomic.AddInt32(&running, -1)
+	})
+	slowGatherer.On("RequestStop", contracts.StopTypeSoftStop).Return(nil)
+
+	fastGatherer := gatherers.NewMockDefault()
+	fastGatherer.On("Name").Return("Fast-1")
+	fastGatherer.On("Run", p.context, config).Return([]model.Item{{Name: "Fast:Name", Content: "Fast:Content"}}, nil).Run(func(mock.Arguments) {
+		track()
+		atomic.AddInt32(&running, -1)
+	})
+
+	items, err := p.runGatherers(map[gatherers.T]model.Config{slowGatherer: config, fastGatherer: config}, 50*time.Millisecond)
+
+	assert.Equal(t, []model.Item{{Name: "Fast:Name", Content: "Fast:Content"}}, items)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Encountered error while executing Slow-1. Error - timed out after 50ms")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+}
+
+func TestGathererTimeout(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	assert.Equal(t, 30*time.Minute, p.gathererTimeout())
+
+	ctx := new(context.Mock)
+	ctx.On("AppConfig").Return(appconfig.SsmagentConfig{Ssm: appconfig.SsmCfg{InventoryGathererTimeoutMinutes: 5}})
+	p.context = ctx
+	assert.Equal(t, 5*time.Minute, p.gathererTimeout())
+}
+
+func TestRunGatherersWorkerLimit(t *testing.T) {
+	origLimit := gathererWorkerLimit
+	gathererWorkerLimit = 2
+	defer func() { gathererWorkerLimit = origLimit }()
+
+	p, _ := MockInventoryPlugin(nil, nil)
+	config := model.Config{Collection: "Enabled"}
+	var running, maxRunning int32
+	testGathererConfig := make(map[gatherers.T]model.Config)
+	for i := 0; i < 6; i++ {
+		gatherer := gatherers.NewMockDefault()
+		gatherer.On("Name").Return(fmt.Sprintf("Gatherer-%v", i))
+		gatherer.On("Run", p.context, config).Return(MockInventoryItems(), nil).Run(func(mock.Arguments) {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				observed := atomic.LoadInt32(&maxRunning)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+		testGathererConfig[gatherer] = config
+	}
+
+	items, err := p.RunGatherers(testGathererConfig)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(items))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+}
+
 func TestVerifyInventoryDataSize(t *testing.T) {
 	var smallItem, largeItem model.Item
 	var items []model.Item
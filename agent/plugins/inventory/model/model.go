@@ -128,6 +128,10 @@ type ServiceData struct {
 	ServicesDependedOn string
 	ServiceType        string
 	StartType          string
+	// SubState, MainPID and UnitFilePath are only reported for Linux services
+	SubState     string `json:",omitempty"`
+	MainPID      string `json:",omitempty"`
+	UnitFilePath string `json:",omitempty"`
 }
 
 type RegistryData struct {
@@ -0,0 +1,53 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runscript implements the runscript plugin.
+package runscript
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
+	"github.com/aws/amazon-ssm-agent/agent/ssmparameterresolver"
+)
+
+// dependency on parameter store to resolve the secure values of the environment,
+// and on the run as policy and the local users to run the script as another user
+type runScriptDeps interface {
+	ResolveParameters(log log.T, text string) (string, error)
+	CheckRunAs(log log.T, runAsUser string, runAsGroup string) error
+	GrantRunAsAccess(path string, runAsUser string, runAsGroup string) error
+}
+
+type runScriptDepImpl struct{}
+
+var dep runScriptDeps = &runScriptDepImpl{}
+
+// ResolveParameters resolves the ssm and ssm-secure parameter references in the text
+// NOTE: Do not log the resolved value
+func (runScriptDepImpl) ResolveParameters(log log.T, text string) (string, error) {
+	service := ssmparameterresolver.NewService()
+	return ssmparameterresolver.ResolveParametersInText(&service, log, text, ssmparameterresolver.ResolveOptions{
+		IgnoreSecureParameters: false,
+	})
+}
+
+// CheckRunAs verifies the user and group are allowed by the run as policy of the agent
+func (runScriptDepImpl) CheckRunAs(log log.T, runAsUser string, runAsGroup string) error {
+	return runas.CheckAllowed(log, runAsUser, runAsGroup)
+}
+
+// GrantRunAsAccess makes the file owned by the user and group the script runs as
+func (runScriptDepImpl) GrantRunAsAccess(path string, runAsUser string, runAsGroup string) error {
+	return executers.GrantRunAsAccess(path, runAsUser, runAsGroup)
+}
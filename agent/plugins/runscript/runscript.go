@@ -16,10 +16,16 @@ package runscript
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
 	"path/filepath"
-
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
@@ -54,6 +60,27 @@ type RunScriptPluginInput struct {
 	ID               string
 	WorkingDirectory string
 	TimeoutSeconds   interface{}
+	// Environment holds variables added to the environment of the script,
+	// values can reference ssm parameters, for e.g. {{ ssm-secure:parameter-name }}
+	Environment map[string]string
+	// RunAsUser and RunAsGroup are the user and group the script runs as, they must be allowed by the run as policy of the agent.
+	// A group is only accepted along with a user.
+	RunAsUser  string
+	RunAsGroup string
+	// TerminationGracePeriodSeconds is how long the script has to exit after it is cancelled or timed out
+	// before it is killed, it defaults to the agent configuration
+	TerminationGracePeriodSeconds interface{}
+	// ResourceLimits override the resource limits of the agent configuration, zero values keep the configured limits
+	ResourceLimits ResourceLimitsInput
+}
+
+// ResourceLimitsInput represents the resource limits of a step, the values are numbers or strings
+// so that they can be set by document parameters, for e.g. {{ memoryLimit }}
+type ResourceLimitsInput struct {
+	CPUQuotaPercent interface{}
+	MemoryLimitMB   interface{}
+	MaxProcesses    interface{}
+	IOWeight        interface{}
 }
 
 // Execute runs multiple sets of commands and returns their outputs.
@@ -68,13 +95,13 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
 	} else {
-		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output)
+		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, context.AppConfig().RunScript, cancelFlag, output)
 	}
 }
 
 // runCommandsRawInput executes one set of commands and returns their output.
 // The input is in the default json unmarshal format (e.g. map[string]interface{}).
-func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, runScriptConfig appconfig.RunScriptCfg, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var pluginInput RunScriptPluginInput
 	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
 	if err != nil {
@@ -82,11 +109,11 @@ func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput
 		output.MarkAsFailed(errorString)
 		return
 	}
-	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, cancelFlag, output)
+	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, runScriptConfig, cancelFlag, output)
 }
 
 // runCommands executes one set of commands and returns their output.
-func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, runScriptConfig appconfig.RunScriptCfg, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var err error
 	var workingDir string
 
@@ -105,21 +132,43 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	orchestrationDir := fileutil.BuildPath(orchestrationDirectory, pluginInput.ID)
 	log.Debugf("Running commands %v in workingDirectory %v; orchestrationDir %v ", pluginInput.RunCommand, workingDir, orchestrationDir)
 
+	// Prepare the user and the environment of the process
+	options, err := prepareExecuteOptions(log, pluginInput, runScriptConfig)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
 	// create orchestration dir if needed
 	if err = fileutil.MakeDirsWithExecuteAccess(orchestrationDir); err != nil {
 		output.MarkAsFailed(fmt.Errorf("failed to create orchestrationDir directory, %v", orchestrationDir))
 		return
 	}
 
+	// The orchestration directory is only accessible to the agent,
+	// the script of another user is written to a temporary directory owned by the user
+	scriptDir := orchestrationDir
+	if options.RunAsUser != "" || options.RunAsGroup != "" {
+		if scriptDir, err = createRunAsDir(options); err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to create the script directory of user %v: %v", options.RunAsUser, err))
+			return
+		}
+		defer os.RemoveAll(scriptDir)
+	}
+
 	// Create script file path
-	scriptPath := filepath.Join(orchestrationDir, p.ScriptName)
-	log.Debugf("Writing commands %v to file %v", pluginInput, scriptPath)
+	scriptPath := filepath.Join(scriptDir, p.ScriptName)
+	log.Debugf("Writing commands %v to file %v", pluginInput.RunCommand, scriptPath)
 
 	// Create script file
 	if err = pluginutil.CreateScriptFile(log, scriptPath, pluginInput.RunCommand, p.ByteOrderMark); err != nil {
 		output.MarkAsFailed(fmt.Errorf("failed to create script file. %v", err))
 		return
 	}
+	if err = dep.GrantRunAsAccess(scriptPath, options.RunAsUser, options.RunAsGroup); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to give user %v access to the script file: %v", options.RunAsUser, err))
+		return
+	}
 
 	// Set execution time
 	executionTimeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
@@ -129,7 +178,7 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	commandArguments := append(p.ShellArguments, scriptPath)
 
 	// Execute Command
-	exitCode, err := p.CommandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments)
+	exitCode, err := p.CommandExecuter.NewExecuteWithOptions(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments, options)
 
 	// Set output status
 	output.SetExitCode(exitCode)
@@ -137,10 +186,141 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 
 	if err != nil {
 		status := output.GetStatus()
-		if status != contracts.ResultStatusCancelled &&
-			status != contracts.ResultStatusTimedOut &&
-			status != contracts.ResultStatusSuccessAndReboot {
+		if _, ok := err.(*executers.OutOfMemoryError); ok {
+			// the exit code of a killed script can't be told apart from the exit code of a stopped one
+			output.SetFailureReason(contracts.FailureReasonOutOfMemory)
+			output.MarkAsFailed(fmt.Errorf("failed to run commands: %v", err))
+		} else if status == contracts.ResultStatusCancelled || status == contracts.ResultStatusTimedOut {
+			// record the signal which ended the process
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				output.AppendInfo(string(exitErr.Stderr))
+			}
+		} else if status != contracts.ResultStatusSuccessAndReboot {
 			output.MarkAsFailed(fmt.Errorf("failed to run commands: %v", err))
 		}
 	}
 }
+
+// createRunAsDir creates a temporary directory owned by the user and group the script runs as
+func createRunAsDir(options executers.ExecuteOptions) (dir string, err error) {
+	if dir, err = ioutil.TempDir("", "ssm-runscript-"); err != nil {
+		return "", err
+	}
+	if err = dep.GrantRunAsAccess(dir, options.RunAsUser, options.RunAsGroup); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// prepareExecuteOptions checks the user and group of the script against the run as policy of the agent, a group is only
+// accepted along with a user
+// and resolves the ssm parameter references in the environment.
+// NOTE: Do not log the resolved environment values
+func prepareExecuteOptions(log log.T, pluginInput RunScriptPluginInput, runScriptConfig appconfig.RunScriptCfg) (options executers.ExecuteOptions, err error) {
+	// the script would keep the user of the agent and only change its group
+	if pluginInput.RunAsGroup != "" && pluginInput.RunAsUser == "" {
+		return options, fmt.Errorf("running commands as group %q requires runAsUser", pluginInput.RunAsGroup)
+	}
+	if err = dep.CheckRunAs(log, pluginInput.RunAsUser, pluginInput.RunAsGroup); err != nil {
+		return options, fmt.Errorf("running commands as user %q and group %q is not allowed: %v", pluginInput.RunAsUser, pluginInput.RunAsGroup, err)
+	}
+	options.RunAsUser = pluginInput.RunAsUser
+	options.RunAsGroup = pluginInput.RunAsGroup
+	options.TerminationGracePeriod = time.Duration(
+		getTerminationGracePeriod(log, pluginInput.TerminationGracePeriodSeconds, runScriptConfig.TerminationGracePeriodSeconds)) * time.Second
+	if options.ResourceLimits, err = getResourceLimits(pluginInput.ResourceLimits, runScriptConfig.ResourceLimits); err != nil {
+		return options, err
+	}
+
+	if len(pluginInput.Environment) == 0 {
+		return options, nil
+	}
+	options.Environment = make(map[string]string, len(pluginInput.Environment))
+	for name, value := range pluginInput.Environment {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return options, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if strings.Contains(value, "{{") {
+			if value, err = dep.ResolveParameters(log, value); err != nil {
+				return options, fmt.Errorf("Could not resolve ssm parameter in environment variable %v. Error - %v", name, err)
+			}
+		}
+		options.Environment[name] = value
+	}
+	return options, nil
+}
+
+// getTerminationGracePeriod returns the grace period of the step in seconds, or the default when it is not set or invalid
+func getTerminationGracePeriod(log log.T, input interface{}, defaultSeconds int) int {
+	var seconds int
+	switch value := input.(type) {
+	case nil:
+		return defaultSeconds
+	case string:
+		var err error
+		if seconds, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			log.Infof("Unexpected 'TerminationGracePeriodSeconds' value %v received. Setting it to default value %v", value, defaultSeconds)
+			return defaultSeconds
+		}
+	case int:
+		seconds = value
+	case float64:
+		seconds = int(value)
+	default:
+		log.Infof("Unexpected 'TerminationGracePeriodSeconds' value %v received. Setting it to default value %v", value, defaultSeconds)
+		return defaultSeconds
+	}
+
+	if seconds < appconfig.DefaultTerminationGracePeriodSecondsMin || seconds > appconfig.DefaultTerminationGracePeriodSecondsMax {
+		log.Infof("'TerminationGracePeriodSeconds' value should be between %v and %v. Setting it to default value %v",
+			appconfig.DefaultTerminationGracePeriodSecondsMin, appconfig.DefaultTerminationGracePeriodSecondsMax, defaultSeconds)
+		return defaultSeconds
+	}
+	return seconds
+}
+
+// getResourceLimits returns the resource limits of the step, the limits which are not set by the step are the configured ones
+func getResourceLimits(stepLimits ResourceLimitsInput, configLimits appconfig.ResourceLimitsCfg) (limits appconfig.ResourceLimitsCfg, err error) {
+	override := func(name string, stepValue interface{}, configValue int, maxValue int) int {
+		value, parseErr := parseResourceLimit(stepValue)
+		if parseErr != nil {
+			err = fmt.Errorf("resource limit %v should be a number: %v", name, parseErr)
+			return configValue
+		}
+		if value < 0 || value > maxValue {
+			err = fmt.Errorf("resource limit %v should be between 0 and %v", name, maxValue)
+		}
+		if value == 0 {
+			return configValue
+		}
+		return value
+	}
+	limits.CPUQuotaPercent = override("CPUQuotaPercent", stepLimits.CPUQuotaPercent, configLimits.CPUQuotaPercent, appconfig.ResourceLimitCPUQuotaPercentMax)
+	limits.MemoryLimitMB = override("MemoryLimitMB", stepLimits.MemoryLimitMB, configLimits.MemoryLimitMB, appconfig.ResourceLimitMemoryLimitMBMax)
+	limits.MaxProcesses = override("MaxProcesses", stepLimits.MaxProcesses, configLimits.MaxProcesses, appconfig.ResourceLimitMaxProcessesMax)
+	limits.IOWeight = override("IOWeight", stepLimits.IOWeight, configLimits.IOWeight, appconfig.ResourceLimitIOWeightMax)
+	return limits, err
+}
+
+// parseResourceLimit converts the value of a resource limit to an integer, unset and empty values are zero
+func parseResourceLimit(value interface{}) (int, error) {
+	switch value := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return value, nil
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, fmt.Errorf("%v is not an integer", value)
+		}
+		return int(value), nil
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(strings.TrimSpace(value))
+	default:
+		return 0, fmt.Errorf("unexpected value %v", value)
+	}
+}
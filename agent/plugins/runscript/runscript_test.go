@@ -15,11 +15,17 @@ package runscript
 
 import (
 	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	multiwritermock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter/mock"
@@ -140,9 +146,9 @@ func testRunScripts(t *testing.T, testCase TestCase, rawInput bool) {
 			err := jsonutil.Remarshal(testCase.Input, &rawPluginInput)
 			assert.Nil(t, err)
 
-			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
 		} else {
-			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
 		}
 	}
 
@@ -167,7 +173,7 @@ func testBucketsInDifferentRegions(t *testing.T, testCase TestCase, testingBucke
 		setIOHandlerExpectations(mockIOHandler, testCase)
 
 		// call method under test
-		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
 	}
 
 	testExecution(t, runScriptTester)
@@ -323,7 +329,7 @@ func testExecution(t *testing.T, commandtester CommandTester) {
 }
 
 func setExecuterExpectations(mockExecuter *executers.MockCommandExecuter, t TestCase, cancelFlag task.CancelFlag, p *Plugin) {
-	mockExecuter.On("NewExecute", mock.Anything, t.Input.WorkingDirectory, t.Output.StdoutWriter, t.Output.StderrWriter, cancelFlag, mock.Anything, mock.Anything, mock.Anything).Return(
+	mockExecuter.On("NewExecuteWithOptions", mock.Anything, t.Input.WorkingDirectory, t.Output.StdoutWriter, t.Output.StderrWriter, cancelFlag, mock.Anything, mock.Anything, mock.Anything, executers.ExecuteOptions{}).Return(
 		t.Output.ExitCode, t.ExecuterError)
 }
 
@@ -343,3 +349,231 @@ func setCancelFlagExpectations(mockCancelFlag *task.MockCancelFlag, times int) {
 	mockCancelFlag.On("Canceled").Return(false).Times(times)
 	mockCancelFlag.On("ShutDown").Return(false).Times(times)
 }
+
+type resolverStub struct {
+	values        map[string]string
+	allowedUsers  []string
+	allowedGroups []string
+	grantedPaths  []string
+}
+
+func (r resolverStub) ResolveParameters(log log.T, text string) (string, error) {
+	for reference, value := range r.values {
+		text = strings.Replace(text, "{{ "+reference+" }}", value, -1)
+	}
+	if strings.Contains(text, "{{") {
+		return "", fmt.Errorf("unknown parameter in %v", text)
+	}
+	return text, nil
+}
+
+func (r resolverStub) CheckRunAs(log log.T, runAsUser string, runAsGroup string) error {
+	if runAsUser != "" && !containsName(r.allowedUsers, runAsUser) {
+		return fmt.Errorf("run as user %v is not allowed by the run as policy", runAsUser)
+	}
+	if runAsGroup != "" && !containsName(r.allowedGroups, runAsGroup) {
+		return fmt.Errorf("run as group %v is not allowed by the run as policy", runAsGroup)
+	}
+	return nil
+}
+
+func (r *resolverStub) GrantRunAsAccess(path string, runAsUser string, runAsGroup string) error {
+	if runAsUser != "" || runAsGroup != "" {
+		r.grantedPaths = append(r.grantedPaths, path)
+	}
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, allowedName := range names {
+		if allowedName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPrepareExecuteOptions(t *testing.T) {
+	origDep := dep
+	stub := &resolverStub{values: map[string]string{"ssm-secure:db-password": "secret"}, allowedUsers: []string{"app"}, allowedGroups: []string{"app", "ops"}}
+	dep = stub
+	defer func() { dep = origDep }()
+
+	config := appconfig.RunScriptCfg{TerminationGracePeriodSeconds: 10}
+	input := RunScriptPluginInput{
+		Environment: map[string]string{"DB_PASSWORD": "{{ ssm-secure:db-password }}", "MODE": "batch"},
+		RunAsUser:   "app",
+		RunAsGroup:  "ops",
+	}
+
+	options, err := prepareExecuteOptions(logger, input, config)
+	assert.NoError(t, err)
+	assert.Equal(t, executers.ExecuteOptions{
+		Environment:            map[string]string{"DB_PASSWORD": "secret", "MODE": "batch"},
+		RunAsUser:              "app",
+		RunAsGroup:             "ops",
+		TerminationGracePeriod: 10 * time.Second,
+	}, options)
+
+	// the step can override the grace period of the agent configuration
+	options, err = prepareExecuteOptions(logger, RunScriptPluginInput{TerminationGracePeriodSeconds: "30"}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, options.TerminationGracePeriod)
+
+	// users and groups must be allowed by the run as policy
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{RunAsUser: "root"}, config)
+	assert.Error(t, err)
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{RunAsUser: "app", RunAsGroup: "wheel"}, config)
+	assert.Error(t, err)
+
+	// a group alone would leave the script running as the agent
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{RunAsGroup: "ops"}, config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "requires runAsUser")
+	stub.allowedUsers = nil
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{RunAsUser: "app"}, config)
+	assert.Error(t, err)
+
+	// the error doesn't contain the value of the variable
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{Environment: map[string]string{"TOKEN": "{{ ssm-secure:unknown }}"}}, config)
+	assert.Error(t, err)
+	_, err = prepareExecuteOptions(logger, RunScriptPluginInput{Environment: map[string]string{"A=B": "value"}}, config)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "value")
+}
+
+func TestRunCommandsWithExecuteOptions(t *testing.T) {
+	origDep := dep
+	stub := &resolverStub{values: map[string]string{"ssm-secure:token": "secret"}, allowedUsers: []string{"app"}}
+	dep = stub
+	defer func() { dep = origDep }()
+
+	testCase := generateTestCaseOk("0")
+	testCase.Input.Environment = map[string]string{"TOKEN": "{{ ssm-secure:token }}"}
+	testCase.Input.RunAsUser = "app"
+	config := appconfig.RunScriptCfg{}
+
+	runScriptTester := func(p *Plugin, mockCancelFlag *task.MockCancelFlag, mockExecuter *executers.MockCommandExecuter, mockIOHandler *iohandlermocks.MockIOHandler) {
+		expectedOptions := executers.ExecuteOptions{Environment: map[string]string{"TOKEN": "secret"}, RunAsUser: "app"}
+		mockExecuter.On("NewExecuteWithOptions", mock.Anything, testCase.Input.WorkingDirectory, testCase.Output.StdoutWriter, testCase.Output.StderrWriter, mockCancelFlag, mock.Anything, mock.Anything, mock.Anything, expectedOptions).Return(
+			testCase.Output.ExitCode, testCase.ExecuterError)
+		setIOHandlerExpectations(mockIOHandler, testCase)
+
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, config, mockCancelFlag, mockIOHandler)
+	}
+
+	testExecution(t, runScriptTester)
+
+	// the script and its directory are given to the user, and the directory is removed afterwards
+	assert.Len(t, stub.grantedPaths, 2)
+	assert.Equal(t, stub.grantedPaths[0], filepath.Dir(stub.grantedPaths[1]))
+	assert.False(t, fileutil.Exists(stub.grantedPaths[0]))
+}
+
+func TestRunCommandsWithUserNotAllowed(t *testing.T) {
+	origDep := dep
+	dep = &resolverStub{}
+	defer func() { dep = origDep }()
+
+	testCase := generateTestCaseOk("0")
+	testCase.Input.RunAsUser = "root"
+
+	runScriptTester := func(p *Plugin, mockCancelFlag *task.MockCancelFlag, mockExecuter *executers.MockCommandExecuter, mockIOHandler *iohandlermocks.MockIOHandler) {
+		mockIOHandler.On("MarkAsFailed", fmt.Errorf("running commands as user \"root\" and group \"\" is not allowed: run as user root is not allowed by the run as policy")).Return()
+
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
+	}
+
+	testExecution(t, runScriptTester)
+}
+
+func TestGetTerminationGracePeriod(t *testing.T) {
+	assert.Equal(t, 10, getTerminationGracePeriod(logger, nil, 10))
+	assert.Equal(t, 0, getTerminationGracePeriod(logger, 0, 10))
+	assert.Equal(t, 30, getTerminationGracePeriod(logger, "30", 10))
+	assert.Equal(t, 30, getTerminationGracePeriod(logger, 30.0, 10))
+	assert.Equal(t, 10, getTerminationGracePeriod(logger, "thirty", 10))
+	assert.Equal(t, 10, getTerminationGracePeriod(logger, -1, 10))
+	assert.Equal(t, 10, getTerminationGracePeriod(logger, appconfig.DefaultTerminationGracePeriodSecondsMax+1, 10))
+	assert.Equal(t, 10, getTerminationGracePeriod(logger, true, 10))
+}
+
+func TestRunCommandsCancelledRecordsSignal(t *testing.T) {
+	testCase := generateTestCaseOk("0")
+	testCase.Input.TerminationGracePeriodSeconds = 5
+	testCase.Output.ExitCode = appconfig.CommandStoppedPreemptivelyExitCode
+	testCase.Output.Status = contracts.ResultStatusCancelled
+	executerError := &exec.ExitError{Stderr: []byte("Cancelled process, stopped with SIGTERM")}
+
+	runScriptTester := func(p *Plugin, mockCancelFlag *task.MockCancelFlag, mockExecuter *executers.MockCommandExecuter, mockIOHandler *iohandlermocks.MockIOHandler) {
+		expectedOptions := executers.ExecuteOptions{TerminationGracePeriod: 5 * time.Second}
+		mockExecuter.On("NewExecuteWithOptions", mock.Anything, testCase.Input.WorkingDirectory, testCase.Output.StdoutWriter, testCase.Output.StderrWriter, mockCancelFlag, mock.Anything, mock.Anything, mock.Anything, expectedOptions).Return(
+			testCase.Output.ExitCode, executerError)
+		mockCancelFlag.On("ShutDown").Return(false)
+		mockCancelFlag.On("Canceled").Return(true)
+		mockIOHandler.On("GetStdoutWriter").Return(testCase.Output.StdoutWriter)
+		mockIOHandler.On("GetStderrWriter").Return(testCase.Output.StderrWriter)
+		mockIOHandler.On("SetExitCode", testCase.Output.ExitCode).Return()
+		mockIOHandler.On("SetStatus", contracts.ResultStatusCancelled).Return()
+		mockIOHandler.On("GetStatus").Return(contracts.ResultStatusCancelled)
+		mockIOHandler.On("AppendInfo", "Cancelled process, stopped with SIGTERM").Return()
+
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
+	}
+
+	testExecution(t, runScriptTester)
+}
+
+func TestGetResourceLimits(t *testing.T) {
+	config := appconfig.ResourceLimitsCfg{CPUQuotaPercent: 100, MemoryLimitMB: 512}
+
+	limits, err := getResourceLimits(ResourceLimitsInput{}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, config, limits)
+
+	// the step overrides the limits it sets
+	limits, err = getResourceLimits(ResourceLimitsInput{MemoryLimitMB: 128, MaxProcesses: 50}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, appconfig.ResourceLimitsCfg{CPUQuotaPercent: 100, MemoryLimitMB: 128, MaxProcesses: 50}, limits)
+
+	// the values of the document are numbers or the strings of substituted parameters
+	limits, err = getResourceLimits(ResourceLimitsInput{MemoryLimitMB: "128", MaxProcesses: float64(50), IOWeight: ""}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, appconfig.ResourceLimitsCfg{CPUQuotaPercent: 100, MemoryLimitMB: 128, MaxProcesses: 50}, limits)
+
+	_, err = getResourceLimits(ResourceLimitsInput{IOWeight: appconfig.ResourceLimitIOWeightMax + 1}, config)
+	assert.Error(t, err)
+	_, err = getResourceLimits(ResourceLimitsInput{MemoryLimitMB: -1}, config)
+	assert.Error(t, err)
+	_, err = getResourceLimits(ResourceLimitsInput{MemoryLimitMB: "{{ memoryLimit }}"}, config)
+	assert.Error(t, err)
+	_, err = getResourceLimits(ResourceLimitsInput{CPUQuotaPercent: 12.5}, config)
+	assert.Error(t, err)
+}
+
+func TestRunCommandsOutOfMemory(t *testing.T) {
+	testCase := generateTestCaseOk("0")
+	testCase.Input.ResourceLimits = ResourceLimitsInput{MemoryLimitMB: "64"}
+	testCase.Output.ExitCode = appconfig.CommandStoppedPreemptivelyExitCode
+	testCase.Output.Status = contracts.ResultStatusTimedOut
+	testCase.ExecuterError = &executers.OutOfMemoryError{Err: fmt.Errorf("exit status 137")}
+
+	runScriptTester := func(p *Plugin, mockCancelFlag *task.MockCancelFlag, mockExecuter *executers.MockCommandExecuter, mockIOHandler *iohandlermocks.MockIOHandler) {
+		expectedOptions := executers.ExecuteOptions{ResourceLimits: appconfig.ResourceLimitsCfg{MemoryLimitMB: 64}}
+		mockExecuter.On("NewExecuteWithOptions", mock.Anything, testCase.Input.WorkingDirectory, testCase.Output.StdoutWriter, testCase.Output.StderrWriter, mockCancelFlag, mock.Anything, mock.Anything, mock.Anything, expectedOptions).Return(
+			testCase.Output.ExitCode, testCase.ExecuterError)
+		mockCancelFlag.On("ShutDown").Return(false)
+		mockCancelFlag.On("Canceled").Return(false)
+		mockIOHandler.On("GetStdoutWriter").Return(testCase.Output.StdoutWriter)
+		mockIOHandler.On("GetStderrWriter").Return(testCase.Output.StderrWriter)
+		mockIOHandler.On("SetExitCode", testCase.Output.ExitCode).Return()
+		mockIOHandler.On("SetStatus", contracts.ResultStatusTimedOut).Return()
+		mockIOHandler.On("GetStatus").Return(contracts.ResultStatusTimedOut)
+		mockIOHandler.On("SetFailureReason", contracts.FailureReasonOutOfMemory).Return()
+		mockIOHandler.On("MarkAsFailed", fmt.Errorf("failed to run commands: %v", testCase.ExecuterError)).Return()
+
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, appconfig.RunScriptCfg{}, mockCancelFlag, mockIOHandler)
+	}
+
+	testExecution(t, runScriptTester)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package runscript implements the runscript plugin.
+package runscript
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// policyStub allows the given user and gives the files to the user like the agent does
+type policyStub struct {
+	runScriptDepImpl
+	allowedUser string
+}
+
+func (p policyStub) CheckRunAs(log log.T, runAsUser string, runAsGroup string) error {
+	return (&resolverStub{allowedUsers: []string{p.allowedUser}}).CheckRunAs(log, runAsUser, runAsGroup)
+}
+
+func TestRunCommandsAsNonRootUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running commands as another user requires root")
+	}
+	account, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("user nobody does not exist")
+	}
+
+	origDep := dep
+	dep = policyStub{allowedUser: account.Username}
+	defer func() { dep = origDep }()
+
+	// the orchestration directory is only accessible to root like the one of the agent
+	orchestrationDir, err := ioutil.TempDir("", "orchestration")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+
+	plugin, err := NewRunShellPlugin(logger)
+	assert.NoError(t, err)
+	input := RunScriptPluginInput{
+		RunCommand: []string{"id -un", `cat "$0" > /dev/null && echo readable`},
+		ID:         "0.aws:runShellScript",
+		RunAsUser:  account.Username,
+	}
+
+	output := iohandler.NewDefaultIOHandler(logger, contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir})
+	output.Init(logger, pluginID)
+	plugin.runCommands(logger, pluginID, input, orchestrationDir, "/", appconfig.RunScriptCfg{}, task.NewChanneledCancelFlag(), output)
+	output.Close(logger)
+
+	assert.Equal(t, 0, output.GetExitCode(), output.GetStderr())
+assert.Equal(t, []string{account.Username, "readable"}, strings.Fields(output.GetStdout()))
+}
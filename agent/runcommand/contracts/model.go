@@ -27,6 +27,11 @@ type CancelPayload struct {
 	CancelMessageID string `json:"CancelMessageId"`
 }
 
+// OfflineCancelPayload represents the json structure of a cancel command document submitted to the offline service.
+type OfflineCancelPayload struct {
+	CancelCommandID string `json:"CancelCommandId"`
+}
+
 // SendCommandPayload parallels the structure of a send command MDS message payload.
 type SendCommandPayload struct {
 	Parameters              map[string]interface{}    `json:"Parameters"`
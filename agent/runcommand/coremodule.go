@@ -29,6 +29,7 @@ import (
 	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
 	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/aws/aws-sdk-go/service/ssmmds"
 	"github.com/carlescere/scheduler"
 )
@@ -41,6 +42,8 @@ const (
 	parameters       = "Parameters"
 	// MDS service will mark document as timeout if it didn't recieve any responce from the agent after 2 hours
 	documentLevelTimeOutDurationHour = 2
+	// commandBlackoutMessage is the output of the commands which are failed during a blackout window
+	commandBlackoutMessage = "Command received during blackout window %v, commands are accepted again at %v"
 )
 
 var singletonMapOfUnsupportedSSMDocs map[string]bool
@@ -189,6 +192,14 @@ func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
 
 	log.Debugf("Ack done. Received message - messageId - %v", *msg.MessageId)
 
+	if docState.DocumentType == contracts.SendCommand || docState.DocumentType == contracts.SendCommandOffline {
+		if reason, inBlackout := s.checkBlackout(); inBlackout {
+			log.Info(reason)
+			s.sendDocLevelResponse(*msg.MessageId, contracts.ResultStatusFailed, reason)
+			return
+		}
+	}
+
 	log.Debugf("Processing to send a reply to update the document status to InProgress")
 
 	//TODO This function should be called in service when it submits the document to the engine
@@ -207,6 +218,18 @@ func (s *RunCommandService) processMessage(msg *ssmmds.Message) {
 
 }
 
+// checkBlackout returns why commands are failed if the agent is in a blackout window which applies to Run Command
+func (s *RunCommandService) checkBlackout() (reason string, inBlackout bool) {
+	if s.blackoutSchedule == nil || !s.blackoutSchedule.AppliesToRunCommand() {
+		return "", false
+	}
+	windowName, end, active := s.blackoutSchedule.ActiveWindow(time.Now().UTC())
+	if !active {
+		return "", false
+	}
+	return fmt.Sprintf(commandBlackoutMessage, windowName, times.ToIso8601UTC(end)), true
+}
+
 // sendFailedReplies loads replies from local disk and send it again to the service, if it fails no action is needed
 func (s *RunCommandService) sendFailedReplies() {
 	log := s.context.Log()
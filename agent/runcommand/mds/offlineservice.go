@@ -35,6 +35,7 @@ import (
 
 type offlineService struct {
 	TopicPrefix         string
+	CancelTopicPrefix   string
 	newCommandDir       string
 	submittedCommandDir string
 	commandResultDir    string
@@ -42,7 +43,7 @@ type offlineService struct {
 }
 
 // NewOfflineService initializes a service that looks for work in a local command folder
-func NewOfflineService(log log.T, topicPrefix string) (Service, error) {
+func NewOfflineService(log log.T, topicPrefix string, cancelTopicPrefix string) (Service, error) {
 	uuid.SwitchFormat(uuid.CleanHyphen)
 	// Create and harden local document folder if needed
 	err := fileutil.MakeDirs(appconfig.LocalCommandRoot)
@@ -53,6 +54,7 @@ func NewOfflineService(log log.T, topicPrefix string) (Service, error) {
 	err = fileutil.MakeDirs(appconfig.LocalCommandRootCompleted)
 	return &offlineService{
 		TopicPrefix:         topicPrefix,
+		CancelTopicPrefix:   cancelTopicPrefix,
 		newCommandDir:       appconfig.LocalCommandRoot,
 		submittedCommandDir: appconfig.LocalCommandRootSubmitted,
 		invalidCommandDir:   appconfig.LocalCommandRootInvalid,
@@ -83,22 +85,30 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 		commandID := uuid.NewV4().String()
 		messageID := fmt.Sprintf("aws.ssm.%v.%v", commandID, instanceID)
 
-		// Parse file
-		var content contracts.DocumentContent
-		if errContent := jsonutil.UnmarshalFile(docPath, &content); errContent != nil {
-			log.Errorf("Error parsing command document %v:\n%v", docName, errContent)
-			if errMove := moveCommandDocument(ols.newCommandDir, ols.invalidCommandDir, docName, commandID); errMove != nil {
-				log.Errorf("Command %v was invalid but failed to move to invalid folder: %v", commandID, errMove.Error())
+		// Parse file and turn it into a message
+		var payloadstr, topicPrefix string
+		if cancelPayload, isCancel := parseCancelDocument(docPath); isCancel {
+			log.Debugf("Local cancel document for command %v", cancelPayload.CancelCommandID)
+			payload := &messageContracts.CancelPayload{CancelMessageID: fmt.Sprintf("aws.ssm.%v.%v", cancelPayload.CancelCommandID, instanceID)}
+			payloadstr, err = jsonutil.Marshal(payload)
+			topicPrefix = ols.CancelTopicPrefix
+		} else {
+			var content contracts.DocumentContent
+			if errContent := jsonutil.UnmarshalFile(docPath, &content); errContent != nil {
+				log.Errorf("Error parsing command document %v:\n%v", docName, errContent)
+				if errMove := moveCommandDocument(ols.newCommandDir, ols.invalidCommandDir, docName, commandID); errMove != nil {
+					log.Errorf("Command %v was invalid but failed to move to invalid folder: %v", commandID, errMove.Error())
+				}
+				continue
 			}
-			continue
-		}
-		debugContent, _ := jsonutil.Marshal(content)
-		log.Debugf("Local command content:\n%v", debugContent)
+			debugContent, _ := jsonutil.Marshal(content)
+			log.Debugf("Local command content:\n%v", debugContent)
 
-		// Turn it into a message
-		payload := &messageContracts.SendCommandPayload{DocumentContent: content, CommandID: commandID, DocumentName: docName}
-		var payloadstr string
-		if payloadstr, err = jsonutil.Marshal(payload); err != nil {
+			payload := &messageContracts.SendCommandPayload{DocumentContent: content, CommandID: commandID, DocumentName: docName}
+			payloadstr, err = jsonutil.Marshal(payload)
+			topicPrefix = ols.TopicPrefix
+		}
+		if err != nil {
 			log.Errorf("Error marshalling message for command document %v with message ID %v:\n%v", docName, messageID, err)
 			if errMove := moveCommandDocument(ols.newCommandDir, ols.invalidCommandDir, docName, commandID); errMove != nil {
 				log.Errorf("Command %v was invalid but failed to move to invalid folder: %v", commandID, errMove.Error())
@@ -106,7 +116,7 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 			continue
 		}
 		created := times.ToIso8601UTC(time.Now())
-		topic := fmt.Sprintf("%v.%v", ols.TopicPrefix, docName)
+		topic := fmt.Sprintf("%v.%v", topicPrefix, docName)
 		message := &ssmmds.Message{
 			CreatedDate: &created,
 			Destination: &instanceID,
@@ -126,6 +136,14 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 	return messages, nil
 }
 
+// parseCancelDocument checks whether a local document asks to cancel a command rather than run one
+func parseCancelDocument(docPath string) (payload messageContracts.OfflineCancelPayload, isCancel bool) {
+	if err := jsonutil.UnmarshalFile(docPath, &payload); err != nil {
+		return payload, false
+	}
+	return payload, payload.CancelCommandID != ""
+}
+
 // TODO:MF: clean up old documents in dstDir?  Or maybe do that in SendReply?  Maybe both
 // moveCommandDocument moves a command into its final destination and attaches the command ID file extension
 func moveCommandDocument(srcDir string, dstDir string, docName string, commandID string) error {
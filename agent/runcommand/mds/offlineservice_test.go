@@ -16,6 +16,7 @@ package service
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -80,6 +81,23 @@ func TestBothVersions(t *testing.T) {
 	assert.Equal(t, 2, FileCount(submittedCommands))
 }
 
+func TestCancel(t *testing.T) {
+	service := GetTestService()
+
+	defer CleanTestDirs()
+	err := SubmitTestDoc("cancelcommand.json")
+	assert.Nil(t, err)
+
+	messages, err := service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages.Messages))
+	assert.True(t, strings.HasPrefix(*messages.Messages[0].Topic, "bar."))
+	assert.Contains(t, *messages.Messages[0].Payload, "aws.ssm.01234567-890a-bcde-f012-34567890abcd.i-bar")
+	assert.Equal(t, 0, FileCount(newCommands))
+	assert.Equal(t, 1, FileCount(submittedCommands))
+}
+
 func TestOfflineService_SendReply(t *testing.T) {
 	service := GetTestService()
 	defer CleanTestDirs()
@@ -91,6 +109,7 @@ func GetTestService() Service {
 	CleanTestDirs()
 	return &offlineService{
 		TopicPrefix:         "foo",
+		CancelTopicPrefix:   "bar",
 		newCommandDir:       newCommands,
 		submittedCommandDir: submittedCommands,
 		invalidCommandDir:   invalidCommands,
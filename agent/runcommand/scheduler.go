@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/carlescere/scheduler"
 )
@@ -30,6 +31,14 @@ var lock sync.RWMutex
 
 var processMessage = (*RunCommandService).processMessage
 
+var (
+	pollDuration = metrics.NewHistogram("ssm_agent_poll_duration_seconds",
+		"Duration of the polls for messages, the MessagingDeliveryService polls are long polls.",
+		metrics.DefaultDurationBuckets, "service")
+	pollErrors = metrics.NewCounter("ssm_agent_poll_errors_total",
+		"Number of polls for messages which failed.", "service")
+)
+
 func updateLastPollTime(processorType string, currentTime time.Time) {
 	lock.Lock()
 	defer lock.Unlock()
@@ -144,8 +153,11 @@ func (s *RunCommandService) pollOnce() {
 	if s.name == mdsName {
 		log.Debugf("Polling for messages")
 	}
+	pollStart := time.Now()
 	messages, err := s.service.GetMessages(log, s.config.InstanceID)
+	pollDuration.Observe(time.Since(pollStart).Seconds(), s.name)
 	if err != nil {
+		pollErrors.Inc(s.name)
 		sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 		return
 	}
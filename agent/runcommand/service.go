@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	associationProcessor "github.com/aws/amazon-ssm-agent/agent/association/processor"
+	"github.com/aws/amazon-ssm-agent/agent/blackout"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
@@ -97,6 +98,7 @@ type RunCommandService struct {
 	processorStopPolicy *sdkutil.StopPolicy
 	pollAssociations    bool
 	processor           processor.Processor
+	blackoutSchedule    *blackout.Schedule
 }
 
 // NewOfflineProcessor initialize a new offline command document processor
@@ -182,6 +184,7 @@ func NewService(ctx context.T, serviceName string, service mdsService.Service, c
 		assocProcessor:       assocProc,
 		pollAssociations:     pollAssoc,
 		processor:            processor,
+		blackoutSchedule:     blackout.NewSchedule(log, config.Blackout),
 	}
 }
 
@@ -214,7 +217,7 @@ func processSendReply(log log.T, messageID string, mdsService mdsService.Service
 }
 
 var newOfflineService = func(log log.T) (mdsService.Service, error) {
-	return mdsService.NewOfflineService(log, string(SendCommandTopicPrefixOffline))
+	return mdsService.NewOfflineService(log, string(SendCommandTopicPrefixOffline), string(CancelCommandTopicPrefixOffline))
 }
 
 var newMdsService = func(config appconfig.SsmagentConfig) mdsService.Service {
@@ -23,6 +23,8 @@ import (
 	"encoding/json"
 	"path"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/blackout"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/docparser"
@@ -92,6 +94,39 @@ func TestProcessMessageWithSendCommandTopicPrefix(t *testing.T) {
 	assert.True(t, *tc.IsDocLevelResponseSent)
 }
 
+// TestProcessMessageDuringBlackout tests processMessage fails commands during a blackout window which applies to Run Command
+func TestProcessMessageDuringBlackout(t *testing.T) {
+	var fakeDocState = contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+	}
+	svc, tc := prepareTestProcessMessage(testTopicSend)
+	allDay := appconfig.BlackoutWindow{Name: "freeze", StartTime: "00:00", EndTime: "00:00"}
+	svc.blackoutSchedule = blackout.NewSchedule(loggers, appconfig.BlackoutCfg{
+		Windows:           []appconfig.BlackoutWindow{allDay},
+		ApplyToRunCommand: true,
+	})
+	var reportedStatus contracts.ResultStatus
+	svc.sendDocLevelResponse = func(messageID string, resultStatus contracts.ResultStatus, documentTraceOutput string) {
+		reportedStatus = resultStatus
+	}
+
+	// set the expectations
+	tc.MdsMock.On("AcknowledgeMessage", mock.Anything, *tc.Message.MessageId).Return(nil)
+	loadDocStateFromSendCommand = func(context context.T,
+		msg *ssmmds.Message,
+		messagesOrchestrationRootDir string) (*contracts.DocumentState, error) {
+		return &fakeDocState, nil
+	}
+
+	// execute processMessage
+	svc.processMessage(&tc.Message)
+
+	// check expectations
+	tc.MdsMock.AssertExpectations(t)
+	tc.ProcessMock.AssertNotCalled(t, "Submit", mock.Anything)
+	assert.Equal(t, contracts.ResultStatusFailed, reportedStatus)
+}
+
 // TestProcessMessageWithCancelCommandTopicPrefix tests processMessage with CancelCommand topic prefix
 func TestProcessMessageWithCancelCommandTopicPrefix(t *testing.T) {
 	// CancelCommand topic prefix
@@ -287,6 +287,8 @@ func (agentMessage *AgentMessage) ParseAgentMessage(context context.T,
 		OrchestrationDir: messageOrchestrationDirectory,
 		MessageId:        documentInfo.MessageID,
 		DocumentId:       documentInfo.DocumentID,
+		SessionOwner:     parsedMessagePayload.SessionOwner,
+		RunAsUser:        parsedMessagePayload.RunAsUser,
 	}
 	docContent := &docparser.SessionDocContent{
 		SchemaVersion:   parsedMessagePayload.DocumentContent.SchemaVersion,
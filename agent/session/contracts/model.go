@@ -93,6 +93,8 @@ type AgentTaskPayload struct {
 	DocumentContent contracts.SessionDocumentContent `json:"DocumentContent"`
 	SessionId       string                           `json:"SessionId"`
 	Parameters      map[string]interface{}           `json:"Parameters"`
+	RunAsUser       string                           `json:"RunAsUser"`
+	SessionOwner    string                           `json:"SessionOwner"`
 }
 
 // AcknowledgeContent is used to inform the sender of an acknowledge message that the message has been received.
@@ -188,6 +190,7 @@ type AgentTaskCompletePayload struct {
 	S3UrlSuffix      string `json:"S3UrlSuffix"`
 	CwlGroup         string `json:"CwlGroup"`
 	CwlStream        string `json:"CwlStream"`
+	ExitCode         int    `json:"ExitCode"`
 }
 
 // SessionPluginResultOutput represents PluginResult output sent to MGS as part of AgentTaskComplete message
@@ -211,6 +214,7 @@ const (
 	HandshakeComplete    PayloadType = 7
 	EncChallengeRequest  PayloadType = 8
 	EncChallengeResponse PayloadType = 9
+	StdErr               PayloadType = 10
 )
 
 type SessionStatus string
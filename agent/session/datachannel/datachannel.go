@@ -29,6 +29,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/crypto"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/rip"
 	"github.com/aws/amazon-ssm-agent/agent/session/communicator"
@@ -43,6 +44,14 @@ import (
 	"github.com/twinj/uuid"
 )
 
+var (
+	retransmissions = metrics.NewCounter("ssm_agent_session_retransmissions_total",
+		"Number of stream data messages resent on the data channels because they weren't acknowledged in time.")
+	roundTripTimes = metrics.NewHistogram("ssm_agent_session_round_trip_time_seconds",
+		"Time between sending a stream data message on the data channels and receiving its acknowledgement.",
+		metrics.DefaultDurationBuckets)
+)
+
 const (
 	schemaVersion  = 1
 	sequenceNumber = 0
@@ -69,6 +78,8 @@ type IDataChannel interface {
 	RemoveDataFromIncomingMessageBuffer(sequenceNumber int64)
 	SkipHandshake(log log.T)
 	PerformHandshake(log log.T, kmsKeyId string) (err error)
+	IsPaused() bool
+	StartSessionTimer(log log.T, idleTimeout time.Duration, maxDuration time.Duration, warnClient bool)
 }
 
 // DataChannel used for session communication between the message gateway service and the agent.
@@ -107,6 +118,10 @@ type DataChannel struct {
 	blockCipher crypto.IBlockCipher
 	// Indicates whether encryption was enabled
 	encryptionEnabled bool
+	//sessionTimer ends the session when it is idle or exceeds its maximum duration
+	sessionTimer *sessionTimer
+	//streamDataMutex serializes stream data messages sent by the plugin and by the session timer
+	streamDataMutex sync.Mutex
 }
 
 type ListMessageBuffer struct {
@@ -241,6 +256,7 @@ func (dataChannel *DataChannel) Initialize(context context.T,
 	dataChannel.wsChannel = &communicator.WebSocketChannel{}
 	dataChannel.cancelFlag = cancelFlag
 	dataChannel.inputStreamMessageHandler = inputStreamMessageHandler
+	dataChannel.sessionTimer = newSessionTimer()
 	dataChannel.handshake = Handshake{
 		responseChan:            make(chan bool),
 		encryptionConfirmedChan: make(chan bool),
@@ -362,6 +378,7 @@ func (dataChannel *DataChannel) Reconnect(log log.T) error {
 // Close closes datachannel - its web socket connection.
 func (dataChannel *DataChannel) Close(log log.T) error {
 	log.Infof("Closing datachannel with channel Id %s", dataChannel.ChannelId)
+	dataChannel.sessionTimer.stop()
 	return dataChannel.wsChannel.Close(log)
 }
 
@@ -372,13 +389,27 @@ func (dataChannel *DataChannel) SendStreamDataMessage(log log.T, payloadType mgs
 		return nil
 	}
 
+	// data sent to the client keeps the session active, like the input of the client
+	dataChannel.sessionTimer.recordActivity(time.Now())
+	return dataChannel.sendStreamDataMessage(log, payloadType, inputData, false)
+}
+
+// sendStreamDataMessage sends the stream data message, the acknowledge of an expiry warning isn't session activity.
+func (dataChannel *DataChannel) sendStreamDataMessage(log log.T, payloadType mgsContracts.PayloadType, inputData []byte, expiryWarning bool) (err error) {
+	dataChannel.streamDataMutex.Lock()
+	defer dataChannel.streamDataMutex.Unlock()
+
+	if expiryWarning {
+		dataChannel.sessionTimer.ignoreAcknowledge(dataChannel.StreamDataSequenceNumber)
+	}
+
 	var flag uint64 = 0
 	if dataChannel.StreamDataSequenceNumber == 0 {
 		flag = 1
 	}
 
 	// If encryption has been enabled, encrypt the payload
-	if dataChannel.encryptionEnabled && payloadType == mgsContracts.Output {
+	if dataChannel.encryptionEnabled && (payloadType == mgsContracts.Output || payloadType == mgsContracts.StdErr) {
 		if inputData, err = dataChannel.blockCipher.EncryptWithAESGCM(inputData); err != nil {
 			return fmt.Errorf("error encrypting stream data message sequence %d, err: %v", dataChannel.StreamDataSequenceNumber, err)
 		}
@@ -439,6 +470,7 @@ func (dataChannel *DataChannel) ResendStreamDataMessageScheduler(log log.T) erro
 			streamMessage := streamMessageElement.Value.(StreamingMessage)
 			if time.Since(streamMessage.LastSentTime) > dataChannel.RetransmissionTimeout {
 				log.Tracef("Resend stream data message: %d", streamMessage.SequenceNumber)
+				retransmissions.Inc()
 				if err := dataChannel.SendMessage(log, streamMessage.Content, websocket.BinaryMessage); err != nil {
 					log.Errorf("Unable to send stream data message: %s", err)
 				}
@@ -611,7 +643,9 @@ func (dataChannel *DataChannel) dataChannelIncomingMessageHandler(log log.T, raw
 
 // calculateRetransmissionTimeout calculates message retransmission timeout value based on round trip time on given message.
 func (dataChannel *DataChannel) calculateRetransmissionTimeout(log log.T, streamingMessage StreamingMessage) {
-	newRoundTripTime := float64(time.Since(streamingMessage.LastSentTime))
+	elapsed := time.Since(streamingMessage.LastSentTime)
+	roundTripTimes.Observe(elapsed.Seconds())
+	newRoundTripTime := float64(elapsed)
 
 	dataChannel.RoundTripTimeVariation = ((1 - mgsConfig.RTTVConstant) * dataChannel.RoundTripTimeVariation) +
 		(mgsConfig.RTTVConstant * math.Abs(dataChannel.RoundTripTime-newRoundTripTime))
@@ -696,6 +730,7 @@ func (dataChannel *DataChannel) handleAcknowledgeMessage(log log.T, streamDataMe
 		return err
 	}
 
+	dataChannel.sessionTimer.recordAcknowledge(acknowledgeMessage.SequenceNumber, time.Now())
 	dataChannel.ProcessAcknowledgedMessage(log, *acknowledgeMessage)
 	return nil
 }
@@ -726,6 +761,11 @@ func (dataChannel *DataChannel) handleStartPublicationMessage(log log.T, streamD
 	log.Debugf("Processed %s message. Datachannel pause status set to %s", streamDataMessage.MessageType, dataChannel.Pause)
 }
 
+// IsPaused returns true if the service has requested the agent to stop publishing stream data messages.
+func (dataChannel *DataChannel) IsPaused() bool {
+	return dataChannel.Pause
+}
+
 // processIncomingMessageBufferItems checks if new expected sequence stream data is present in IncomingMessageBuffer.
 // If so process it and increment expected sequence number.
 // Repeat until expected sequence stream data is not found in IncomingMessageBuffer.
@@ -790,6 +830,7 @@ func (dataChannel *DataChannel) processStreamDataMessage(log log.T, streamDataMe
 			return nil
 		}
 
+		dataChannel.sessionTimer.recordActivity(time.Now())
 		if err = dataChannel.inputStreamMessageHandler(log, streamDataMessage); err != nil {
 			return err
 		}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -27,6 +28,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/crypto"
 	cryptoMocks "github.com/aws/amazon-ssm-agent/agent/crypto/mocks"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	communicatorMocks "github.com/aws/amazon-ssm-agent/agent/session/communicator/mocks"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
@@ -203,10 +205,12 @@ func TestResendStreamDataMessageScheduler(t *testing.T) {
 		wg.Done()
 	}()
 
+	retransmissionCount := getMetricSample(t, "ssm_agent_session_retransmissions_total")
 	dataChannel.ResendStreamDataMessageScheduler(mockLog)
 
 	wg.Wait()
 	mockWsChannel.AssertExpectations(t)
+	assert.True(t, getMetricSample(t, "ssm_agent_session_retransmissions_total") > retransmissionCount)
 }
 
 func TestProcessAcknowledgedMessage(t *testing.T) {
@@ -219,9 +223,25 @@ func TestProcessAcknowledgedMessage(t *testing.T) {
 		IsSequentialMessage: true,
 	}
 
+	roundTripTimeCount := getMetricSample(t, "ssm_agent_session_round_trip_time_seconds_count")
 	dataChannel.ProcessAcknowledgedMessage(mockLog, dataStreamAcknowledgeContent)
 
 	assert.Equal(t, 0, dataChannel.OutgoingMessageBuffer.Messages.Len())
+	assert.Equal(t, roundTripTimeCount+1, getMetricSample(t, "ssm_agent_session_round_trip_time_seconds_count"))
+}
+
+// getMetricSample returns the value of the given sample without labels in the metrics of the agent
+func getMetricSample(t *testing.T, sampleName string) float64 {
+	var buffer bytes.Buffer
+	assert.NoError(t, metrics.DefaultRegistry.WriteText(&buffer))
+	for _, line := range strings.Split(buffer.String(), "\n") {
+		if strings.HasPrefix(line, sampleName+" ") {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(line, sampleName+" "), 64)
+			assert.NoError(t, err)
+			return value
+		}
+	}
+	return 0
 }
 
 func TestSendAcknowledgeMessage(t *testing.T) {
@@ -442,6 +462,7 @@ func TestDataChannelIncomingMessageHandlerForPausePublicationMessage(t *testing.
 
 	assert.Nil(t, err)
 	assert.Equal(t, true, dataChannel.Pause)
+	assert.True(t, dataChannel.IsPaused())
 }
 
 func TestDataChannelIncomingMessageHandlerForStartPublicationMessage(t *testing.T) {
@@ -22,6 +22,7 @@ import log "github.com/aws/amazon-ssm-agent/agent/log"
 import mock "github.com/stretchr/testify/mock"
 import service "github.com/aws/amazon-ssm-agent/agent/session/service"
 import task "github.com/aws/amazon-ssm-agent/agent/task"
+import time "time"
 
 // IDataChannel is an autogenerated mock type for the IDataChannel type
 type IDataChannel struct {
@@ -71,6 +72,20 @@ func (_m *IDataChannel) PerformHandshake(_a0 log.T, kmsKeyId string) error {
 	return r0
 }
 
+// IsPaused provides a mock function with given fields:
+func (_m *IDataChannel) IsPaused() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Open provides a mock function with given fields: _a0
 func (_m *IDataChannel) Open(_a0 log.T) error {
 	ret := _m.Called(_a0)
@@ -202,3 +217,8 @@ func (_m *IDataChannel) SetWebSocket(_a0 context.T, mgsService service.Service,
 func (_m *IDataChannel) SkipHandshake(_a0 log.T) {
 	_m.Called(_a0)
 }
+
+// StartSessionTimer provides a mock function with given fields: _a0, idleTimeout, maxDuration, warnClient
+func (_m *IDataChannel) StartSessionTimer(_a0 log.T, idleTimeout time.Duration, maxDuration time.Duration, warnClient bool) {
+	_m.Called(_a0, idleTimeout, maxDuration, warnClient)
+}
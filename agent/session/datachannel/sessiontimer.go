@@ -0,0 +1,186 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datachannel implements data channel which is used to interactively run commands.
+package datachannel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+var (
+	// sessionTimerCheckInterval is the interval at which the session timer checks for expiry
+	sessionTimerCheckInterval = time.Second
+	// sessionExpiryWarningPeriod is how long before expiry the client is warned
+	sessionExpiryWarningPeriod = time.Minute
+)
+
+const (
+	idleExpiryReason        = "due to inactivity"
+	maxDurationExpiryReason = "as it reached its maximum duration"
+)
+
+// sessionTimer tracks the activity of the session to end sessions that are idle or that exceed their maximum duration.
+// The input of the client, the data sent to the client and its acknowledges are activity.
+type sessionTimer struct {
+	mu           sync.Mutex
+	idleTimeout  time.Duration
+	maxDuration  time.Duration
+	startTime    time.Time
+	lastActivity time.Time
+	started      bool
+	stopChan     chan bool
+	// warnings holds the sequence numbers of the expiry warnings which aren't acknowledged yet
+	warnings map[int64]bool
+}
+
+// newSessionTimer creates a session timer which is not started yet
+func newSessionTimer() *sessionTimer {
+	return &sessionTimer{stopChan: make(chan bool), warnings: make(map[int64]bool)}
+}
+
+// recordActivity resets the idle timeout of the session
+func (timer *sessionTimer) recordActivity(now time.Time) {
+	if timer == nil {
+		return
+	}
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	timer.lastActivity = now
+}
+
+// ignoreAcknowledge keeps the acknowledge of the message with the sequence number from resetting the idle timeout
+func (timer *sessionTimer) ignoreAcknowledge(sequenceNumber int64) {
+	if timer == nil {
+		return
+	}
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	timer.warnings[sequenceNumber] = true
+}
+
+// recordAcknowledge resets the idle timeout of the session unless the client acknowledged an expiry warning
+func (timer *sessionTimer) recordAcknowledge(sequenceNumber int64, now time.Time) {
+	if timer == nil {
+		return
+	}
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	if timer.warnings[sequenceNumber] {
+		delete(timer.warnings, sequenceNumber)
+		return
+	}
+	timer.lastActivity = now
+}
+
+// expiry returns when the session expires and why, the earliest of the idle and maximum duration deadlines applies
+func (timer *sessionTimer) expiry() (deadline time.Time, reason string) {
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+
+	if timer.idleTimeout > 0 {
+		deadline, reason = timer.lastActivity.Add(timer.idleTimeout), idleExpiryReason
+	}
+	if timer.maxDuration > 0 {
+		if maxDeadline := timer.startTime.Add(timer.maxDuration); deadline.IsZero() || maxDeadline.Before(deadline) {
+			deadline, reason = maxDeadline, maxDurationExpiryReason
+		}
+	}
+	return
+}
+
+// stop stops the session timer, it is safe to call stop more than once
+func (timer *sessionTimer) stop() {
+	if timer == nil {
+		return
+	}
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	select {
+	case <-timer.stopChan:
+	default:
+		close(timer.stopChan)
+	}
+}
+
+// StartSessionTimer ends the session when no data is exchanged with the client for the idle timeout or when the session
+// reaches its maximum duration. A timeout of 0 is not enforced. The client is warned through an output message shortly
+// before the session expires if warnClient is set, plugins that stream raw data to the client shouldn't set it.
+func (dataChannel *DataChannel) StartSessionTimer(log log.T, idleTimeout time.Duration, maxDuration time.Duration, warnClient bool) {
+	timer := dataChannel.sessionTimer
+	if timer == nil || (idleTimeout <= 0 && maxDuration <= 0) {
+		return
+	}
+
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	if timer.started {
+		return
+	}
+	now := time.Now()
+	timer.idleTimeout = idleTimeout
+	timer.maxDuration = maxDuration
+	timer.startTime = now
+	timer.lastActivity = now
+	timer.started = true
+
+	log.Infof("Session %s has an idle timeout of %v and a maximum duration of %v", dataChannel.ChannelId, idleTimeout, maxDuration)
+	go dataChannel.runSessionTimer(log, timer, warnClient)
+}
+
+// runSessionTimer checks the session timer until the session expires or the data channel is closed
+func (dataChannel *DataChannel) runSessionTimer(log log.T, timer *sessionTimer, warnClient bool) {
+	ticker := time.NewTicker(sessionTimerCheckInterval)
+	defer ticker.Stop()
+
+	var warnedDeadline time.Time
+	for {
+		select {
+		case <-timer.stopChan:
+			return
+		case now := <-ticker.C:
+			deadline, reason := timer.expiry()
+			remaining := deadline.Sub(now)
+			if remaining <= 0 {
+				dataChannel.expireSession(log, reason)
+				return
+			}
+
+			// the idle deadline moves with session activity, the client is warned again when it becomes idle again
+			if warnClient && remaining <= sessionExpiryWarningPeriod && !deadline.Equal(warnedDeadline) {
+				warnedDeadline = deadline
+				warning := fmt.Sprintf("\r\nThis session will be terminated in %v %s.\r\n", remaining.Round(time.Second), reason)
+				if err := dataChannel.sendStreamDataMessage(log, mgsContracts.Output, []byte(warning), true); err != nil {
+					log.Warnf("Unable to warn client about the expiry of session %s: %v", dataChannel.ChannelId, err)
+				}
+			}
+		}
+	}
+}
+
+// expireSession informs MGS that the session is terminating and cancels the session plugin
+func (dataChannel *DataChannel) expireSession(log log.T, reason string) {
+	log.Infof("Terminating session %s %s", dataChannel.ChannelId, reason)
+	if err := dataChannel.SendAgentSessionStateMessage(log, mgsContracts.Terminating); err != nil {
+		log.Errorf("Unable to send AgentSessionState message with session status %s. %v", mgsContracts.Terminating, err)
+	}
+	if dataChannel.cancelFlag != nil {
+		dataChannel.cancelFlag.Set(task.Canceled)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datachannel implements data channel which is used to interactively run commands.
+package datachannel
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	communicatorMocks "github.com/aws/amazon-ssm-agent/agent/session/communicator/mocks"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const canceledEvent = "canceled"
+
+// getTimedDataChannel returns a data channel whose sent messages and cancellation are reported as events
+func getTimedDataChannel(t *testing.T) (*DataChannel, chan string) {
+	events := make(chan string, 10)
+	dataChannel := getDataChannel()
+
+	wsChannel := &communicatorMocks.IWebSocketChannel{}
+	wsChannel.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		agentMessage := &mgsContracts.AgentMessage{}
+		assert.NoError(t, agentMessage.Deserialize(mockLog, args.Get(1).([]byte)))
+		if agentMessage.MessageType == mgsContracts.AgentSessionState {
+			var content mgsContracts.AgentSessionStateContent
+			json.Unmarshal(agentMessage.Payload, &content)
+			events <- content.SessionState
+		} else {
+			events <- string(agentMessage.Payload)
+		}
+	})
+	wsChannel.On("Close", mock.Anything).Return(nil)
+	dataChannel.wsChannel = wsChannel
+
+	cancelFlag := &task.MockCancelFlag{}
+	cancelFlag.On("Set", task.Canceled).Return().Run(func(args mock.Arguments) {
+		events <- canceledEvent
+	})
+	dataChannel.cancelFlag = cancelFlag
+	return dataChannel, events
+}
+
+func setSessionTimerIntervals(checkInterval time.Duration, warningPeriod time.Duration) func() {
+	origCheckInterval, origWarningPeriod := sessionTimerCheckInterval, sessionExpiryWarningPeriod
+	sessionTimerCheckInterval, sessionExpiryWarningPeriod = checkInterval, warningPeriod
+	return func() {
+		sessionTimerCheckInterval, sessionExpiryWarningPeriod = origCheckInterval, origWarningPeriod
+	}
+}
+
+func nextEvent(t *testing.T, events chan string) string {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "timed out waiting for session timer")
+		return ""
+	}
+}
+
+func TestSessionTimerIdleExpiry(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+
+	dataChannel.StartSessionTimer(mockLog, 300*time.Millisecond, 0, true)
+
+	warning := nextEvent(t, events)
+	assert.Contains(t, warning, "This session will be terminated in")
+	assert.Contains(t, warning, idleExpiryReason)
+	assert.Equal(t, string(mgsContracts.Terminating), nextEvent(t, events))
+	assert.Equal(t, canceledEvent, nextEvent(t, events))
+}
+
+func TestSessionTimerActivityPostponesExpiry(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+
+	startTime := time.Now()
+	dataChannel.StartSessionTimer(mockLog, 200*time.Millisecond, 0, false)
+	for i := 0; i < 8; i++ {
+		time.Sleep(50 * time.Millisecond)
+		dataChannel.sessionTimer.recordActivity(time.Now())
+	}
+	assert.Empty(t, events)
+
+	// no warning is sent when warnClient is not set
+	assert.Equal(t, string(mgsContracts.Terminating), nextEvent(t, events))
+	assert.Equal(t, canceledEvent, nextEvent(t, events))
+	assert.True(t, time.Since(startTime) >= 600*time.Millisecond)
+}
+
+func TestSessionTimerOutgoingDataPostponesExpiry(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+	dataChannel.SkipHandshake(mockLog)
+
+	startTime := time.Now()
+	dataChannel.StartSessionTimer(mockLog, 200*time.Millisecond, 0, false)
+	for i := 0; i < 8; i++ {
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, dataChannel.SendStreamDataMessage(mockLog, mgsContracts.Output, []byte("data")))
+		assert.Equal(t, "data", nextEvent(t, events))
+	}
+
+	assert.Equal(t, string(mgsContracts.Terminating), nextEvent(t, events))
+	assert.Equal(t, canceledEvent, nextEvent(t, events))
+	assert.True(t, time.Since(startTime) >= 600*time.Millisecond)
+}
+
+func TestSessionTimerAcknowledgePostponesExpiry(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+
+	startTime := time.Now()
+	dataChannel.StartSessionTimer(mockLog, 200*time.Millisecond, 0, false)
+	for i := 0; i < 8; i++ {
+		time.Sleep(50 * time.Millisecond)
+		acknowledgeContent := &mgsContracts.AcknowledgeContent{
+			MessageType:         mgsContracts.AcknowledgeMessage,
+			MessageId:           messageId,
+			SequenceNumber:      int64(i),
+			IsSequentialMessage: true,
+		}
+		payload, _ := acknowledgeContent.Serialize(mockLog)
+		assert.NoError(t, dataChannel.handleAcknowledgeMessage(mockLog, *getAgentMessage(0, mgsContracts.AcknowledgeMessage, uint32(0), payload)))
+	}
+	assert.Empty(t, events)
+
+	assert.Equal(t, string(mgsContracts.Terminating), nextEvent(t, events))
+	assert.Equal(t, canceledEvent, nextEvent(t, events))
+	assert.True(t, time.Since(startTime) >= 600*time.Millisecond)
+}
+
+func TestSessionTimerIgnoresAcknowledgeOfWarning(t *testing.T) {
+	now := time.Now()
+	timer := newSessionTimer()
+	timer.lastActivity = now
+	timer.ignoreAcknowledge(5)
+
+	timer.recordAcknowledge(5, now.Add(time.Minute))
+	assert.Equal(t, now, timer.lastActivity)
+	assert.Empty(t, timer.warnings)
+
+	timer.recordAcknowledge(6, now.Add(time.Minute))
+	assert.Equal(t, now.Add(time.Minute), timer.lastActivity)
+}
+
+func TestSessionTimerMaxDuration(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+
+	dataChannel.StartSessionTimer(mockLog, time.Hour, 300*time.Millisecond, true)
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				dataChannel.sessionTimer.recordActivity(time.Now())
+			}
+		}
+	}()
+
+	assert.Contains(t, nextEvent(t, events), maxDurationExpiryReason)
+	assert.Equal(t, string(mgsContracts.Terminating), nextEvent(t, events))
+	assert.Equal(t, canceledEvent, nextEvent(t, events))
+}
+
+func TestSessionTimerStoppedOnClose(t *testing.T) {
+	defer setSessionTimerIntervals(10*time.Millisecond, 100*time.Millisecond)()
+	dataChannel, events := getTimedDataChannel(t)
+
+	dataChannel.StartSessionTimer(mockLog, 200*time.Millisecond, 0, true)
+	dataChannel.Close(mockLog)
+	dataChannel.Close(mockLog)
+
+	time.Sleep(400 * time.Millisecond)
+	assert.Empty(t, events)
+}
+
+func TestSessionTimerExpiry(t *testing.T) {
+	now := time.Now()
+	timer := &sessionTimer{idleTimeout: 20 * time.Minute, maxDuration: time.Hour, startTime: now.Add(-50 * time.Minute), lastActivity: now}
+
+	deadline, reason := timer.expiry()
+	assert.Equal(t, now.Add(10*time.Minute), deadline)
+	assert.Equal(t, maxDurationExpiryReason, reason)
+
+	timer.maxDuration = 0
+	deadline, reason = timer.expiry()
+	assert.Equal(t, now.Add(20*time.Minute), deadline)
+	assert.Equal(t, idleExpiryReason, reason)
+}
@@ -0,0 +1,344 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package noninteractivecommands implements session manager plugin that runs a single command without a pty.
+package noninteractivecommands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	agentContracts "github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
+	"github.com/aws/amazon-ssm-agent/agent/session/plugins/sessionplugin"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// NonInteractiveCommandsPlugin is the type for the plugin.
+type NonInteractiveCommandsPlugin struct {
+	stdin       io.WriteCloser
+	logFilePath string
+	logFileLock sync.Mutex
+	dataChannel datachannel.IDataChannel
+}
+
+// NewPlugin returns a new instance of the NonInteractiveCommands Plugin
+func NewPlugin() (sessionplugin.ISessionPlugin, error) {
+	var plugin = NonInteractiveCommandsPlugin{}
+	return &plugin, nil
+}
+
+// name returns the name of NonInteractiveCommands Plugin
+func (p *NonInteractiveCommandsPlugin) name() string {
+	return appconfig.PluginNameNonInteractiveCommands
+}
+
+// validate validates the cloudwatch and s3 encryption configuration.
+func (p *NonInteractiveCommandsPlugin) validate(context context.T,
+	config agentContracts.Configuration,
+	cwl cloudwatchlogsinterface.ICloudWatchLogsService,
+	s3Util s3util.IAmazonS3Util) error {
+
+	if config.CloudWatchLogGroup != "" && config.CloudWatchEncryptionEnabled {
+		if encrypted := cwl.IsLogGroupEncryptedWithKMS(context.Log(), config.CloudWatchLogGroup); !encrypted {
+			return errors.New(mgsConfig.CloudWatchEncryptionErrorMsg)
+		}
+	}
+
+	if config.OutputS3BucketName != "" && config.S3EncryptionEnabled {
+		if encrypted := s3Util.IsBucketEncrypted(context.Log(), config.OutputS3BucketName); !encrypted {
+			return errors.New(mgsConfig.S3EncryptionErrorMsg)
+		}
+	}
+	return nil
+}
+
+// Execute runs the command from the session document without allocating a pty.
+// It streams the stdout and stderr of the process to the data channel as separate payload types
+// and terminates the session once the process exits.
+func (p *NonInteractiveCommandsPlugin) Execute(context context.T,
+	config agentContracts.Configuration,
+	cancelFlag task.CancelFlag,
+	output iohandler.IOHandler,
+	dataChannel datachannel.IDataChannel) {
+
+	log := context.Log()
+	p.dataChannel = dataChannel
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("Error occurred while executing plugin %s: \n%v", p.name(), err)
+			log.Flush()
+			os.Exit(1)
+		}
+	}()
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.execute(context, config, cancelFlag, output)
+	}
+}
+
+var resolveRunAsUser = runas.ResolveUser
+
+// execute starts the command and pumps its output streams to the data channel.
+func (p *NonInteractiveCommandsPlugin) execute(context context.T,
+	config agentContracts.Configuration,
+	cancelFlag task.CancelFlag,
+	output iohandler.IOHandler) {
+
+	log := context.Log()
+	var err error
+	sessionPluginResultOutput := mgsContracts.SessionPluginResultOutput{}
+
+	if strings.TrimSpace(config.Commands) == "" {
+		errorString := errors.New("No command was specified for the session")
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+
+	var cwl cloudwatchlogsinterface.ICloudWatchLogsService
+	var s3Util s3util.IAmazonS3Util
+	if config.OutputS3BucketName != "" {
+		s3Util = s3util.NewAmazonS3Util(log, config.OutputS3BucketName)
+	}
+	if config.CloudWatchLogGroup != "" {
+		cwl = cloudwatchlogspublisher.NewCloudWatchLogsService()
+	}
+	if err = p.validate(context, config, cwl, s3Util); err != nil {
+		output.SetExitCode(appconfig.ErrorExitCode)
+		output.SetStatus(agentContracts.ResultStatusFailed)
+		sessionPluginResultOutput.Output = err.Error()
+		output.SetOutput(sessionPluginResultOutput)
+		log.Errorf("Encryption validation failed, err: %s", err)
+		return
+	}
+
+	// Generate final log file path
+	logFileName := config.SessionId + mgsConfig.LogFileExtension
+	p.logFilePath = filepath.Join(config.OrchestrationDirectory, logFileName)
+	if err = fileutil.MakeDirs(config.OrchestrationDirectory); err != nil {
+		errorString := fmt.Errorf("Unable to create orchestration directory: %s", err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+	logFile, err := os.Create(p.logFilePath)
+	if err != nil {
+		errorString := fmt.Errorf("Unable to create log file: %s", err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+	defer logFile.Close()
+
+	// A session with run as enabled runs as the user allowed by the run as policy of the agent,
+	// other sessions run as ssm-user unless they are elevated.
+	var runAsUser string
+	if config.RunAsEnabled {
+		if runAsUser, err = resolveRunAsUser(log, config.SessionOwner, config.RunAsUser); err != nil {
+			errorString := fmt.Errorf("Unable to prepare command: %s", err)
+			log.Error(errorString)
+			output.MarkAsFailed(errorString)
+			return
+		}
+		log.Infof("Session runs as user %s", runAsUser)
+	}
+
+	cmd, err := newCommand(log, !config.RunAsElevated, runAsUser, config.Commands)
+	if err != nil {
+		errorString := fmt.Errorf("Unable to prepare command: %s", err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+	cmd.Dir = config.DefaultWorkingDirectory
+
+	stdout, stderr, err := p.startCommand(cmd)
+	if err != nil {
+		errorString := fmt.Errorf("Unable to start command: %s", err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+	log.Infof("Plugin %s started", p.name())
+
+	processExited := make(chan bool)
+	cancelled := make(chan bool, 1)
+	go func() {
+		cancelState := cancelFlag.Wait()
+		if cancelFlag.Canceled() {
+			select {
+			case <-processExited:
+				return
+			default:
+			}
+			cancelled <- true
+			log.Debug("Session cancelled. Attempting to stop process.")
+			if err := killProcess(cmd.Process); err != nil {
+				log.Errorf("Unable to stop process: %s", err)
+			}
+		}
+		log.Debugf("Cancel flag set to %v in session", cancelState)
+	}()
+
+	var pumps sync.WaitGroup
+	pumps.Add(2)
+	go func() {
+		defer pumps.Done()
+		p.writePump(log, stdout, mgsContracts.Output, logFile)
+	}()
+	go func() {
+		defer pumps.Done()
+		p.writePump(log, stderr, mgsContracts.StdErr, logFile)
+	}()
+	pumps.Wait()
+
+	exitCode := getExitCode(log, cmd.Wait())
+	close(processExited)
+
+	// Terminating session
+	if err = p.dataChannel.SendAgentSessionStateMessage(log, mgsContracts.Terminating); err != nil {
+		log.Errorf("Unable to send AgentSessionState message with session status %s. %v", mgsContracts.Terminating, err)
+	}
+
+	select {
+	case <-cancelled:
+		log.Info("The session was cancelled")
+		output.SetExitCode(exitCode)
+		output.SetStatus(agentContracts.ResultStatusCancelled)
+	default:
+		log.Infof("The command exited with exit code %d", exitCode)
+		output.SetExitCode(exitCode)
+		if exitCode == appconfig.SuccessExitCode {
+			output.SetStatus(agentContracts.ResultStatusSuccess)
+		} else {
+			output.SetStatus(agentContracts.ResultStatusFailed)
+		}
+	}
+
+	if config.OutputS3BucketName != "" {
+		s3KeyPrefix := fileutil.BuildS3Path(config.OutputS3KeyPrefix, logFileName)
+		log.Debugf("Preparing to upload session logs to S3 bucket %s and prefix %s", config.OutputS3BucketName, s3KeyPrefix)
+		if err = s3Util.S3Upload(log, config.OutputS3BucketName, s3KeyPrefix, p.logFilePath); err != nil {
+			log.Errorf("Failed to upload session logs to S3: %s", err)
+		}
+		sessionPluginResultOutput.S3Bucket = config.OutputS3BucketName
+		sessionPluginResultOutput.S3UrlSuffix = s3KeyPrefix
+	}
+
+	if config.CloudWatchLogGroup != "" {
+		cwl.StreamData(log, config.CloudWatchLogGroup, config.SessionId, p.logFilePath, true, false)
+		sessionPluginResultOutput.CwlGroup = config.CloudWatchLogGroup
+		sessionPluginResultOutput.CwlStream = config.SessionId
+	}
+	output.SetOutput(sessionPluginResultOutput)
+
+	log.Debug("NonInteractiveCommands session execution complete")
+}
+
+// startCommand wires up the standard streams of the command and starts it.
+func (p *NonInteractiveCommandsPlugin) startCommand(cmd *exec.Cmd) (stdout io.ReadCloser, stderr io.ReadCloser, err error) {
+	if p.stdin, err = cmd.StdinPipe(); err != nil {
+		return
+	}
+	if stdout, err = cmd.StdoutPipe(); err != nil {
+		return
+	}
+	if stderr, err = cmd.StderrPipe(); err != nil {
+		return
+	}
+	err = cmd.Start()
+	return
+}
+
+// writePump reads from the given process stream and writes to data channel with the given payload type.
+func (p *NonInteractiveCommandsPlugin) writePump(log log.T, reader io.Reader, payloadType mgsContracts.PayloadType, logFile io.Writer) {
+	buf := make([]byte, mgsConfig.StreamDataPayloadSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if err := p.dataChannel.SendStreamDataMessage(log, payloadType, buf[:n]); err != nil {
+				log.Errorf("Unable to send stream data message: %s", err)
+			}
+
+			p.logFileLock.Lock()
+			if _, err := logFile.Write(buf[:n]); err != nil {
+				log.Errorf("Encountered an error while writing to file: %s", err)
+			}
+			p.logFileLock.Unlock()
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Debugf("Failed to read from process stream %d: %s", payloadType, err)
+			}
+			return
+		}
+	}
+}
+
+// getExitCode returns the exit code of the process from the error returned by cmd.Wait.
+func getExitCode(log log.T, err error) int {
+	if err == nil {
+		return appconfig.SuccessExitCode
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	log.Debugf("Command returned error %v", err)
+	return appconfig.ErrorExitCode
+}
+
+// InputStreamMessageHandler passes payload byte stream to the stdin of the process
+func (p *NonInteractiveCommandsPlugin) InputStreamMessageHandler(log log.T, streamDataMessage mgsContracts.AgentMessage) error {
+	if p.stdin == nil {
+		// This is to handle scenario when cli/console starts sending data but the process has not been started yet
+		log.Tracef("Process unavailable. Reject incoming message packet")
+		return nil
+	}
+
+	switch mgsContracts.PayloadType(streamDataMessage.PayloadType) {
+	case mgsContracts.Output:
+		log.Tracef("Output message received: %d", streamDataMessage.SequenceNumber)
+		if _, err := p.stdin.Write(streamDataMessage.Payload); err != nil {
+			log.Errorf("Unable to write to stdin, err: %v.", err)
+			return err
+		}
+	default:
+		log.Tracef("Ignoring payload type %d for non-interactive session", streamDataMessage.PayloadType)
+	}
+	return nil
+}
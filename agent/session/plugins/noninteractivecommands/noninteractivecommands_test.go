@@ -0,0 +1,308 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package noninteractivecommands implements session manager plugin that runs a single command without a pty.
+package noninteractivecommands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	dataChannelMock "github.com/aws/amazon-ssm-agent/agent/session/datachannel/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var mockLog = log.NewMockLog()
+
+type NonInteractiveCommandsTestSuite struct {
+	suite.Suite
+	mockContext      *context.Mock
+	mockCancelFlag   *task.MockCancelFlag
+	mockDataChannel  *dataChannelMock.IDataChannel
+	mockIohandler    *iohandlermocks.MockIOHandler
+	orchestrationDir string
+	plugin           *NonInteractiveCommandsPlugin
+}
+
+func (suite *NonInteractiveCommandsTestSuite) SetupTest() {
+	suite.mockContext = context.NewMockDefault()
+	suite.mockCancelFlag = &task.MockCancelFlag{}
+	suite.mockDataChannel = &dataChannelMock.IDataChannel{}
+	suite.mockIohandler = new(iohandlermocks.MockIOHandler)
+	suite.orchestrationDir, _ = ioutil.TempDir("", "noninteractivecommands")
+	suite.plugin = &NonInteractiveCommandsPlugin{}
+}
+
+func (suite *NonInteractiveCommandsTestSuite) TearDownTest() {
+	os.RemoveAll(suite.orchestrationDir)
+}
+
+//Execute the test suite
+func TestNonInteractiveCommandsTestSuite(t *testing.T) {
+	suite.Run(t, new(NonInteractiveCommandsTestSuite))
+}
+
+// Testing Name
+func (suite *NonInteractiveCommandsTestSuite) TestName() {
+	assert.Equal(suite.T(), appconfig.PluginNameNonInteractiveCommands, suite.plugin.name())
+}
+
+// Testing Execute
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteWhenCancelFlagIsShutDown() {
+	suite.mockCancelFlag.On("ShutDown").Return(true)
+	suite.mockIohandler.On("MarkAsShutdown").Return(nil)
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockCancelFlag.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteWhenCancelFlagIsCancelled() {
+	suite.mockCancelFlag.On("Canceled").Return(true)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockIohandler.On("MarkAsCancelled").Return(nil)
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockCancelFlag.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute fails when no command is given
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteWithoutCommand() {
+	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{},
+		task.NewChanneledCancelFlag(),
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing stdout and stderr are sent as separate payload types and the exit code is reported
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteStreamsOutputAndReportsExitCode() {
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.Output, []byte("out\n")).Return(nil)
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.StdErr, []byte("err\n")).Return(nil)
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+	suite.mockIohandler.On("SetExitCode", 3).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusFailed).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			Commands:               "echo out; echo err 1>&2; exit 3",
+			RunAsElevated:          true,
+			SessionId:              "sessionId",
+			OrchestrationDirectory: suite.orchestrationDir,
+		},
+		task.NewChanneledCancelFlag(),
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+
+	logContent, err := ioutil.ReadFile(suite.plugin.logFilePath)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(logContent), "out\n")
+	assert.Contains(suite.T(), string(logContent), "err\n")
+}
+
+// Testing binary output is passed through unchanged
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteWithBinaryOutput() {
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.Output, []byte{0xff, 0xfe, 0x00, 0x01}).Return(nil)
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+	suite.mockIohandler.On("SetExitCode", appconfig.SuccessExitCode).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			Commands:               `printf '\377\376\000\001'`,
+			RunAsElevated:          true,
+			SessionId:              "sessionId",
+			OrchestrationDirectory: suite.orchestrationDir,
+		},
+		task.NewChanneledCancelFlag(),
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing the process is stopped when the session is cancelled
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteWhenSessionIsCancelled() {
+	cancelFlag := task.NewChanneledCancelFlag()
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+	suite.mockIohandler.On("SetExitCode", mock.Anything).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusCancelled).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancelFlag.Set(task.Canceled)
+	}()
+
+	start := time.Now()
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			Commands:               "sleep 30",
+			RunAsElevated:          true,
+			SessionId:              "sessionId",
+			OrchestrationDirectory: suite.orchestrationDir,
+		},
+		cancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	assert.True(suite.T(), time.Since(start) < 10*time.Second)
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute with run as enabled runs the command as the user resolved from the run as policy
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteRunAsUser() {
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+	suite.mockIohandler.On("SetExitCode", appconfig.SuccessExitCode).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	origNewCommand, origResolveRunAsUser := newCommand, resolveRunAsUser
+	defer func() { newCommand, resolveRunAsUser = origNewCommand, origResolveRunAsUser }()
+	resolveRunAsUser = func(log log.T, sessionOwner string, requestedUser string) (string, error) {
+		assert.Equal(suite.T(), "arn:aws:iam::123456789012:role/Deployer", sessionOwner)
+		assert.Equal(suite.T(), "ec2-user", requestedUser)
+		return "deploy", nil
+	}
+	var startedAs string
+	newCommand = func(log log.T, runAsSsmUser bool, runAsUser string, commands string) (*exec.Cmd, error) {
+		startedAs = runAsUser
+		return exec.Command("sh", "-c", commands), nil
+	}
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			Commands:               "exit 0",
+			RunAsEnabled:           true,
+			RunAsUser:              "ec2-user",
+			SessionOwner:           "arn:aws:iam::123456789012:role/Deployer",
+			SessionId:              "sessionId",
+			OrchestrationDirectory: suite.orchestrationDir,
+		},
+		task.NewChanneledCancelFlag(),
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	assert.Equal(suite.T(), "deploy", startedAs)
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute fails the session when the run as user is not allowed
+func (suite *NonInteractiveCommandsTestSuite) TestExecuteRunAsUserNotAllowed() {
+	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
+
+	origNewCommand, origResolveRunAsUser := newCommand, resolveRunAsUser
+	defer func() { newCommand, resolveRunAsUser = origNewCommand, origResolveRunAsUser }()
+	resolveRunAsUser = func(log log.T, sessionOwner string, requestedUser string) (string, error) {
+		return "", fmt.Errorf("run as user %v is not allowed by the run as policy", requestedUser)
+	}
+	newCommand = func(log log.T, runAsSsmUser bool, runAsUser string, commands string) (*exec.Cmd, error) {
+		assert.Fail(suite.T(), "command must not be started")
+		return nil, nil
+	}
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			Commands:               "exit 0",
+			RunAsEnabled:           true,
+			RunAsUser:              "root",
+			SessionId:              "sessionId",
+			OrchestrationDirectory: suite.orchestrationDir,
+		},
+		task.NewChanneledCancelFlag(),
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockIohandler.AssertCalled(suite.T(), "MarkAsFailed", fmt.Errorf("Unable to prepare command: run as user root is not allowed by the run as policy"))
+}
+
+// Testing the command of a run as user gets the credentials and the home directory of the user
+func (suite *NonInteractiveCommandsTestSuite) TestNewCommandRunAsUser() {
+	origLookupUser := lookupUser
+	defer func() { lookupUser = origLookupUser }()
+	lookupUser = func(userName string) (*runas.User, error) {
+		return &runas.User{Name: userName, Uid: 1001, Gid: 1001, Groups: []uint32{1001, 10}, HomeDir: "/home/deploy", Shell: "/bin/bash"}, nil
+	}
+
+	cmd, err := newCommand(mockLog, true, "deploy", "whoami")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint32(1001), cmd.SysProcAttr.Credential.Uid)
+	assert.Equal(suite.T(), []uint32{1001, 10}, cmd.SysProcAttr.Credential.Groups)
+	assert.Contains(suite.T(), cmd.Env, "HOME=/home/deploy")
+	assert.Contains(suite.T(), cmd.Env, "USER=deploy")
+}
+
+// Testing input stream data is written to stdin of the process
+func (suite *NonInteractiveCommandsTestSuite) TestInputStreamMessageHandler() {
+	stdinFile, _ := ioutil.TempFile("", "stdin")
+	defer os.Remove(stdinFile.Name())
+	plugin := &NonInteractiveCommandsPlugin{
+		stdin: stdinFile,
+	}
+	agentMessage := mgsContracts.AgentMessage{
+		MessageType: mgsContracts.InputStreamDataMessage,
+		PayloadType: uint32(mgsContracts.Output),
+		Payload:     []byte("testPayload"),
+	}
+	err := plugin.InputStreamMessageHandler(mockLog, agentMessage)
+	assert.Nil(suite.T(), err)
+
+	stdinFileContent, _ := ioutil.ReadFile(stdinFile.Name())
+	assert.Equal(suite.T(), "testPayload", string(stdinFileContent))
+}
+
+func (suite *NonInteractiveCommandsTestSuite) TestGetExitCode() {
+	assert.Equal(suite.T(), appconfig.SuccessExitCode, getExitCode(mockLog, nil))
+	assert.Equal(suite.T(), 2, getExitCode(mockLog, exec.Command("sh", "-c", "exit 2").Run()))
+	assert.Equal(suite.T(), appconfig.ErrorExitCode, getExitCode(mockLog, errors.New("error")))
+}
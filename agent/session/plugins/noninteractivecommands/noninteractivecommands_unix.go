@@ -0,0 +1,72 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package noninteractivecommands implements session manager plugin that runs a single command without a pty.
+package noninteractivecommands
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
+	"github.com/aws/amazon-ssm-agent/agent/session/utility"
+)
+
+const (
+	homeEnvVariable = "HOME=/home/" + appconfig.DefaultRunAsUserName
+)
+
+var lookupUser = runas.LookupUser
+
+// newCommand builds the command to run, switching to runAsUser when it is set or to the default run as user
+// when runAsSsmUser is set.
+var newCommand = func(log log.T, runAsSsmUser bool, runAsUser string, commands string) (*exec.Cmd, error) {
+	commandArgs := append(utility.ShellPluginCommandArgs, commands)
+	cmd := exec.Command(utility.ShellPluginCommandName, commandArgs...)
+	cmd.Env = os.Environ()
+
+	// make the process the leader of its process group so that the whole group can be stopped on cancel
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if runAsUser != "" {
+		// the user is neither created nor granted any additional permission
+		user, err := lookupUser(runAsUser)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr.Credential = user.Credential()
+		cmd.Env = append(cmd.Env, "HOME="+user.HomeDir, "USER="+user.Name, "LOGNAME="+user.Name)
+	} else if runAsSsmUser {
+		// Create ssm-user before starting a session.
+		u := &utility.SessionUtil{}
+		u.CreateLocalAdminUser(log)
+
+		uid, gid, groups, err := utility.GetUserCredentials(log, appconfig.DefaultRunAsUserName)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: groups, NoSetGroups: false}
+		cmd.Env = append(cmd.Env, homeEnvVariable)
+	}
+	return cmd, nil
+}
+
+// killProcess kills the process and all of its descendants.
+func killProcess(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL) // note the minus sign
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build windows
+
+// Package noninteractivecommands implements session manager plugin that runs a single command without a pty.
+package noninteractivecommands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// newCommand builds the powershell command to run.
+// Switching to another user is not supported for non-interactive sessions on Windows.
+var newCommand = func(log log.T, runAsSsmUser bool, runAsUser string, commands string) (*exec.Cmd, error) {
+	if runAsUser != "" {
+		return nil, fmt.Errorf("running sessions as user %s is not supported on Windows", runAsUser)
+	}
+	if runAsSsmUser {
+		return nil, errors.New("running non-interactive commands as " + appconfig.DefaultRunAsUserName + " is not supported on Windows")
+	}
+	cmd := exec.Command(appconfig.PowerShellPluginCommandName, "-NonInteractive", "-NoProfile", "-Command", commands)
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
+
+// killProcess kills the process.
+func killProcess(process *os.Process) error {
+	return process.Kill()
+}
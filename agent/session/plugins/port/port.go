@@ -0,0 +1,262 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package port implements session manager's port plugin.
+package port
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	agentContracts "github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
+	"github.com/aws/amazon-ssm-agent/agent/session/plugins/sessionplugin"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	defaultHost = "localhost"
+	// pauseCheckInterval is how often the paused data channel is polled before reading from the connection again.
+	pauseCheckInterval = 100 * time.Millisecond
+	dialTimeout        = 10 * time.Second
+)
+
+// PortParameters contains the inputs required to execute the port plugin.
+type PortParameters struct {
+	PortNumber string `json:"portNumber" yaml:"portNumber"`
+	Host       string `json:"host" yaml:"host"`
+}
+
+// PortPlugin is the type for the port plugin.
+type PortPlugin struct {
+	conn net.Conn
+	// connReady is closed once the connection is established, connFailed if the session ends without a connection
+	connReady   chan struct{}
+	connFailed  chan struct{}
+	dataChannel datachannel.IDataChannel
+}
+
+// NewPlugin returns a new instance of the Port Plugin
+func NewPlugin() (sessionplugin.ISessionPlugin, error) {
+	var plugin = PortPlugin{
+		connReady:  make(chan struct{}),
+		connFailed: make(chan struct{}),
+	}
+	return &plugin, nil
+}
+
+// name returns the name of Port Plugin
+func (p *PortPlugin) name() string {
+	return appconfig.PluginNamePort
+}
+
+// Execute establishes a connection to the configured host and port.
+// It reads incoming message from data channel and writes to the connection.
+// It reads message from the connection and writes to data channel.
+func (p *PortPlugin) Execute(context context.T,
+	config agentContracts.Configuration,
+	cancelFlag task.CancelFlag,
+	output iohandler.IOHandler,
+	dataChannel datachannel.IDataChannel) {
+
+	log := context.Log()
+	p.dataChannel = dataChannel
+	defer func() {
+		if p.conn != nil {
+			if err := p.conn.Close(); err != nil {
+				log.Debugf("Error occurred while closing connection: %v", err)
+			}
+		} else {
+			// stop the incoming messages from waiting for a connection which will never be established
+			close(p.connFailed)
+		}
+		if err := recover(); err != nil {
+			log.Errorf("Error occurred while executing plugin %s: \n%v", p.name(), err)
+			log.Flush()
+			os.Exit(1)
+		}
+	}()
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.execute(context, config, cancelFlag, output)
+	}
+}
+
+// dialConnection opens the tcp connection that is forwarded over the data channel
+var dialConnection = func(address string) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, dialTimeout)
+}
+
+// execute connects to the configured port and pumps data between the connection and the data channel.
+func (p *PortPlugin) execute(context context.T,
+	config agentContracts.Configuration,
+	cancelFlag task.CancelFlag,
+	output iohandler.IOHandler) {
+
+	log := context.Log()
+	var err error
+
+	var portParameters PortParameters
+	if err = jsonutil.Remarshal(config.Properties, &portParameters); err != nil {
+		errorString := fmt.Errorf("Unable to parse port plugin parameters: %s", err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+
+	address, err := portParameters.address()
+	if err != nil {
+		log.Error(err)
+		output.MarkAsFailed(err)
+		return
+	}
+
+	if p.conn, err = dialConnection(address); err != nil {
+		errorString := fmt.Errorf("Unable to start port forwarding to %s: %s", address, err)
+		log.Error(errorString)
+		output.MarkAsFailed(errorString)
+		return
+	}
+	close(p.connReady)
+
+	cancelled := make(chan bool, 1)
+	go func() {
+		cancelState := cancelFlag.Wait()
+		if cancelFlag.Canceled() {
+			cancelled <- true
+			log.Debug("Cancel flag set to cancelled in session")
+		}
+		log.Debugf("Cancel flag set to %v in session", cancelState)
+	}()
+
+	log.Debugf("Start separate go routine to read from port connection and write to data channel")
+	done := make(chan int, 1)
+	go func() {
+		done <- p.writePump(log, cancelFlag)
+	}()
+
+	log.Infof("Plugin %s started for %s", p.name(), address)
+
+	select {
+	case <-cancelled:
+		log.Debug("Session cancelled. Attempting to close connection.")
+		output.SetExitCode(appconfig.SuccessExitCode)
+		output.SetStatus(agentContracts.ResultStatusSuccess)
+		log.Info("The session was cancelled")
+
+	case exitCode := <-done:
+		if exitCode == appconfig.ErrorExitCode {
+			output.SetExitCode(appconfig.ErrorExitCode)
+			output.SetStatus(agentContracts.ResultStatusFailed)
+		} else {
+			output.SetExitCode(appconfig.SuccessExitCode)
+			output.SetStatus(agentContracts.ResultStatusSuccess)
+		}
+		if cancelFlag.Canceled() {
+			log.Errorf("The cancellation failed to stop the session.")
+		}
+	}
+	output.SetOutput(mgsContracts.SessionPluginResultOutput{})
+
+	log.Debug("Port session execution complete")
+}
+
+// address validates the port parameters and returns the address to connect to.
+func (params PortParameters) address() (string, error) {
+	portNumber, err := strconv.Atoi(params.PortNumber)
+	if err != nil || portNumber <= 0 || portNumber > 65535 {
+		return "", fmt.Errorf("Invalid port number %q", params.PortNumber)
+	}
+
+	host := params.Host
+	if host == "" {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, params.PortNumber), nil
+}
+
+// writePump reads from the connection and writes to data channel.
+// Reading is suspended while the service has paused publication on the data channel.
+func (p *PortPlugin) writePump(log log.T, cancelFlag task.CancelFlag) (errorCode int) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("WritePump thread crashed with message: \n%v", err)
+		}
+	}()
+
+	buf := make([]byte, mgsConfig.StreamDataPayloadSize)
+	for {
+		for p.dataChannel.IsPaused() {
+			if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+				return appconfig.SuccessExitCode
+			}
+			time.Sleep(pauseCheckInterval)
+		}
+
+		n, err := p.conn.Read(buf)
+		if err != nil {
+			// Terminating session
+			log.Debugf("Failed to read from port connection: %s", err)
+			if err = p.dataChannel.SendAgentSessionStateMessage(log, mgsContracts.Terminating); err != nil {
+				log.Errorf("Unable to send AgentSessionState message with session status %s. %v", mgsContracts.Terminating, err)
+			}
+			return appconfig.SuccessExitCode
+		}
+
+		if err = p.dataChannel.SendStreamDataMessage(log, mgsContracts.Output, buf[:n]); err != nil {
+			log.Errorf("Unable to send stream data message: %s", err)
+			return appconfig.ErrorExitCode
+		}
+	}
+}
+
+// InputStreamMessageHandler passes payload byte stream to the port connection
+func (p *PortPlugin) InputStreamMessageHandler(log log.T, streamDataMessage mgsContracts.AgentMessage) error {
+	// Data may arrive while the connection is still being established. Since the data channel has already
+	// acknowledged the message it will not be resent, so wait for the connection instead of dropping the payload.
+	select {
+	case <-p.connReady:
+	case <-p.connFailed:
+		log.Tracef("Connection failed. Reject incoming message packet")
+		return nil
+	case <-time.After(dialTimeout):
+		log.Tracef("Connection unavailable. Reject incoming message packet")
+		return nil
+	}
+
+	switch mgsContracts.PayloadType(streamDataMessage.PayloadType) {
+	case mgsContracts.Output:
+		log.Tracef("Output message received: %d", streamDataMessage.SequenceNumber)
+		if _, err := p.conn.Write(streamDataMessage.Payload); err != nil {
+			log.Errorf("Unable to write to port, err: %v.", err)
+			return err
+		}
+	default:
+		log.Tracef("Ignoring payload type %d for port session", streamDataMessage.PayloadType)
+	}
+	return nil
+}
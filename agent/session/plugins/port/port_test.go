@@ -0,0 +1,316 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package port implements session manager's port plugin.
+package port
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	dataChannelMock "github.com/aws/amazon-ssm-agent/agent/session/datachannel/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	payload = []byte("testPayload")
+	mockLog = log.NewMockLog()
+)
+
+type PortTestSuite struct {
+	suite.Suite
+	mockContext     *context.Mock
+	mockCancelFlag  *task.MockCancelFlag
+	mockDataChannel *dataChannelMock.IDataChannel
+	mockIohandler   *iohandlermocks.MockIOHandler
+	listener        net.Listener
+	plugin          *PortPlugin
+}
+
+func (suite *PortTestSuite) SetupTest() {
+	suite.mockContext = context.NewMockDefault()
+	suite.mockCancelFlag = &task.MockCancelFlag{}
+	suite.mockDataChannel = &dataChannelMock.IDataChannel{}
+	suite.mockIohandler = new(iohandlermocks.MockIOHandler)
+
+	// Start a local tcp echo server for the plugin to forward to
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(suite.T(), err)
+	suite.listener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	plugin, _ := NewPlugin()
+	suite.plugin = plugin.(*PortPlugin)
+}
+
+func (suite *PortTestSuite) TearDownTest() {
+	suite.listener.Close()
+}
+
+//Execute the test suite
+func TestPortTestSuite(t *testing.T) {
+	suite.Run(t, new(PortTestSuite))
+}
+
+// Testing Name
+func (suite *PortTestSuite) TestName() {
+	assert.Equal(suite.T(), appconfig.PluginNamePort, suite.plugin.name())
+}
+
+// Testing Execute
+func (suite *PortTestSuite) TestExecuteWhenCancelFlagIsShutDown() {
+	suite.mockCancelFlag.On("ShutDown").Return(true)
+	suite.mockIohandler.On("MarkAsShutdown").Return(nil)
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockCancelFlag.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute
+func (suite *PortTestSuite) TestExecuteWhenCancelFlagIsCancelled() {
+	suite.mockCancelFlag.On("Canceled").Return(true)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockIohandler.On("MarkAsCancelled").Return(nil)
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockCancelFlag.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing Execute with an invalid port number
+func (suite *PortTestSuite) TestExecuteWithInvalidPortNumber() {
+	suite.mockCancelFlag.On("Canceled").Return(false)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
+
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{Properties: map[string]interface{}{"portNumber": "notaport"}},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing data round trip between the data channel and a local echo server
+func (suite *PortTestSuite) TestExecuteForwardsDataToEchoServer() {
+	cancelFlag := task.NewChanneledCancelFlag()
+	received := make(chan []byte, 10)
+
+	suite.mockDataChannel.On("IsPaused").Return(false)
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.Output, mock.Anything).
+		Run(func(args mock.Arguments) {
+			data := args.Get(2).([]byte)
+			received <- append([]byte{}, data...)
+		}).Return(nil)
+	suite.mockIohandler.On("SetExitCode", appconfig.SuccessExitCode).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	done := make(chan bool)
+	go func() {
+		suite.plugin.Execute(suite.mockContext,
+			contracts.Configuration{Properties: suite.portProperties()},
+			cancelFlag,
+			suite.mockIohandler,
+			suite.mockDataChannel)
+		done <- true
+	}()
+
+	err := suite.plugin.InputStreamMessageHandler(mockLog, getAgentMessage(mgsContracts.Output, payload))
+	assert.Nil(suite.T(), err)
+
+	select {
+	case data := <-received:
+		assert.Equal(suite.T(), payload, data)
+	case <-time.After(5 * time.Second):
+		assert.Fail(suite.T(), "payload was not echoed back over the data channel")
+	}
+
+	cancelFlag.Set(task.Canceled)
+	<-done
+
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing incoming messages are rejected right away when the connection can't be established
+func (suite *PortTestSuite) TestInputStreamMessageHandlerWhenDialFails() {
+	dialConnection = func(address string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	defer func() {
+		dialConnection = func(address string) (net.Conn, error) {
+			return net.DialTimeout("tcp", address, dialTimeout)
+		}
+	}()
+	suite.mockCancelFlag.On("Canceled").Return(false)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
+
+	handled := make(chan error, 1)
+	go func() {
+		handled <- suite.plugin.InputStreamMessageHandler(mockLog, getAgentMessage(mgsContracts.Output, payload))
+	}()
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{Properties: suite.portProperties()},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	select {
+	case err := <-handled:
+		assert.Nil(suite.T(), err)
+	case <-time.After(dialTimeout / 2):
+		assert.Fail(suite.T(), "incoming message waited for the dial timeout")
+	}
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing session terminates when remote closes the connection
+func (suite *PortTestSuite) TestExecuteTerminatesWhenConnectionIsClosed() {
+	cancelFlag := task.NewChanneledCancelFlag()
+	serverConn, clientConn := net.Pipe()
+	dialConnection = func(address string) (net.Conn, error) {
+		return clientConn, nil
+	}
+	defer func() {
+		dialConnection = func(address string) (net.Conn, error) {
+			return net.DialTimeout("tcp", address, dialTimeout)
+		}
+	}()
+
+	suite.mockDataChannel.On("IsPaused").Return(false)
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+	suite.mockIohandler.On("SetExitCode", appconfig.SuccessExitCode).Return()
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+
+	serverConn.Close()
+	suite.plugin.Execute(suite.mockContext,
+		contracts.Configuration{Properties: suite.portProperties()},
+		cancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockIohandler.AssertExpectations(suite.T())
+}
+
+// Testing writePump does not read from the connection while data channel is paused
+func (suite *PortTestSuite) TestWritePumpWaitsWhileDataChannelIsPaused() {
+	serverConn, clientConn := net.Pipe()
+	suite.plugin.conn = clientConn
+	suite.plugin.dataChannel = suite.mockDataChannel
+
+	suite.mockDataChannel.On("IsPaused").Return(true).Twice()
+	suite.mockDataChannel.On("IsPaused").Return(false)
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.Output, payload).Return(nil)
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+
+	go func() {
+		serverConn.Write(payload)
+		serverConn.Close()
+	}()
+	exitCode := suite.plugin.writePump(mockLog, task.NewChanneledCancelFlag())
+
+	assert.Equal(suite.T(), appconfig.SuccessExitCode, exitCode)
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockDataChannel.AssertNumberOfCalls(suite.T(), "IsPaused", 4)
+}
+
+// Testing writePump exits when the session is cancelled while data channel is paused
+func (suite *PortTestSuite) TestWritePumpExitsOnCancelWhilePaused() {
+	_, clientConn := net.Pipe()
+	suite.plugin.conn = clientConn
+	suite.plugin.dataChannel = suite.mockDataChannel
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+
+	suite.mockDataChannel.On("IsPaused").Return(true)
+
+	exitCode := suite.plugin.writePump(mockLog, cancelFlag)
+
+	assert.Equal(suite.T(), appconfig.SuccessExitCode, exitCode)
+	suite.mockDataChannel.AssertNotCalled(suite.T(), "SendStreamDataMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *PortTestSuite) TestAddress() {
+	address, err := PortParameters{PortNumber: "22"}.address()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "localhost:22", address)
+
+	address, err = PortParameters{PortNumber: "3306", Host: "10.0.0.1"}.address()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "10.0.0.1:3306", address)
+
+	_, err = PortParameters{PortNumber: "65536"}.address()
+	assert.NotNil(suite.T(), err)
+
+	_, err = PortParameters{}.address()
+	assert.NotNil(suite.T(), err)
+}
+
+// portProperties returns the plugin properties pointing to the local echo server
+func (suite *PortTestSuite) portProperties() map[string]interface{} {
+	port := suite.listener.Addr().(*net.TCPAddr).Port
+	return map[string]interface{}{
+		"portNumber": strconv.Itoa(port),
+		"host":       "127.0.0.1",
+	}
+}
+
+// getAgentMessage constructs and returns AgentMessage with given payloadType & payload
+func getAgentMessage(payloadType mgsContracts.PayloadType, payload []byte) mgsContracts.AgentMessage {
+	return mgsContracts.AgentMessage{
+		MessageType:    mgsContracts.InputStreamDataMessage,
+		SchemaVersion:  1,
+		SequenceNumber: 1,
+		PayloadType:    uint32(payloadType),
+		Payload:        payload,
+	}
+}
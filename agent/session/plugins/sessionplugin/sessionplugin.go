@@ -17,7 +17,10 @@ package sessionplugin
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
@@ -81,6 +84,10 @@ func (p *SessionPlugin) Execute(context context.T,
 		dataChannel.SkipHandshake(log)
 	}
 
+	idleTimeout, maxDuration := getSessionTimeouts(log, context.AppConfig().Mgs, config)
+	// only the shell renders text sent to the client, other plugins stream raw data
+	dataChannel.StartSessionTimer(log, idleTimeout, maxDuration, config.PluginName == appconfig.PluginNameStandardStream)
+
 	p.sessionPlugin.Execute(context, config, cancelFlag, output, dataChannel)
 }
 
@@ -89,6 +96,30 @@ func (p *SessionPlugin) isEncryptionEnabled(kmsKeyId string) bool {
 	return kmsKeyId != ""
 }
 
+// getSessionTimeouts returns the idle timeout and the maximum duration of the session.
+// The agent configuration sets the default of each timeout, sessions can override them within the bounds of the setting.
+func getSessionTimeouts(log log.T, mgsConfig appconfig.MgsConfig, config contracts.Configuration) (idleTimeout time.Duration, maxDuration time.Duration) {
+	idleMinutes := getSessionTimeoutMinutes(log, "idleSessionTimeout", mgsConfig.IdleSessionTimeoutMinutes, config.IdleSessionTimeout,
+		appconfig.DefaultIdleSessionTimeoutMinutesMin, appconfig.DefaultIdleSessionTimeoutMinutesMax)
+	maxMinutes := getSessionTimeoutMinutes(log, "maxSessionDuration", mgsConfig.MaxSessionDurationMinutes, config.MaxSessionDuration,
+		appconfig.DefaultMaxSessionDurationMinutesMin, appconfig.DefaultMaxSessionDurationMinutesMax)
+	return time.Duration(idleMinutes) * time.Minute, time.Duration(maxMinutes) * time.Minute
+}
+
+// getSessionTimeoutMinutes returns the session value of a timeout when it is within the bounds, else the agent value.
+// 0 means no timeout.
+func getSessionTimeoutMinutes(log log.T, name string, agentMinutes int, sessionValue string, minMinutes int, maxMinutes int) int {
+	if sessionValue == "" {
+		return agentMinutes
+	}
+	sessionMinutes, err := strconv.Atoi(sessionValue)
+	if err != nil || sessionMinutes < minMinutes || sessionMinutes > maxMinutes {
+		log.Warnf("Ignoring %s %q outside of %d to %d minutes, using %d minutes", name, sessionValue, minMinutes, maxMinutes, agentMinutes)
+		return agentMinutes
+	}
+	return sessionMinutes
+}
+
 // getDataChannelForSessionPlugin opens new data channel to MGS service
 var getDataChannelForSessionPlugin = func(context context.T, sessionId string, clientId string, cancelFlag task.CancelFlag, inputStreamMessageHandler datachannel.InputStreamMessageHandler) (datachannel.IDataChannel, error) {
 	retryer := retry.ExponentialRetryer{
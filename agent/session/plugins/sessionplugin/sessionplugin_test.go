@@ -17,7 +17,9 @@ package sessionplugin
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	iohandlerMock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
@@ -70,6 +72,7 @@ func (suite *SessionPluginTestSuite) TestExecute() {
 	suite.mockSessionPlugin.On("Execute", suite.mockContext, mock.Anything, suite.mockCancelFlag, suite.mockIohandler, suite.mockDataChannel).Return()
 
 	suite.mockDataChannel.On("SkipHandshake", suite.mockContext.Log()).Return()
+	suite.mockDataChannel.On("StartSessionTimer", suite.mockContext.Log(), time.Duration(0), time.Duration(0), false).Return()
 	suite.sessionPlugin.Execute(suite.mockContext,
 		contracts.Configuration{},
 		suite.mockCancelFlag,
@@ -90,6 +93,7 @@ func (suite *SessionPluginTestSuite) TestExecuteEncryptionHandshakeSuccess() {
 
 	kmsKey := "some-key"
 	suite.mockDataChannel.On("PerformHandshake", suite.mockContext.Log(), kmsKey).Return(nil)
+	suite.mockDataChannel.On("StartSessionTimer", suite.mockContext.Log(), time.Duration(0), time.Duration(0), false).Return()
 	suite.sessionPlugin.Execute(suite.mockContext,
 		contracts.Configuration{KmsKeyId: kmsKey},
 		suite.mockCancelFlag,
@@ -119,3 +123,55 @@ func (suite *SessionPluginTestSuite) TestExecuteEncryptionHandshakeFailed() {
 	suite.mockDataChannel.AssertExpectations(suite.T())
 	suite.mockSessionPlugin.AssertExpectations(suite.T())
 }
+
+func (suite *SessionPluginTestSuite) TestExecuteStartsSessionTimer() {
+	getDataChannelForSessionPlugin =
+		func(context context.T, sessionId string, clientId string, cancelFlag task.CancelFlag, inputStreamMessageHandler datachannel.InputStreamMessageHandler) (datachannel.IDataChannel, error) {
+			return suite.mockDataChannel, nil
+		}
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", suite.mockContext.Log(), mgsContracts.Connected).Return(nil)
+	suite.mockDataChannel.On("Close", suite.mockContext.Log()).Return(nil)
+	suite.mockDataChannel.On("SkipHandshake", suite.mockContext.Log()).Return()
+	suite.mockDataChannel.On("StartSessionTimer", suite.mockContext.Log(), 5*time.Minute, time.Duration(0), true).Return()
+	suite.mockSessionPlugin.On("Execute", suite.mockContext, mock.Anything, suite.mockCancelFlag, suite.mockIohandler, suite.mockDataChannel).Return()
+
+	suite.sessionPlugin.Execute(suite.mockContext,
+		contracts.Configuration{PluginName: appconfig.PluginNameStandardStream, IdleSessionTimeout: "5"},
+		suite.mockCancelFlag,
+		suite.mockIohandler)
+
+	suite.mockDataChannel.AssertExpectations(suite.T())
+	suite.mockSessionPlugin.AssertExpectations(suite.T())
+}
+
+func (suite *SessionPluginTestSuite) TestGetSessionTimeouts() {
+	mgsConfig := appconfig.MgsConfig{IdleSessionTimeoutMinutes: 20, MaxSessionDurationMinutes: 0}
+	testCases := []struct {
+		idleSessionTimeout  string
+		maxSessionDuration  string
+		expectedIdleTimeout time.Duration
+		expectedMaxDuration time.Duration
+	}{
+		{"", "", 20 * time.Minute, 0},
+		{"10", "60", 10 * time.Minute, 60 * time.Minute},
+		{"30", "1440", 30 * time.Minute, 1440 * time.Minute},
+		{"61", "1441", 20 * time.Minute, 0},
+		{"-5", "abc", 20 * time.Minute, 0},
+		{"0", "0", 0, 0},
+	}
+
+	for _, testCase := range testCases {
+		idleTimeout, maxDuration := getSessionTimeouts(suite.mockLog, mgsConfig, contracts.Configuration{
+			IdleSessionTimeout: testCase.idleSessionTimeout,
+			MaxSessionDuration: testCase.maxSessionDuration,
+		})
+		suite.Equal(testCase.expectedIdleTimeout, idleTimeout)
+		suite.Equal(testCase.expectedMaxDuration, maxDuration)
+	}
+
+	// the idle timeout is disabled unless the agent or the session enables it
+	idleTimeout, _ := getSessionTimeouts(suite.mockLog, appconfig.MgsConfig{}, contracts.Configuration{})
+	suite.Equal(time.Duration(0), idleTimeout)
+	idleTimeout, _ = getSessionTimeouts(suite.mockLog, appconfig.MgsConfig{}, contracts.Configuration{IdleSessionTimeout: "45"})
+	suite.Equal(45*time.Minute, idleTimeout)
+}
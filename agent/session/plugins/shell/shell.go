@@ -37,6 +37,7 @@ import (
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 	"github.com/aws/amazon-ssm-agent/agent/session/plugins/sessionplugin"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
@@ -44,6 +45,7 @@ import (
 type ShellPlugin struct {
 	stdin       *os.File
 	stdout      *os.File
+	terminal    pseudoTerminal
 	ipcFilePath string
 	logFilePath string
 	dataChannel datachannel.IDataChannel
@@ -92,7 +94,7 @@ func (p *ShellPlugin) Execute(context context.T,
 	log := context.Log()
 	p.dataChannel = dataChannel
 	defer func() {
-		if err := Stop(log); err != nil {
+		if err := p.terminal.stop(log); err != nil {
 			log.Errorf("Error occured while closing pty: %v", err)
 		}
 		if err := recover(); err != nil {
@@ -111,10 +113,12 @@ func (p *ShellPlugin) Execute(context context.T,
 	}
 }
 
-var startPty = func(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
-	return StartPty(log, runAsSsmUser, shellCmd)
+var startPty = func(terminal *pseudoTerminal, log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
+	return terminal.start(log, runAsSsmUser, runAsUser, shellCmd)
 }
 
+var resolveRunAsUser = runas.ResolveUser
+
 // execute starts pseudo terminal.
 // It reads incoming message from data channel and writes to pty.stdin.
 // It reads message from pty.stdout and writes to data channel
@@ -144,7 +148,20 @@ func (p *ShellPlugin) execute(context context.T,
 		return
 	}
 
-	p.stdin, p.stdout, err = startPty(log, !config.RunAsElevated, config.Commands)
+	// A session with run as enabled runs as the user allowed by the run as policy of the agent,
+	// other sessions run as ssm-user unless they are elevated.
+	var runAsUser string
+	if config.RunAsEnabled {
+		if runAsUser, err = resolveRunAsUser(log, config.SessionOwner, config.RunAsUser); err != nil {
+			errorString := fmt.Errorf("Unable to start shell: %s", err)
+			log.Error(errorString)
+			output.MarkAsFailed(errorString)
+			return
+		}
+		log.Infof("Session runs as user %s", runAsUser)
+	}
+
+	p.stdin, p.stdout, err = startPty(&p.terminal, log, !config.RunAsElevated, runAsUser, config.Commands)
 	if err != nil {
 		errorString := fmt.Errorf("Unable to start shell: %s", err)
 		log.Error(errorString)
@@ -243,7 +260,7 @@ func (p *ShellPlugin) writePump(log log.T) (errorCode int) {
 	defer func() {
 		if err := recover(); err != nil {
 			fmt.Println("WritePump thread crashed with message: \n", err)
-			Stop(log)
+			p.terminal.stop(log)
 		}
 	}()
 
@@ -15,8 +15,10 @@
 package shell
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -135,7 +137,7 @@ func (suite *ShellTestSuite) TestExecute() {
 
 	stdout, stdin, _ := os.Pipe()
 	stdin.Write(payload)
-	startPty = func(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
+	startPty = func(terminal *pseudoTerminal, log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
 		return stdin, stdout, nil
 	}
 	plugin := &ShellPlugin{
@@ -156,6 +158,162 @@ func (suite *ShellTestSuite) TestExecute() {
 	stdout.Close()
 }
 
+// Testing Execute with run as enabled starts the shell as the user resolved from the run as policy
+func (suite *ShellTestSuite) TestExecuteRunAsUser() {
+	suite.mockCancelFlag.On("Canceled").Return(false)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockCancelFlag.On("Wait").Return(task.Completed)
+	suite.mockIohandler.On("SetExitCode", 0).Return(nil)
+	suite.mockIohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+	suite.mockIohandler.On("SetOutput", mock.Anything).Return()
+	suite.mockDataChannel.On("SendStreamDataMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.mockDataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+
+	origStartPty, origResolveRunAsUser := startPty, resolveRunAsUser
+	defer func() { startPty, resolveRunAsUser = origStartPty, origResolveRunAsUser }()
+	resolveRunAsUser = func(log log.T, sessionOwner string, requestedUser string) (string, error) {
+		assert.Equal(suite.T(), "arn:aws:iam::123456789012:role/Deployer", sessionOwner)
+		assert.Equal(suite.T(), "ec2-user", requestedUser)
+		return "deploy", nil
+	}
+	var startedAs string
+	stdout, stdin, _ := os.Pipe()
+	startPty = func(terminal *pseudoTerminal, log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (*os.File, *os.File, error) {
+		startedAs = runAsUser
+		stdin.Close()
+		return stdin, stdout, nil
+	}
+	orchDir, _ := ioutil.TempDir("", "shell")
+	defer os.RemoveAll(orchDir)
+
+	plugin := &ShellPlugin{}
+	plugin.Execute(suite.mockContext,
+		contracts.Configuration{
+			OrchestrationDirectory: orchDir,
+			RunAsEnabled:           true,
+			RunAsUser:              "ec2-user",
+			SessionOwner:           "arn:aws:iam::123456789012:role/Deployer",
+		},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	assert.Equal(suite.T(), "deploy", startedAs)
+	suite.mockIohandler.AssertExpectations(suite.T())
+	stdout.Close()
+}
+
+// Testing Execute fails the session when the run as user is not allowed
+func (suite *ShellTestSuite) TestExecuteRunAsUserNotAllowed() {
+	suite.mockCancelFlag.On("Canceled").Return(false)
+	suite.mockCancelFlag.On("ShutDown").Return(false)
+	suite.mockIohandler.On("MarkAsFailed", mock.Anything).Return()
+
+	origStartPty, origResolveRunAsUser := startPty, resolveRunAsUser
+	defer func() { startPty, resolveRunAsUser = origStartPty, origResolveRunAsUser }()
+	resolveRunAsUser = func(log log.T, sessionOwner string, requestedUser string) (string, error) {
+		return "", fmt.Errorf("run as user %v is not allowed by the run as policy", requestedUser)
+	}
+	startPty = func(terminal *pseudoTerminal, log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (*os.File, *os.File, error) {
+		assert.Fail(suite.T(), "shell must not be started")
+		return nil, nil, nil
+	}
+
+	plugin := &ShellPlugin{}
+	plugin.Execute(suite.mockContext,
+		contracts.Configuration{RunAsEnabled: true, RunAsUser: "root"},
+		suite.mockCancelFlag,
+		suite.mockIohandler,
+		suite.mockDataChannel)
+
+	suite.mockIohandler.AssertCalled(suite.T(), "MarkAsFailed", fmt.Errorf("Unable to start shell: run as user root is not allowed by the run as policy"))
+}
+
+// Testing several sessions running concurrently, each session must only see the output of its own pty
+func (suite *ShellTestSuite) TestExecuteConcurrentSessions() {
+	const sessionCount = 5
+	origStartPty := startPty
+	defer func() { startPty = origStartPty }()
+
+	type session struct {
+		plugin      *ShellPlugin
+		ptyInput    *os.File
+		ptyOutput   *os.File
+		orchDir     string
+		dataChannel *dataChannelMock.IDataChannel
+		cancelFlag  *task.MockCancelFlag
+		iohandler   *iohandlermocks.MockIOHandler
+		mu          sync.Mutex
+		received    []byte
+	}
+
+	sessions := make([]*session, sessionCount)
+	terminals := make(map[*pseudoTerminal]*session)
+	for i := range sessions {
+		s := &session{
+			plugin:      &ShellPlugin{},
+			dataChannel: &dataChannelMock.IDataChannel{},
+			cancelFlag:  &task.MockCancelFlag{},
+			iohandler:   new(iohandlermocks.MockIOHandler),
+		}
+		s.ptyOutput, s.ptyInput, _ = os.Pipe()
+		s.orchDir, _ = ioutil.TempDir("", "shell")
+		defer os.RemoveAll(s.orchDir)
+
+		s.dataChannel.On("SendStreamDataMessage", mock.Anything, mgsContracts.Output, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.received = append(s.received, args.Get(2).([]byte)...)
+		})
+		s.dataChannel.On("SendAgentSessionStateMessage", mock.Anything, mgsContracts.Terminating).Return(nil)
+		s.cancelFlag.On("Canceled").Return(false)
+		s.cancelFlag.On("ShutDown").Return(false)
+		s.cancelFlag.On("Wait").Return(task.Completed)
+		s.iohandler.On("SetExitCode", 0).Return(nil)
+		s.iohandler.On("SetStatus", contracts.ResultStatusSuccess).Return()
+		s.iohandler.On("SetOutput", mock.Anything).Return()
+		sessions[i] = s
+		terminals[&s.plugin.terminal] = s
+	}
+
+	// every plugin gets the pipe of its own session as pty
+	startPty = func(terminal *pseudoTerminal, log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
+		s, found := terminals[terminal]
+		if !found {
+			return nil, nil, fmt.Errorf("unknown terminal")
+		}
+		return s.ptyInput, s.ptyOutput, nil
+	}
+
+	var wg sync.WaitGroup
+	for index, s := range sessions {
+		wg.Add(1)
+		go func(s *session, index int) {
+			defer wg.Done()
+			go func() {
+				s.ptyInput.Write([]byte(fmt.Sprintf("output of session %d", index)))
+				time.Sleep(1500 * time.Millisecond)
+				s.ptyInput.Close()
+			}()
+			s.plugin.Execute(suite.mockContext,
+				contracts.Configuration{OrchestrationDirectory: s.orchDir},
+				s.cancelFlag,
+				s.iohandler,
+				s.dataChannel)
+		}(s, index)
+	}
+	wg.Wait()
+
+	for index, s := range sessions {
+		s.dataChannel.AssertExpectations(suite.T())
+		s.iohandler.AssertExpectations(suite.T())
+		assert.Equal(suite.T(), fmt.Sprintf("output of session %d", index), string(s.received))
+		ipcFileContent, _ := ioutil.ReadFile(s.plugin.ipcFilePath)
+		assert.Equal(suite.T(), fmt.Sprintf("output of session %d", index), string(ipcFileContent))
+		s.ptyOutput.Close()
+	}
+}
+
 // Testing writepump separately
 func (suite *ShellTestSuite) TestWritePump() {
 	stdout, stdin, _ := os.Pipe()
@@ -18,12 +18,12 @@ package shell
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -32,12 +32,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
 	"github.com/aws/amazon-ssm-agent/agent/session/utility"
 	"github.com/kr/pty"
 )
 
-var ptyFile *os.File
-
 const (
 	termEnvVariable       = "TERM=xterm-256color"
 	langEnvVariable       = "LANG=C.UTF-8"
@@ -46,12 +45,51 @@ const (
 	newLineCharacter      = "\n"
 	screenBufferSizeCmd   = "screen -h %d%s"
 	homeEnvVariable       = "HOME=/home/" + appconfig.DefaultRunAsUserName
+	loginPathEnvVariable  = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
 )
 
-//StartPty starts pty and provides handles to stdin and stdout
-func StartPty(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
+var lookupUser = runas.LookupUser
+
+// shellExitTimeout is how long the shell gets to exit after the pty is closed before it is killed
+var shellExitTimeout = 5 * time.Second
+
+// pseudoTerminal holds the pty of a shell session and the shell process attached to it.
+type pseudoTerminal struct {
+	mu   sync.Mutex
+	file *os.File
+	cmd  *exec.Cmd
+}
+
+//start starts pty and provides handles to stdin and stdout
+func (t *pseudoTerminal) start(log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
 	log.Info("Starting pty")
 	//Start the command with a pty
+	var cmd *exec.Cmd
+	if runAsUser != "" {
+		cmd, err = loginShellCommand(log, runAsUser, shellCmd)
+	} else {
+		cmd, err = shellCommand(log, runAsSsmUser, shellCmd)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ptyFile, err := pty.Start(cmd)
+	if err != nil {
+		log.Errorf("Failed to start pty: %s\n", err)
+		return nil, nil, fmt.Errorf("Failed to start pty: %s\n", err)
+	}
+
+	t.mu.Lock()
+	t.file = ptyFile
+	t.cmd = cmd
+	t.mu.Unlock()
+
+	return ptyFile, ptyFile, nil
+}
+
+//shellCommand creates the sh command of the session which runs either as root or as ssm-user
+func shellCommand(log log.T, runAsSsmUser bool, shellCmd string) (*exec.Cmd, error) {
 	var cmd *exec.Cmd
 	if strings.TrimSpace(shellCmd) == "" {
 		cmd = exec.Command("sh")
@@ -80,127 +118,130 @@ func StartPty(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, st
 		u := &utility.SessionUtil{}
 		u.CreateLocalAdminUser(log)
 
-		uid, gid, groups, err := getUserCredentials(log)
+		uid, gid, groups, err := utility.GetUserCredentials(log, appconfig.DefaultRunAsUserName)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		cmd.SysProcAttr = &syscall.SysProcAttr{}
 		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: groups, NoSetGroups: false}
 	}
+	return cmd, nil
+}
 
-	ptyFile, err = pty.Start(cmd)
+//loginShellCommand creates the command which starts the login shell of an existing user.
+//The shell starts in the home directory of the user with a clean environment and reads the profile of the user,
+//the user is neither created nor granted any additional permission.
+func loginShellCommand(log log.T, userName string, shellCmd string) (*exec.Cmd, error) {
+	user, err := lookupUser(userName)
 	if err != nil {
-		log.Errorf("Failed to start pty: %s\n", err)
-		return nil, nil, fmt.Errorf("Failed to start pty: %s\n", err)
+		return nil, err
 	}
 
-	return ptyFile, ptyFile, nil
-}
-
-//Stop closes pty file.
-func Stop(log log.T) (err error) {
-	log.Info("Stopping pty")
-	if err := ptyFile.Close(); err != nil {
-		return fmt.Errorf("unable to close ptyFile. %s", err)
+	//A shell started with a leading dash in its name is a login shell
+	cmd := &exec.Cmd{
+		Path: user.Shell,
+		Args: []string{"-" + filepath.Base(user.Shell)},
 	}
-	return nil
-}
-
-//SetSize sets size of console terminal window.
-func SetSize(log log.T, ws_col, ws_row uint32) (err error) {
-	winSize := pty.Winsize{
-		Cols: uint16(ws_col),
-		Rows: uint16(ws_row),
+	if strings.TrimSpace(shellCmd) != "" {
+		cmd.Args = append(cmd.Args, append(utility.ShellPluginCommandArgs, shellCmd)...)
 	}
 
-	if err := pty.Setsize(ptyFile, &winSize); err != nil {
-		return fmt.Errorf("set pty size failed: %s", err)
+	cmd.Env = []string{
+		"HOME=" + user.HomeDir,
+		"USER=" + user.Name,
+		"LOGNAME=" + user.Name,
+		"SHELL=" + user.Shell,
+		loginPathEnvVariable,
+		termEnvVariable,
 	}
-	return nil
-}
-
-// getUserCredentials returns the uid, gid and groups associated to the runas user.
-func getUserCredentials(log log.T) (uint32, uint32, []uint32, error) {
-	uidCmdArgs := append(utility.ShellPluginCommandArgs, fmt.Sprintf("id -u %s", appconfig.DefaultRunAsUserName))
-	cmd := exec.Command(utility.ShellPluginCommandName, uidCmdArgs...)
-	out, err := cmd.Output()
-	if err != nil {
-		log.Errorf("Failed to retrieve uid for %s: %v", appconfig.DefaultRunAsUserName, err)
-		return 0, 0, nil, err
+	if langEnvVariableValue := os.Getenv(langEnvVariableKey); langEnvVariableValue != "" {
+		cmd.Env = append(cmd.Env, langEnvVariableKey+"="+langEnvVariableValue)
+	} else {
+		cmd.Env = append(cmd.Env, langEnvVariable)
 	}
 
-	uid, err := strconv.Atoi(strings.TrimSpace(string(out)))
-	if err != nil {
-		log.Errorf("%s not found: %v", appconfig.DefaultRunAsUserName, err)
-		return 0, 0, nil, err
+	if fileInfo, err := os.Stat(user.HomeDir); err == nil && fileInfo.IsDir() {
+		cmd.Dir = user.HomeDir
+	} else {
+		log.Warnf("Home directory %s of user %s is not available, starting shell in /", user.HomeDir, userName)
+		cmd.Dir = "/"
 	}
 
-	gidCmdArgs := append(utility.ShellPluginCommandArgs, fmt.Sprintf("id -g %s", appconfig.DefaultRunAsUserName))
-	cmd = exec.Command(utility.ShellPluginCommandName, gidCmdArgs...)
-	out, err = cmd.Output()
-	if err != nil {
-		log.Errorf("Failed to retrieve gid for %s: %v", appconfig.DefaultRunAsUserName, err)
-		return 0, 0, nil, err
-	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: user.Credential()}
+	return cmd, nil
+}
 
-	gid, err := strconv.Atoi(strings.TrimSpace(string(out)))
-	if err != nil {
-		log.Errorf("%s not found: %v", appconfig.DefaultRunAsUserName, err)
-		return 0, 0, nil, err
+//stop closes pty file and reaps the shell process.
+func (t *pseudoTerminal) stop(log log.T) (err error) {
+	t.mu.Lock()
+	ptyFile, cmd := t.file, t.cmd
+	t.file, t.cmd = nil, nil
+	t.mu.Unlock()
+
+	if ptyFile == nil {
+		return nil
 	}
 
-	// Get the list of associated groups
-	groupNamesCmdArgs := append(utility.ShellPluginCommandArgs, fmt.Sprintf("groups %s", appconfig.DefaultRunAsUserName))
-	cmd = exec.Command(utility.ShellPluginCommandName, groupNamesCmdArgs...)
-	out, err = cmd.Output()
-	if err != nil {
-		log.Errorf("Failed to retrieve groups for %s: %v", appconfig.DefaultRunAsUserName, err)
-		return 0, 0, nil, err
+	log.Info("Stopping pty")
+	if closeErr := ptyFile.Close(); closeErr != nil {
+		err = fmt.Errorf("unable to close ptyFile. %s", closeErr)
 	}
+	reapShell(log, cmd)
+	return err
+}
 
-	groupNames := strings.Split(string(out), " ")
-	var groupIds []uint32
+//reapShell waits for the shell to exit after its pty is closed and kills its process group if it doesn't.
+func reapShell(log log.T, cmd *exec.Cmd) {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
 
-	// Skip the first two elements. Group names start from the third element.
-	// Format ex: ssm-user : ssm-user test
-	for i := 2; i < len(groupNames); i++ {
-		groupIdFromNameCmdArgs := append(utility.ShellPluginCommandArgs, fmt.Sprintf("getent group %s", groupNames[i]))
-		cmd = exec.Command(utility.ShellPluginCommandName, groupIdFromNameCmdArgs...)
-		out, err = cmd.Output()
-		if err != nil {
-			log.Errorf("Failed to retrieve group id for %s: %v", groupNames[i], err)
-			return 0, 0, nil, err
+	select {
+	case <-exited:
+	case <-time.After(shellExitTimeout):
+		log.Infof("Shell process %v did not exit after closing pty, killing it", cmd.Process.Pid)
+		// the shell is the leader of a new session started by pty, so its pid is also its process group id
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			log.Warnf("Failed to kill shell process %v: %v", cmd.Process.Pid, err)
 		}
+		<-exited
+	}
+	log.Debugf("Shell process %v exited", cmd.Process.Pid)
+}
 
-		// Get the third element from the array which contains the id and convert it to int
-		// Format ex: test:x:1004:ssm-user
-		groupIdFromName, err := strconv.Atoi(strings.TrimSpace(strings.Split(string(out), ":")[2]))
-		if err != nil {
-			log.Errorf("%s group id not found: %v", groupNames[i], err)
-			return 0, 0, nil, err
-		}
+//setSize sets size of console terminal window.
+func (t *pseudoTerminal) setSize(log log.T, ws_col, ws_row uint32) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		groupIds = append(groupIds, uint32(groupIdFromName))
+	if t.file == nil {
+		return fmt.Errorf("pty is not started")
 	}
 
-	// Make sure they are non-zero valid positive ids
-	if uid > 0 && gid > 0 {
-		return uint32(uid), uint32(gid), groupIds, nil
+	winSize := pty.Winsize{
+		Cols: uint16(ws_col),
+		Rows: uint16(ws_row),
 	}
 
-	return 0, 0, nil, errors.New("invalid uid and gid")
+	if err := pty.Setsize(t.file, &winSize); err != nil {
+		return fmt.Errorf("set pty size failed: %s", err)
+	}
+	return nil
 }
 
 // generateLogData generates a log file with the executed commands.
 func (p *ShellPlugin) generateLogData(log log.T, config agentContracts.Configuration) error {
-	shadowShellInput, _, err := StartPty(log, false, "")
+	// the commands are run in a separate shadow shell which doesn't share the pty of the session
+	var shadow pseudoTerminal
+	shadowShellInput, _, err := shadow.start(log, false, "", "")
 	if err != nil {
 		return err
 	}
 
 	defer func() {
 		if err := recover(); err != nil {
-			if err = Stop(log); err != nil {
+			if err = shadow.stop(log); err != nil {
 				log.Errorf("Error occured while closing pty: %v", err)
 			}
 		}
@@ -248,7 +289,9 @@ func (p *ShellPlugin) generateLogData(log log.T, config agentContracts.Configura
 	time.Sleep(5 * time.Second)
 
 	// Close pty
-	shadowShellInput.Close()
+	if err = shadow.stop(log); err != nil {
+		log.Errorf("Error occured while closing pty: %v", err)
+	}
 
 	// Sleep till the shell successfully exits before uploading
 	time.Sleep(15 * time.Second)
@@ -279,7 +322,7 @@ func (p *ShellPlugin) InputStreamMessageHandler(log log.T, streamDataMessage mgs
 			return err
 		}
 		log.Tracef("Resize data received: cols: %d, rows: %d", size.Cols, size.Rows)
-		if err := SetSize(log, size.Cols, size.Rows); err != nil {
+		if err := p.terminal.setSize(log, size.Cols, size.Rows); err != nil {
 			log.Errorf("Unable to set pty size: %s", err)
 			return err
 		}
@@ -0,0 +1,170 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/session/runas"
+	"github.com/kr/pty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPseudoTerminalSizeIsPerSession(t *testing.T) {
+	var first, second pseudoTerminal
+	_, _, err := first.start(mockLog, false, "", "")
+	assert.NoError(t, err)
+	defer first.stop(mockLog)
+	_, _, err = second.start(mockLog, false, "", "")
+	assert.NoError(t, err)
+	defer second.stop(mockLog)
+
+	assert.NoError(t, first.setSize(mockLog, 120, 40))
+	assert.NoError(t, second.setSize(mockLog, 80, 24))
+
+	rows, cols, err := pty.Getsize(first.file)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, rows)
+	assert.Equal(t, 120, cols)
+	rows, cols, err = pty.Getsize(second.file)
+	assert.NoError(t, err)
+	assert.Equal(t, 24, rows)
+	assert.Equal(t, 80, cols)
+}
+
+func TestPseudoTerminalStopReapsOnlyItsShell(t *testing.T) {
+	var first, second pseudoTerminal
+	_, _, err := first.start(mockLog, false, "", "")
+	assert.NoError(t, err)
+	stdin, stdout, err := second.start(mockLog, false, "", "")
+	assert.NoError(t, err)
+	defer second.stop(mockLog)
+
+	firstCmd := first.cmd
+	assert.NoError(t, first.stop(mockLog))
+	assert.NotNil(t, firstCmd.ProcessState)
+	assert.Nil(t, first.file)
+	assert.Error(t, first.setSize(mockLog, 80, 24))
+
+	// the shell of the second session keeps running
+	assert.Nil(t, second.cmd.ProcessState)
+	_, err = stdin.Write([]byte("echo still-$((40+2))\n"))
+	assert.NoError(t, err)
+	assert.Contains(t, readUntil(stdout, "still-42", 5*time.Second), "still-42")
+}
+
+func TestPseudoTerminalStopKillsShellIgnoringHangup(t *testing.T) {
+	origTimeout := shellExitTimeout
+	shellExitTimeout = 500 * time.Millisecond
+	defer func() { shellExitTimeout = origTimeout }()
+
+	var terminal pseudoTerminal
+	_, stdout, err := terminal.start(mockLog, false, "", "trap '' HUP; echo ready; sleep 60")
+	assert.NoError(t, err)
+	assert.Contains(t, readUntil(stdout, "ready", 5*time.Second), "ready")
+
+	cmd := terminal.cmd
+	start := time.Now()
+	assert.NoError(t, terminal.stop(mockLog))
+	assert.NotNil(t, cmd.ProcessState)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+// readUntil reads the pty output until it contains the expected text or the timeout expires
+func readUntil(stdout *os.File, expected string, timeout time.Duration) string {
+	found := make(chan string, 1)
+	go func() {
+		var output []byte
+		buf := make([]byte, 1024)
+		for !strings.Contains(string(output), expected) {
+			n, err := stdout.Read(buf)
+			output = append(output, buf[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		found <- string(output)
+	}()
+
+	select {
+	case output := <-found:
+		return output
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+func stubLookupUser(homeDir string) func() {
+	origLookupUser := lookupUser
+	lookupUser = func(userName string) (*runas.User, error) {
+		return &runas.User{
+			Name:    userName,
+			Uid:     uint32(os.Getuid()),
+			Gid:     uint32(os.Getgid()),
+			Groups:  []uint32{uint32(os.Getgid())},
+			HomeDir: homeDir,
+			Shell:   "/bin/sh",
+		}, nil
+	}
+	return func() { lookupUser = origLookupUser }
+}
+
+func TestLoginShellCommand(t *testing.T) {
+	homeDir, _ := ioutil.TempDir("", "home")
+	defer os.RemoveAll(homeDir)
+	defer stubLookupUser(homeDir)()
+
+	cmd, err := loginShellCommand(mockLog, "deploy", "ls -al")
+	assert.NoError(t, err)
+	assert.Equal(t, "/bin/sh", cmd.Path)
+	assert.Equal(t, []string{"-sh", "-c", "ls -al"}, cmd.Args)
+	assert.Equal(t, homeDir, cmd.Dir)
+	assert.Contains(t, cmd.Env, "HOME="+homeDir)
+	assert.Contains(t, cmd.Env, "USER=deploy")
+	assert.Contains(t, cmd.Env, "LOGNAME=deploy")
+	assert.Contains(t, cmd.Env, "SHELL=/bin/sh")
+	assert.Equal(t, uint32(os.Getuid()), cmd.SysProcAttr.Credential.Uid)
+	assert.Equal(t, []uint32{uint32(os.Getgid())}, cmd.SysProcAttr.Credential.Groups)
+
+	// the environment of the agent is not passed to the session
+	os.Setenv("SSM_AGENT_TEST_SECRET", "secret")
+	defer os.Unsetenv("SSM_AGENT_TEST_SECRET")
+	cmd, err = loginShellCommand(mockLog, "deploy", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-sh"}, cmd.Args)
+	for _, env := range cmd.Env {
+		assert.False(t, strings.HasPrefix(env, "SSM_AGENT_TEST_SECRET="))
+	}
+}
+
+func TestPseudoTerminalStartsLoginShellOfUser(t *testing.T) {
+	homeDir, _ := ioutil.TempDir("", "home")
+	defer os.RemoveAll(homeDir)
+	defer stubLookupUser(homeDir)()
+
+	var terminal pseudoTerminal
+	_, stdout, err := terminal.start(mockLog, true, "deploy", `echo "user=$USER home=$HOME pwd=$(pwd) shell=$0"`)
+	assert.NoError(t, err)
+	defer terminal.stop(mockLog)
+
+	output := readUntil(stdout, "shell=-sh", 5*time.Second)
+	assert.Contains(t, output, "user=deploy home="+homeDir+" pwd="+homeDir+" shell=-sh")
+}
@@ -42,7 +42,6 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/session/winpty"
 )
 
-var pty *winpty.WinPTY
 var u = &utility.SessionUtil{}
 
 const (
@@ -67,13 +66,24 @@ var (
 	winptyDllFilePath = filepath.Join(winptyDllDir, winptyDllName)
 )
 
-//StartPty starts winpty agent and provides handles to stdin and stdout.
-func StartPty(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
+// pseudoTerminal holds the winpty agent of a shell session, the shell process is shut down with the agent.
+type pseudoTerminal struct {
+	mu           sync.Mutex
+	pty          *winpty.WinPTY
+	runAsSsmUser bool
+}
+
+//start starts winpty agent and provides handles to stdin and stdout.
+func (t *pseudoTerminal) start(log log.T, runAsSsmUser bool, runAsUser string, shellCmd string) (stdin *os.File, stdout *os.File, err error) {
 	log.Info("Starting winpty")
+	if runAsUser != "" {
+		return nil, nil, fmt.Errorf("running sessions as user %s is not supported on Windows", runAsUser)
+	}
 	if _, err := os.Stat(winptyDllFilePath); os.IsNotExist(err) {
 		return nil, nil, fmt.Errorf("Missing %s file.", winptyDllFilePath)
 	}
 
+	var pty *winpty.WinPTY
 	var finalCmd string
 	if strings.TrimSpace(shellCmd) == "" {
 		finalCmd = winptyCmd
@@ -110,7 +120,7 @@ func StartPty(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, st
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err = startPtyAsUser(log, appconfig.DefaultRunAsUserName, newPassword, finalCmd)
+			pty, err = startPtyAsUser(log, appconfig.DefaultRunAsUserName, newPassword, finalCmd)
 		}()
 		wg.Wait()
 	} else {
@@ -121,24 +131,47 @@ func StartPty(log log.T, runAsSsmUser bool, shellCmd string) (stdin *os.File, st
 		return nil, nil, err
 	}
 
+	t.mu.Lock()
+	t.pty = pty
+	t.runAsSsmUser = runAsSsmUser
+	t.mu.Unlock()
+
 	return pty.StdIn, pty.StdOut, err
 }
 
-//Stop closes winpty process handle and stdin/stdout.
-func Stop(log log.T) (err error) {
+//stop closes winpty process handle and stdin/stdout.
+func (t *pseudoTerminal) stop(log log.T) (err error) {
+	t.mu.Lock()
+	pty, runAsSsmUser := t.pty, t.runAsSsmUser
+	t.pty = nil
+	t.mu.Unlock()
+
+	if pty == nil {
+		return nil
+	}
+
 	log.Info("Stopping winpty")
 	if err = pty.Close(); err != nil {
 		return fmt.Errorf("Stop winpty failed: %s", err)
 	}
 
-	log.Debugf("Disabling ssm-user")
-	u.DisableLocalUser(log)
+	if runAsSsmUser {
+		log.Debugf("Disabling ssm-user")
+		u.DisableLocalUser(log)
+	}
 	return nil
 }
 
-//SetSize sets size of console terminal window.
-func SetSize(log log.T, ws_col, ws_row uint32) (err error) {
-	if err = pty.SetSize(ws_col, ws_row); err != nil {
+//setSize sets size of console terminal window.
+func (t *pseudoTerminal) setSize(log log.T, ws_col, ws_row uint32) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pty == nil {
+		return fmt.Errorf("winpty is not started")
+	}
+
+	if err = t.pty.SetSize(ws_col, ws_row); err != nil {
 		return fmt.Errorf("Set winpty size failed: %s", err)
 	}
 
@@ -146,7 +179,7 @@ func SetSize(log log.T, ws_col, ws_row uint32) (err error) {
 }
 
 //startPtyAsUser starts a winpty process in runas user context.
-func startPtyAsUser(log log.T, user string, pass string, shellCmd string) (err error) {
+func startPtyAsUser(log log.T, user string, pass string, shellCmd string) (pty *winpty.WinPTY, err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
@@ -266,14 +299,16 @@ func (p *ShellPlugin) generateLogData(log log.T, config agentContracts.Configura
 
 // generateTranscriptFile generates a transcript file using PowerShell
 func generateTranscriptFile(log log.T, transcriptFile string, loggerFile string, enableVirtualTerminalProcessingForWindows bool) error {
-	shadowShellInput, _, err := StartPty(log, false, "")
+	// the commands are run in a separate shadow shell which doesn't share the winpty agent of the session
+	var shadow pseudoTerminal
+	shadowShellInput, _, err := shadow.start(log, false, "", "")
 	if err != nil {
 		return err
 	}
 
 	defer func() {
 		if err := recover(); err != nil {
-			if err = Stop(log); err != nil {
+			if err = shadow.stop(log); err != nil {
 				log.Errorf("Error occured while closing pty: %v", err)
 			}
 		}
@@ -307,6 +342,10 @@ func generateTranscriptFile(log log.T, transcriptFile string, loggerFile string,
 	// Sleep till the shell successfully exits before uploading
 	time.Sleep(5 * time.Second)
 
+	if err = shadow.stop(log); err != nil {
+		log.Errorf("Error occured while closing pty: %v", err)
+	}
+
 	return nil
 }
 
@@ -389,7 +428,7 @@ func (p *ShellPlugin) InputStreamMessageHandler(log log.T, streamDataMessage mgs
 			return err
 		}
 		log.Tracef("Resize data received: cols: %d, rows: %d", size.Cols, size.Rows)
-		if err := SetSize(log, size.Cols, size.Rows); err != nil {
+		if err := p.terminal.setSize(log, size.Cols, size.Rows); err != nil {
 			log.Errorf("Unable to set pty size: %s", err)
 			return err
 		}
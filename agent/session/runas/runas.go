@@ -0,0 +1,173 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runas resolves the local OS user a session or a script step runs as.
+package runas
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Policy is the agent side policy which restricts the local users sessions and script steps can run as.
+// Sample policy file:
+// {
+//     "AllowedUsers": ["ec2-user", "deploy"],
+//     "AllowedGroups": ["deploy"],
+//     "PrincipalUsers": {
+//         "arn:aws:iam::123456789012:role/Deployer": "deploy",
+//         "arn:aws:sts::123456789012:assumed-role/Admin/*": "ec2-user"
+//     },
+//     "DefaultUser": ""
+// }
+type Policy struct {
+	// AllowedUsers lists the only local users sessions and script steps can run as
+	AllowedUsers []string
+	// AllowedGroups lists the only local groups script steps can run as
+	AllowedGroups []string
+	// PrincipalUsers maps the principal owning a session to the local user its sessions run as,
+	// a principal ending with * matches all the principals starting with the same prefix
+	PrincipalUsers map[string]string
+	// DefaultUser is used when neither the principal mapping nor the session specify a user
+	DefaultUser string
+}
+
+// userNamePattern matches the portable user names accepted by useradd
+var userNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,31}$`)
+
+// policyFilePath returns the path of the run as policy file, the program folder can change during init
+var policyFilePath = func() string {
+	return filepath.Join(appconfig.DefaultProgramFolder, appconfig.SessionRunAsPolicyFileName)
+}
+
+// LoadPolicy reads the run as policy of the agent.
+// The policy must be owned by root and not writable by other users since it grants access to local users.
+func LoadPolicy(log log.T) (policy Policy, err error) {
+	path := policyFilePath()
+	fileInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return policy, fmt.Errorf("run as policy file %v is not present, sessions can't run as a named user", path)
+	} else if err != nil {
+		return policy, fmt.Errorf("unable to read run as policy file %v: %v", path, err)
+	}
+	if err = verifyPolicyFile(fileInfo); err != nil {
+		return policy, fmt.Errorf("run as policy file %v is ignored: %v", path, err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return policy, fmt.Errorf("unable to read run as policy file %v: %v", path, err)
+	}
+	if err = jsonutil.Unmarshal(string(content), &policy); err != nil {
+		return policy, fmt.Errorf("run as policy file %v is invalid: %v", path, err)
+	}
+	log.Debugf("Loaded run as policy from %v", path)
+	return policy, nil
+}
+
+// ResolveUser returns the local user a session runs as.
+// The user mapped to the session owner in the policy takes precedence over the user requested for the session,
+// the default user of the policy is used when neither is present. The user must be in the allow-list of the policy.
+func ResolveUser(log log.T, sessionOwner string, requestedUser string) (userName string, err error) {
+	policy, err := LoadPolicy(log)
+	if err != nil {
+		return "", err
+	}
+	return policy.resolveUser(log, sessionOwner, requestedUser)
+}
+
+// resolveUser applies the policy to the session owner and the requested user
+func (policy Policy) resolveUser(log log.T, sessionOwner string, requestedUser string) (userName string, err error) {
+	if mappedUser, found := policy.principalUser(sessionOwner); found {
+		log.Debugf("Session owner %v is mapped to user %v", sessionOwner, mappedUser)
+		userName = mappedUser
+	} else if userName = strings.TrimSpace(requestedUser); userName == "" {
+		userName = policy.DefaultUser
+	}
+
+	if userName == "" {
+		return "", errors.New("no run as user is specified for the session")
+	}
+	if !userNamePattern.MatchString(userName) {
+		return "", fmt.Errorf("run as user %q is not a valid user name", userName)
+	}
+	if !contains(policy.AllowedUsers, userName) {
+		return "", fmt.Errorf("run as user %v is not allowed by the run as policy", userName)
+	}
+	return userName, nil
+}
+
+// CheckAllowed verifies that the user and group a script step runs as are allowed by the run as policy,
+// an empty user or group is not checked.
+func CheckAllowed(log log.T, userName string, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	policy, err := LoadPolicy(log)
+	if err != nil {
+		return err
+	}
+	return policy.checkAllowed(userName, groupName)
+}
+
+// checkAllowed applies the allow-lists of the policy to the user and group
+func (policy Policy) checkAllowed(userName string, groupName string) error {
+	if userName != "" && !contains(policy.AllowedUsers, userName) {
+		return fmt.Errorf("run as user %v is not allowed by the run as policy", userName)
+	}
+	if groupName != "" && !contains(policy.AllowedGroups, groupName) {
+		return fmt.Errorf("run as group %v is not allowed by the run as policy", groupName)
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, allowedName := range names {
+		if allowedName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// principalUser returns the user mapped to the principal, an exact match takes precedence over the longest prefix match
+func (policy Policy) principalUser(principal string) (userName string, found bool) {
+	if principal == "" {
+		return "", false
+	}
+	if userName, found = policy.PrincipalUsers[principal]; found {
+		return
+	}
+
+	longestPrefix := -1
+	for pattern, user := range policy.PrincipalUsers {
+		if !strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, "*")
+		if strings.HasPrefix(principal, prefix) && len(prefix) > longestPrefix {
+			longestPrefix = len(prefix)
+			userName, found = user, true
+		}
+	}
+	return
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runas resolves the local OS user a session runs as.
+package runas
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+var testPolicy = Policy{
+	AllowedUsers: []string{"deploy", "ec2-user", "admin"},
+	PrincipalUsers: map[string]string{
+		"arn:aws:iam::123456789012:role/Deployer":     "deploy",
+		"arn:aws:sts::123456789012:assumed-role/*":    "ec2-user",
+		"arn:aws:sts::123456789012:assumed-role/Ad*":  "admin",
+		"arn:aws:sts::123456789012:assumed-role/Root": "root",
+	},
+}
+
+func TestPolicyResolveUser(t *testing.T) {
+	testCases := []struct {
+		sessionOwner  string
+		requestedUser string
+		defaultUser   string
+		expectedUser  string
+		expectedError string
+	}{
+		{"arn:aws:iam::123456789012:role/Deployer", "admin", "", "deploy", ""},
+		{"arn:aws:sts::123456789012:assumed-role/Admin/alice", "", "", "admin", ""},
+		{"arn:aws:sts::123456789012:assumed-role/Developer/bob", "deploy", "", "ec2-user", ""},
+		{"arn:aws:iam::123456789012:user/carol", "deploy", "", "deploy", ""},
+		{"arn:aws:iam::123456789012:user/carol", "", "ec2-user", "ec2-user", ""},
+		{"", " admin ", "", "admin", ""},
+		{"arn:aws:iam::123456789012:user/carol", "", "", "", "no run as user is specified"},
+		{"arn:aws:sts::123456789012:assumed-role/Root", "", "", "", "run as user root is not allowed"},
+		{"", "ssm-user", "", "", "run as user ssm-user is not allowed"},
+		{"", "deploy;reboot", "", "", "is not a valid user name"},
+	}
+
+	for _, testCase := range testCases {
+		policy := testPolicy
+		policy.DefaultUser = testCase.defaultUser
+		user, err := policy.resolveUser(logMock, testCase.sessionOwner, testCase.requestedUser)
+		if testCase.expectedError == "" {
+			assert.NoError(t, err, testCase.sessionOwner)
+			assert.Equal(t, testCase.expectedUser, user, testCase.sessionOwner)
+		} else {
+			assert.Error(t, err, testCase.sessionOwner)
+			assert.Contains(t, err.Error(), testCase.expectedError, testCase.sessionOwner)
+		}
+	}
+}
+
+func TestResolveUserFromPolicyFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "runas")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session-runas-policy.json")
+
+	origPolicyFilePath := policyFilePath
+	policyFilePath = func() string { return path }
+	defer func() { policyFilePath = origPolicyFilePath }()
+
+	// sessions can't run as a named user without a policy
+	_, err := ResolveUser(logMock, "", "deploy")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not present")
+
+	ioutil.WriteFile(path, []byte(`{"AllowedUsers": ["deploy"], "PrincipalUsers": {"arn:aws:iam::123456789012:role/Deployer": "deploy"}}`), 0600)
+	user, err := ResolveUser(logMock, "arn:aws:iam::123456789012:role/Deployer", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy", user)
+
+	ioutil.WriteFile(path, []byte(`{"AllowedUsers": "deploy"}`), 0600)
+	_, err = ResolveUser(logMock, "", "deploy")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is invalid")
+}
+
+func TestPolicyCheckAllowed(t *testing.T) {
+	policy := Policy{AllowedUsers: []string{"deploy"}, AllowedGroups: []string{"ops"}}
+
+	assert.NoError(t, policy.checkAllowed("deploy", ""))
+	assert.NoError(t, policy.checkAllowed("deploy", "ops"))
+	assert.NoError(t, policy.checkAllowed("", "ops"))
+	assert.Error(t, policy.checkAllowed("root", ""))
+	assert.Error(t, policy.checkAllowed("deploy", "wheel"))
+	assert.Error(t, Policy{}.checkAllowed("", "ops"))
+}
+
+func TestCheckAllowedFromPolicyFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "runas")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session-runas-policy.json")
+
+	origPolicyFilePath := policyFilePath
+	policyFilePath = func() string { return path }
+	defer func() { policyFilePath = origPolicyFilePath }()
+
+	// steps which run as the agent don't need a policy
+	assert.NoError(t, CheckAllowed(logMock, "", ""))
+	err := CheckAllowed(logMock, "deploy", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not present")
+
+	ioutil.WriteFile(path, []byte(`{"AllowedUsers": ["deploy"], "AllowedGroups": ["ops"]}`), 0600)
+	assert.NoError(t, CheckAllowed(logMock, "deploy", "ops"))
+	assert.Error(t, CheckAllowed(logMock, "root", ""))
+}
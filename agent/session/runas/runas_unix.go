@@ -0,0 +1,130 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package runas resolves the local OS user a session runs as.
+package runas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	passwdFieldCount = 7
+	passwdUidIndex   = 2
+	passwdGidIndex   = 3
+	passwdHomeIndex  = 5
+	passwdShellIndex = 6
+)
+
+// nonLoginShells are the shells used to disable interactive logins of a user
+var nonLoginShells = map[string]bool{
+	"nologin": true,
+	"false":   true,
+}
+
+// User holds the credentials and the login settings of a local user
+type User struct {
+	Name    string
+	Uid     uint32
+	Gid     uint32
+	Groups  []uint32
+	HomeDir string
+	Shell   string
+}
+
+// getent and id are used instead of os/user to resolve users from all the name services without cgo
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// LookupUser returns the credentials, home directory and login shell of the local user.
+// Users whose login shell disables interactive logins are rejected.
+func LookupUser(userName string) (user *User, err error) {
+	if !userNamePattern.MatchString(userName) {
+		return nil, fmt.Errorf("%q is not a valid user name", userName)
+	}
+
+	out, err := runCommand("getent", "passwd", userName)
+	if err != nil {
+		return nil, fmt.Errorf("user %v does not exist", userName)
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), ":")
+	if len(fields) != passwdFieldCount {
+		return nil, fmt.Errorf("unexpected passwd entry for user %v", userName)
+	}
+
+	user = &User{
+		Name:    userName,
+		HomeDir: fields[passwdHomeIndex],
+		Shell:   fields[passwdShellIndex],
+	}
+	if user.Uid, err = parseId(fields[passwdUidIndex]); err != nil {
+		return nil, fmt.Errorf("invalid uid for user %v: %v", userName, err)
+	}
+	if user.Gid, err = parseId(fields[passwdGidIndex]); err != nil {
+		return nil, fmt.Errorf("invalid gid for user %v: %v", userName, err)
+	}
+
+	if user.Shell == "" {
+		user.Shell = "/bin/sh"
+	}
+	if nonLoginShells[filepath.Base(user.Shell)] {
+		return nil, fmt.Errorf("user %v does not have a login shell", userName)
+	}
+	if _, err = os.Stat(user.Shell); err != nil {
+		return nil, fmt.Errorf("login shell %v of user %v is not available: %v", user.Shell, userName, err)
+	}
+
+	// id lists the primary group followed by the supplementary groups
+	if out, err = runCommand("id", "-G", userName); err != nil {
+		return nil, fmt.Errorf("failed to retrieve groups of user %v: %v", userName, err)
+	}
+	for _, group := range strings.Fields(string(out)) {
+		gid, err := parseId(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group of user %v: %v", userName, err)
+		}
+		user.Groups = append(user.Groups, gid)
+	}
+	return user, nil
+}
+
+// Credential returns the credential used to start a process as the user
+func (user *User) Credential() *syscall.Credential {
+	return &syscall.Credential{Uid: user.Uid, Gid: user.Gid, Groups: user.Groups, NoSetGroups: false}
+}
+
+// verifyPolicyFile ensures the policy file can only be modified by root or the user running the agent
+func verifyPolicyFile(fileInfo os.FileInfo) error {
+	if fileInfo.Mode().Perm()&0022 != 0 {
+		return errors.New("the file must not be writable by group or other users")
+	}
+	if stat, ok := fileInfo.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+		return errors.New("the file must be owned by root")
+	}
+	return nil
+}
+
+func parseId(id string) (uint32, error) {
+	value, err := strconv.ParseUint(id, 10, 32)
+	return uint32(value), err
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package runas resolves the local OS user a session runs as.
+package runas
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stubCommands(passwd string, groups string) func() {
+	origRunCommand := runCommand
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		switch name + " " + strings.Join(args, " ") {
+		case "getent passwd deploy":
+			if passwd == "" {
+				return nil, errors.New("exit status 2")
+			}
+			return []byte(passwd + "\n"), nil
+		case "id -G deploy":
+			return []byte(groups + "\n"), nil
+		}
+		return nil, errors.New("unexpected command")
+	}
+	return func() { runCommand = origRunCommand }
+}
+
+func TestLookupUser(t *testing.T) {
+	defer stubCommands("deploy:x:1001:1002:Deploy user:/home/deploy:/bin/sh", "1002 10 27")()
+
+	user, err := LookupUser("deploy")
+	assert.NoError(t, err)
+	assert.Equal(t, &User{
+		Name:    "deploy",
+		Uid:     1001,
+		Gid:     1002,
+		Groups:  []uint32{1002, 10, 27},
+		HomeDir: "/home/deploy",
+		Shell:   "/bin/sh",
+	}, user)
+
+	credential := user.Credential()
+	assert.Equal(t, uint32(1001), credential.Uid)
+	assert.Equal(t, uint32(1002), credential.Gid)
+	assert.Equal(t, []uint32{1002, 10, 27}, credential.Groups)
+	assert.False(t, credential.NoSetGroups)
+}
+
+func TestLookupUserWithoutLoginShell(t *testing.T) {
+	defer stubCommands("deploy:x:1001:1002::/home/deploy:/usr/sbin/nologin", "1002")()
+
+	_, err := LookupUser("deploy")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not have a login shell")
+}
+
+func TestLookupUserErrors(t *testing.T) {
+	defer stubCommands("", "")()
+
+	_, err := LookupUser("deploy")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+
+	_, err = LookupUser("$(reboot)")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a valid user name")
+}
+
+func TestLoadPolicyRejectsWritableFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "runas")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session-runas-policy.json")
+	ioutil.WriteFile(path, []byte(`{"AllowedUsers": ["deploy"]}`), 0600)
+	os.Chmod(path, 0666)
+
+	origPolicyFilePath := policyFilePath
+	policyFilePath = func() string { return path }
+	defer func() { policyFilePath = origPolicyFilePath }()
+
+	_, err := LoadPolicy(logMock)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must not be writable")
+}
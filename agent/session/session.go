@@ -344,6 +344,7 @@ func formatAgentTaskCompletePayload(log log.T,
 		S3UrlSuffix:      sessionPluginResultOutput.S3UrlSuffix,
 		CwlGroup:         sessionPluginResultOutput.CwlGroup,
 		CwlStream:        sessionPluginResultOutput.CwlStream,
+		ExitCode:         pluginResult.Code,
 	}
 	return payload
 }
@@ -261,6 +261,37 @@ func (suite *SessionTestSuite) TestBuildAgentTaskCompleteWhenPluginResultOutputH
 	assert.Equal(suite.T(), cwlStream, payload.CwlStream)
 }
 
+// Testing buildAgentTaskComplete reports the exit code of the plugin.
+func (suite *SessionTestSuite) TestBuildAgentTaskCompleteWithExitCode() {
+	log := log.NewMockLog()
+	pluginResults := make(map[string]*contracts.PluginResult)
+	pluginResult := contracts.PluginResult{
+		PluginName: "NonInteractiveCommands",
+		Status:     contracts.ResultStatusFailed,
+		Code:       3,
+		Output:     mgsContracts.SessionPluginResultOutput{},
+	}
+	pluginResults["NonInteractiveCommands"] = &pluginResult
+
+	result := contracts.DocumentResult{
+		Status:        contracts.ResultStatusFailed,
+		PluginResults: pluginResults,
+		LastPlugin:    "NonInteractiveCommands",
+		MessageID:     messageId,
+		NPlugins:      1,
+	}
+	msg, err := buildAgentTaskComplete(log, result, instanceId)
+	assert.Nil(suite.T(), err)
+
+	agentMessage := &mgsContracts.AgentMessage{}
+	agentMessage.Deserialize(log, msg)
+
+	payload := &mgsContracts.AgentTaskCompletePayload{}
+	json.Unmarshal(agentMessage.Payload, payload)
+	assert.Equal(suite.T(), string(contracts.ResultStatusFailed), payload.FinalTaskStatus)
+	assert.Equal(suite.T(), 3, payload.ExitCode)
+}
+
 func (suite *SessionTestSuite) TestBuildAgentTaskCompleteWhenPluginIdIsEmpty() {
 	log := log.NewMockLog()
 	pluginResults := make(map[string]*contracts.PluginResult)
@@ -17,9 +17,12 @@
 package utility
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -124,3 +127,75 @@ func (u *SessionUtil) DisableLocalUser(log log.T) (err error) {
 	// Do nothing here as no password is required for unix platform local user, so that no need to disable user.
 	return nil
 }
+
+// GetUserCredentials returns the uid, gid and groups associated to the given user.
+func GetUserCredentials(log log.T, userName string) (uint32, uint32, []uint32, error) {
+	uidCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("id -u %s", userName))
+	cmd := exec.Command(ShellPluginCommandName, uidCmdArgs...)
+	out, err := cmd.Output()
+	if err != nil {
+		log.Errorf("Failed to retrieve uid for %s: %v", userName, err)
+		return 0, 0, nil, err
+	}
+
+	uid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		log.Errorf("%s not found: %v", userName, err)
+		return 0, 0, nil, err
+	}
+
+	gidCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("id -g %s", userName))
+	cmd = exec.Command(ShellPluginCommandName, gidCmdArgs...)
+	out, err = cmd.Output()
+	if err != nil {
+		log.Errorf("Failed to retrieve gid for %s: %v", userName, err)
+		return 0, 0, nil, err
+	}
+
+	gid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		log.Errorf("%s not found: %v", userName, err)
+		return 0, 0, nil, err
+	}
+
+	// Get the list of associated groups
+	groupNamesCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("groups %s", userName))
+	cmd = exec.Command(ShellPluginCommandName, groupNamesCmdArgs...)
+	out, err = cmd.Output()
+	if err != nil {
+		log.Errorf("Failed to retrieve groups for %s: %v", userName, err)
+		return 0, 0, nil, err
+	}
+
+	groupNames := strings.Split(string(out), " ")
+	var groupIds []uint32
+
+	// Skip the first two elements. Group names start from the third element.
+	// Format ex: ssm-user : ssm-user test
+	for i := 2; i < len(groupNames); i++ {
+		groupIdFromNameCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("getent group %s", groupNames[i]))
+		cmd = exec.Command(ShellPluginCommandName, groupIdFromNameCmdArgs...)
+		out, err = cmd.Output()
+		if err != nil {
+			log.Errorf("Failed to retrieve group id for %s: %v", groupNames[i], err)
+			return 0, 0, nil, err
+		}
+
+		// Get the third element from the array which contains the id and convert it to int
+		// Format ex: test:x:1004:ssm-user
+		groupIdFromName, err := strconv.Atoi(strings.TrimSpace(strings.Split(string(out), ":")[2]))
+		if err != nil {
+			log.Errorf("%s group id not found: %v", groupNames[i], err)
+			return 0, 0, nil, err
+		}
+
+		groupIds = append(groupIds, uint32(groupIdFromName))
+	}
+
+	// Make sure they are non-zero valid positive ids
+	if uid > 0 && gid > 0 {
+		return uint32(uid), uint32(gid), groupIds, nil
+	}
+
+	return 0, 0, nil, errors.New("invalid uid and gid")
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package status implements the local read-only status API of the agent.
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/hibernation"
+)
+
+const (
+	name = "StatusAPI"
+	// StatusPath is the resource which returns the status of the agent
+	StatusPath = "/status"
+
+	readTimeout  = 10 * time.Second
+	writeTimeout = 30 * time.Second
+)
+
+// Server serves the status of the agent as JSON over HTTP on a local socket which only root can connect to
+type Server struct {
+	context        context.T
+	socketPath     string
+	healthModule   health.IHealthCheck
+	hibernateState hibernation.IHibernate
+
+	lock        sync.RWMutex
+	coreManager coremanager.ICoreManager
+	listener    net.Listener
+	httpServer  *http.Server
+}
+
+// NewServer creates a status server for the socket configured in appconfig.
+// The server is created before the core manager so that it can report the agent while it hibernates.
+func NewServer(context context.T, healthModule health.IHealthCheck, hibernateState hibernation.IHibernate) *Server {
+	return &Server{
+		context:        context.With("[" + name + "]"),
+		socketPath:     context.AppConfig().Agent.StatusSocketPath,
+		healthModule:   healthModule,
+		hibernateState: hibernateState,
+	}
+}
+
+// SetCoreManager sets the core manager whose modules are reported once the agent starts them
+func (s *Server) SetCoreManager(coreManager coremanager.ICoreManager) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.coreManager = coreManager
+}
+
+// Start listens on the status socket and serves the status API in the background
+func (s *Server) Start() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+
+	log := s.context.Log()
+	if s.listener, err = listen(s.socketPath); err != nil {
+		return fmt.Errorf("failed to listen on status socket %v: %v", s.socketPath, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(StatusPath, s.handleStatus)
+	s.httpServer = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+
+	log.Infof("Serving agent status on %v", s.socketPath)
+	go func(httpServer *http.Server, listener net.Listener) {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Status API stopped unexpectedly: %v", err)
+		}
+	}(s.httpServer, s.listener)
+	return nil
+}
+
+// Stop closes the status socket
+func (s *Server) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener == nil {
+		return
+	}
+
+	s.context.Log().Info("Stopping status API")
+	s.httpServer.Close()
+	removeSocket(s.socketPath)
+	s.listener = nil
+	s.httpServer = nil
+}
+
+// handleStatus returns the status of the agent, the API is read-only
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.lock.RLock()
+	coreManager := s.coreManager
+	s.lock.RUnlock()
+
+	hibernating := false
+	if s.hibernateState != nil {
+		hibernating = s.hibernateState.IsHibernating()
+	}
+
+	agentStatus := getAgentStatus(s.context.Log(), s.healthModule, hibernating, coreManager)
+	content, err := json.MarshalIndent(agentStatus, "", "  ")
+	if err != nil {
+		s.context.Log().Errorf("Failed to serialize agent status: %v", err)
+		http.Error(w, "failed to serialize agent status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+// removeSocket removes the socket file, a missing socket isn't an error
+func removeSocket(socketPath string) error {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
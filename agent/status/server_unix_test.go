@@ -0,0 +1,138 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+// Package status implements the local read-only status API of the agent.
+package status
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
+	coreManagerMock "github.com/aws/amazon-ssm-agent/agent/framework/coremanager/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	healthMock "github.com/aws/amazon-ssm-agent/agent/health/mocks"
+	hibernationMock "github.com/aws/amazon-ssm-agent/agent/hibernation/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestServer(socketPath string) *Server {
+	config := appconfig.SsmagentConfig{}
+	config.Agent.StatusSocketPath = socketPath
+	ctx := &context.Mock{}
+	ctx.On("Log").Return(logMock)
+	ctx.On("AppConfig").Return(config)
+	ctx.On("With", mock.AnythingOfType("string")).Return(ctx)
+
+	healthModule := &healthMock.IHealthCheck{}
+	healthModule.On("GetLastHealthStatus").Return(health.HealthStatus{})
+	hibernateState := &hibernationMock.IHibernate{}
+	hibernateState.On("IsHibernating").Return(true)
+	return NewServer(ctx, healthModule, hibernateState)
+}
+
+func newSocketClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", socketPath)
+			},
+		},
+	}
+}
+
+func TestServerServesStatusOnSocket(t *testing.T) {
+	dir, restore := stubStatusSources(t, nil)
+	defer restore()
+	socketPath := filepath.Join(dir, "status.sock")
+
+	// a socket left behind by a previous agent is replaced
+	assert.NoError(t, ioutil.WriteFile(socketPath, []byte{}, 0666))
+
+	server := newTestServer(socketPath)
+	assert.NoError(t, server.Start())
+	defer server.Stop()
+
+	fileInfo, err := os.Stat(socketPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+	client := newSocketClient(socketPath)
+	response, err := client.Get("http://localhost" + StatusPath)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var agentStatus AgentStatus
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&agentStatus))
+	assert.Equal(t, AgentStateUnknown, agentStatus.Health.AgentState)
+	assert.True(t, agentStatus.Health.Hibernating)
+	assert.Equal(t, []ModuleStatus{}, agentStatus.CoreModules)
+
+	// core modules are reported once the agent starts them
+	coreManager := &coreManagerMock.ICoreManager{}
+	coreManager.On("GetModuleStatuses").Return([]coremanager.ModuleStatus{{Name: "HealthCheck", Status: coremanager.ModuleStatusRunning}})
+	server.SetCoreManager(coreManager)
+	response, err = client.Get("http://localhost" + StatusPath)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&agentStatus))
+	assert.Equal(t, []ModuleStatus{{Name: "HealthCheck", Status: coremanager.ModuleStatusRunning}}, agentStatus.CoreModules)
+}
+
+func TestServerIsReadOnly(t *testing.T) {
+	dir, restore := stubStatusSources(t, nil)
+	defer restore()
+	socketPath := filepath.Join(dir, "status.sock")
+
+	server := newTestServer(socketPath)
+	assert.NoError(t, server.Start())
+	defer server.Stop()
+
+	client := newSocketClient(socketPath)
+	response, err := client.Post("http://localhost"+StatusPath, "application/json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+
+	response, err = client.Get("http://localhost/commands")
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestServerStopRemovesSocket(t *testing.T) {
+	dir, restore := stubStatusSources(t, nil)
+	defer restore()
+	socketPath := filepath.Join(dir, "status.sock")
+
+	server := newTestServer(socketPath)
+	assert.NoError(t, server.Start())
+	server.Stop()
+	server.Stop()
+
+	_, err := os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
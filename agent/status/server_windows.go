@@ -0,0 +1,30 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build windows
+
+// Package status implements the local read-only status API of the agent.
+package status
+
+import (
+	"net"
+)
+
+// listen removes the socket left behind by a previous agent and listens on a new socket,
+// access to the socket is restricted by the hardened ACL of the SSM data folder
+func listen(socketPath string) (net.Listener, error) {
+	if err := removeSocket(socketPath); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", socketPath)
+}
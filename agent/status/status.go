@@ -0,0 +1,274 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package status implements the local read-only status API of the agent.
+package status
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+// Agent states reported by the status API
+const (
+	AgentStateUnknown = "Unknown"
+	AgentStateActive  = "Active"
+	AgentStatePassive = "Passive"
+)
+
+// Document states reported by the status API
+const (
+	DocumentStatePending    = "Pending"
+	DocumentStateInProgress = "InProgress"
+)
+
+// AgentStatus is the status of the agent served by the status API
+type AgentStatus struct {
+	Version                  string                `json:"version"`
+	InstanceID               string                `json:"instanceId,omitempty"`
+	Health                   HealthStatus          `json:"health"`
+	CoreModules              []ModuleStatus        `json:"coreModules"`
+	Commands                 []DocumentStatus      `json:"commands"`
+	Associations             []DocumentStatus      `json:"associations"`
+	Sessions                 []string              `json:"sessions"`
+	NextScheduledAssociation *ScheduledAssociation `json:"nextScheduledAssociation,omitempty"`
+}
+
+// HealthStatus is the outcome of the last health check of the agent
+type HealthStatus struct {
+	AgentState     string     `json:"agentState"`
+	Hibernating    bool       `json:"hibernating"`
+	LastCheckTime  *time.Time `json:"lastCheckTime,omitempty"`
+	LastCheckError string     `json:"lastCheckError,omitempty"`
+}
+
+// ModuleStatus is the execution status of a core module
+type ModuleStatus struct {
+	Name   string     `json:"name"`
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+// DocumentStatus is the progress of a command or an association the agent is running
+type DocumentStatus struct {
+	DocumentID      string         `json:"documentId"`
+	CommandID       string         `json:"commandId,omitempty"`
+	AssociationID   string         `json:"associationId,omitempty"`
+	DocumentName    string         `json:"documentName"`
+	DocumentVersion string         `json:"documentVersion,omitempty"`
+	DocumentType    string         `json:"documentType"`
+	State           string         `json:"state"`
+	CreatedDate     string         `json:"createdDate,omitempty"`
+	Plugins         []PluginStatus `json:"plugins"`
+}
+
+// PluginStatus is the progress of a plugin of a document
+type PluginStatus struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	StartDateTime *time.Time `json:"startDateTime,omitempty"`
+	EndDateTime   *time.Time `json:"endDateTime,omitempty"`
+}
+
+// ScheduledAssociation is the association the agent runs next
+type ScheduledAssociation struct {
+	AssociationID     string    `json:"associationId"`
+	DocumentName      string    `json:"documentName"`
+	NextScheduledDate time.Time `json:"nextScheduledDate"`
+}
+
+var getInstanceID = platform.InstanceID
+var getDocumentStateDir = docmanager.DocumentStateDir
+var loadNextScheduledAssociation = schedulemanager.LoadNextScheduledAssociationSummary
+
+// getHealthStatus returns the last known health state without contacting the service
+func getHealthStatus(healthModule health.IHealthCheck, hibernating bool) HealthStatus {
+	status := HealthStatus{AgentState: AgentStateUnknown, Hibernating: hibernating}
+	if healthModule == nil {
+		return status
+	}
+
+	lastStatus := healthModule.GetLastHealthStatus()
+	if lastStatus.CheckTime.IsZero() {
+		return status
+	}
+	status.AgentState = AgentStatePassive
+	if lastStatus.AgentState == health.Active {
+		status.AgentState = AgentStateActive
+	}
+	status.LastCheckTime = timeOrNil(lastStatus.CheckTime)
+	if lastStatus.Error != nil {
+		status.LastCheckError = lastStatus.Error.Error()
+	}
+	return status
+}
+
+// getModuleStatuses returns the status of the core modules, the core modules aren't started while the agent hibernates
+func getModuleStatuses(coreManager coremanager.ICoreManager) []ModuleStatus {
+	statuses := []ModuleStatus{}
+	if coreManager == nil {
+		return statuses
+	}
+	for _, moduleStatus := range coreManager.GetModuleStatuses() {
+		statuses = append(statuses, ModuleStatus{
+			Name:   moduleStatus.Name,
+			Status: moduleStatus.Status,
+			Error:  moduleStatus.Error,
+			Since:  timeOrNil(moduleStatus.Since),
+		})
+	}
+	return statuses
+}
+
+// addDocumentStatuses adds the documents the agent is running or will run to the status.
+// Documents are read from their interim state, which the processor updates as the plugins complete.
+func addDocumentStatuses(log log.T, instanceID string, agentStatus *AgentStatus) {
+	locations := []struct {
+		folder string
+		state  string
+	}{
+		{appconfig.DefaultLocationOfCurrent, DocumentStateInProgress},
+		{appconfig.DefaultLocationOfPending, DocumentStatePending},
+	}
+
+	for _, location := range locations {
+		for _, docState := range readDocumentStates(log, getDocumentStateDir(instanceID, location.folder)) {
+			switch docState.DocumentType {
+			case contracts.SendCommand, contracts.SendCommandOffline:
+				agentStatus.Commands = append(agentStatus.Commands, newDocumentStatus(docState, location.state))
+			case contracts.Association:
+				agentStatus.Associations = append(agentStatus.Associations, newDocumentStatus(docState, location.state))
+			case contracts.StartSession:
+				if location.state == DocumentStateInProgress {
+					agentStatus.Sessions = append(agentStatus.Sessions, docState.DocumentInformation.DocumentID)
+				}
+			}
+		}
+	}
+}
+
+// readDocumentStates reads the interim states of the documents in the given directory,
+// states that can't be read are skipped since they may be moved or rewritten while they are read
+func readDocumentStates(log log.T, dir string) (docStates []contracts.DocumentState) {
+	if !fileutil.Exists(dir) {
+		return
+	}
+	files, err := fileutil.ReadDir(dir)
+	if err != nil {
+		log.Debugf("Failed to read document states from %v: %v", dir, err)
+		return
+	}
+	for _, file := range files {
+		var docState contracts.DocumentState
+		if err = jsonutil.UnmarshalFile(filepath.Join(dir, file.Name()), &docState); err != nil {
+			log.Debugf("Skipping document state %v: %v", file.Name(), err)
+			continue
+		}
+		if docState.DocumentInformation.DocumentID != "" {
+			docStates = append(docStates, docState)
+		}
+	}
+	sort.Slice(docStates, func(i, j int) bool {
+		return docStates[i].DocumentInformation.CreatedDate < docStates[j].DocumentInformation.CreatedDate
+	})
+	return
+}
+
+func newDocumentStatus(docState contracts.DocumentState, state string) DocumentStatus {
+	docInfo := docState.DocumentInformation
+	status := DocumentStatus{
+		DocumentID:      docInfo.DocumentID,
+		CommandID:       docInfo.CommandID,
+		AssociationID:   docInfo.AssociationID,
+		DocumentName:    docInfo.DocumentName,
+		DocumentVersion: docInfo.DocumentVersion,
+		DocumentType:    string(docState.DocumentType),
+		State:           state,
+		CreatedDate:     docInfo.CreatedDate,
+		Plugins:         []PluginStatus{},
+	}
+	for _, pluginState := range docState.InstancePluginsInformation {
+		pluginStatus := PluginStatus{
+			ID:            pluginState.Id,
+			Name:          pluginState.Name,
+			Status:        string(pluginState.Result.Status),
+			StartDateTime: timeOrNil(pluginState.Result.StartDateTime),
+			EndDateTime:   timeOrNil(pluginState.Result.EndDateTime),
+		}
+		if pluginStatus.Status == "" {
+			pluginStatus.Status = string(contracts.ResultStatusNotStarted)
+		}
+		status.Plugins = append(status.Plugins, pluginStatus)
+	}
+	return status
+}
+
+// getNextScheduledAssociation returns the association the agent runs next, if any
+func getNextScheduledAssociation() *ScheduledAssociation {
+	assoc := loadNextScheduledAssociation()
+	if assoc == nil || assoc.Association == nil || assoc.NextScheduledDate == nil {
+		return nil
+	}
+	scheduled := &ScheduledAssociation{NextScheduledDate: *assoc.NextScheduledDate}
+	if assoc.Association.AssociationId != nil {
+		scheduled.AssociationID = *assoc.Association.AssociationId
+	}
+	if assoc.Association.Name != nil {
+		scheduled.DocumentName = *assoc.Association.Name
+	}
+	return scheduled
+}
+
+// getAgentStatus collects the status of the agent, it never contacts the service
+func getAgentStatus(log log.T, healthModule health.IHealthCheck, hibernating bool, coreManager coremanager.ICoreManager) AgentStatus {
+	agentStatus := AgentStatus{
+		Version:      version.Version,
+		Health:       getHealthStatus(healthModule, hibernating),
+		CoreModules:  getModuleStatuses(coreManager),
+		Commands:     []DocumentStatus{},
+		Associations: []DocumentStatus{},
+		Sessions:     []string{},
+	}
+
+	instanceID, err := getInstanceID()
+	if err != nil {
+		log.Debugf("Instance ID is not available: %v", err)
+		return agentStatus
+	}
+	agentStatus.InstanceID = instanceID
+	addDocumentStatuses(log, instanceID, &agentStatus)
+	agentStatus.NextScheduledAssociation = getNextScheduledAssociation()
+	return agentStatus
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package status implements the local read-only status API of the agent.
+package status
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
+	coreManagerMock "github.com/aws/amazon-ssm-agent/agent/framework/coremanager/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/health"
+	healthMock "github.com/aws/amazon-ssm-agent/agent/health/mocks"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInstanceID = "i-1234567890abcdef0"
+
+var logMock = log.NewMockLog()
+
+// stubStatusSources serves document states from a temporary directory and the given next scheduled association
+func stubStatusSources(t *testing.T, nextAssociation *model.InstanceAssociation) (dir string, restore func()) {
+	dir, err := ioutil.TempDir("", "status")
+	assert.NoError(t, err)
+
+	origGetInstanceID, origGetDocumentStateDir, origLoadNextScheduledAssociation := getInstanceID, getDocumentStateDir, loadNextScheduledAssociation
+	getInstanceID = func() (string, error) { return testInstanceID, nil }
+	getDocumentStateDir = func(instanceID, locationFolder string) string {
+		assert.Equal(t, testInstanceID, instanceID)
+		return filepath.Join(dir, locationFolder)
+	}
+	loadNextScheduledAssociation = func() *model.InstanceAssociation { return nextAssociation }
+
+	return dir, func() {
+		getInstanceID, getDocumentStateDir, loadNextScheduledAssociation = origGetInstanceID, origGetDocumentStateDir, origLoadNextScheduledAssociation
+		os.RemoveAll(dir)
+	}
+}
+
+func writeDocumentState(t *testing.T, dir string, locationFolder string, docState contracts.DocumentState) {
+	content, err := jsonutil.Marshal(docState)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, locationFolder), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, locationFolder, docState.DocumentInformation.DocumentID), []byte(content), 0600))
+}
+
+func TestGetAgentStatus(t *testing.T) {
+	nextScheduledDate := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	dir, restore := stubStatusSources(t, &model.InstanceAssociation{
+		NextScheduledDate: &nextScheduledDate,
+		Association: &ssm.InstanceAssociationSummary{
+			AssociationId: aws.String("assoc-1"),
+			Name:          aws.String("AWS-GatherSoftwareInventory"),
+		},
+	})
+	defer restore()
+
+	pluginStart := time.Date(2019, 6, 1, 11, 0, 0, 0, time.UTC)
+	writeDocumentState(t, dir, appconfig.DefaultLocationOfCurrent, contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:   "command-1",
+			CommandID:    "command-1",
+			DocumentName: "AWS-RunShellScript",
+			CreatedDate:  "2019-06-01T11:00:00.000Z",
+		},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "step1", Name: "aws:runShellScript", Result: contracts.PluginResult{Status: contracts.ResultStatusSuccess, StartDateTime: pluginStart, EndDateTime: pluginStart.Add(time.Minute)}},
+			{Id: "step2", Name: "aws:runShellScript"},
+		},
+	})
+	writeDocumentState(t, dir, appconfig.DefaultLocationOfPending, contracts.DocumentState{
+		DocumentType:        contracts.SendCommandOffline,
+		DocumentInformation: contracts.DocumentInfo{DocumentID: "command-2", CommandID: "command-2", DocumentName: "local.json"},
+	})
+	writeDocumentState(t, dir, appconfig.DefaultLocationOfCurrent, contracts.DocumentState{
+		DocumentType:        contracts.Association,
+		DocumentInformation: contracts.DocumentInfo{DocumentID: "assoc-2.run-1", AssociationID: "assoc-2", DocumentName: "AWS-UpdateSSMAgent"},
+	})
+	writeDocumentState(t, dir, appconfig.DefaultLocationOfCurrent, contracts.DocumentState{
+		DocumentType:        contracts.StartSession,
+		DocumentInformation: contracts.DocumentInfo{DocumentID: "session-1"},
+	})
+	// a document state which is being written is skipped
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, appconfig.DefaultLocationOfCurrent, "command-3"), []byte(`{"DocumentInformation": {`), 0600))
+
+	checkTime := time.Now()
+	healthModule := &healthMock.IHealthCheck{}
+	healthModule.On("GetLastHealthStatus").Return(health.HealthStatus{AgentState: health.Active, CheckTime: checkTime})
+	coreManager := &coreManagerMock.ICoreManager{}
+	coreManager.On("GetModuleStatuses").Return([]coremanager.ModuleStatus{
+		{Name: "HealthCheck", Status: coremanager.ModuleStatusRunning, Since: checkTime},
+		{Name: "MessageGatewayService", Status: coremanager.ModuleStatusFailed, Error: "failed to connect", Since: checkTime},
+	})
+
+	agentStatus := getAgentStatus(logMock, healthModule, false, coreManager)
+
+	assert.Equal(t, version.Version, agentStatus.Version)
+	assert.Equal(t, testInstanceID, agentStatus.InstanceID)
+	assert.Equal(t, HealthStatus{AgentState: AgentStateActive, LastCheckTime: &checkTime}, agentStatus.Health)
+	assert.Equal(t, []ModuleStatus{
+		{Name: "HealthCheck", Status: coremanager.ModuleStatusRunning, Since: &checkTime},
+		{Name: "MessageGatewayService", Status: coremanager.ModuleStatusFailed, Error: "failed to connect", Since: &checkTime},
+	}, agentStatus.CoreModules)
+
+	pluginEnd := pluginStart.Add(time.Minute)
+	assert.Equal(t, []DocumentStatus{
+		{
+			DocumentID:   "command-1",
+			CommandID:    "command-1",
+			DocumentName: "AWS-RunShellScript",
+			DocumentType: string(contracts.SendCommand),
+			State:        DocumentStateInProgress,
+			CreatedDate:  "2019-06-01T11:00:00.000Z",
+			Plugins: []PluginStatus{
+				{ID: "step1", Name: "aws:runShellScript", Status: string(contracts.ResultStatusSuccess), StartDateTime: &pluginStart, EndDateTime: &pluginEnd},
+				{ID: "step2", Name: "aws:runShellScript", Status: string(contracts.ResultStatusNotStarted)},
+			},
+		},
+		{
+			DocumentID:   "command-2",
+			CommandID:    "command-2",
+			DocumentName: "local.json",
+			DocumentType: string(contracts.SendCommandOffline),
+			State:        DocumentStatePending,
+			Plugins:      []PluginStatus{},
+		},
+	}, agentStatus.Commands)
+	assert.Equal(t, 1, len(agentStatus.Associations))
+	assert.Equal(t, "assoc-2", agentStatus.Associations[0].AssociationID)
+	assert.Equal(t, DocumentStateInProgress, agentStatus.Associations[0].State)
+	assert.Equal(t, []string{"session-1"}, agentStatus.Sessions)
+	assert.Equal(t, &ScheduledAssociation{
+		AssociationID:     "assoc-1",
+		DocumentName:      "AWS-GatherSoftwareInventory",
+		NextScheduledDate: nextScheduledDate,
+	}, agentStatus.NextScheduledAssociation)
+}
+
+func TestGetAgentStatusWhileHibernating(t *testing.T) {
+	_, restore := stubStatusSources(t, nil)
+	defer restore()
+
+	checkTime := time.Now()
+	healthModule := &healthMock.IHealthCheck{}
+	healthModule.On("GetLastHealthStatus").Return(health.HealthStatus{AgentState: health.Passive, CheckTime: checkTime, Error: errors.New("unable to reach SSM")})
+
+	// the core manager isn't created until the agent leaves hibernate mode
+	agentStatus := getAgentStatus(logMock, healthModule, true, nil)
+
+	assert.Equal(t, HealthStatus{
+		AgentState:     AgentStatePassive,
+		Hibernating:    true,
+		LastCheckTime:  &checkTime,
+		LastCheckError: "unable to reach SSM",
+	}, agentStatus.Health)
+	assert.Equal(t, []ModuleStatus{}, agentStatus.CoreModules)
+	assert.Equal(t, []DocumentStatus{}, agentStatus.Commands)
+	assert.Equal(t, []string{}, agentStatus.Sessions)
+	assert.Nil(t, agentStatus.NextScheduledAssociation)
+}
+
+func TestGetHealthStatusBeforeFirstCheck(t *testing.T) {
+	healthModule := &healthMock.IHealthCheck{}
+	healthModule.On("GetLastHealthStatus").Return(health.HealthStatus{})
+
+	assert.Equal(t, HealthStatus{AgentState: AgentStateUnknown}, getHealthStatus(healthModule, false))
+}
@@ -19,9 +19,18 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/metrics"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 )
 
+var (
+	queuedJobs = metrics.NewGauge("ssm_agent_task_pool_queued_jobs",
+		"Number of jobs submitted to the task pools which are waiting for a worker.")
+	jobDuration = metrics.NewHistogram("ssm_agent_task_job_duration_seconds",
+		"Duration of the jobs run by the task pools.",
+		metrics.DefaultDurationBuckets)
+)
+
 // Pool is a pool of jobs.
 type Pool interface {
 	// Submit schedules a job to be executed in the associated worker pool.
@@ -86,7 +95,9 @@ func NewPool(log log.T, maxParallel int, cancelWaitDuration time.Duration, clock
 	// defines the job processing function.
 	processor := func(j JobToken) {
 		defer p.jobStore.DeleteJob(j.id)
+		jobStart := time.Now()
 		process(j.log, j.job, j.cancelFlag, cancelWaitDuration, p.clock)
+		jobDuration.Observe(time.Since(jobStart).Seconds())
 	}
 
 	// start the workers
@@ -161,6 +172,7 @@ func (p *pool) workerDone() {
 // worker processes jobs from a channel.
 func worker(workerName string, queue chan JobToken, processor func(JobToken)) {
 	for token := range queue {
+		queuedJobs.Dec()
 		if !token.cancelFlag.Canceled() {
 			processor(token)
 		}
@@ -179,6 +191,8 @@ func (p *pool) Submit(log log.T, jobID string, job Job) (err error) {
 	if err != nil {
 		return
 	}
+	// the queue is unbuffered, a job waits in Submit until a worker is available
+	queuedJobs.Inc()
 	p.jobQueue <- token
 	return
 }